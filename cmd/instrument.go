@@ -3,8 +3,10 @@ package cmd
 import (
 	"errors"
 	"fmt"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/charmbracelet/bubbles/spinner"
@@ -14,15 +16,25 @@ import (
 	"github.com/newrelic/go-easy-instrumentation/integrations/nragent"
 	nrecho_v3 "github.com/newrelic/go-easy-instrumentation/integrations/nrecho-v3"
 	nrecho_v4 "github.com/newrelic/go-easy-instrumentation/integrations/nrecho-v4"
+	"github.com/newrelic/go-easy-instrumentation/integrations/nrfasthttp"
+	"github.com/newrelic/go-easy-instrumentation/integrations/nrfiber"
 	"github.com/newrelic/go-easy-instrumentation/integrations/nrgin"
 	"github.com/newrelic/go-easy-instrumentation/integrations/nrgochi"
+	"github.com/newrelic/go-easy-instrumentation/integrations/nrgorilla"
 	"github.com/newrelic/go-easy-instrumentation/integrations/nrgrpc"
+	"github.com/newrelic/go-easy-instrumentation/integrations/nrhttprouter"
 	"github.com/newrelic/go-easy-instrumentation/integrations/nrlogrus"
 	"github.com/newrelic/go-easy-instrumentation/integrations/nrnethttp"
-	"github.com/newrelic/go-easy-instrumentation/integrations/nrpq"
 	"github.com/newrelic/go-easy-instrumentation/integrations/nrpgx5"
+	"github.com/newrelic/go-easy-instrumentation/integrations/nrpq"
+	"github.com/newrelic/go-easy-instrumentation/integrations/nrredis"
 	"github.com/newrelic/go-easy-instrumentation/integrations/nrslog"
+	"github.com/newrelic/go-easy-instrumentation/internal/cache"
 	"github.com/newrelic/go-easy-instrumentation/internal/comment"
+	"github.com/newrelic/go-easy-instrumentation/internal/config"
+	"github.com/newrelic/go-easy-instrumentation/internal/filter"
+	"github.com/newrelic/go-easy-instrumentation/internal/modules"
+	"github.com/newrelic/go-easy-instrumentation/internal/report"
 	"github.com/newrelic/go-easy-instrumentation/parser"
 	"github.com/spf13/cobra"
 	"golang.org/x/term"
@@ -30,65 +42,183 @@ import (
 )
 
 const (
-	defaultAgentVariableName = "NewRelicAgent"
-	defaultPackageName       = "./..."
-	defaultPackagePath       = ""
-	defaultAppName           = ""
-	defaultOutputFilePath    = ""
-	defaultDiffFileName      = "new-relic-instrumentation.diff"
+	defaultPackageName    = "./..."
+	defaultPackagePath    = ""
+	defaultOutputFilePath = ""
+	defaultDiffFileName   = config.DefaultDiffFileName
 )
 
-// registerIntegrations registers all integration tracing functions with the manager
-// in the correct order (order matters for instrumentation!)
-func registerIntegrations(manager *parser.InstrumentationManager) {
-	// Pre-instrumentation scanning phase (ORDER PRESERVED)
-	manager.LoadPreInstrumentationTracingFunctions(
-		parser.DetectTransactions,
-		parser.DetectErrors,
-		parser.DetectGinInstrumentation,
-		parser.DetectEchoInstrumentation,
-		parser.DetectEchoV3Instrumentation,
-		nrnethttp.DetectWrappedRoutes,
-	)
+// coreIntegration is the name of the instrumentation that every application gets, such as starting the agent
+// and tracing transactions and errors. It can not be disabled.
+const coreIntegration = ""
 
-	// Stateless tracing functions (ORDER PRESERVED)
-	manager.LoadStatelessTracingFunctions(
-		nragent.InstrumentMain,
-		nrnethttp.InstrumentHandleFunction,
-		nrnethttp.InstrumentHttpClient,
-		nrnethttp.CannotInstrumentHttpMethod,
-		nrgrpc.InstrumentGrpcDial,
-		nrgin.InstrumentGinFunction,
-		nrecho_v4.InstrumentEchoFunction,
-		nrecho_v3.InstrumentEchoFunction,
-		nrgrpc.InstrumentGrpcServerMethod,
-		nrslog.InstrumentSlogHandler,
-		nrlogrus.InstrumentLogrusHandler,
-		nrpq.InstrumentPQHandler,
-		nrpgx5.InstrumentPgxHandler,
-	)
+// The tracing functions of every integration, by integration name. The integration names match the
+// directories in the integrations folder, and are used to enable and disable them in the project
+// configuration. The order of each list matters for instrumentation, and is preserved when integrations
+// are disabled.
+var (
+	preInstrumentationTracingFunctions = []struct {
+		integration string
+		fn          parser.PreInstrumentationTracingFunction
+	}{
+		{coreIntegration, nragent.DetectApplication},
+		{coreIntegration, parser.DetectTransactions},
+		{coreIntegration, parser.DetectErrors},
+		{"nrgin", parser.DetectGinInstrumentation},
+		{"nrecho-v4", parser.DetectEchoInstrumentation},
+		{"nrecho-v3", parser.DetectEchoV3Instrumentation},
+		{"nrnethttp", nrnethttp.DetectWrappedRoutes},
+	}
 
-	// Stateful tracing functions (ORDER PRESERVED)
-	manager.LoadStatefulTracingFunctions(
-		nrnethttp.ExternalHttpCall,
-		nrnethttp.WrapNestedHandleFunction,
-		nrgrpc.InstrumentGrpcServer,
-		nrgin.InstrumentGinMiddleware,
-		nrecho_v4.InstrumentEchoMiddleware,
-		nrecho_v3.InstrumentEchoMiddleware,
-		nrgochi.InstrumentChiMiddleware,
-		nrgochi.InstrumentChiRouterLiteral,
-	)
+	statelessTracingFunctions = []struct {
+		integration string
+		fn          parser.StatelessTracingFunction
+	}{
+		{coreIntegration, nragent.InstrumentMain},
+		{"nrnethttp", nrnethttp.InstrumentHandleFunction},
+		{"nrnethttp", nrnethttp.InstrumentHttpClient},
+		{"nrfasthttp", nrfasthttp.InstrumentRequestHandler},
+		{"nrhttprouter", nrhttprouter.InstrumentHttprouterHandle},
+		{"nrfiber", nrfiber.InstrumentFiberHandler},
+		{"nrgrpc", nrgrpc.InstrumentGrpcDial},
+		{"nrgin", nrgin.InstrumentGinFunction},
+		{"nrecho-v4", nrecho_v4.InstrumentEchoFunction},
+		{"nrecho-v3", nrecho_v3.InstrumentEchoFunction},
+		{"nrgrpc", nrgrpc.InstrumentGrpcServerMethod},
+		{coreIntegration, nragent.InstrumentLibraryEntryPoint},
+		{"nrslog", nrslog.InstrumentSlogHandler},
+		{"nrlogrus", nrlogrus.InstrumentLogrusHandler},
+		{"nrpq", nrpq.InstrumentPQHandler},
+		{"nrpgx5", nrpgx5.InstrumentPgxHandler},
+		{"nrredis", nrredis.InstrumentRedisClient},
+	}
 
-	// Fact discovery functions
-	manager.LoadDependencyScans(
-		nrgrpc.FindGrpcServerObject,
-	)
+	diagnosticFunctions = []struct {
+		integration string
+		fn          parser.DiagnosticFunction
+	}{
+		{"nrnethttp", nrnethttp.CannotInstrumentHttpMethod},
+	}
+
+	statefulTracingFunctions = []struct {
+		integration string
+		fn          parser.StatefulTracingFunction
+	}{
+		{"nrnethttp", nrnethttp.ExternalHttpCall},
+		{"nrnethttp", nrnethttp.WrapNestedHandleFunction},
+		{"nrfasthttp", nrfasthttp.WrapRequestHandler},
+		{"nrfasthttp", nrfasthttp.ExternalFasthttpCall},
+		{"nrgrpc", nrgrpc.InstrumentGrpcServer},
+		{"nrgin", nrgin.InstrumentGinMiddleware},
+		{"nrecho-v4", nrecho_v4.InstrumentEchoMiddleware},
+		{"nrecho-v3", nrecho_v3.InstrumentEchoMiddleware},
+		{"nrgochi", nrgochi.InstrumentChiMiddleware},
+		{"nrgochi", nrgochi.InstrumentChiRouterLiteral},
+		{"nrgorilla", nrgorilla.InstrumentGorillaMiddleware},
+		{"nrgorilla", nrgorilla.InstrumentGorillaRouterLiteral},
+		{"nrhttprouter", nrhttprouter.InstrumentHttprouter},
+		{"nrfiber", nrfiber.InstrumentFiberMiddleware},
+		{"nrredis", nrredis.RedisCommandContext},
+	}
+
+	factDiscoveryFunctions = []struct {
+		integration string
+		fn          parser.FactDiscoveryFunction
+	}{
+		{"nrgrpc", nrgrpc.FindGrpcServerObject},
+	}
+)
+
+// integrationNames returns the sorted names of every integration that can be enabled or disabled.
+func integrationNames() []string {
+	names := []string{}
+	add := func(name string) {
+		if name != coreIntegration && !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	for _, f := range preInstrumentationTracingFunctions {
+		add(f.integration)
+	}
+	for _, f := range statelessTracingFunctions {
+		add(f.integration)
+	}
+	for _, f := range diagnosticFunctions {
+		add(f.integration)
+	}
+	for _, f := range statefulTracingFunctions {
+		add(f.integration)
+	}
+	for _, f := range factDiscoveryFunctions {
+		add(f.integration)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// validateIntegrations returns an error if the configuration enables or disables an integration that does not exist.
+func validateIntegrations(cfg *config.Config) error {
+	names := integrationNames()
+	for _, name := range slices.Concat(cfg.Integrations.Enabled, cfg.Integrations.Disabled) {
+		if !slices.Contains(names, name) {
+			return fmt.Errorf("unknown integration %q; supported integrations are %s", name, strings.Join(names, ", "))
+		}
+	}
+	return nil
+}
+
+// registerIntegrations registers the tracing functions of every enabled integration with the manager
+// in the correct order (order matters for instrumentation!)
+func registerIntegrations(manager *parser.InstrumentationManager, cfg *config.Config) {
+	enabled := func(integration string) bool {
+		return integration == coreIntegration || cfg.IntegrationEnabled(integration)
+	}
+
+	for _, f := range preInstrumentationTracingFunctions {
+		if enabled(f.integration) {
+			manager.LoadPreInstrumentationTracingFunctions(f.fn)
+		}
+	}
+	for _, f := range statelessTracingFunctions {
+		if enabled(f.integration) {
+			manager.LoadStatelessTracingFunctions(f.fn)
+		}
+	}
+	for _, f := range diagnosticFunctions {
+		if enabled(f.integration) {
+			manager.LoadDiagnosticFunctions(f.fn)
+		}
+	}
+	for _, f := range statefulTracingFunctions {
+		if enabled(f.integration) {
+			manager.LoadStatefulTracingFunctions(f.fn)
+		}
+	}
+	for _, f := range factDiscoveryFunctions {
+		if enabled(f.integration) {
+			manager.LoadDependencyScans(f.fn)
+		}
+	}
 }
 
 var (
-	diffFile    string
-	excludeDirs string
+	diffFile                string
+	verify                  bool
+	dropFailingHunks        bool
+	offline                 bool
+	appName                 string
+	agentVariableName       string
+	transactionVariableName string
+	enableIntegrations      string
+	disableIntegrations     string
+	reportFile              string
+	sarifFile               string
+	review                  bool
+	update                  bool
+	preserveSignatures      bool
+	library                 bool
+	callGraph               string
+	cacheDir                string
 )
 
 var instrumentCmd = &cobra.Command{
@@ -97,10 +227,161 @@ var instrumentCmd = &cobra.Command{
 	Long:  "add instrumentation to an application's source files and write these changes to a diff file",
 	Args:  cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
+		runningCommand = cmd
 		Instrument(args[0])
 	},
 }
 
+// splitPatterns splits a comma-separated flag value into its patterns, ignoring empty entries.
+func splitPatterns(value string) []string {
+	var patterns []string
+	for _, pattern := range strings.Split(value, ",") {
+		trimmed := strings.TrimSpace(pattern)
+		if trimmed != "" {
+			patterns = append(patterns, trimmed)
+		}
+	}
+	return patterns
+}
+
+// flagChanged returns true if the flag was set on the command line for the command being run.
+func flagChanged(name string) bool {
+	return runningCommand != nil && runningCommand.Flags().Changed(name)
+}
+
+// loadConfig loads the project configuration for the application at packagePath, either from the file
+// given with --config or from the first configuration file found in packagePath or its parents. Flags
+// set on the command line override the settings in the file.
+func loadConfig(packagePath string) (*config.Config, error) {
+	path := configFile
+	if path == "" {
+		found, err := config.Find(packagePath)
+		if err != nil {
+			return nil, err
+		}
+		path = found
+	}
+
+	cfg := config.Default()
+	if path != "" {
+		loaded, err := config.Load(path)
+		if err != nil {
+			return nil, err
+		}
+		cfg = loaded
+	}
+
+	if flagChanged("app-name") {
+		cfg.AppName = appName
+	}
+	if flagChanged("agent-variable") {
+		cfg.AgentVariableName = agentVariableName
+	}
+	if flagChanged("transaction-variable") {
+		cfg.TransactionVariableName = transactionVariableName
+	}
+	if flagChanged("enable-integrations") {
+		cfg.Integrations.Enabled = splitPatterns(enableIntegrations)
+	}
+	if flagChanged("disable-integrations") {
+		cfg.Integrations.Disabled = splitPatterns(disableIntegrations)
+	}
+	if flagChanged("include") {
+		cfg.Include = splitPatterns(includePatterns)
+	}
+	if flagChanged("exclude") {
+		cfg.Exclude = splitPatterns(excludeDirs)
+	}
+	if flagChanged("preserve-signatures") {
+		cfg.PreserveSignatures = preserveSignatures
+	}
+	if flagChanged("library") {
+		cfg.Library = library
+	}
+	if flagChanged("callgraph") {
+		cfg.CallGraph = callGraph
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	if err := validateIntegrations(cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// configureManager applies the settings of the project configuration that are not passed to
+// parser.NewInstrumentationManager.
+func configureManager(manager *parser.InstrumentationManager, cfg *config.Config) error {
+	f, err := filter.New(cfg.Include, cfg.Exclude)
+	if err != nil {
+		return fmt.Errorf("invalid include or exclude pattern: %w", err)
+	}
+	manager.SetFilter(f)
+	manager.SetAgentConfigOptions(cfg.AgentConfigOptions())
+	manager.SetPreserveSignatures(cfg.PreserveSignatures)
+	manager.SetLibrary(cfg.Library)
+	manager.SetBinaries(cfg.Binaries)
+	manager.SetCallGraph(cfg.CallGraph)
+	manager.SetTransactionVariableName(cfg.TransactionVariableName)
+	return nil
+}
+
+// binariesSummary lists the binaries of the application and the application names they report, or returns an
+// empty string if the application has a single binary that was not opted out of instrumentation.
+func binariesSummary(manager *parser.InstrumentationManager) string {
+	binaries := manager.Binaries()
+	if len(binaries) == 0 || len(binaries) == 1 && !binaries[0].Skipped {
+		return ""
+	}
+	instrumented := 0
+	for _, binary := range binaries {
+		if !binary.Skipped {
+			instrumented++
+		}
+	}
+
+	summary := strings.Builder{}
+	fmt.Fprintf(&summary, "%d of %d binaries were instrumented:\n", instrumented, len(binaries))
+	for _, binary := range binaries {
+		switch {
+		case binary.Skipped:
+			fmt.Fprintf(&summary, "  %s: skipped\n", binary.Dir)
+		case binary.AppName == "":
+			fmt.Fprintf(&summary, "  %s: application name from NEW_RELIC_APP_NAME\n", binary.Dir)
+		default:
+			fmt.Fprintf(&summary, "  %s: %q\n", binary.Dir, binary.AppName)
+		}
+	}
+	return summary.String()
+}
+
+// alreadyInstrumentedSummary returns a note about the functions that were left unchanged because they were
+// already instrumented, or an empty string if there are none or they are being updated.
+func alreadyInstrumentedSummary(manager *parser.InstrumentationManager) string {
+	count := manager.AlreadyInstrumented()
+	if count == 0 || update {
+		return ""
+	}
+	return fmt.Sprintf("%d functions were already instrumented and were left unchanged.\nRun again with --update to instrument the code added to them since then.\n", count)
+}
+
+// preservedSignaturesSummary lists the functions that were not traced because their signatures are preserved,
+// or returns an empty string if there are none.
+func preservedSignaturesSummary(manager *parser.InstrumentationManager) string {
+	names := manager.PreservedSignatures()
+	if len(names) == 0 {
+		return ""
+	}
+	summary := strings.Builder{}
+	fmt.Fprintf(&summary, "%d functions were not traced, since a transaction can not be passed to them without changing their signatures:\n", len(names))
+	for _, name := range names {
+		fmt.Fprintf(&summary, "  %s\n", name)
+	}
+	return summary.String()
+}
+
 // validateOutputFile checks that the custom output path is valid
 func validateOutputFile(path string) error {
 	if filepath.Ext(path) != ".diff" {
@@ -134,8 +415,171 @@ func setOutputFilePath(outputFilePath, applicationPath string) (string, error) {
 	return outputFilePath, nil
 }
 
+// addRequiredModules makes the modules needed by the instrumentation available to the application. By default,
+// they are installed with go get. In offline mode, the go.mod and go.sum changes are added to the diff instead,
+// and a summary of anything that could not be resolved offline is returned.
+func addRequiredModules(manager *parser.InstrumentationManager) (string, error) {
+	if !offline {
+		return "", manager.AddRequiredModules()
+	}
+
+	resolver, err := modules.NewResolver()
+	if err != nil {
+		return "", err
+	}
+	warnings, err := manager.AddRequiredModulesToDiff(resolver)
+	if err != nil {
+		return "", err
+	}
+
+	summary := strings.Builder{}
+	for _, warning := range warnings {
+		fmt.Fprintf(&summary, "Warning: %s\n", warning)
+		report.Add(report.Entry{Code: report.CodeModuleNotResolved, Severity: report.SeverityWarning, Integration: report.CoreIntegration, Message: warning})
+	}
+	return summary.String(), nil
+}
+
+// runVerification type checks the instrumented application before the diff is written, if verification
+// was requested. It returns a summary of any changes that were dropped because they did not compile, and
+// an error listing every compile error that remains in the instrumented application.
+func runVerification(manager *parser.InstrumentationManager) (string, error) {
+	if !verify && !dropFailingHunks {
+		return "", nil
+	}
+
+	result, err := manager.VerifyChanges(dropFailingHunks)
+	if err != nil {
+		return "", err
+	}
+
+	summary := strings.Builder{}
+	if len(result.Dropped) > 0 {
+		fmt.Fprintf(&summary, "Dropped %d change(s) that did not compile:\n", len(result.Dropped))
+		for _, compileError := range result.Dropped {
+			fmt.Fprintf(&summary, "  %s\n", compileError)
+			report.Add(compileErrorEntry(compileError, report.SeveritySkipped, report.CodeHunkDropped))
+		}
+	}
+
+	if len(result.Errors) > 0 {
+		errs := strings.Builder{}
+		for _, compileError := range result.Errors {
+			fmt.Fprintf(&errs, "\n  %s", compileError)
+			report.Add(compileErrorEntry(compileError, report.SeverityWarning, report.CodeCompileError))
+		}
+		return summary.String(), fmt.Errorf("instrumented application does not compile:%s", errs.String())
+	}
+
+	return summary.String(), nil
+}
+
+// compileErrorEntry converts a compile error found while verifying the changes into a report entry.
+func compileErrorEntry(compileError parser.CompileError, severity report.Severity, code report.Code) report.Entry {
+	message := compileError.Message
+	if compileError.Hunk != "" {
+		message += " (in " + compileError.Hunk + ")"
+	}
+	return report.Entry{
+		Code:        code,
+		Severity:    severity,
+		Integration: report.CoreIntegration,
+		File:        compileError.File,
+		Line:        compileError.Line,
+		Column:      compileError.Column,
+		Message:     message,
+	}
+}
+
+// writeReport writes the report of everything the instrumentation did to the file given with --report, and
+// the warnings to the SARIF log given with --sarif, if any.
+func writeReport(outputFile string) error {
+	if reportFile != "" {
+		if err := report.Write(reportFile, AppVersion, outputFile); err != nil {
+			return err
+		}
+	}
+	if sarifFile != "" {
+		return report.WriteSARIF(sarifFile, AppVersion)
+	}
+	return nil
+}
+
 const LoadMode = packages.LoadSyntax | packages.NeedForTest
 
+// cachedRun is an instrumentation run that reuses the results of the packages that a run with the same settings
+// cached, and have not changed since.
+type cachedRun struct {
+	cache    *cache.Cache
+	app      *cache.Application
+	settings string
+	cached   map[string]*cache.Result // by load path
+}
+
+// cacheSettings returns everything other than the code of the application that decides how it is instrumented,
+// so that results are never reused by a run with different settings.
+func cacheSettings(cfg *config.Config) string {
+	return fmt.Sprintf("%s %+v update=%t comments=%t report=%t", AppVersion, *cfg, update, comment.CodeCommentsEnabled(), report.Enabled())
+}
+
+// loadPackages loads the packages of the application that match patterns. When a cache directory is given, only the
+// packages that are stale since the cached run are loaded, and the run that reuses the results of the others is
+// returned; otherwise, the returned run is nil.
+func loadPackages(packagePath string, patterns []string, cfg *config.Config) ([]*decorator.Package, *cachedRun, error) {
+	if cacheDir == "" {
+		pkgs, err := decorator.Load(&packages.Config{Dir: packagePath, Mode: LoadMode, Tests: true}, patterns...)
+		return pkgs, nil, err
+	}
+
+	c, err := cache.Open(cacheDir, packagePath, patterns)
+	if err != nil {
+		return nil, nil, err
+	}
+	app, err := cache.Scan(packagePath, patterns)
+	if err != nil {
+		return nil, nil, err
+	}
+	settings := cacheSettings(cfg)
+	stale := c.Stale(app, settings)
+	run := &cachedRun{
+		cache:    c,
+		app:      app,
+		settings: settings,
+		cached:   c.Cached(app, stale),
+	}
+	if len(stale) == 0 {
+		return nil, run, nil
+	}
+	pkgs, err := decorator.Load(&packages.Config{Dir: packagePath, Mode: LoadMode, Tests: true}, stale...)
+	return pkgs, run, err
+}
+
+// useCachedResults gives the manager the results of the packages that were not loaded, and adds what they reported.
+func (r *cachedRun) useCachedResults(manager *parser.InstrumentationManager) {
+	if r == nil {
+		return
+	}
+	manager.SetCachedResults(r.cached)
+	for _, path := range slices.Sorted(maps.Keys(r.cached)) {
+		for _, entry := range r.cached[path].Report {
+			report.Add(entry)
+		}
+	}
+}
+
+// save caches the results of the packages that were loaded, along with those that were reused.
+func (r *cachedRun) save(manager *parser.InstrumentationManager) error {
+	if r == nil {
+		return nil
+	}
+	results, err := manager.Results()
+	if err != nil {
+		return err
+	}
+	r.cache.Update(r.app, r.settings, results, report.Entries())
+	return r.cache.Save()
+}
+
 // Bubble Tea Model
 type model struct {
 	spinner     spinner.Model
@@ -148,6 +592,12 @@ type model struct {
 	pkgPath     string
 	sub         chan tea.Msg
 	outputFile  string
+	width       int
+	height      int
+
+	// the review being shown, if any, and where to send it when it is finished
+	review       *reviewModel
+	reviewResult chan reviewModel
 }
 
 // Messages
@@ -158,6 +608,10 @@ type progressMsg struct {
 type pkgLoadedMsg []*decorator.Package
 type errMsg error
 type completedMsg struct{}
+type reviewRequestMsg struct {
+	review reviewModel
+	result chan reviewModel
+}
 
 func initialModel(pkgPath, outputFile string) model {
 	s := spinner.New()
@@ -186,31 +640,52 @@ func Instrument(packagePath string, patterns ...string) {
 	if _, err := os.Stat(packagePath); err != nil {
 		cobra.CheckErr(fmt.Errorf("path argument \"%s\" is invalid: %v", packagePath, err))
 	}
-	outputFile, err := setOutputFilePath(diffFile, packagePath)
+	cfg, err := loadConfig(packagePath)
+	cobra.CheckErr(err)
+
+	outputFilePath := diffFile
+	if outputFilePath == "" && cfg.DiffFile != "" {
+		outputFilePath = cfg.DiffFile
+		if !filepath.IsAbs(outputFilePath) {
+			outputFilePath = filepath.Join(packagePath, outputFilePath)
+		}
+	}
+	outputFile, err := setOutputFilePath(outputFilePath, packagePath)
 	cobra.CheckErr(err)
 	if debug {
 		comment.EnableConsolePrinter(packagePath)
 	}
+	if review && !term.IsTerminal(int(os.Stdout.Fd())) {
+		cobra.CheckErr(errors.New("--review needs an interactive terminal"))
+	}
+	// the review uses the report to tell which integration proposed each change
+	if reportFile != "" || sarifFile != "" || review {
+		report.Enable(packagePath)
+	}
+	if sarifFile != "" {
+		// the diagnostics are in the SARIF log, so they do not need to be in the diff
+		comment.DisableCodeComments()
+	}
 
 	// If debug mode is enabled or no terminal is available (CI/CD), run in text mode (no TUI)
 	if debug || !term.IsTerminal(int(os.Stdout.Fd())) {
-		runTextMode(packagePath, patterns, outputFile)
+		runTextMode(packagePath, patterns, outputFile, cfg)
 		return
 	}
 
 	// Normal TUI mode
-	runTUIMode(packagePath, patterns, outputFile)
+	runTUIMode(packagePath, patterns, outputFile, cfg)
 }
 
 // runTextMode runs the instrumentation pipeline with plain text output to stdout.
 // This is used when the TUI is unavailable (e.g. CI/CD, piped output) or when
 // the --debug flag is enabled. It delegates to instrumentPackages for the core
 // logic and handles printing status and exit on error.
-func runTextMode(packagePath string, patterns []string, outputFile string) {
+func runTextMode(packagePath string, patterns []string, outputFile string, cfg *config.Config) {
 	fmt.Printf("Instrumentation started for %s\n", packagePath)
 	fmt.Printf("Output file: %s\n\n", outputFile)
 
-	if err := instrumentPackages(packagePath, patterns, outputFile); err != nil {
+	if err := instrumentPackages(packagePath, patterns, outputFile, cfg); err != nil {
 		fmt.Printf("Error: %v\n", err)
 		os.Exit(1)
 	}
@@ -219,25 +694,32 @@ func runTextMode(packagePath string, patterns []string, outputFile string) {
 }
 
 // instrumentPackages loads Go packages from packagePath, runs the full
-// instrumentation pipeline, and writes the resulting diff to outputFile.
+// instrumentation pipeline configured by cfg, and writes the resulting diff to outputFile.
 // It returns an error rather than exiting the process, making it safe to
 // call from both runTextMode (which handles os.Exit) and from tests.
-func instrumentPackages(packagePath string, patterns []string, outputFile string) error {
+func instrumentPackages(packagePath string, patterns []string, outputFile string, cfg *config.Config) error {
 	loadPatterns := patterns
 	if len(loadPatterns) == 0 {
 		loadPatterns = []string{defaultPackageName}
 	}
 
 	fmt.Println(" -> Loading packages...")
-	pkgs, err := decorator.Load(&packages.Config{Dir: packagePath, Mode: LoadMode, Tests: true}, loadPatterns...)
+	pkgs, run, err := loadPackages(packagePath, loadPatterns, cfg)
 	if err != nil {
 		return fmt.Errorf("loading packages: %w", err)
 	}
 
-	manager := parser.NewInstrumentationManager(pkgs, defaultAppName, defaultAgentVariableName, outputFile, packagePath)
+	manager := parser.NewInstrumentationManager(pkgs, cfg.AppName, cfg.AgentVariableName, outputFile, packagePath)
+	if err := configureManager(manager, cfg); err != nil {
+		return err
+	}
+	manager.SetUpdate(update)
+	run.useCachedResults(manager)
+
+	// Register all enabled integrations
+	registerIntegrations(manager, cfg)
 
-	// Register all integrations
-	registerIntegrations(manager)
+	var instrumentedSummary, reviewSummary, modulesSummary, verificationSummary string
 
 	steps := []struct {
 		desc string
@@ -246,12 +728,35 @@ func instrumentPackages(packagePath string, patterns []string, outputFile string
 		{"Creating diff file", manager.CreateDiffFile},
 		{"Tracing package calls", manager.TracePackageCalls},
 		{"Scanning application", manager.ScanApplication},
-		{"Instrumenting application", manager.InstrumentApplication},
+		{"Instrumenting application", func() error {
+			if err := manager.InstrumentApplication(); err != nil {
+				return err
+			}
+			instrumentedSummary = binariesSummary(manager) + alreadyInstrumentedSummary(manager) + preservedSignaturesSummary(manager)
+			return nil
+		}},
 		{"Resolving unit tests", manager.ResolveUnitTests},
-		{"Adding required modules", manager.AddRequiredModules},
+		{"Caching results", func() error { return run.save(manager) }},
+		{"Reviewing changes", func() (err error) {
+			if review {
+				reviewSummary, err = reviewChanges(manager, runReviewProgram)
+			}
+			return err
+		}},
+		{"Adding required modules", func() (err error) {
+			modulesSummary, err = addRequiredModules(manager)
+			return err
+		}},
+		{"Verifying changes", func() (err error) {
+			verificationSummary, err = runVerification(manager)
+			return err
+		}},
 		{"Writing diff file", func() error {
 			comment.WriteAll()
-			return manager.WriteDiff(func(msg string) {})
+			if err := manager.WriteDiff(func(msg string) {}); err != nil {
+				return err
+			}
+			return writeReport(outputFile)
 		}},
 	}
 
@@ -261,12 +766,14 @@ func instrumentPackages(packagePath string, patterns []string, outputFile string
 		}
 	}
 
+	fmt.Print(instrumentedSummary + reviewSummary + modulesSummary + verificationSummary)
 	return nil
 }
 
-func runTUIMode(packagePath string, patterns []string, outputFile string) {
+func runTUIMode(packagePath string, patterns []string, outputFile string, cfg *config.Config) {
 	// Channel to receive updates from the worker
 	updates := make(chan tea.Msg)
+	var instrumentedSummary, reviewSummary, modulesSummary, verificationSummary string
 
 	// Worker goroutine
 	go func() {
@@ -275,7 +782,7 @@ func runTUIMode(packagePath string, patterns []string, outputFile string) {
 			loadPatterns = []string{defaultPackageName}
 		}
 
-		pkgs, err := decorator.Load(&packages.Config{Dir: packagePath, Mode: LoadMode, Tests: true}, loadPatterns...)
+		pkgs, run, err := loadPackages(packagePath, loadPatterns, cfg)
 		if err != nil {
 			updates <- errMsg(err)
 			return
@@ -283,27 +790,62 @@ func runTUIMode(packagePath string, patterns []string, outputFile string) {
 
 		updates <- pkgLoadedMsg(pkgs)
 
-		manager := parser.NewInstrumentationManager(pkgs, defaultAppName, defaultAgentVariableName, outputFile, packagePath)
+		manager := parser.NewInstrumentationManager(pkgs, cfg.AppName, cfg.AgentVariableName, outputFile, packagePath)
+		if err := configureManager(manager, cfg); err != nil {
+			updates <- errMsg(err)
+			return
+		}
+		manager.SetUpdate(update)
+		run.useCachedResults(manager)
 
 		steps := []struct {
 			desc string
 			fn   func() error
 		}{
 			{"Creating diff file", manager.CreateDiffFile},
-			{"Detecting dependencies", func() error { registerIntegrations(manager); return nil }},
+			{"Detecting dependencies", func() error { registerIntegrations(manager, cfg); return nil }},
 			{"Tracing package calls", manager.TracePackageCalls},
 			{"Scanning application", manager.ScanApplication},
-			{"Instrumenting application", manager.InstrumentApplication},
+			{"Instrumenting application", func() error {
+				if err := manager.InstrumentApplication(); err != nil {
+					return err
+				}
+				instrumentedSummary = binariesSummary(manager) + alreadyInstrumentedSummary(manager) + preservedSignaturesSummary(manager)
+				return nil
+			}},
 			{"Resolving unit tests", manager.ResolveUnitTests},
-			{"Adding required modules", manager.AddRequiredModules},
+			{"Caching results", func() error { return run.save(manager) }},
+			{"Reviewing changes", func() (err error) {
+				if review {
+					reviewSummary, err = reviewChanges(manager, func(r reviewModel) (reviewModel, error) {
+						// the review is shown by the program that owns the terminal, which sends it back when it is finished
+						result := make(chan reviewModel, 1)
+						updates <- reviewRequestMsg{review: r, result: result}
+						return <-result, nil
+					})
+				}
+				return err
+			}},
+			{"Adding required modules", func() (err error) {
+				modulesSummary, err = addRequiredModules(manager)
+				return err
+			}},
+			{"Verifying changes", func() (err error) {
+				verificationSummary, err = runVerification(manager)
+				return err
+			}},
 			{"Writing diff file", func() error {
 				comment.WriteAll()
 				// Pass a callback to WriteDiff to receive granular progress updates.
 				// This callback updates the UI with the name of the file currently being written,
 				// avoiding a "stalled" UI during this potentially long-running step.
-				return manager.WriteDiff(func(msg string) {
+				err := manager.WriteDiff(func(msg string) {
 					updates <- progressMsg{desc: msg}
 				})
+				if err != nil {
+					return err
+				}
+				return writeReport(outputFile)
 			}},
 		}
 
@@ -333,12 +875,22 @@ func runTUIMode(packagePath string, patterns []string, outputFile string) {
 			os.Exit(1)
 		}
 		if m.done {
+			fmt.Print(instrumentedSummary + reviewSummary + modulesSummary + verificationSummary)
 			fmt.Printf("\nDone! Changes written to: %s\nTip: Apply these changes with: git apply %s\n", m.outputFile, m.outputFile)
 		}
 	}
 }
 
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if size, ok := msg.(tea.WindowSizeMsg); ok {
+		m.width, m.height = size.Width, size.Height
+	}
+	if m.review != nil {
+		if _, ok := msg.(spinner.TickMsg); !ok {
+			return m.updateReview(msg)
+		}
+	}
+
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
 		if msg.Type == tea.KeyCtrlC {
@@ -363,6 +915,14 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case completedMsg:
 		m.done = true
 		return m, tea.Quit
+	case reviewRequestMsg:
+		review := msg.review
+		if m.width > 0 {
+			review.setSize(m.width, m.height)
+		}
+		m.review = &review
+		m.reviewResult = msg.result
+		return m, tea.EnterAltScreen
 	}
 
 	// If we are strictly in Init, we should return the initial batch.
@@ -374,6 +934,21 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
+// updateReview passes a message to the review being shown, and hands the review back to the worker
+// once the user has finished it.
+func (m model) updateReview(msg tea.Msg) (tea.Model, tea.Cmd) {
+	review, cmd := m.review.update(msg)
+	if !review.done {
+		m.review = &review
+		return m, cmd
+	}
+
+	m.reviewResult <- review
+	m.review = nil
+	m.reviewResult = nil
+	return m, tea.Batch(tea.ExitAltScreen, waitForNext(m.sub))
+}
+
 func waitForNext(sub chan tea.Msg) tea.Cmd {
 	if sub == nil {
 		return nil
@@ -391,6 +966,9 @@ func (m model) View() string {
 	if m.err != nil {
 		return fmt.Sprintf("\nError: %v\n", m.err)
 	}
+	if m.review != nil {
+		return m.review.view()
+	}
 
 	pad := strings.Repeat(" ", padding(m.stepDesc, 30))
 
@@ -408,8 +986,25 @@ func padding(s string, width int) int {
 
 func init() {
 	instrumentCmd.Flags().StringVarP(&diffFile, "output", "o", defaultOutputFilePath, "specify diff output file path")
-	instrumentCmd.Flags().StringVarP(&excludeDirs, "exclude", "e", "", "comma-separated list of folders to exclude from instrumentation")
+	instrumentCmd.Flags().StringVar(&appName, "app-name", "", "name of the application reported by the agent; defaults to the NEW_RELIC_APP_NAME environment variable")
+	instrumentCmd.Flags().StringVar(&agentVariableName, "agent-variable", config.DefaultAgentVariableName, "name of the variable that holds the agent application")
+	instrumentCmd.Flags().StringVar(&transactionVariableName, "transaction-variable", config.DefaultTransactionVariableName, "name of the variables that hold transactions")
+	instrumentCmd.Flags().StringVar(&enableIntegrations, "enable-integrations", "", "comma-separated list of the only integrations to instrument the application with")
+	instrumentCmd.Flags().StringVar(&disableIntegrations, "disable-integrations", "", "comma-separated list of integrations not to instrument the application with")
+	instrumentCmd.Flags().BoolVar(&verify, "verify", false, "type check the instrumented application before writing the diff, and fail if it does not compile")
+	instrumentCmd.Flags().BoolVar(&dropFailingHunks, "drop-failing-hunks", false, "type check the instrumented application, and leave out any changes that do not compile (implies --verify)")
+	instrumentCmd.Flags().BoolVar(&offline, "offline", false, "resolve required modules from the local module cache, and add the go.mod and go.sum changes to the diff instead of running go get")
+	instrumentCmd.Flags().StringVar(&reportFile, "report", "", "write a JSON report of every instrumentation action, warning and skipped construct to this file")
+	instrumentCmd.Flags().StringVar(&sarifFile, "sarif", "", "write warnings and unsupported code patterns to this file as a SARIF 2.1.0 log, instead of as comments in the diff")
+	instrumentCmd.Flags().BoolVar(&update, "update", false, "instrument the code added to functions that are already instrumented, instead of leaving them unchanged")
+	instrumentCmd.Flags().BoolVar(&preserveSignatures, "preserve-signatures", false, "never change the signatures of exported functions and of methods that implement interfaces, and report the functions that can not be traced without doing so")
+	instrumentCmd.Flags().BoolVar(&library, "library", false, "instrument a library without a main function: its exported functions take the transaction from the context they are passed, and the agent is never initialized")
+	instrumentCmd.Flags().StringVar(&callGraph, "callgraph", "", "trace the implementations of interface methods that are called, found with a call graph built with \"cha\" or \"vta\"")
+	instrumentCmd.Flags().StringVar(&cacheDir, "cache-dir", "", "cache the results of each package in this directory, so that later runs only load and instrument the packages affected by changes")
+	instrumentCmd.Flags().BoolVar(&review, "review", false, "review each proposed change in an interactive terminal, and only write the accepted changes to the diff")
 	cobra.MarkFlagFilename(instrumentCmd.Flags(), "output", ".diff") // for file completion
+	cobra.MarkFlagFilename(instrumentCmd.Flags(), "report", ".json")
+	cobra.MarkFlagFilename(instrumentCmd.Flags(), "sarif", ".sarif", ".json")
 
 	rootCmd.AddCommand(instrumentCmd)
 }
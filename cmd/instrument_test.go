@@ -1,12 +1,16 @@
 package cmd
 
 import (
+	"encoding/json"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/newrelic/go-easy-instrumentation/internal/config"
+	"github.com/newrelic/go-easy-instrumentation/internal/diff"
+	"github.com/newrelic/go-easy-instrumentation/internal/report"
 )
 
 func TestValidateOutputFile(t *testing.T) {
@@ -316,7 +320,7 @@ func TestInstrumentPackages_BasicGin(t *testing.T) {
 
 	outputFile := filepath.Join(t.TempDir(), "output.diff")
 
-	err := instrumentPackages(packagePath, nil, outputFile)
+	err := instrumentPackages(packagePath, nil, outputFile, config.Default())
 	if err != nil {
 		t.Fatalf("instrumentPackages failed: %v", err)
 	}
@@ -339,7 +343,7 @@ func TestInstrumentPackages_HttpApp(t *testing.T) {
 
 	outputFile := filepath.Join(t.TempDir(), "output.diff")
 
-	err := instrumentPackages(packagePath, nil, outputFile)
+	err := instrumentPackages(packagePath, nil, outputFile, config.Default())
 	if err != nil {
 		t.Fatalf("instrumentPackages failed: %v", err)
 	}
@@ -362,7 +366,7 @@ func TestInstrumentPackages_CustomPatterns(t *testing.T) {
 	outputFile := filepath.Join(t.TempDir(), "output.diff")
 
 	// Use explicit patterns instead of the default "./..."
-	err := instrumentPackages(packagePath, []string{"./..."}, outputFile)
+	err := instrumentPackages(packagePath, []string{"./..."}, outputFile, config.Default())
 	if err != nil {
 		t.Fatalf("instrumentPackages with custom patterns failed: %v", err)
 	}
@@ -379,7 +383,7 @@ func TestInstrumentPackages_CustomPatterns(t *testing.T) {
 func TestInstrumentPackages_InvalidPackagePath(t *testing.T) {
 	outputFile := filepath.Join(t.TempDir(), "output.diff")
 
-	err := instrumentPackages("/nonexistent/path/to/package", nil, outputFile)
+	err := instrumentPackages("/nonexistent/path/to/package", nil, outputFile, config.Default())
 	if err == nil {
 		t.Fatal("expected error for invalid package path, got nil")
 	}
@@ -387,3 +391,207 @@ func TestInstrumentPackages_InvalidPackagePath(t *testing.T) {
 		t.Errorf("expected error about loading packages, got: %v", err)
 	}
 }
+
+func TestRunVerification_Disabled(t *testing.T) {
+	verify, dropFailingHunks = false, false
+
+	// the manager is never used when verification is disabled
+	summary, err := runVerification(nil)
+	if err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+	if summary != "" {
+		t.Errorf("expected an empty summary, got %q", summary)
+	}
+}
+
+func TestInstrumentPackages_ExcludeEverything(t *testing.T) {
+	packagePath := t.TempDir()
+	files := map[string]string{
+		"go.mod":  "module example.com/app\n\ngo 1.22\n",
+		"main.go": "package main\n\nimport \"net/http\"\n\nfunc main() {\n\thttp.ListenAndServe(\":8080\", nil)\n}\n",
+	}
+	for name, contents := range files {
+		if err := os.WriteFile(filepath.Join(packagePath, name), []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg := config.Default()
+	cfg.Exclude = []string{"**"}
+
+	outputFile := filepath.Join(t.TempDir(), "output.diff")
+	if err := instrumentPackages(packagePath, nil, outputFile, cfg); err != nil {
+		t.Fatalf("instrumentPackages failed: %v", err)
+	}
+
+	info, err := os.Stat(outputFile)
+	if err != nil {
+		t.Fatalf("output file not created: %v", err)
+	}
+	if info.Size() != 0 {
+		t.Error("expected an empty diff when everything is excluded")
+	}
+}
+
+func TestLoadConfig_InvalidFilter(t *testing.T) {
+	runningCommand = instrumentCmd
+	if err := instrumentCmd.ParseFlags([]string{"--include", "handlers/[bad"}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		includePatterns = ""
+		instrumentCmd.Flags().Lookup("include").Changed = false
+		runningCommand = nil
+	})
+
+	_, err := loadConfig(t.TempDir())
+	if err == nil || !strings.Contains(err.Error(), "invalid pattern") {
+		t.Errorf("expected an invalid pattern error, got %v", err)
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	root := t.TempDir()
+	app := filepath.Join(root, "services", "checkout")
+	if err := os.MkdirAll(app, 0755); err != nil {
+		t.Fatal(err)
+	}
+	contents := "app_name: checkout\ntransaction_variable_name: txn\nintegrations:\n  disabled: [nrlogrus]\n"
+	if err := os.WriteFile(filepath.Join(root, config.FileName), []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	runningCommand = instrumentCmd
+	if err := instrumentCmd.ParseFlags([]string{"--app-name", "checkout-cli"}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		appName = ""
+		instrumentCmd.Flags().Lookup("app-name").Changed = false
+		runningCommand = nil
+	})
+
+	cfg, err := loadConfig(app)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.AppName != "checkout-cli" {
+		t.Errorf("expected the --app-name flag to override the config file, got %q", cfg.AppName)
+	}
+	if cfg.TransactionVariableName != "txn" {
+		t.Errorf("expected the transaction variable from the config file, got %q", cfg.TransactionVariableName)
+	}
+	if cfg.AgentVariableName != config.DefaultAgentVariableName {
+		t.Errorf("expected the default agent variable, got %q", cfg.AgentVariableName)
+	}
+	if cfg.IntegrationEnabled("nrlogrus") {
+		t.Error("expected nrlogrus to be disabled by the config file")
+	}
+}
+
+func TestValidateIntegrations(t *testing.T) {
+	cfg := config.Default()
+	cfg.Integrations.Disabled = []string{"nrgin", "nrecho-v4"}
+	if err := validateIntegrations(cfg); err != nil {
+		t.Errorf("expected known integrations to be valid, got %v", err)
+	}
+
+	cfg.Integrations.Enabled = []string{"nrunknown"}
+	if err := validateIntegrations(cfg); err == nil || !strings.Contains(err.Error(), "nrunknown") {
+		t.Errorf("expected an unknown integration error, got %v", err)
+	}
+}
+
+func TestInstrumentPackages_Report(t *testing.T) {
+	packagePath := t.TempDir()
+	files := map[string]string{
+		"go.mod":  "module example.com/app\n\ngo 1.22\n",
+		"main.go": "package main\n\nimport \"errors\"\n\nfunc work() error {\n\treturn errors.New(\"failed\")\n}\n\nfunc main() {\n\twork()\n\tgo work()\n}\n",
+	}
+	for name, contents := range files {
+		if err := os.WriteFile(filepath.Join(packagePath, name), []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	reportFile = filepath.Join(t.TempDir(), "report.json")
+	offline = true
+	report.Enable(packagePath)
+	t.Cleanup(func() {
+		reportFile = ""
+		offline = false
+		report.Disable()
+	})
+
+	outputFile := filepath.Join(t.TempDir(), "output.diff")
+	if err := instrumentPackages(packagePath, nil, outputFile, config.Default()); err != nil {
+		t.Fatalf("instrumentPackages failed: %v", err)
+	}
+
+	data, err := os.ReadFile(reportFile)
+	if err != nil {
+		t.Fatalf("report not written: %v", err)
+	}
+	var got report.Report
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	for _, code := range []report.Code{report.CodeAgentInitialized, report.CodeTransaction, report.CodeSegment, report.CodeGoroutineInMain} {
+		if got.Summary.Codes[code] == 0 {
+			t.Errorf("expected the report to contain %s, got %v", code, got.Summary.Codes)
+		}
+	}
+	if got.DiffFile != outputFile {
+		t.Errorf("expected diff file %q, got %q", outputFile, got.DiffFile)
+	}
+}
+
+func TestWriteReport_SARIF(t *testing.T) {
+	sarifFile = filepath.Join(t.TempDir(), "results.sarif")
+	report.Enable(t.TempDir())
+	t.Cleanup(func() {
+		sarifFile = ""
+		report.Disable()
+	})
+	report.Add(report.Entry{Code: report.CodeModuleNotResolved, Severity: report.SeverityWarning, Message: "module not found"})
+
+	if err := writeReport("output.diff"); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(sarifFile)
+	if err != nil {
+		t.Fatalf("SARIF log not written: %v", err)
+	}
+	if !strings.Contains(string(data), `"ruleId": "NR2007"`) {
+		t.Errorf("expected the SARIF log to contain the module warning, got %s", data)
+	}
+}
+
+func TestInstrumentPackages_AlreadyInstrumented(t *testing.T) {
+	packagePath := writeTestApp(t, uninstrumentOriginal)
+	files, err := instrumentTestApp(t, packagePath).ProposedChanges()
+	if err != nil {
+		t.Fatal(err)
+	}
+	instrumented, err := diff.Apply(uninstrumentOriginal, files[0].Hunks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(packagePath, "main.go"), []byte(instrumented), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	manager := instrumentTestApp(t, packagePath)
+	files, err = manager.ProposedChanges()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 0 {
+		t.Errorf("expected no changes to an application that is already instrumented, got:\n%s", files[0].Hunks)
+	}
+	if manager.AlreadyInstrumented() == 0 {
+		t.Error("expected the instrumented functions to be detected")
+	}
+}
@@ -12,15 +12,7 @@ import (
 
 func runInteractiveMode(cmd *cobra.Command, args []string) {
 	// Parse exclusions from the --exclude flag if provided
-	var exclusions []string
-	if excludeDirs != "" {
-		for _, dir := range strings.Split(excludeDirs, ",") {
-			trimmed := strings.TrimSpace(dir)
-			if trimmed != "" {
-				exclusions = append(exclusions, trimmed)
-			}
-		}
-	}
+	exclusions := splitPatterns(excludeDirs)
 
 	files, err := scanGoFiles(".", exclusions)
 	if err != nil {
@@ -35,6 +27,9 @@ func runInteractiveMode(cmd *cobra.Command, args []string) {
 	printFiles(files)
 
 	if promptUser("Do you want to run instrumentation on these files?") {
+		if !review {
+			review = promptUser("Do you want to review each change before it is written to the diff?")
+		}
 		// Pass the detected files as patterns to Instrument
 		Instrument(".", files...)
 	} else {
@@ -128,7 +128,7 @@ func TestScanGoFiles(t *testing.T) {
 }
 
 func TestParseExcludeDirs(t *testing.T) {
-	// This tests the parsing logic used in runInteractiveMode
+	// This tests the parsing of the --include and --exclude flags
 	tests := []struct {
 		name     string
 		input    string
@@ -168,15 +168,7 @@ func TestParseExcludeDirs(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			var result []string
-			if tt.input != "" {
-				for _, dir := range strings.Split(tt.input, ",") {
-					trimmed := strings.TrimSpace(dir)
-					if trimmed != "" {
-						result = append(result, trimmed)
-					}
-				}
-			}
+			result := splitPatterns(tt.input)
 
 			if len(result) != len(tt.expected) {
 				t.Fatalf("expected %d exclusions, got %d: %v", len(tt.expected), len(result), result)
@@ -0,0 +1,622 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"go/scanner"
+	"go/token"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/newrelic/go-easy-instrumentation/internal/diff"
+	"github.com/newrelic/go-easy-instrumentation/internal/report"
+	"github.com/newrelic/go-easy-instrumentation/parser"
+)
+
+// errReviewCancelled is returned when the user quits the review without finishing it.
+var errReviewCancelled = errors.New("review cancelled")
+
+// minSideBySideWidth is the narrowest terminal that shows the before and after of a hunk side by side.
+const minSideBySideWidth = 100
+
+// integrationImport matches the import path of a go agent integration, e.g.
+// "github.com/newrelic/go-agent/v3/integrations/logcontext-v2/nrslog".
+var integrationImport = regexp.MustCompile(`go-agent/v3/integrations/(?:[\w.-]+/)*(nr[\w.-]+)"`)
+
+var (
+	reviewTitleStyle    = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("#1CE783"))
+	reviewDimStyle      = lipgloss.NewStyle().Foreground(lipgloss.Color("#808080"))
+	reviewErrorStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("#FF5F5F"))
+	reviewDeleteStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("#FF5F5F"))
+	reviewInsertStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("#1CE783"))
+	reviewAcceptedStyle = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("#1CE783"))
+	reviewRejectedStyle = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("#FF5F5F"))
+
+	syntaxKeywordStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#C678DD"))
+	syntaxStringStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("#98C379"))
+	syntaxNumberStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("#D19A66"))
+	syntaxCommentStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#7F848E"))
+)
+
+type reviewDecision int
+
+const (
+	undecided reviewDecision = iota
+	accepted
+	rejected
+)
+
+// reviewHunk is a single hunk of the proposed changes, and the decision the user made about it.
+type reviewHunk struct {
+	integration string
+	file        string
+	index       int        // position of the hunk in the patch of its file
+	original    *diff.Hunk // the hunk as it was proposed
+	hunk        *diff.Hunk // the hunk that will be kept if accepted, which may have been edited
+	decision    reviewDecision
+}
+
+func (h *reviewHunk) edited() bool {
+	return h.hunk != h.original
+}
+
+// reviewModel lets the user accept, reject or edit each hunk of the proposed changes. Hunks are
+// grouped by the integration that added them, and then by file.
+type reviewModel struct {
+	files     []string // every file with proposed changes, in the order they appear in the diff
+	hunks     []*reviewHunk
+	current   int
+	viewport  viewport.Model
+	width     int
+	height    int
+	status    string
+	done      bool
+	cancelled bool
+}
+
+// editedHunkMsg is sent when the editor opened for a hunk exits.
+type editedHunkMsg struct {
+	index int
+	path  string
+	err   error
+}
+
+func newReviewModel(files []parser.FileHunks, entries []report.Entry) reviewModel {
+	r := reviewModel{viewport: viewport.New(80, 20), width: 80, height: 24}
+	for _, file := range files {
+		r.files = append(r.files, file.File)
+		for i, hunk := range file.Hunks {
+			r.hunks = append(r.hunks, &reviewHunk{
+				integration: hunkIntegration(file.File, hunk, entries),
+				file:        file.File,
+				index:       i,
+				original:    hunk,
+				hunk:        hunk,
+			})
+		}
+	}
+
+	// the core instrumentation comes first, and files keep the order of the diff
+	slices.SortStableFunc(r.hunks, func(a, b *reviewHunk) int {
+		if a.integration != b.integration {
+			if a.integration == report.CoreIntegration {
+				return -1
+			}
+			if b.integration == report.CoreIntegration {
+				return 1
+			}
+			return strings.Compare(a.integration, b.integration)
+		}
+		return slices.Index(r.files, a.file) - slices.Index(r.files, b.file)
+	})
+	r.refresh()
+	return r
+}
+
+// hunkIntegration returns the name of the integration that most likely proposed a hunk: the integration of a
+// report entry for a line the hunk changes, or of a go agent integration that the hunk imports. Everything
+// else is attributed to the core instrumentation.
+func hunkIntegration(file string, hunk *diff.Hunk, entries []report.Entry) string {
+	end := hunk.OldStart + max(hunk.OldLines, 1)
+	integration := ""
+	for _, entry := range entries {
+		if entry.File != filepath.ToSlash(file) || entry.Line < hunk.OldStart || entry.Line >= end {
+			continue
+		}
+		if entry.Integration != "" && entry.Integration != report.CoreIntegration {
+			return entry.Integration
+		}
+		integration = report.CoreIntegration
+	}
+	if integration != "" {
+		return integration
+	}
+
+	for _, line := range hunk.Lines {
+		if line.Kind != diff.Insert {
+			continue
+		}
+		if match := integrationImport.FindStringSubmatch(line.Content); match != nil {
+			return match[1]
+		}
+	}
+	return report.CoreIntegration
+}
+
+// result returns the hunks to keep for every file with proposed changes, in the order of the diff.
+func (r reviewModel) result() []parser.FileHunks {
+	kept := map[string][]*reviewHunk{}
+	for _, h := range r.hunks {
+		if h.decision == accepted {
+			kept[h.file] = append(kept[h.file], h)
+		}
+	}
+
+	files := []parser.FileHunks{}
+	for _, file := range r.files {
+		hunks := kept[file]
+		slices.SortFunc(hunks, func(a, b *reviewHunk) int { return a.index - b.index })
+		fileHunks := parser.FileHunks{File: file, Hunks: []*diff.Hunk{}}
+		for _, h := range hunks {
+			fileHunks.Hunks = append(fileHunks.Hunks, h.hunk)
+		}
+		files = append(files, fileHunks)
+	}
+	return files
+}
+
+// summary describes the decisions made in the review.
+func (r reviewModel) summary() string {
+	var kept, edited, dropped int
+	for _, h := range r.hunks {
+		switch {
+		case h.decision == accepted && h.edited():
+			edited++
+			kept++
+		case h.decision == accepted:
+			kept++
+		default:
+			dropped++
+		}
+	}
+	return fmt.Sprintf("Reviewed %d change(s): %d accepted (%d edited), %d rejected\n", len(r.hunks), kept, edited, dropped)
+}
+
+func (r reviewModel) update(msg tea.Msg) (reviewModel, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		r.setSize(msg.Width, msg.Height)
+		return r, nil
+
+	case editedHunkMsg:
+		r.finishEdit(msg)
+		r.refresh()
+		return r, nil
+
+	case tea.KeyMsg:
+		r.status = ""
+		switch msg.String() {
+		case "ctrl+c":
+			r.cancelled = true
+			r.done = true
+			return r, nil
+		case "y":
+			r.decide(accepted)
+		case "n":
+			r.decide(rejected)
+		case "a":
+			r.decideFile(accepted)
+		case "d":
+			r.decideFile(rejected)
+		case "A":
+			r.decideAll(accepted)
+		case "e":
+			return r, r.edit()
+		case "right", "l", "tab":
+			r.current = min(r.current+1, len(r.hunks)-1)
+		case "left", "h", "shift+tab":
+			r.current = max(r.current-1, 0)
+		case "q", "enter":
+			r.done = true
+			return r, nil
+		default:
+			var cmd tea.Cmd
+			r.viewport, cmd = r.viewport.Update(msg)
+			return r, cmd
+		}
+		if r.remaining() == 0 {
+			r.status = "Every change has been reviewed; press enter to write the diff"
+		}
+		r.refresh()
+		return r, nil
+	}
+	return r, nil
+}
+
+// decide records a decision for the current hunk, and moves on to the next undecided one.
+func (r *reviewModel) decide(decision reviewDecision) {
+	r.hunks[r.current].decision = decision
+	r.next()
+}
+
+// decideFile records a decision for every undecided hunk in the file of the current hunk.
+func (r *reviewModel) decideFile(decision reviewDecision) {
+	file := r.hunks[r.current].file
+	for _, h := range r.hunks {
+		if h.file == file && h.decision == undecided {
+			h.decision = decision
+		}
+	}
+	r.hunks[r.current].decision = decision
+	r.next()
+}
+
+// decideAll records a decision for every undecided hunk.
+func (r *reviewModel) decideAll(decision reviewDecision) {
+	for _, h := range r.hunks {
+		if h.decision == undecided {
+			h.decision = decision
+		}
+	}
+}
+
+// next moves to the next undecided hunk, wrapping around, or stays put if there is none.
+func (r *reviewModel) next() {
+	for i := 1; i <= len(r.hunks); i++ {
+		j := (r.current + i) % len(r.hunks)
+		if r.hunks[j].decision == undecided {
+			r.current = j
+			return
+		}
+	}
+}
+
+func (r reviewModel) remaining() int {
+	count := 0
+	for _, h := range r.hunks {
+		if h.decision == undecided {
+			count++
+		}
+	}
+	return count
+}
+
+// edit opens the current hunk in the user's editor.
+func (r *reviewModel) edit() tea.Cmd {
+	h := r.hunks[r.current]
+	f, err := os.CreateTemp("", "go-easy-instrumentation-*.diff")
+	if err != nil {
+		r.status = fmt.Sprintf("could not edit the change: %v", err)
+		return nil
+	}
+	defer f.Close()
+
+	text := h.hunk.String()
+	fmt.Fprintf(f, "# Editing %s %s\n", h.file, h.original.Header())
+	fmt.Fprintln(f, "# Change the added '+' lines, delete them, or turn a '-' into a ' ' to keep a removed line.")
+	fmt.Fprintln(f, "# Lines starting with '#' are ignored.")
+	f.WriteString(text[strings.Index(text, "\n")+1:])
+
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+	args := append(strings.Fields(editor), f.Name())
+
+	index, path := r.current, f.Name()
+	return tea.ExecProcess(exec.Command(args[0], args[1:]...), func(err error) tea.Msg {
+		return editedHunkMsg{index: index, path: path, err: err}
+	})
+}
+
+// finishEdit reads back a hunk edited by the user, and accepts it if it is valid.
+func (r *reviewModel) finishEdit(msg editedHunkMsg) {
+	defer os.Remove(msg.path)
+	if msg.err != nil {
+		r.status = fmt.Sprintf("editor failed: %v", msg.err)
+		return
+	}
+	data, err := os.ReadFile(msg.path)
+	if err != nil {
+		r.status = fmt.Sprintf("could not read the edited change: %v", err)
+		return
+	}
+
+	h := r.hunks[msg.index]
+	edited, err := diff.ParseEdited(h.original, string(data))
+	if err != nil {
+		r.status = fmt.Sprintf("edit discarded: %v", err)
+		return
+	}
+	h.hunk = edited
+	r.current = msg.index
+	r.decide(accepted)
+}
+
+func (r *reviewModel) setSize(width, height int) {
+	r.width = width
+	r.height = height
+	r.viewport.Width = width
+	r.viewport.Height = max(height-5, 1)
+	r.refresh()
+}
+
+// refresh renders the current hunk into the viewport.
+func (r *reviewModel) refresh() {
+	if len(r.hunks) == 0 {
+		return
+	}
+	h := r.hunks[r.current]
+	highlight := filepath.Ext(h.file) == ".go"
+	if r.width >= minSideBySideWidth {
+		r.viewport.SetContent(renderSideBySide(h.hunk, (r.width-3)/2, highlight))
+	} else {
+		r.viewport.SetContent(renderStacked(h.hunk, highlight))
+	}
+	r.viewport.GotoTop()
+}
+
+func (r reviewModel) view() string {
+	if len(r.hunks) == 0 {
+		return ""
+	}
+	h := r.hunks[r.current]
+
+	inFile, fileTotal := 0, 0
+	for i, other := range r.hunks {
+		if other.file == h.file && other.integration == h.integration {
+			fileTotal++
+			if i <= r.current {
+				inFile++
+			}
+		}
+	}
+
+	decision := reviewDimStyle.Render("undecided")
+	switch {
+	case h.decision == accepted && h.edited():
+		decision = reviewAcceptedStyle.Render("accepted (edited)")
+	case h.decision == accepted:
+		decision = reviewAcceptedStyle.Render("accepted")
+	case h.decision == rejected:
+		decision = reviewRejectedStyle.Render("rejected")
+	}
+
+	b := strings.Builder{}
+	fmt.Fprintf(&b, "%s %s\n", reviewTitleStyle.Render(fmt.Sprintf("Change %d of %d", r.current+1, len(r.hunks))), reviewDimStyle.Render(fmt.Sprintf("(%d left to review)", r.remaining())))
+	fmt.Fprintf(&b, "%s · %s · %s %s · %s\n\n", h.integration, h.file, h.original.Header(), reviewDimStyle.Render(fmt.Sprintf("(%d of %d)", inFile, fileTotal)), decision)
+	b.WriteString(r.viewport.View())
+	b.WriteString("\n")
+	if r.status != "" {
+		b.WriteString(reviewErrorStyle.Render(r.status))
+	}
+	b.WriteString("\n")
+	b.WriteString(reviewDimStyle.Render("y accept · n reject · e edit · a/d accept/reject file · A accept all · ←/→ move · ↑/↓ scroll · enter finish · ctrl+c cancel"))
+	return b.String()
+}
+
+// reviewRow is a line of the before and after columns of a hunk. Either side may be missing.
+type reviewRow struct {
+	before, after *diff.Line
+}
+
+// alignHunk pairs the removed and added lines of a hunk, so that they can be shown side by side.
+func alignHunk(hunk *diff.Hunk) []reviewRow {
+	rows := []reviewRow{}
+	var deleted, inserted []*diff.Line
+	flush := func() {
+		for i := range max(len(deleted), len(inserted)) {
+			row := reviewRow{}
+			if i < len(deleted) {
+				row.before = deleted[i]
+			}
+			if i < len(inserted) {
+				row.after = inserted[i]
+			}
+			rows = append(rows, row)
+		}
+		deleted, inserted = nil, nil
+	}
+
+	for i := range hunk.Lines {
+		line := &hunk.Lines[i]
+		switch line.Kind {
+		case diff.Delete:
+			deleted = append(deleted, line)
+		case diff.Insert:
+			inserted = append(inserted, line)
+		default:
+			flush()
+			rows = append(rows, reviewRow{before: line, after: line})
+		}
+	}
+	flush()
+	return rows
+}
+
+func renderSideBySide(hunk *diff.Hunk, width int, highlight bool) string {
+	b := strings.Builder{}
+	b.WriteString(fitWidth(reviewTitleStyle.Render("Before"), width) + " │ " + fitWidth(reviewTitleStyle.Render("After"), width) + "\n")
+	for _, row := range alignHunk(hunk) {
+		b.WriteString(fitWidth(renderLine(row.before, highlight), width))
+		b.WriteString(" │ ")
+		b.WriteString(fitWidth(renderLine(row.after, highlight), width))
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// fitWidth truncates or pads a rendered line to exactly width cells, so that columns line up.
+func fitWidth(s string, width int) string {
+	s = lipgloss.NewStyle().MaxWidth(width).Render(s)
+	return s + strings.Repeat(" ", max(width-lipgloss.Width(s), 0))
+}
+
+func renderStacked(hunk *diff.Hunk, highlight bool) string {
+	b := strings.Builder{}
+	b.WriteString(reviewTitleStyle.Render("Before") + "\n")
+	for i := range hunk.Lines {
+		if hunk.Lines[i].Kind != diff.Insert {
+			b.WriteString(renderLine(&hunk.Lines[i], highlight) + "\n")
+		}
+	}
+	b.WriteString("\n" + reviewTitleStyle.Render("After") + "\n")
+	for i := range hunk.Lines {
+		if hunk.Lines[i].Kind != diff.Delete {
+			b.WriteString(renderLine(&hunk.Lines[i], highlight) + "\n")
+		}
+	}
+	return b.String()
+}
+
+// renderLine renders a line of a hunk with a gutter that shows whether it was removed or added.
+func renderLine(line *diff.Line, highlight bool) string {
+	if line == nil {
+		return ""
+	}
+	content := strings.ReplaceAll(strings.TrimSuffix(line.Content, "\n"), "\t", "    ")
+	if highlight {
+		content = highlightGo(content)
+	}
+	switch line.Kind {
+	case diff.Delete:
+		return reviewDeleteStyle.Render("- ") + content
+	case diff.Insert:
+		return reviewInsertStyle.Render("+ ") + content
+	}
+	return "  " + content
+}
+
+// highlightGo colors the keywords, literals and comments of a single line of Go code.
+func highlightGo(line string) string {
+	src := []byte(line)
+	fset := token.NewFileSet()
+	file := fset.AddFile("", fset.Base(), len(src))
+
+	var s scanner.Scanner
+	s.Init(file, src, func(token.Position, string) {}, scanner.ScanComments)
+
+	b := strings.Builder{}
+	last := 0
+	for {
+		pos, tok, lit := s.Scan()
+		if tok == token.EOF {
+			break
+		}
+		if tok == token.SEMICOLON && lit == "\n" {
+			continue // automatically inserted
+		}
+
+		start := file.Offset(pos)
+		text := lit
+		if text == "" {
+			text = tok.String()
+		}
+		end := min(start+len(text), len(src))
+		if start < last || start >= end {
+			continue
+		}
+
+		var style *lipgloss.Style
+		switch {
+		case tok.IsKeyword():
+			style = &syntaxKeywordStyle
+		case tok == token.STRING || tok == token.CHAR:
+			style = &syntaxStringStyle
+		case tok == token.INT || tok == token.FLOAT || tok == token.IMAG:
+			style = &syntaxNumberStyle
+		case tok == token.COMMENT:
+			style = &syntaxCommentStyle
+		}
+
+		b.Write(src[last:start])
+		if style != nil {
+			b.WriteString(style.Render(string(src[start:end])))
+		} else {
+			b.Write(src[start:end])
+		}
+		last = end
+	}
+	b.Write(src[last:])
+	return b.String()
+}
+
+// reviewProgram runs a review as a program of its own, for when no other program owns the terminal.
+type reviewProgram struct {
+	reviewModel
+}
+
+func (p reviewProgram) Init() tea.Cmd {
+	return nil
+}
+
+func (p reviewProgram) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+	p.reviewModel, cmd = p.reviewModel.update(msg)
+	if p.done {
+		return p, tea.Quit
+	}
+	return p, cmd
+}
+
+func (p reviewProgram) View() string {
+	return p.view()
+}
+
+// runReviewProgram runs the review in a full screen program, and returns the finished review.
+func runReviewProgram(r reviewModel) (reviewModel, error) {
+	final, err := tea.NewProgram(reviewProgram{r}, tea.WithAltScreen()).Run()
+	if err != nil {
+		return r, err
+	}
+	return final.(reviewProgram).reviewModel, nil
+}
+
+// reviewChanges lets the user review every hunk of the proposed changes with run, and keeps only the hunks
+// they accept. Rejected hunks are recorded in the report. It returns a summary of the review.
+func reviewChanges(manager *parser.InstrumentationManager, run func(reviewModel) (reviewModel, error)) (string, error) {
+	files, err := manager.ProposedChanges()
+	if err != nil {
+		return "", err
+	}
+	built := report.Build("", "")
+	r := newReviewModel(files, slices.Concat(built.Actions, built.Warnings))
+	if len(r.hunks) == 0 {
+		return "", nil
+	}
+
+	r, err = run(r)
+	if err != nil {
+		return "", err
+	}
+	if r.cancelled {
+		return "", errReviewCancelled
+	}
+
+	for _, h := range r.hunks {
+		if h.decision != accepted {
+			report.Add(report.Entry{
+				Code:        report.CodeHunkRejected,
+				Severity:    report.SeveritySkipped,
+				Integration: h.integration,
+				File:        h.file,
+				Line:        h.original.OldStart,
+				Message:     "change " + h.original.Header() + " was rejected during review",
+			})
+		}
+	}
+	if err := manager.KeepHunks(r.result()); err != nil {
+		return "", err
+	}
+	return r.summary(), nil
+}
@@ -0,0 +1,318 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/dave/dst/decorator"
+	"github.com/newrelic/go-easy-instrumentation/internal/config"
+	"github.com/newrelic/go-easy-instrumentation/internal/diff"
+	"github.com/newrelic/go-easy-instrumentation/internal/report"
+	"github.com/newrelic/go-easy-instrumentation/parser"
+	"golang.org/x/tools/go/packages"
+)
+
+const reviewOriginal = `package main
+
+import "fmt"
+
+func main() {
+	fmt.Println("one")
+	fmt.Println("two")
+	fmt.Println("three")
+	fmt.Println("four")
+	fmt.Println("five")
+	fmt.Println("six")
+	fmt.Println("seven")
+	fmt.Println("eight")
+	fmt.Println("nine")
+	fmt.Println("ten")
+}
+`
+
+// reviewTestFiles returns two files with proposed changes: main.go with two hunks, and handlers.go with one.
+func reviewTestFiles(t *testing.T) []parser.FileHunks {
+	t.Helper()
+	modified := strings.Replace(reviewOriginal, "import \"fmt\"\n", "import (\n\t\"fmt\"\n\n\t\"github.com/newrelic/go-agent/v3/integrations/nrgin\"\n)\n", 1)
+	modified = strings.Replace(modified, "\tfmt.Println(\"ten\")\n", "\tfmt.Println(\"ten\")\n\tfmt.Println(\"eleven\")\n", 1)
+	mainHunks, err := diff.Parse(diff.Generate("main.go", reviewOriginal, modified))
+	if err != nil {
+		t.Fatal(err)
+	}
+	handlerHunks, err := diff.Parse(diff.Generate("handlers.go", reviewOriginal, strings.Replace(reviewOriginal, "\"one\"", "\"uno\"", 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mainHunks) != 2 || len(handlerHunks) != 1 {
+		t.Fatalf("expected 2 and 1 hunks, got %d and %d", len(mainHunks), len(handlerHunks))
+	}
+	return []parser.FileHunks{{File: "main.go", Hunks: mainHunks}, {File: "handlers.go", Hunks: handlerHunks}}
+}
+
+func key(s string) tea.KeyMsg {
+	switch s {
+	case "ctrl+c":
+		return tea.KeyMsg{Type: tea.KeyCtrlC}
+	case "enter":
+		return tea.KeyMsg{Type: tea.KeyEnter}
+	}
+	return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(s)}
+}
+
+func TestHunkIntegration(t *testing.T) {
+	files := reviewTestFiles(t)
+	importHunk, printHunk := files[0].Hunks[0], files[0].Hunks[1]
+
+	entries := []report.Entry{
+		{Integration: report.CoreIntegration, File: "main.go", Line: 15},
+		{Integration: "nrnethttp", File: "handlers.go", Line: 6},
+	}
+
+	tests := []struct {
+		name string
+		file string
+		hunk *diff.Hunk
+		want string
+	}{
+		{name: "integration import", file: "main.go", hunk: importHunk, want: "nrgin"},
+		{name: "core report entry", file: "main.go", hunk: printHunk, want: report.CoreIntegration},
+		{name: "integration report entry", file: "handlers.go", hunk: files[1].Hunks[0], want: "nrnethttp"},
+		{name: "no report entry", file: "other.go", hunk: printHunk, want: report.CoreIntegration},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hunkIntegration(tt.file, tt.hunk, entries); got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestReviewModel(t *testing.T) {
+	r := newReviewModel(reviewTestFiles(t), nil)
+	if len(r.hunks) != 3 {
+		t.Fatalf("expected 3 hunks, got %d", len(r.hunks))
+	}
+
+	// core hunks come first, in the order of the diff
+	order := []string{}
+	for _, h := range r.hunks {
+		order = append(order, h.integration+" "+h.file)
+	}
+	want := []string{"core main.go", "core handlers.go", "nrgin main.go"}
+	if strings.Join(order, ",") != strings.Join(want, ",") {
+		t.Errorf("expected hunks in order %v, got %v", want, order)
+	}
+
+	// both layouts render the current hunk
+	for _, width := range []int{80, minSideBySideWidth} {
+		r.setSize(width, 40)
+		if view := r.view(); !strings.Contains(view, "Change 1 of 3") || !strings.Contains(view, "eleven") {
+			t.Errorf("unexpected view at width %d:\n%s", width, view)
+		}
+	}
+
+	for _, k := range []string{"y", "n"} {
+		r, _ = r.update(key(k))
+	}
+	if r.remaining() != 1 || r.current != 2 {
+		t.Errorf("expected to be on the last undecided hunk, got %d remaining at %d", r.remaining(), r.current)
+	}
+	r, _ = r.update(key("y"))
+	if !strings.Contains(r.status, "Every change has been reviewed") {
+		t.Errorf("expected a status that the review is complete, got %q", r.status)
+	}
+	r, _ = r.update(key("enter"))
+	if !r.done || r.cancelled {
+		t.Fatal("expected the review to be done")
+	}
+
+	result := r.result()
+	if len(result) != 2 || result[0].File != "main.go" || result[1].File != "handlers.go" {
+		t.Fatalf("expected a result for every file in the order of the diff, got %+v", result)
+	}
+	if len(result[0].Hunks) != 2 || len(result[1].Hunks) != 0 {
+		t.Errorf("expected both main.go hunks to be kept and the handlers.go hunk to be rejected, got %d and %d", len(result[0].Hunks), len(result[1].Hunks))
+	}
+	if got := r.summary(); got != "Reviewed 3 change(s): 2 accepted (0 edited), 1 rejected\n" {
+		t.Errorf("unexpected summary %q", got)
+	}
+}
+
+func TestReviewModelFileAndCancel(t *testing.T) {
+	r := newReviewModel(reviewTestFiles(t), nil)
+
+	// accepting the file of the first hunk only accepts hunks in main.go
+	r, _ = r.update(key("a"))
+	for _, h := range r.hunks {
+		if want := h.file == "main.go"; (h.decision == accepted) != want {
+			t.Errorf("unexpected decision %v for hunk in %s", h.decision, h.file)
+		}
+	}
+
+	r, _ = r.update(key("ctrl+c"))
+	if !r.done || !r.cancelled {
+		t.Error("expected the review to be cancelled")
+	}
+}
+
+func TestReviewModelEdit(t *testing.T) {
+	r := newReviewModel(reviewTestFiles(t), nil)
+	h := r.hunks[0]
+
+	text := h.original.String()
+	path := filepath.Join(t.TempDir(), "edit.diff")
+	edited := strings.Replace(text[strings.Index(text, "\n")+1:], "eleven", "twelve", 1)
+	if err := os.WriteFile(path, []byte(edited), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	r, _ = r.update(editedHunkMsg{index: 0, path: path})
+	if h.decision != accepted || !h.edited() {
+		t.Fatalf("expected the edited hunk to be accepted, got status %q", r.status)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("expected the edited file to be removed")
+	}
+
+	// an edit that changes the original lines is discarded
+	path = filepath.Join(t.TempDir(), "edit.diff")
+	if err := os.WriteFile(path, []byte(strings.Replace(edited, "\"ten\"", "\"zehn\"", 1)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	h.decision = undecided
+	r, _ = r.update(editedHunkMsg{index: 0, path: path})
+	if h.decision != undecided || !strings.Contains(r.status, "edit discarded") {
+		t.Errorf("expected the edit to be discarded, got decision %v and status %q", h.decision, r.status)
+	}
+}
+
+func TestAlignHunk(t *testing.T) {
+	hunk := &diff.Hunk{Lines: []diff.Line{
+		{Kind: diff.Context, Content: "a\n"},
+		{Kind: diff.Delete, Content: "b\n"},
+		{Kind: diff.Insert, Content: "c\n"},
+		{Kind: diff.Insert, Content: "d\n"},
+		{Kind: diff.Context, Content: "e\n"},
+	}}
+
+	rows := alignHunk(hunk)
+	got := []string{}
+	for _, row := range rows {
+		before, after := "_", "_"
+		if row.before != nil {
+			before = strings.TrimSpace(row.before.Content)
+		}
+		if row.after != nil {
+			after = strings.TrimSpace(row.after.Content)
+		}
+		got = append(got, before+after)
+	}
+	if want := []string{"aa", "bc", "_d", "ee"}; strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("expected rows %v, got %v", want, got)
+	}
+}
+
+func TestHighlightGo(t *testing.T) {
+	// without a color terminal, highlighting must leave the code unchanged
+	for _, line := range []string{
+		`	nrTxn := NewRelicAgent.StartTransaction("main") // start`,
+		`func main() {`,
+		"	query := `SELECT 1`",
+		`	x := 1.5 + 'a'`,
+		`	"unterminated`,
+		"",
+	} {
+		if got := highlightGo(line); got != line {
+			t.Errorf("expected %q, got %q", line, got)
+		}
+	}
+}
+
+// reviewTestManager instruments a temporary application with the given main.go, and returns its manager.
+func reviewTestManager(t *testing.T, mainSource string) *parser.InstrumentationManager {
+	t.Helper()
+	return instrumentTestApp(t, writeTestApp(t, mainSource))
+}
+
+// writeTestApp writes an application with the given main.go to a temporary directory, and returns the directory.
+func writeTestApp(t *testing.T, mainSource string) string {
+	t.Helper()
+	packagePath := t.TempDir()
+	files := map[string]string{
+		"go.mod":  "module example.com/app\n\ngo 1.22\n",
+		"main.go": mainSource,
+	}
+	for name, contents := range files {
+		if err := os.WriteFile(filepath.Join(packagePath, name), []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return packagePath
+}
+
+// instrumentTestApp instruments the application at packagePath, and returns its manager.
+func instrumentTestApp(t *testing.T, packagePath string) *parser.InstrumentationManager {
+	t.Helper()
+	pkgs, err := decorator.Load(&packages.Config{Dir: packagePath, Mode: LoadMode, Tests: true}, defaultPackageName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.Default()
+	manager := parser.NewInstrumentationManager(pkgs, cfg.AppName, cfg.AgentVariableName, filepath.Join(t.TempDir(), "output.diff"), packagePath)
+	registerIntegrations(manager, cfg)
+	for _, step := range []func() error{manager.TracePackageCalls, manager.ScanApplication, manager.InstrumentApplication} {
+		if err := step(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return manager
+}
+
+func TestReviewChanges(t *testing.T) {
+	manager := reviewTestManager(t, "package main\n\nimport \"errors\"\n\nfunc work() error {\n\treturn errors.New(\"failed\")\n}\n\nfunc main() {\n\twork()\n}\n")
+
+	// reject everything
+	summary, err := reviewChanges(manager, func(r reviewModel) (reviewModel, error) {
+		r.decideAll(rejected)
+		r.done = true
+		return r, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(summary, "0 accepted") {
+		t.Errorf("unexpected summary %q", summary)
+	}
+	proposed, err := manager.ProposedChanges()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(proposed) != 0 {
+		t.Errorf("expected no changes after rejecting every hunk, got %+v", proposed)
+	}
+
+	_, err = reviewChanges(manager, func(r reviewModel) (reviewModel, error) {
+		t.Error("expected no review when there are no changes")
+		return r, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReviewChangesCancelled(t *testing.T) {
+	manager := reviewTestManager(t, "package main\n\nfunc main() {}\n")
+
+	_, err := reviewChanges(manager, func(r reviewModel) (reviewModel, error) {
+		r.cancelled = true
+		r.done = true
+		return r, nil
+	})
+	if err != errReviewCancelled {
+		t.Errorf("expected %v, got %v", errReviewCancelled, err)
+	}
+}
@@ -11,6 +11,7 @@ var rootCmd = &cobra.Command{
 	Version: AppVersion,
 	Args:    cobra.MaximumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
+		runningCommand = cmd
 		if len(args) == 0 {
 			runInteractiveMode(cmd, args)
 			return
@@ -19,7 +20,15 @@ var rootCmd = &cobra.Command{
 	},
 }
 
-var debug bool
+var (
+	debug           bool
+	includePatterns string
+	excludeDirs     string
+	configFile      string
+
+	// runningCommand is the command being run, used to tell which flags were set on the command line
+	runningCommand *cobra.Command
+)
 
 func Execute() {
 	if err := rootCmd.Execute(); err != nil {
@@ -29,4 +38,7 @@ func Execute() {
 
 func init() {
 	rootCmd.PersistentFlags().BoolVarP(&debug, "debug", "d", false, "enable debugging output")
+	rootCmd.PersistentFlags().StringVarP(&configFile, "config", "c", "", "path to the project configuration file; defaults to the first .go-easy-instrumentation.yaml found in the application directory or its parents")
+	rootCmd.PersistentFlags().StringVar(&includePatterns, "include", "", "comma-separated list of package, file or function patterns to instrument; everything is instrumented if empty")
+	rootCmd.PersistentFlags().StringVarP(&excludeDirs, "exclude", "e", "", "comma-separated list of folders, or package, file or function patterns to exclude from instrumentation")
 }
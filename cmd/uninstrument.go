@@ -0,0 +1,164 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dave/dst/decorator"
+	"github.com/newrelic/go-easy-instrumentation/integrations/nragent"
+	nrecho_v3 "github.com/newrelic/go-easy-instrumentation/integrations/nrecho-v3"
+	nrecho_v4 "github.com/newrelic/go-easy-instrumentation/integrations/nrecho-v4"
+	"github.com/newrelic/go-easy-instrumentation/integrations/nrfasthttp"
+	"github.com/newrelic/go-easy-instrumentation/integrations/nrfiber"
+	"github.com/newrelic/go-easy-instrumentation/integrations/nrgin"
+	"github.com/newrelic/go-easy-instrumentation/integrations/nrgochi"
+	"github.com/newrelic/go-easy-instrumentation/integrations/nrgorilla"
+	"github.com/newrelic/go-easy-instrumentation/integrations/nrgrpc"
+	"github.com/newrelic/go-easy-instrumentation/integrations/nrhttprouter"
+	"github.com/newrelic/go-easy-instrumentation/integrations/nrlogrus"
+	"github.com/newrelic/go-easy-instrumentation/integrations/nrmysql"
+	"github.com/newrelic/go-easy-instrumentation/integrations/nrnethttp"
+	"github.com/newrelic/go-easy-instrumentation/integrations/nrpgx5"
+	"github.com/newrelic/go-easy-instrumentation/integrations/nrpq"
+	"github.com/newrelic/go-easy-instrumentation/integrations/nrredis"
+	"github.com/newrelic/go-easy-instrumentation/integrations/nrslog"
+	"github.com/newrelic/go-easy-instrumentation/internal/comment"
+	"github.com/newrelic/go-easy-instrumentation/internal/config"
+	"github.com/newrelic/go-easy-instrumentation/parser"
+	"github.com/spf13/cobra"
+	"golang.org/x/tools/go/packages"
+)
+
+const defaultUninstrumentDiffFileName = "new-relic-uninstrumentation.diff"
+
+// The functions that remove the instrumentation of every integration. Instrumentation is removed for every
+// integration, even those disabled in the project configuration, since an application may have been instrumented
+// with a different configuration. The integrations come first, so that the core functions do not remove the
+// values they pass around before they are recognized.
+var uninstrumentFunctions = []parser.UninstrumentFunction{
+	nrnethttp.UnwrapHandleFunction,
+	nrnethttp.RemoveRoundTripper,
+	nrnethttp.RemoveRequestContext,
+	nrnethttp.RemoveCannotTraceComment,
+	nrfasthttp.UnwrapRequestHandler,
+	nrfasthttp.RemoveDo,
+	nrgrpc.RemoveGrpcInterceptors,
+	nrgin.RemoveGinMiddleware,
+	nrecho_v4.RemoveEchoMiddleware,
+	nrecho_v3.RemoveEchoMiddleware,
+	nrgochi.RemoveChiMiddleware,
+	nrgorilla.RemoveGorillaMiddleware,
+	nrhttprouter.RemoveNrHttprouter,
+	nrfiber.RemoveFiberMiddleware,
+	nrslog.RemoveSlogHandler,
+	nrlogrus.RemoveLogrusFormatter,
+	nrpq.RemovePQHandler,
+	nrpgx5.RemovePgxTracer,
+	nrmysql.RemoveMySQLHandler,
+	nrredis.RemoveRedisHook,
+	nragent.RemoveAgent,
+	parser.RemoveSegments,
+	parser.RemoveNoticeErrors,
+	parser.RemoveTransactionContexts,
+}
+
+var uninstrumentDiffFile string
+
+var uninstrumentCmd = &cobra.Command{
+	Use:   "uninstrument <path>",
+	Short: "remove instrumentation",
+	Long:  "remove the New Relic instrumentation from an application's source files and write these changes to a diff file",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		runningCommand = cmd
+		Uninstrument(args[0])
+	},
+}
+
+// Uninstrument removes the New Relic instrumentation from the application at packagePath, and writes the
+// changes to a diff file.
+func Uninstrument(packagePath string) {
+	if packagePath == "" {
+		cobra.CheckErr("path argument cannot be empty")
+	}
+	if _, err := os.Stat(packagePath); err != nil {
+		cobra.CheckErr(fmt.Errorf("path argument \"%s\" is invalid: %v", packagePath, err))
+	}
+	cfg, err := loadConfig(packagePath)
+	cobra.CheckErr(err)
+
+	outputFilePath := uninstrumentDiffFile
+	if outputFilePath == "" {
+		outputFilePath = filepath.Join(packagePath, defaultUninstrumentDiffFileName)
+	}
+	outputFile, err := setOutputFilePath(outputFilePath, packagePath)
+	cobra.CheckErr(err)
+	if debug {
+		comment.EnableConsolePrinter(packagePath)
+	}
+
+	fmt.Printf("Removing instrumentation from %s\n", packagePath)
+	fmt.Printf("Output file: %s\n\n", outputFile)
+
+	summary, err := uninstrumentPackages(packagePath, outputFile, cfg)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Print(summary)
+	fmt.Printf("\nDone! Changes written to: %s\nTip: Apply these changes with: git apply %s, then run go mod tidy to remove the New Relic modules\n", outputFile, outputFile)
+}
+
+// uninstrumentPackages loads the Go packages at packagePath, removes their instrumentation, and writes the
+// resulting diff to outputFile. It returns a summary of the instrumentation that could not be removed.
+func uninstrumentPackages(packagePath, outputFile string, cfg *config.Config) (string, error) {
+	fmt.Println(" -> Loading packages...")
+	pkgs, err := decorator.Load(&packages.Config{Dir: packagePath, Mode: LoadMode, Tests: true}, defaultPackageName)
+	if err != nil {
+		return "", fmt.Errorf("loading packages: %w", err)
+	}
+
+	manager := parser.NewInstrumentationManager(pkgs, cfg.AppName, cfg.AgentVariableName, outputFile, packagePath)
+	if err := configureManager(manager, cfg); err != nil {
+		return "", err
+	}
+	manager.LoadUninstrumentFunctions(uninstrumentFunctions...)
+
+	var warnings []string
+	steps := []struct {
+		desc string
+		fn   func() error
+	}{
+		{"Creating diff file", manager.CreateDiffFile},
+		{"Removing instrumentation", func() (err error) {
+			warnings, err = manager.RemoveInstrumentation()
+			return err
+		}},
+		{"Writing diff file", func() error {
+			comment.WriteAll()
+			return manager.WriteDiff(func(msg string) {})
+		}},
+	}
+
+	for _, step := range steps {
+		if err := step.fn(); err != nil {
+			return "", fmt.Errorf("%s: %w", step.desc, err)
+		}
+	}
+
+	summary := strings.Builder{}
+	for _, warning := range warnings {
+		fmt.Fprintf(&summary, "Warning: %s\n", warning)
+	}
+	return summary.String(), nil
+}
+
+func init() {
+	uninstrumentCmd.Flags().StringVarP(&uninstrumentDiffFile, "output", "o", defaultOutputFilePath, "specify diff output file path")
+	cobra.MarkFlagFilename(uninstrumentCmd.Flags(), "output", ".diff") // for file completion
+
+	rootCmd.AddCommand(uninstrumentCmd)
+}
@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/newrelic/go-easy-instrumentation/internal/config"
+	"github.com/newrelic/go-easy-instrumentation/internal/diff"
+)
+
+const uninstrumentOriginal = `package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+func work(n int) error {
+	if n > 3 {
+		return errors.New("too big")
+	}
+	fmt.Println("working", n)
+	return nil
+}
+
+func index(w http.ResponseWriter, r *http.Request) {
+	if err := work(1); err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	w.Write([]byte("hi"))
+}
+
+func main() {
+	err := work(1)
+	if err != nil {
+		fmt.Println(err)
+	}
+
+	http.Get("http://example.com")
+
+	http.HandleFunc("/", index)
+	http.ListenAndServe(":8080", nil)
+}
+`
+
+func TestUninstrumentPackages(t *testing.T) {
+	packagePath := writeTestApp(t, uninstrumentOriginal)
+	files, err := instrumentTestApp(t, packagePath).ProposedChanges()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("expected changes to main.go only, got %+v", files)
+	}
+	instrumented, err := diff.Apply(uninstrumentOriginal, files[0].Hunks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(packagePath, "main.go"), []byte(instrumented), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	outputFile := filepath.Join(t.TempDir(), "output.diff")
+	summary, err := uninstrumentPackages(packagePath, outputFile, config.Default())
+	if err != nil {
+		t.Fatalf("uninstrumentPackages failed: %v", err)
+	}
+	if summary != "" {
+		t.Errorf("expected all of the instrumentation to be removed, got %q", summary)
+	}
+
+	patch, err := os.ReadFile(outputFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hunks, err := diff.Parse(string(patch))
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored, err := diff.Apply(instrumented, hunks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored != uninstrumentOriginal {
+		t.Errorf("expected the original application to be restored from:\n%s\ngot:\n%s", instrumented, restored)
+	}
+}
+
+func TestUninstrumentPackages_Remaining(t *testing.T) {
+	packagePath := writeTestApp(t, "package main\n\nimport \"github.com/newrelic/go-agent/v3/newrelic\"\n\nvar app *newrelic.Application\n\nfunc main() {}\n")
+
+	summary, err := uninstrumentPackages(packagePath, filepath.Join(t.TempDir(), "output.diff"), config.Default())
+	if err != nil {
+		t.Fatalf("uninstrumentPackages failed: %v", err)
+	}
+	if !strings.Contains(summary, "main.go:5: New Relic instrumentation could not be removed automatically") {
+		t.Errorf("expected a warning for the package variable, got %q", summary)
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/dave/dst/dstutil"
 	"github.com/newrelic/go-easy-instrumentation/internal/codegen"
 	"github.com/newrelic/go-easy-instrumentation/internal/comment"
+	"github.com/newrelic/go-easy-instrumentation/internal/report"
 	"github.com/newrelic/go-easy-instrumentation/internal/util"
 	"github.com/newrelic/go-easy-instrumentation/parser"
 	"github.com/newrelic/go-easy-instrumentation/parser/tracestate"
@@ -84,6 +85,15 @@ func findErrorVariable(stmt *dst.AssignStmt, pkg *decorator.Package) dst.Expr {
 	return nil
 }
 
+// PreInstrumentationTracingFunctions
+//////////////////////////////////////////////
+
+// DetectApplication looks for a function that creates the agent application. It is found while scanning,
+// so that it is known even if the function is already instrumented, and is left out of the instrumentation.
+func DetectApplication(manager *parser.InstrumentationManager, c *dstutil.Cursor) {
+	checkFuncDeclForApplication(manager, c.Node())
+}
+
 // StatelessTracingFunctions
 //////////////////////////////////////////////
 
@@ -94,17 +104,28 @@ func InstrumentMain(manager *parser.InstrumentationManager, c *dstutil.Cursor) {
 		// Check functions return signatures for newrelic.Application and if it exists, load it into manager.SetupFunc()
 		// We don't want to propagate tracing into the setup function so later on in our trace function we will ignore it
 		checkForExistingApplicationInFunctions(manager, c)
-		if decl.Name.Name == "main" {
+		// a library never initializes the agent, even if it has a main function, such as an example
+		if decl.Name.Name == "main" && !manager.Library() {
 			if !checkForExistingApplicationInMain(manager, decl) {
 				comment.Debug(manager.GetDecoratorPackage(), decl, "Injecting New Relic agent initialization into main()")
-				agentDecl := InitializeAgent(manager.AppName(), manager.AgentVariableName())
+				appName := manager.AppName()
+				message := "initialized the New Relic agent in main"
+				if appName != "" {
+					message += fmt.Sprintf(" with application name %q", appName)
+				}
+				report.Action(manager.GetDecoratorPackage(), decl, report.CoreIntegration, report.KindAgent, message)
+				agentDecl := InitializeAgent(appName, manager.AgentVariableName(), manager.AgentConfigOptions()...)
 				decl.Body.List = append(agentDecl, decl.Body.List...)
 				comment.Debug(manager.GetDecoratorPackage(), decl, "Injecting agent shutdown into main()")
 				decl.Body.List = append(decl.Body.List, ShutdownAgent(manager.AgentVariableName()))
 				// add go-agent/v3/newrelic to imports
 				manager.AddImport(codegen.NewRelicAgentImportPath)
 			}
-			newMain, _ := parser.TraceFunction(manager, decl, tracestate.Main(manager.AgentVariableName()))
+			state := tracestate.Main(manager.AgentVariableName())
+			if definesTransaction(decl, manager.TransactionVariableName()) {
+				state.DefineTransaction()
+			}
+			newMain, _ := parser.TraceFunction(manager, decl, state)
 
 			// this will skip the tracing of this function in the outer tree walking algorithm
 			c.Replace(newMain)
@@ -112,6 +133,42 @@ func InstrumentMain(manager *parser.InstrumentationManager, c *dstutil.Cursor) {
 	}
 }
 
+// InstrumentLibraryEntryPoint traces the entry points of a library, when the application is instrumented as one.
+// The exported functions of a library that take a context or an HTTP request are called by the applications that
+// use it, which may have added a transaction to its context, so the transaction is taken from it and a segment is
+// added to the function. When no transaction was added, the transaction is nil, and the instrumentation does nothing.
+func InstrumentLibraryEntryPoint(manager *parser.InstrumentationManager, c *dstutil.Cursor) {
+	decl, ok := c.Node().(*dst.FuncDecl)
+	if !ok || !manager.Library() || manager.IsFunctionTraced(decl) {
+		return
+	}
+	tracing, ok := manager.LibraryEntryPoint(decl)
+	if !ok {
+		return
+	}
+
+	comment.Debug(manager.GetDecoratorPackage(), decl, fmt.Sprintf("Instrumenting library entry point: %s", decl.Name.Name))
+	report.Action(manager.GetDecoratorPackage(), decl, report.CoreIntegration, report.KindTransaction, fmt.Sprintf("used the transaction of the context passed to library entry point %s", decl.Name.Name))
+	parser.TraceFunction(manager, decl, tracing)
+}
+
+// definesTransaction returns true if the body of main already declares the transaction variable named txnName,
+// which is the case when main was instrumented by an earlier run.
+func definesTransaction(decl *dst.FuncDecl, txnName string) bool {
+	for _, stmt := range decl.Body.List {
+		assign, ok := stmt.(*dst.AssignStmt)
+		if !ok || assign.Tok != token.DEFINE {
+			continue
+		}
+		for _, lhs := range assign.Lhs {
+			if ident, ok := lhs.(*dst.Ident); ok && ident.Name == txnName {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // checkForExistingApplicationInFunctions calls functions related to application detection
 // It inspects the AST nodes within the cursor's scope to find any references to the New Relic application.
 func checkForExistingApplicationInFunctions(manager *parser.InstrumentationManager, c *dstutil.Cursor) {
@@ -303,6 +360,7 @@ func NoticeError(manager *parser.InstrumentationManager, stmt dst.Stmt, c *dstut
 				}
 
 				comment.Debug(pkg, stmt, "Capturing error return value for NoticeError")
+				report.Action(pkg, stmt, report.CoreIntegration, report.KindNoticeError, "captured the error returned by "+util.WriteExpr(call.Fun, pkg))
 
 				// add an empty line beore the return statement for readability
 				nodeVal.Decorations().Before = dst.EmptyLine
@@ -323,6 +381,7 @@ func NoticeError(manager *parser.InstrumentationManager, stmt dst.Stmt, c *dstut
 			if cachedExpr != nil && util.AssertExpressionEqual(result, cachedExpr) {
 				manager.ErrorCache().Clear()
 				comment.Debug(pkg, stmt, "Injecting error nil check with NoticeError before return")
+				report.Action(pkg, stmt, report.CoreIntegration, report.KindNoticeError, fmt.Sprintf("added NoticeError for %s before return", util.WriteExpr(cachedExpr, pkg)))
 				capture := codegen.IfErrorNotNilNoticeError(cachedExpr, tracing.TransactionVariable())
 				capture.Decs.Before = dst.EmptyLine
 				c.InsertBefore(capture)
@@ -341,6 +400,7 @@ func NoticeError(manager *parser.InstrumentationManager, stmt dst.Stmt, c *dstut
 					stmtBlock = nodeVal.Body.List[0]
 				}
 				comment.Debug(pkg, stmt, "Injecting NoticeError into error handling block")
+				report.Action(pkg, stmt, report.CoreIntegration, report.KindNoticeError, fmt.Sprintf("added NoticeError for %s to error handling block", util.WriteExpr(errExpr, pkg)))
 				nodeVal.Body.List = append([]dst.Stmt{codegen.NoticeError(errExpr, tracing.TransactionVariable(), stmtBlock)}, nodeVal.Body.List...)
 				manager.ErrorCache().Clear()
 				return true
@@ -366,6 +426,7 @@ func NoticeError(manager *parser.InstrumentationManager, stmt dst.Stmt, c *dstut
 		if cachedErrExpr != nil {
 			stmt := manager.ErrorCache().GetStatement()
 			comment.Warn(pkg, stmt, stmt, fmt.Sprintf("Unchecked Error \"%s\", please consult New Relic documentation on error capture", util.WriteExpr(cachedErrExpr, pkg)))
+			report.Warning(pkg, stmt, report.CoreIntegration, report.CodeUncheckedError, fmt.Sprintf("unchecked error %q can not be captured", util.WriteExpr(cachedErrExpr, pkg)))
 			manager.ErrorCache().Clear()
 		}
 
@@ -388,3 +449,60 @@ func NoticeError(manager *parser.InstrumentationManager, stmt dst.Stmt, c *dstut
 	}
 	return false
 }
+
+// Uninstrument Functions
+//////////////////////////////////////////////
+
+// isPanicOnError returns true if the statement was created by PanicOnError for the given error variable.
+func isPanicOnError(stmt dst.Stmt, errorVariableName string) bool {
+	ifStmt, ok := stmt.(*dst.IfStmt)
+	if !ok || ifStmt.Init != nil || ifStmt.Else != nil || len(ifStmt.Body.List) != 1 {
+		return false
+	}
+	cond, ok := ifStmt.Cond.(*dst.BinaryExpr)
+	if !ok || cond.Op != token.NEQ {
+		return false
+	}
+	if ident, ok := cond.X.(*dst.Ident); !ok || ident.Name != errorVariableName {
+		return false
+	}
+	exprStmt, ok := ifStmt.Body.List[0].(*dst.ExprStmt)
+	if !ok {
+		return false
+	}
+	call, ok := exprStmt.X.(*dst.CallExpr)
+	if !ok {
+		return false
+	}
+	fun, ok := call.Fun.(*dst.Ident)
+	return ok && fun.Name == "panic"
+}
+
+// RemoveAgent removes the check for an error starting the agent that follows the call to newrelic.NewApplication.
+// The agent itself is removed along with the other New Relic variables once nothing uses it anymore.
+func RemoveAgent(manager *parser.InstrumentationManager, c *dstutil.Cursor) bool {
+	assign, ok := c.Node().(*dst.AssignStmt)
+	if !ok || len(assign.Lhs) != 2 || len(assign.Rhs) != 1 {
+		return false
+	}
+	block, ok := c.Parent().(*dst.BlockStmt)
+	if !ok || c.Index() < 0 || c.Index()+1 >= len(block.List) {
+		return false
+	}
+	call, ok := assign.Rhs[0].(*dst.CallExpr)
+	if !ok {
+		return false
+	}
+	if fun, ok := call.Fun.(*dst.Ident); !ok || fun.Name != "NewApplication" || fun.Path != NewRelicAgentImportPath {
+		return false
+	}
+	errIdent, ok := assign.Lhs[1].(*dst.Ident)
+	check := block.List[c.Index()+1]
+	if !ok || !isPanicOnError(check, errIdent.Name) {
+		return false
+	}
+
+	assign.Lhs[1] = dst.NewIdent("_")
+	block.List = parser.DeleteStatements(block.List, func(stmt dst.Stmt) bool { return stmt == check })
+	return true
+}
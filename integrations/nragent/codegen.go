@@ -2,8 +2,10 @@ package nragent
 
 import (
 	"go/token"
+	"strconv"
 
 	"github.com/dave/dst"
+	"github.com/newrelic/go-easy-instrumentation/internal/config"
 )
 
 const (
@@ -12,17 +14,31 @@ const (
 	AgentErrorVariableName  string = "agentInitError"
 )
 
-func InitializeAgent(AppName, AgentVariableName string) []dst.Stmt {
-	newappArgs := []dst.Expr{
-		&dst.CallExpr{
+// InitializeAgent creates the statements that start the agent. Config options are passed before
+// ConfigFromEnvironment, so that the environment can still override them.
+func InitializeAgent(AppName, AgentVariableName string, options ...config.AgentConfigOption) []dst.Stmt {
+	newappArgs := []dst.Expr{}
+	for _, option := range options {
+		newappArgs = append(newappArgs, &dst.CallExpr{
 			Fun: &dst.Ident{
 				Path: NewRelicAgentImportPath,
-				Name: "ConfigFromEnvironment",
+				Name: option.Function,
 			},
-		},
+			Args: []dst.Expr{
+				&dst.Ident{
+					Name: strconv.FormatBool(option.Value),
+				},
+			},
+		})
 	}
+	newappArgs = append(newappArgs, &dst.CallExpr{
+		Fun: &dst.Ident{
+			Path: NewRelicAgentImportPath,
+			Name: "ConfigFromEnvironment",
+		},
+	})
 	if AppName != "" {
-		AppName = "\"" + AppName + "\""
+		AppName = strconv.Quote(AppName)
 		newappArgs = append([]dst.Expr{&dst.CallExpr{
 			Fun: &dst.Ident{
 				Path: NewRelicAgentImportPath,
@@ -7,6 +7,7 @@ import (
 
 	"github.com/dave/dst"
 	"github.com/newrelic/go-easy-instrumentation/integrations/nragent"
+	"github.com/newrelic/go-easy-instrumentation/internal/config"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -14,6 +15,7 @@ func TestInitializeAgent(t *testing.T) {
 	type args struct {
 		AppName           string
 		AgentVariableName string
+		Options           []config.AgentConfigOption
 	}
 	tests := []struct {
 		name string
@@ -99,14 +101,79 @@ func TestInitializeAgent(t *testing.T) {
 				},
 			}, nragent.PanicOnError(nragent.AgentErrorVariableName)},
 		},
+		{
+			name: "Test create agent AST with config options",
+			args: args{
+				AgentVariableName: "testAgent",
+				Options: []config.AgentConfigOption{
+					{Function: "ConfigDistributedTracerEnabled", Value: true},
+					{Function: "ConfigAppLogForwardingEnabled", Value: false},
+				},
+			},
+			want: []dst.Stmt{&dst.AssignStmt{
+				Lhs: []dst.Expr{
+					&dst.Ident{
+						Name: "testAgent",
+					},
+					&dst.Ident{
+						Name: nragent.AgentErrorVariableName,
+					},
+				},
+				Tok: token.DEFINE,
+				Rhs: []dst.Expr{
+					&dst.CallExpr{
+						Fun: &dst.Ident{
+							Name: "NewApplication",
+							Path: nragent.NewRelicAgentImportPath,
+						},
+						Args: []dst.Expr{
+							&dst.CallExpr{
+								Fun: &dst.Ident{
+									Path: nragent.NewRelicAgentImportPath,
+									Name: "ConfigDistributedTracerEnabled",
+								},
+								Args: []dst.Expr{
+									&dst.Ident{
+										Name: "true",
+									},
+								},
+							},
+							&dst.CallExpr{
+								Fun: &dst.Ident{
+									Path: nragent.NewRelicAgentImportPath,
+									Name: "ConfigAppLogForwardingEnabled",
+								},
+								Args: []dst.Expr{
+									&dst.Ident{
+										Name: "false",
+									},
+								},
+							},
+							&dst.CallExpr{
+								Fun: &dst.Ident{
+									Path: nragent.NewRelicAgentImportPath,
+									Name: "ConfigFromEnvironment",
+								},
+							},
+						},
+					},
+				},
+			}, nragent.PanicOnError(nragent.AgentErrorVariableName)},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			assert.Equal(t, tt.want, nragent.InitializeAgent(tt.args.AppName, tt.args.AgentVariableName))
+			assert.Equal(t, tt.want, nragent.InitializeAgent(tt.args.AppName, tt.args.AgentVariableName, tt.args.Options...))
 		})
 	}
 }
 
+func TestInitializeAgent_AppNameQuoted(t *testing.T) {
+	stmts := nragent.InitializeAgent(`my "app" \ api`, "testAgent")
+	configAppName := stmts[0].(*dst.AssignStmt).Rhs[0].(*dst.CallExpr).Args[0].(*dst.CallExpr)
+	assert.Equal(t, `"my \"app\" \\ api"`, configAppName.Args[0].(*dst.BasicLit).Value)
+}
+
 func TestShutdownAgent(t *testing.T) {
 	type args struct {
 		AgentVariableName string
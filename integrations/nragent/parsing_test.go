@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/dave/dst"
+	"github.com/dave/dst/dstutil"
 	"github.com/newrelic/go-easy-instrumentation/integrations/nragent"
 	"github.com/newrelic/go-easy-instrumentation/integrations/nrnethttp"
 	"github.com/newrelic/go-easy-instrumentation/internal/codegen"
@@ -549,7 +550,7 @@ func main() {
 `,
 		},
 		{
-			name: "ignore async functions in main",
+			name: "start background transaction for goroutine in main",
 			code: `package main
 
 import "net/http"
@@ -574,9 +575,12 @@ import (
 	"github.com/newrelic/go-agent/v3/newrelic"
 )
 
-func myFunc() {
+func myFunc(nrTxn *newrelic.Transaction) {
+	defer nrTxn.End()
+
 	_, err := http.Get("http://example.com")
 	if err != nil {
+		nrTxn.NoticeError(err)
 		panic(err)
 	}
 }
@@ -587,9 +591,8 @@ func main() {
 		panic(agentInitError)
 	}
 
-	// NR INFO: go-easy-instrumentation doesn't support tracing goroutines in a main method; please instrument manually.
-	// https://docs.newrelic.com/docs/apm/agents/go-agent/instrumentation/instrument-go-transactions/#goroutines
-	go myFunc()
+	nrTxn := NewRelicAgent.StartTransaction("myFunc")
+	go myFunc(nrTxn)
 
 	NewRelicAgent.Shutdown(5 * time.Second)
 }
@@ -677,3 +680,194 @@ func main() {
 		})
 	}
 }
+
+func TestInstrumentLibraryEntryPoint(t *testing.T) {
+	tests := []struct {
+		name   string
+		code   string
+		expect string
+	}{
+		{
+			name: "exported functions take the transaction from their context",
+			code: `package store
+
+import (
+	"context"
+	"database/sql"
+)
+
+type Store struct {
+	db *sql.DB
+}
+
+func (s *Store) Name(ctx context.Context, id string) (string, error) {
+	var name string
+	err := s.db.QueryRowContext(ctx, "SELECT name FROM users WHERE id = $1", id).Scan(&name)
+	if err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+func (s *Store) Count(ctx context.Context) int {
+	return s.count(ctx)
+}
+
+func (s *Store) count(ctx context.Context) int {
+	return 0
+}
+
+func Ping() error {
+	return nil
+}
+`,
+			expect: `package store
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+type Store struct {
+	db *sql.DB
+}
+
+func (s *Store) Name(ctx context.Context, id string) (string, error) {
+	nrTxn := newrelic.FromContext(ctx)
+	defer nrTxn.StartSegment("Name").End()
+
+	var name string
+	err := s.db.QueryRowContext(ctx, "SELECT name FROM users WHERE id = $1", id).Scan(&name)
+	if err != nil {
+		nrTxn.NoticeError(err)
+		return "", err
+	}
+	return name, nil
+}
+
+func (s *Store) Count(ctx context.Context) int {
+	nrTxn := newrelic.FromContext(ctx)
+	defer nrTxn.StartSegment("Count").End()
+
+	return s.count(ctx)
+}
+
+func (s *Store) count(ctx context.Context) int {
+	nrTxn := newrelic.FromContext(ctx)
+	defer nrTxn.StartSegment("count").End()
+
+	return 0
+}
+
+func Ping() error {
+	return nil
+}
+`,
+		},
+		{
+			name: "exported HTTP handlers take the transaction from the context of their request",
+			code: `package handlers
+
+import (
+	"context"
+	"net/http"
+)
+
+func Hello(w http.ResponseWriter, r *http.Request) {
+	name := lookup(r.Context(), r.URL.Query().Get("name"))
+	w.Write([]byte(name))
+}
+
+func lookup(ctx context.Context, name string) string {
+	return name
+}
+`,
+			expect: `package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+func Hello(w http.ResponseWriter, r *http.Request) {
+	nrTxn := newrelic.FromContext(r.Context())
+	defer nrTxn.StartSegment("Hello").End()
+
+	name := lookup(r.Context(), r.URL.Query().Get("name"))
+	w.Write([]byte(name))
+}
+
+func lookup(ctx context.Context, name string) string {
+	nrTxn := newrelic.FromContext(ctx)
+	defer nrTxn.StartSegment("lookup").End()
+
+	return name
+}
+`,
+		},
+		{
+			name: "unnamed contexts and unexported functions are not entry points",
+			code: `package store
+
+import "context"
+
+func Ping(_ context.Context) error {
+	return nil
+}
+
+func ping(ctx context.Context) error {
+	return nil
+}
+`,
+			expect: `package store
+
+import "context"
+
+func Ping(_ context.Context) error {
+	return nil
+}
+
+func ping(ctx context.Context) error {
+	return nil
+}
+`,
+		},
+		{
+			name: "the agent is not initialized in main",
+			code: `package main
+
+import "context"
+
+func main() {
+	run(context.Background())
+}
+
+func run(ctx context.Context) {}
+`,
+			expect: `package main
+
+import "context"
+
+func main() {
+	run(context.Background())
+}
+
+func run(ctx context.Context) {}
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer parser.PanicRecovery(t)
+			got := parser.RunLibraryTracingFunction(t, tt.code, func(manager *parser.InstrumentationManager, c *dstutil.Cursor) {
+				nragent.InstrumentMain(manager, c)
+				nragent.InstrumentLibraryEntryPoint(manager, c)
+			})
+			assert.Equal(t, tt.expect, got)
+		})
+	}
+}
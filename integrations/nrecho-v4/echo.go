@@ -6,8 +6,8 @@ import (
 	"github.com/dave/dst"
 	"github.com/dave/dst/decorator"
 	"github.com/dave/dst/dstutil"
-	"github.com/newrelic/go-easy-instrumentation/internal/codegen"
 	"github.com/newrelic/go-easy-instrumentation/internal/comment"
+	"github.com/newrelic/go-easy-instrumentation/internal/report"
 	"github.com/newrelic/go-easy-instrumentation/internal/util"
 	"github.com/newrelic/go-easy-instrumentation/parser"
 	"github.com/newrelic/go-easy-instrumentation/parser/tracestate"
@@ -189,12 +189,14 @@ func InstrumentEchoMiddleware(manager *parser.InstrumentationManager, stmt dst.S
 	// Check if middleware is already present by looking at the next statement
 	if hasExistingEchoMiddleware(c) {
 		comment.Debug(manager.GetDecoratorPackage(), stmt, fmt.Sprintf("Skipping echo middleware for router %s: already instrumented", routerName))
+		report.Skipped(manager.GetDecoratorPackage(), stmt, "nrecho-v4", report.CodeAlreadyInstrumented, fmt.Sprintf("echo router %s already has New Relic middleware", routerName))
 		return false
 	}
 
 	// Append at the current stmt location
 	middleware, goGet := NrEchoMiddleware(routerName, tracing.AgentVariable())
 	comment.Debug(manager.GetDecoratorPackage(), stmt, fmt.Sprintf("Injecting nrecho middleware for router: %s", routerName))
+	report.Action(manager.GetDecoratorPackage(), stmt, "nrecho-v4", report.KindMiddleware, fmt.Sprintf("added nrecho middleware to router %s", routerName))
 	c.InsertAfter(middleware)
 	manager.AddImport(goGet)
 	return true
@@ -218,15 +220,18 @@ func InstrumentEchoFunction(manager *parser.InstrumentationManager, c *dstutil.C
 		// Check if nrecho.FromContext is already present in the function body
 		if HasExistingEchoTransaction(v) {
 			comment.Debug(manager.GetDecoratorPackage(), v, fmt.Sprintf("Skipping echo handler %s: already has nrecho.FromContext", v.Name.Name))
+			report.Skipped(manager.GetDecoratorPackage(), v, "nrecho-v4", report.CodeAlreadyInstrumented, fmt.Sprintf("echo handler %s already uses the transaction of the request", v.Name.Name))
 			return
 		}
 
 		comment.Debug(manager.GetDecoratorPackage(), v, fmt.Sprintf("Instrumenting echo handler: %s", v.Name.Name))
+		report.Action(manager.GetDecoratorPackage(), v, "nrecho-v4", report.KindTransaction, fmt.Sprintf("used the transaction of the request in echo handler %s", v.Name.Name))
 		funcDecl := currentNode.(*dst.FuncDecl)
-		txnName := codegen.DefaultTransactionVariable
+		txnName := manager.TransactionVariableName()
 		// Don't use TraceFunction for echo handlers - just add segment like we do for function literals
 		tc := tracestate.FunctionBody(txnName).FuncLiteralDeclaration(manager.GetDecoratorPackage(), nil)
 		if _, ok := tc.CreateSegment(funcDecl); ok {
+			report.Action(manager.GetDecoratorPackage(), v, "nrecho-v4", report.KindSegment, fmt.Sprintf("added a segment to echo handler %s", v.Name.Name))
 			DefineTxnFromEchoCtx(funcDecl.Body, txnName, ctxName)
 		}
 
@@ -237,11 +242,21 @@ func InstrumentEchoFunction(manager *parser.InstrumentationManager, c *dstutil.C
 		}
 
 		comment.Debug(manager.GetDecoratorPackage(), v, "Instrumenting echo handler function literal")
+		report.Action(manager.GetDecoratorPackage(), v, "nrecho-v4", report.KindSegment, "added a segment to echo handler function literal")
 		funcLit := currentNode.(*dst.FuncLit)
-		txnName := codegen.DefaultTransactionVariable
-		tc := tracestate.FunctionBody(codegen.DefaultTransactionVariable).FuncLiteralDeclaration(manager.GetDecoratorPackage(), funcLit)
+		txnName := manager.TransactionVariableName()
+		tc := tracestate.FunctionBody(txnName).FuncLiteralDeclaration(manager.GetDecoratorPackage(), funcLit)
 		tc.CreateSegment(funcLit)
 		DefineTxnFromEchoCtx(funcLit.Body, txnName, ctxName)
 		comment.Warn(manager.GetDecoratorPackage(), c.Parent(), c.Node(), "function literal segments will be named \"function literal\" by default", "declare a function instead to improve segment name generation")
+		report.Warning(manager.GetDecoratorPackage(), c.Node(), "nrecho-v4", report.CodeFunctionLiteralName, "function literal segments will be named \"function literal\" by default; declare a function instead")
 	}
 }
+
+// Uninstrument Functions
+// ////////////////////////////////////////////
+
+// RemoveEchoMiddleware removes the New Relic middleware that was added to a echo router.
+func RemoveEchoMiddleware(manager *parser.InstrumentationManager, c *dstutil.Cursor) bool {
+	return parser.RemoveMiddleware(c, NrechoImportPath)
+}
@@ -0,0 +1,91 @@
+package nrfasthttp
+
+import (
+	"go/token"
+	"strconv"
+
+	"github.com/dave/dst"
+)
+
+const (
+	NrFasthttpImportPath = "github.com/newrelic/go-agent/v3/integrations/nrfasthttp"
+)
+
+// WrapHandler wraps a fasthttp.RequestHandler with nrfasthttp.WrapHandle, which starts a transaction named
+// txnName for every request it handles, and assigns the wrapped handler to a new variable.
+// Ex:
+//
+//	_, nrRequestHandler := nrfasthttp.WrapHandle(app, "requestHandler", requestHandler)
+//
+// agentVariable should be passed from tracestate.State and WILL NOT BE CLONED
+func WrapHandler(agentVariable dst.Expr, txnName, handlerVariable string, handler dst.Expr) *dst.AssignStmt {
+	return &dst.AssignStmt{
+		Lhs: []dst.Expr{
+			dst.NewIdent("_"),
+			dst.NewIdent(handlerVariable),
+		},
+		Tok: token.DEFINE,
+		Rhs: []dst.Expr{
+			&dst.CallExpr{
+				Fun: &dst.Ident{
+					Name: "WrapHandle",
+					Path: NrFasthttpImportPath,
+				},
+				Args: []dst.Expr{
+					agentVariable,
+					&dst.BasicLit{
+						Kind:  token.STRING,
+						Value: strconv.Quote(txnName),
+					},
+					handler,
+				},
+			},
+		},
+	}
+}
+
+// TxnFromRequestCtx gets the transaction that nrfasthttp added to the *fasthttp.RequestCtx of a request.
+// Ex:
+//
+//	nrTxn := nrfasthttp.GetTransaction(ctx)
+func TxnFromRequestCtx(txnVariable, ctxVariable string) *dst.AssignStmt {
+	return &dst.AssignStmt{
+		Decs: dst.AssignStmtDecorations{
+			NodeDecs: dst.NodeDecs{
+				After: dst.EmptyLine,
+			},
+		},
+		Lhs: []dst.Expr{
+			dst.NewIdent(txnVariable),
+		},
+		Tok: token.DEFINE,
+		Rhs: []dst.Expr{
+			&dst.CallExpr{
+				Fun: &dst.Ident{
+					Name: "GetTransaction",
+					Path: NrFasthttpImportPath,
+				},
+				Args: []dst.Expr{
+					dst.NewIdent(ctxVariable),
+				},
+			},
+		},
+	}
+}
+
+// DoWithTransaction does an in place edit of a call to fasthttp.Client.Do, replacing it with a call to
+// nrfasthttp.Do, which makes the request in an external segment of the transaction.
+// Ex:
+//
+//	err := client.Do(req, resp)
+//	err := nrfasthttp.Do(client, nrTxn, req, resp)
+//
+// txnVariable should be passed from tracestate.State and WILL NOT BE CLONED
+func DoWithTransaction(txnVariable dst.Expr, call *dst.CallExpr) {
+	client := call.Fun.(*dst.SelectorExpr).X
+	call.Fun = &dst.Ident{
+		Name: "Do",
+		Path: NrFasthttpImportPath,
+	}
+	call.Args = append([]dst.Expr{client, txnVariable}, call.Args...)
+}
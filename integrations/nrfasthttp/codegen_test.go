@@ -0,0 +1,59 @@
+package nrfasthttp_test
+
+import (
+	"go/token"
+	"reflect"
+	"testing"
+
+	"github.com/dave/dst"
+	"github.com/newrelic/go-easy-instrumentation/integrations/nrfasthttp"
+)
+
+func TestWrapHandler(t *testing.T) {
+	want := &dst.AssignStmt{
+		Lhs: []dst.Expr{
+			dst.NewIdent("_"),
+			dst.NewIdent("nrRequestHandler"),
+		},
+		Tok: token.DEFINE,
+		Rhs: []dst.Expr{
+			&dst.CallExpr{
+				Fun: &dst.Ident{Name: "WrapHandle", Path: nrfasthttp.NrFasthttpImportPath},
+				Args: []dst.Expr{
+					dst.NewIdent("app"),
+					&dst.BasicLit{Kind: token.STRING, Value: `"requestHandler"`},
+					dst.NewIdent("requestHandler"),
+				},
+			},
+		},
+	}
+
+	got := nrfasthttp.WrapHandler(dst.NewIdent("app"), "requestHandler", "nrRequestHandler", dst.NewIdent("requestHandler"))
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("nrfasthttp.WrapHandler() = %v, want %v", got, want)
+	}
+}
+
+func TestDoWithTransaction(t *testing.T) {
+	call := &dst.CallExpr{
+		Fun: &dst.SelectorExpr{
+			X:   dst.NewIdent("client"),
+			Sel: dst.NewIdent("Do"),
+		},
+		Args: []dst.Expr{dst.NewIdent("req"), dst.NewIdent("resp")},
+	}
+	want := &dst.CallExpr{
+		Fun: &dst.Ident{Name: "Do", Path: nrfasthttp.NrFasthttpImportPath},
+		Args: []dst.Expr{
+			dst.NewIdent("client"),
+			dst.NewIdent("nrTxn"),
+			dst.NewIdent("req"),
+			dst.NewIdent("resp"),
+		},
+	}
+
+	nrfasthttp.DoWithTransaction(dst.NewIdent("nrTxn"), call)
+	if !reflect.DeepEqual(call, want) {
+		t.Errorf("nrfasthttp.DoWithTransaction() = %v, want %v", call, want)
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/valyala/fasthttp"
+)
+
+func fetchExample(client *fasthttp.Client) (int, error) {
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseResponse(resp)
+
+	req.SetRequestURI("https://example.com")
+	err := client.Do(req, resp)
+	if err != nil {
+		return 0, err
+	}
+	return resp.StatusCode(), nil
+}
+
+func requestHandler(ctx *fasthttp.RequestCtx) {
+	switch string(ctx.Path()) {
+	case "/external":
+		status, err := fetchExample(&fasthttp.Client{})
+		if err != nil {
+			slog.Error(err.Error())
+			ctx.Error(err.Error(), fasthttp.StatusInternalServerError)
+			return
+		}
+		fmt.Fprintf(ctx, "example.com returned %d", status)
+	default:
+		fmt.Fprintf(ctx, "hello from %s", ctx.Path())
+	}
+}
+
+func main() {
+	if err := fasthttp.ListenAndServe(":8080", requestHandler); err != nil {
+		slog.Error(err.Error())
+	}
+}
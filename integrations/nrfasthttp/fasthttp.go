@@ -0,0 +1,301 @@
+package nrfasthttp
+
+import (
+	"fmt"
+	"slices"
+	"unicode"
+
+	"github.com/dave/dst"
+	"github.com/dave/dst/dstutil"
+	"github.com/newrelic/go-easy-instrumentation/internal/comment"
+	"github.com/newrelic/go-easy-instrumentation/internal/report"
+	"github.com/newrelic/go-easy-instrumentation/internal/util"
+	"github.com/newrelic/go-easy-instrumentation/parser"
+	"github.com/newrelic/go-easy-instrumentation/parser/tracestate"
+)
+
+const (
+	FasthttpImportPath = "github.com/valyala/fasthttp"
+
+	// the type of the fasthttp client that nrfasthttp.Do makes requests with
+	fasthttpClientType = "*" + FasthttpImportPath + ".Client"
+)
+
+// fasthttp functions that serve requests with the handler passed as their last argument
+var serveFunctions = []string{"ListenAndServe", "ListenAndServeTLS", "ListenAndServeTLSEmbed", "ListenAndServeUNIX", "Serve", "ServeTLS", "ServeTLSEmbed"}
+
+// GetRequestCtxArgName returns the name of the *fasthttp.RequestCtx parameter of a function declaration if it
+// is a fasthttp.RequestHandler.
+//
+//	func myHandler(ctx *fasthttp.RequestCtx)
+//	_______________^^^
+func GetRequestCtxArgName(fn *dst.FuncDecl) string {
+	if fn == nil || fn.Body == nil || fn.Type.Params == nil || len(fn.Type.Params.List) != 1 || fn.Type.Results != nil {
+		return ""
+	}
+
+	param := fn.Type.Params.List[0]
+	if len(param.Names) != 1 || param.Names[0].Name == "_" {
+		return ""
+	}
+
+	// NOTE: This should be an Ident, not a SelectorExpr, since package.Type is
+	// considered a Qualified Identifier in Go, not a Selector
+	starExpr, ok := param.Type.(*dst.StarExpr)
+	if !ok {
+		return ""
+	}
+	ident, ok := starExpr.X.(*dst.Ident)
+	if !ok || ident.Name != "RequestCtx" || ident.Path != FasthttpImportPath {
+		return ""
+	}
+	return param.Names[0].Name
+}
+
+// getServedHandler returns the request handler served by a statement, which is either passed to a fasthttp
+// function that serves requests, or set as the Handler of a fasthttp.Server.
+//
+//	fasthttp.ListenAndServe(":8080", requestHandler)
+//	_________________________________^^^^^^^^^^^^^^
+//	server := &fasthttp.Server{Handler: requestHandler}
+//	____________________________________^^^^^^^^^^^^^^
+func getServedHandler(stmt dst.Stmt) *dst.Expr {
+	var handler *dst.Expr
+	dst.Inspect(stmt, func(n dst.Node) bool {
+		if handler != nil {
+			return false
+		}
+		switch v := n.(type) {
+		case *dst.BlockStmt, *dst.FuncLit:
+			return false
+		case *dst.CallExpr:
+			ident, ok := v.Fun.(*dst.Ident)
+			if ok && ident.Path == FasthttpImportPath && slices.Contains(serveFunctions, ident.Name) && len(v.Args) > 0 {
+				handler = &v.Args[len(v.Args)-1]
+				return false
+			}
+		case *dst.CompositeLit:
+			ident, ok := v.Type.(*dst.Ident)
+			if !ok || ident.Name != "Server" || ident.Path != FasthttpImportPath {
+				return true
+			}
+			for _, elt := range v.Elts {
+				kv, ok := elt.(*dst.KeyValueExpr)
+				if !ok {
+					continue
+				}
+				if key, ok := kv.Key.(*dst.Ident); ok && key.Name == "Handler" {
+					handler = &kv.Value
+					return false
+				}
+			}
+		}
+		return true
+	})
+	return handler
+}
+
+// handlerName returns the name of a request handler, which names the transactions it starts.
+func handlerName(handler dst.Expr) string {
+	switch v := handler.(type) {
+	case *dst.Ident:
+		return v.Name
+	case *dst.SelectorExpr:
+		return v.Sel.Name
+	}
+	return "handler"
+}
+
+// wrappedHandlerVariable returns the variable that a statement before index in block already assigned the
+// request handler wrapped with nrfasthttp.WrapHandle to, if there is one. Function literals are never reused,
+// since two of them are never the same handler.
+//
+//	_, nrRequestHandler := nrfasthttp.WrapHandle(NewRelicAgent, "requestHandler", requestHandler)
+//	___^^^^^^^^^^^^^^^^
+func wrappedHandlerVariable(block *dst.BlockStmt, index int, handler dst.Expr) string {
+	if _, ok := handler.(*dst.FuncLit); ok {
+		return ""
+	}
+	for _, stmt := range block.List[:index] {
+		assign, ok := stmt.(*dst.AssignStmt)
+		if !ok || len(assign.Lhs) != 2 || len(assign.Rhs) != 1 {
+			continue
+		}
+		wrap, ok := assign.Rhs[0].(*dst.CallExpr)
+		if !ok || len(wrap.Args) != 3 {
+			continue
+		}
+		fun, ok := wrap.Fun.(*dst.Ident)
+		if !ok || fun.Name != "WrapHandle" || fun.Path != NrFasthttpImportPath || !util.AssertExpressionEqual(wrap.Args[2], handler) {
+			continue
+		}
+		if wrapped, ok := assign.Lhs[1].(*dst.Ident); ok {
+			return wrapped.Name
+		}
+	}
+	return ""
+}
+
+// getClientDo returns the call to fasthttp.Client.Do in a statement, if it has one.
+//
+//	err := client.Do(req, resp)
+//	_______^^^^^^^^^^^^^^^^^^^^
+func getClientDo(manager *parser.InstrumentationManager, stmt dst.Stmt) *dst.CallExpr {
+	pkg := manager.GetDecoratorPackage()
+	var do *dst.CallExpr
+	dst.Inspect(stmt, func(n dst.Node) bool {
+		if do != nil {
+			return false
+		}
+		switch v := n.(type) {
+		case *dst.BlockStmt, *dst.FuncLit:
+			return false
+		case *dst.CallExpr:
+			sel, ok := v.Fun.(*dst.SelectorExpr)
+			if !ok || sel.Sel.Name != "Do" || len(v.Args) != 2 {
+				return true
+			}
+			if t := util.TypeOf(sel.X, pkg); t != nil && t.String() == fasthttpClientType {
+				do = v
+				return false
+			}
+		}
+		return true
+	})
+	return do
+}
+
+// StatelessTracingFunctions
+//////////////////////////////////////////////
+
+// InstrumentRequestHandler recognizes fasthttp request handlers, and traces them with the transaction that
+// nrfasthttp adds to their *fasthttp.RequestCtx. The transaction is passed on to the functions they call.
+func InstrumentRequestHandler(manager *parser.InstrumentationManager, c *dstutil.Cursor) {
+	fn, ok := c.Node().(*dst.FuncDecl)
+	if !ok {
+		return
+	}
+	ctxName := GetRequestCtxArgName(fn)
+	if ctxName == "" || manager.IsFunctionTraced(fn) {
+		return
+	}
+
+	txnName := manager.TransactionVariableName()
+	newFn, ok := parser.TraceFunction(manager, fn, tracestate.FunctionBody(txnName))
+	if !ok {
+		return
+	}
+	comment.Debug(manager.GetDecoratorPackage(), fn, fmt.Sprintf("Instrumenting fasthttp request handler: %s", fn.Name.Name))
+	report.Action(manager.GetDecoratorPackage(), fn, "nrfasthttp", report.KindTransaction, fmt.Sprintf("used the transaction of the request in fasthttp request handler %s", fn.Name.Name))
+	decl := newFn.(*dst.FuncDecl)
+	decl.Body.List = append([]dst.Stmt{TxnFromRequestCtx(txnName, ctxName)}, decl.Body.List...)
+	manager.AddImport(NrFasthttpImportPath)
+}
+
+// StatefulTracingFunctions
+//////////////////////////////////////////////
+
+// WrapRequestHandler wraps the request handler passed to a fasthttp server with nrfasthttp.WrapHandle, so
+// that a transaction is started for every request it serves.
+func WrapRequestHandler(manager *parser.InstrumentationManager, stmt dst.Stmt, c *dstutil.Cursor, tracing *tracestate.State) bool {
+	block, ok := c.Parent().(*dst.BlockStmt)
+	if !ok || c.Index() < 0 {
+		return false
+	}
+	handler := getServedHandler(stmt)
+	if handler == nil {
+		return false
+	}
+
+	// a handler served more than once in the same block is only wrapped once
+	name := handlerName(*handler)
+	if variable := wrappedHandlerVariable(block, c.Index(), *handler); variable != "" {
+		comment.Debug(manager.GetDecoratorPackage(), stmt, fmt.Sprintf("Serving fasthttp request handler %s wrapped as %s", name, variable))
+		report.Action(manager.GetDecoratorPackage(), stmt, "nrfasthttp", report.KindTransaction, fmt.Sprintf("served fasthttp request handler %s wrapped with nrfasthttp.WrapHandle as %s", name, variable))
+		*handler = dst.NewIdent(variable)
+		return true
+	}
+
+	variable := util.UniqueName(manager.GetDecoratorPackage(), block, "nr"+string(unicode.ToUpper(rune(name[0])))+name[1:])
+	comment.Debug(manager.GetDecoratorPackage(), stmt, fmt.Sprintf("Wrapping fasthttp request handler %s with nrfasthttp.WrapHandle", name))
+	report.Action(manager.GetDecoratorPackage(), stmt, "nrfasthttp", report.KindTransaction, fmt.Sprintf("wrapped fasthttp request handler %s with nrfasthttp.WrapHandle", name))
+	c.InsertBefore(WrapHandler(tracing.AgentVariable(), name, variable, *handler))
+	*handler = dst.NewIdent(variable)
+	manager.AddImport(NrFasthttpImportPath)
+	return true
+}
+
+// ExternalFasthttpCall replaces the requests made with fasthttp.Client.Do by calls to nrfasthttp.Do, which
+// makes them in an external segment of the transaction.
+func ExternalFasthttpCall(manager *parser.InstrumentationManager, stmt dst.Stmt, c *dstutil.Cursor, tracing *tracestate.State) bool {
+	do := getClientDo(manager, stmt)
+	if do == nil {
+		return false
+	}
+
+	client := util.WriteExpr(do.Fun.(*dst.SelectorExpr).X, manager.GetDecoratorPackage())
+	comment.Debug(manager.GetDecoratorPackage(), stmt, fmt.Sprintf("Replacing %s.Do with nrfasthttp.Do", client))
+	report.Action(manager.GetDecoratorPackage(), stmt, "nrfasthttp", report.KindExternal, fmt.Sprintf("made the request of %s.Do in an external segment with nrfasthttp.Do", client))
+	DoWithTransaction(tracing.TransactionVariable(), do)
+	manager.AddImport(NrFasthttpImportPath)
+	return true
+}
+
+// Uninstrument Functions
+// ////////////////////////////////////////////
+
+// UnwrapRequestHandler removes the statements that wrap a request handler with nrfasthttp.WrapHandle, and serves
+// the handler that was wrapped again.
+func UnwrapRequestHandler(manager *parser.InstrumentationManager, c *dstutil.Cursor) bool {
+	stmt, ok := c.Node().(*dst.AssignStmt)
+	if !ok || c.Index() < 0 || len(stmt.Lhs) != 2 || len(stmt.Rhs) != 1 {
+		return false
+	}
+	block, ok := c.Parent().(*dst.BlockStmt)
+	if !ok {
+		return false
+	}
+	wrap, ok := stmt.Rhs[0].(*dst.CallExpr)
+	if !ok || len(wrap.Args) != 3 {
+		return false
+	}
+	fun, ok := wrap.Fun.(*dst.Ident)
+	if !ok || fun.Name != "WrapHandle" || fun.Path != NrFasthttpImportPath {
+		return false
+	}
+	wrapped, ok := stmt.Lhs[1].(*dst.Ident)
+	if !ok {
+		return false
+	}
+
+	handler := wrap.Args[2]
+	for _, after := range block.List[c.Index()+1:] {
+		dstutil.Apply(after, func(c *dstutil.Cursor) bool {
+			if ident, ok := c.Node().(*dst.Ident); ok && ident.Name == wrapped.Name && ident.Path == "" {
+				c.Replace(dst.Clone(handler))
+			}
+			return true
+		}, nil)
+	}
+	parser.DeleteStatement(c)
+	return true
+}
+
+// RemoveDo makes the requests made with nrfasthttp.Do with the fasthttp client again.
+func RemoveDo(manager *parser.InstrumentationManager, c *dstutil.Cursor) bool {
+	call, ok := c.Node().(*dst.CallExpr)
+	if !ok || len(call.Args) != 4 {
+		return false
+	}
+	fun, ok := call.Fun.(*dst.Ident)
+	if !ok || fun.Name != "Do" || fun.Path != NrFasthttpImportPath {
+		return false
+	}
+
+	call.Fun = &dst.SelectorExpr{
+		X:   call.Args[0],
+		Sel: dst.NewIdent("Do"),
+	}
+	call.Args = call.Args[2:]
+	return true
+}
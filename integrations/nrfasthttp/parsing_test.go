@@ -0,0 +1,312 @@
+package nrfasthttp_test
+
+import (
+	"testing"
+
+	"github.com/dave/dst"
+	"github.com/newrelic/go-easy-instrumentation/integrations/nragent"
+	"github.com/newrelic/go-easy-instrumentation/integrations/nrfasthttp"
+	"github.com/newrelic/go-easy-instrumentation/parser"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetRequestCtxArgName(t *testing.T) {
+	handler := func(name string, paramType dst.Expr) *dst.FuncDecl {
+		return &dst.FuncDecl{
+			Name: dst.NewIdent("handler"),
+			Type: &dst.FuncType{
+				Params: &dst.FieldList{
+					List: []*dst.Field{{Names: []*dst.Ident{dst.NewIdent(name)}, Type: paramType}},
+				},
+			},
+			Body: &dst.BlockStmt{},
+		}
+	}
+
+	tests := []struct {
+		name string
+		fn   *dst.FuncDecl
+		want string
+	}{
+		{
+			name: "request handler",
+			fn:   handler("ctx", &dst.StarExpr{X: &dst.Ident{Name: "RequestCtx", Path: nrfasthttp.FasthttpImportPath}}),
+			want: "ctx",
+		},
+		{
+			name: "unnamed request context",
+			fn:   handler("_", &dst.StarExpr{X: &dst.Ident{Name: "RequestCtx", Path: nrfasthttp.FasthttpImportPath}}),
+			want: "",
+		},
+		{
+			name: "request context value",
+			fn:   handler("ctx", &dst.Ident{Name: "RequestCtx", Path: nrfasthttp.FasthttpImportPath}),
+			want: "",
+		},
+		{
+			name: "other package",
+			fn:   handler("ctx", &dst.StarExpr{X: &dst.Ident{Name: "RequestCtx", Path: "example.com/fasthttp"}}),
+			want: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, nrfasthttp.GetRequestCtxArgName(tt.fn))
+		})
+	}
+}
+
+func TestInstrumentRequestHandler(t *testing.T) {
+	code := `package main
+
+import (
+	"fmt"
+
+	"github.com/valyala/fasthttp"
+)
+
+func fetch(client *fasthttp.Client) {
+	req := fasthttp.AcquireRequest()
+	resp := fasthttp.AcquireResponse()
+	req.SetRequestURI("https://example.com")
+	client.Do(req, resp)
+}
+
+func requestHandler(ctx *fasthttp.RequestCtx) {
+	fetch(&fasthttp.Client{})
+	fmt.Fprintf(ctx, "hello")
+}
+
+func main() {
+	fasthttp.ListenAndServe(":8080", requestHandler)
+}
+`
+	expect := `package main
+
+import (
+	"fmt"
+
+	"github.com/newrelic/go-agent/v3/integrations/nrfasthttp"
+	"github.com/newrelic/go-agent/v3/newrelic"
+	"github.com/valyala/fasthttp"
+)
+
+func fetch(client *fasthttp.Client, nrTxn *newrelic.Transaction) {
+	defer nrTxn.StartSegment("fetch").End()
+
+	req := fasthttp.AcquireRequest()
+	resp := fasthttp.AcquireResponse()
+	req.SetRequestURI("https://example.com")
+	nrfasthttp.Do(client, nrTxn, req, resp)
+}
+
+func requestHandler(ctx *fasthttp.RequestCtx) {
+	nrTxn := nrfasthttp.GetTransaction(ctx)
+
+	fetch(&fasthttp.Client{}, nrTxn)
+	fmt.Fprintf(ctx, "hello")
+}
+
+func main() {
+	fasthttp.ListenAndServe(":8080", requestHandler)
+}
+`
+	defer parser.PanicRecovery(t)
+	got := parser.RunStatelessTracingFunction(t, code, nrfasthttp.InstrumentRequestHandler, nrfasthttp.ExternalFasthttpCall)
+	assert.Equal(t, expect, got)
+}
+
+func TestWrapRequestHandler(t *testing.T) {
+	tests := []struct {
+		name   string
+		code   string
+		expect string
+	}{
+		{
+			name: "fasthttp server",
+			code: `package main
+
+import (
+	"github.com/valyala/fasthttp"
+)
+
+func main() {
+	server := &fasthttp.Server{
+		Handler: func(ctx *fasthttp.RequestCtx) {
+			ctx.WriteString("hello")
+		},
+		Name: "example",
+	}
+	server.ListenAndServe(":8080")
+}
+`,
+			expect: `package main
+
+import (
+	"time"
+
+	"github.com/newrelic/go-agent/v3/integrations/nrfasthttp"
+	"github.com/newrelic/go-agent/v3/newrelic"
+	"github.com/valyala/fasthttp"
+)
+
+func main() {
+	NewRelicAgent, agentInitError := newrelic.NewApplication(newrelic.ConfigFromEnvironment())
+	if agentInitError != nil {
+		panic(agentInitError)
+	}
+
+	_, nrHandler := nrfasthttp.WrapHandle(NewRelicAgent, "handler", func(ctx *fasthttp.RequestCtx) {
+		ctx.WriteString("hello")
+	})
+	server := &fasthttp.Server{
+		Handler: nrHandler,
+		Name:    "example",
+	}
+	server.ListenAndServe(":8080")
+
+	NewRelicAgent.Shutdown(5 * time.Second)
+}
+`,
+		},
+		{
+			name: "two fasthttp servers",
+			code: `package main
+
+import (
+	"github.com/valyala/fasthttp"
+)
+
+func requestHandler(ctx *fasthttp.RequestCtx) {
+	ctx.WriteString("hello")
+}
+
+func main() {
+	admin := &fasthttp.Server{
+		Handler: func(ctx *fasthttp.RequestCtx) {
+			ctx.WriteString("admin")
+		},
+	}
+	metrics := &fasthttp.Server{
+		Handler: func(ctx *fasthttp.RequestCtx) {
+			ctx.WriteString("metrics")
+		},
+	}
+	server := &fasthttp.Server{Handler: requestHandler}
+	admin.ListenAndServe(":8081")
+	metrics.ListenAndServe(":8082")
+	server.ListenAndServe(":8080")
+	fasthttp.ListenAndServe(":8083", requestHandler)
+}
+`,
+			expect: `package main
+
+import (
+	"time"
+
+	"github.com/newrelic/go-agent/v3/integrations/nrfasthttp"
+	"github.com/newrelic/go-agent/v3/newrelic"
+	"github.com/valyala/fasthttp"
+)
+
+func requestHandler(ctx *fasthttp.RequestCtx) {
+	ctx.WriteString("hello")
+}
+
+func main() {
+	NewRelicAgent, agentInitError := newrelic.NewApplication(newrelic.ConfigFromEnvironment())
+	if agentInitError != nil {
+		panic(agentInitError)
+	}
+
+	_, nrHandler := nrfasthttp.WrapHandle(NewRelicAgent, "handler", func(ctx *fasthttp.RequestCtx) {
+		ctx.WriteString("admin")
+	})
+	admin := &fasthttp.Server{
+		Handler: nrHandler,
+	}
+	_, nrHandler2 := nrfasthttp.WrapHandle(NewRelicAgent, "handler", func(ctx *fasthttp.RequestCtx) {
+		ctx.WriteString("metrics")
+	})
+	metrics := &fasthttp.Server{
+		Handler: nrHandler2,
+	}
+	_, nrRequestHandler := nrfasthttp.WrapHandle(NewRelicAgent, "requestHandler", requestHandler)
+	server := &fasthttp.Server{Handler: nrRequestHandler}
+	admin.ListenAndServe(":8081")
+	metrics.ListenAndServe(":8082")
+	server.ListenAndServe(":8080")
+	fasthttp.ListenAndServe(":8083", nrRequestHandler)
+
+	NewRelicAgent.Shutdown(5 * time.Second)
+}
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer parser.PanicRecovery(t)
+			got := parser.RunStatelessTracingFunction(t, tt.code, nragent.InstrumentMain, nrfasthttp.WrapRequestHandler)
+			assert.Equal(t, tt.expect, got)
+		})
+	}
+}
+
+func TestRemoveFasthttpInstrumentation(t *testing.T) {
+	code := `package main
+
+import (
+	"time"
+
+	"github.com/newrelic/go-agent/v3/integrations/nrfasthttp"
+	"github.com/newrelic/go-agent/v3/newrelic"
+	"github.com/valyala/fasthttp"
+)
+
+func fetch(client *fasthttp.Client, nrTxn *newrelic.Transaction) error {
+	req := fasthttp.AcquireRequest()
+	resp := fasthttp.AcquireResponse()
+	return nrfasthttp.Do(client, nrTxn, req, resp)
+}
+
+func requestHandler(ctx *fasthttp.RequestCtx) {
+	nrTxn := nrfasthttp.GetTransaction(ctx)
+
+	fetch(&fasthttp.Client{}, nrTxn)
+}
+
+func main() {
+	NewRelicAgent, agentInitError := newrelic.NewApplication(newrelic.ConfigFromEnvironment())
+	if agentInitError != nil {
+		panic(agentInitError)
+	}
+
+	_, nrRequestHandler := nrfasthttp.WrapHandle(NewRelicAgent, "requestHandler", requestHandler)
+	fasthttp.ListenAndServe(":8080", nrRequestHandler)
+
+	NewRelicAgent.Shutdown(5 * time.Second)
+}
+`
+	expect := `package main
+
+import "github.com/valyala/fasthttp"
+
+func fetch(client *fasthttp.Client) error {
+	req := fasthttp.AcquireRequest()
+	resp := fasthttp.AcquireResponse()
+	return client.Do(req, resp)
+}
+
+func requestHandler(ctx *fasthttp.RequestCtx) {
+	fetch(&fasthttp.Client{})
+}
+
+func main() {
+	fasthttp.ListenAndServe(":8080", requestHandler)
+}
+`
+	defer parser.PanicRecovery(t)
+	got, warnings := parser.RunUninstrumentFunctions(t, code, nrfasthttp.UnwrapRequestHandler, nrfasthttp.RemoveDo, nragent.RemoveAgent)
+	assert.Equal(t, expect, got)
+	assert.Empty(t, warnings)
+}
@@ -0,0 +1,68 @@
+package nrfiber
+
+import (
+	"go/token"
+
+	"github.com/dave/dst"
+)
+
+const (
+	NrFiberImportPath = "github.com/newrelic/go-agent/v3/integrations/nrfiber"
+)
+
+// Inject NR Middleware instrumentation logic to the fiber app via the `Use` directive.
+// Ex:
+//
+//	app := fiber.New()
+//	app.Use(nrfiber.Middleware(NewRelicAgent)) <--- Middleware injection
+func NrFiberMiddleware(appName string, agentVariableName dst.Expr) (*dst.ExprStmt, string) {
+	return &dst.ExprStmt{
+		X: &dst.CallExpr{
+			Fun: &dst.SelectorExpr{
+				X:   &dst.Ident{Name: appName},
+				Sel: &dst.Ident{Name: "Use"},
+			},
+			Args: []dst.Expr{
+				&dst.CallExpr{
+					Fun: &dst.Ident{
+						Name: "Middleware",
+						Path: NrFiberImportPath,
+					},
+					Args: []dst.Expr{
+						agentVariableName,
+					},
+				},
+			},
+		},
+	}, NrFiberImportPath
+}
+
+// TxnFromFiberContext generates code to extract the New Relic transaction that nrfiber added
+// to the context of a fiber handler.
+// Ex:
+//
+//	nrTxn := nrfiber.FromContext(c)
+func TxnFromFiberContext(txnVariable string, ctxName string) *dst.AssignStmt {
+	return &dst.AssignStmt{
+		Lhs: []dst.Expr{
+			&dst.Ident{Name: txnVariable},
+		},
+		Tok: token.DEFINE,
+		Rhs: []dst.Expr{
+			&dst.CallExpr{
+				Fun: &dst.Ident{
+					Name: "FromContext",
+					Path: NrFiberImportPath,
+				},
+				Args: []dst.Expr{
+					&dst.Ident{Name: ctxName},
+				},
+			},
+		},
+		Decs: dst.AssignStmtDecorations{
+			NodeDecs: dst.NodeDecs{
+				After: dst.EmptyLine,
+			},
+		},
+	}
+}
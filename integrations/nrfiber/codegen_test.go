@@ -0,0 +1,66 @@
+package nrfiber_test
+
+import (
+	"go/token"
+	"reflect"
+	"testing"
+
+	"github.com/dave/dst"
+	"github.com/newrelic/go-easy-instrumentation/integrations/nrfiber"
+)
+
+func TestNrFiberMiddleware(t *testing.T) {
+	want := &dst.ExprStmt{
+		X: &dst.CallExpr{
+			Fun: &dst.SelectorExpr{
+				X:   &dst.Ident{Name: "app"},
+				Sel: &dst.Ident{Name: "Use"},
+			},
+			Args: []dst.Expr{
+				&dst.CallExpr{
+					Fun: &dst.Ident{
+						Name: "Middleware",
+						Path: nrfiber.NrFiberImportPath,
+					},
+					Args: []dst.Expr{
+						&dst.Ident{Name: "NewRelicApplication"},
+					},
+				},
+			},
+		},
+	}
+
+	got, imp := nrfiber.NrFiberMiddleware("app", &dst.Ident{Name: "NewRelicApplication"})
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("nrfiber.NrFiberMiddleware() = %v, want %v", got, want)
+	}
+	if imp != nrfiber.NrFiberImportPath {
+		t.Errorf("nrfiber.NrFiberMiddleware() = %v, want %v", imp, nrfiber.NrFiberImportPath)
+	}
+}
+
+func TestTxnFromFiberContext(t *testing.T) {
+	want := &dst.AssignStmt{
+		Lhs: []dst.Expr{&dst.Ident{Name: "nrTxn"}},
+		Tok: token.DEFINE,
+		Rhs: []dst.Expr{
+			&dst.CallExpr{
+				Fun: &dst.Ident{
+					Name: "FromContext",
+					Path: nrfiber.NrFiberImportPath,
+				},
+				Args: []dst.Expr{&dst.Ident{Name: "c"}},
+			},
+		},
+		Decs: dst.AssignStmtDecorations{
+			NodeDecs: dst.NodeDecs{
+				After: dst.EmptyLine,
+			},
+		},
+	}
+
+	got := nrfiber.TxnFromFiberContext("nrTxn", "c")
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("nrfiber.TxnFromFiberContext() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var errNoName = errors.New("a name is required")
+
+func greeting(name string) (string, error) {
+	if name == "" {
+		return "", errNoName
+	}
+	return "hello, " + name, nil
+}
+
+func hello(c *fiber.Ctx) error {
+	message, err := greeting(c.Query("name"))
+	if err != nil {
+		return err
+	}
+	return c.SendString(message)
+}
+
+func main() {
+	app := fiber.New()
+	app.Get("/hello", hello)
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendString("welcome")
+	})
+
+	if err := app.Listen(":3000"); err != nil {
+		slog.Error(err.Error())
+	}
+}
@@ -0,0 +1,229 @@
+package nrfiber
+
+import (
+	"fmt"
+	"go/token"
+
+	"github.com/dave/dst"
+	"github.com/dave/dst/dstutil"
+	"github.com/newrelic/go-easy-instrumentation/internal/codegen"
+	"github.com/newrelic/go-easy-instrumentation/internal/comment"
+	"github.com/newrelic/go-easy-instrumentation/internal/report"
+	"github.com/newrelic/go-easy-instrumentation/internal/util"
+	"github.com/newrelic/go-easy-instrumentation/parser"
+	"github.com/newrelic/go-easy-instrumentation/parser/tracestate"
+)
+
+const (
+	FiberImportPath = "github.com/gofiber/fiber/v2"
+)
+
+// Return the variable name of the fiber app object.
+// Ex:
+//
+//	app := fiber.New()
+//	^^^
+//	app := fiber.New(fiber.Config{AppName: "api"})
+//	^^^
+func GetFiberAppName(stmt dst.Stmt) string {
+	// Verify we're dealing with an assignment operation
+	v, ok := stmt.(*dst.AssignStmt)
+	if !ok || len(v.Rhs) != 1 || len(v.Lhs) != 1 {
+		return ""
+	}
+
+	// Verify the Rhs of the assignment is a Call Expression
+	call, ok := v.Rhs[0].(*dst.CallExpr)
+	if !ok {
+		return ""
+	}
+
+	// Reject calls that are not to the `New` Fn. Verify fiber relationship with the import path.
+	ident, ok := call.Fun.(*dst.Ident)
+	if !ok || ident.Name != "New" || ident.Path != FiberImportPath {
+		return ""
+	}
+
+	app, ok := v.Lhs[0].(*dst.Ident)
+	if !ok || app.Name == "_" {
+		return ""
+	}
+	return app.Name
+}
+
+// GetFiberCtxArgName returns the name of the *fiber.Ctx parameter of a function declaration or literal if it
+// is a fiber handler.
+//
+//	func myHandler(c *fiber.Ctx) error
+//	_______________^
+func GetFiberCtxArgName(fnType *dst.FuncType) string {
+	if fnType == nil || fnType.Params == nil || len(fnType.Params.List) != 1 {
+		return ""
+	}
+	if fnType.Results == nil || len(fnType.Results.List) != 1 || len(fnType.Results.List[0].Names) > 1 {
+		return ""
+	}
+	if ident, ok := fnType.Results.List[0].Type.(*dst.Ident); !ok || ident.Name != "error" || ident.Path != "" {
+		return ""
+	}
+
+	param := fnType.Params.List[0]
+	if len(param.Names) != 1 || param.Names[0].Name == "_" {
+		return ""
+	}
+
+	// NOTE: This should be an Ident, not a SelectorExpr, since package.Type is
+	// considered a Qualified Identifier in Go, not a Selector
+	starExpr, ok := param.Type.(*dst.StarExpr)
+	if !ok {
+		return ""
+	}
+	ident, ok := starExpr.X.(*dst.Ident)
+	if !ok || ident.Name != "Ctx" || ident.Path != FiberImportPath {
+		return ""
+	}
+	return param.Names[0].Name
+}
+
+// noticeReturnedErrors captures the error variables returned by a fiber handler with NoticeError, unless the
+// statement before the return already does. Errors returned by calls are captured when the handler is traced.
+//
+//	if err != nil {
+//		nrTxn.NoticeError(err) <--- NoticeError injection
+//		return err
+//	}
+func noticeReturnedErrors(manager *parser.InstrumentationManager, body *dst.BlockStmt, txnName string) bool {
+	pkg := manager.GetDecoratorPackage()
+	nilChecked := map[*dst.BlockStmt]string{} // the error variable that the if statement a block belongs to checks
+	changed := false
+	dstutil.Apply(body, func(c *dstutil.Cursor) bool {
+		switch v := c.Node().(type) {
+		case *dst.FuncLit:
+			// function literals return their own errors
+			return false
+		case *dst.IfStmt:
+			if cond, ok := v.Cond.(*dst.BinaryExpr); ok && cond.Op == token.NEQ {
+				x, xOk := cond.X.(*dst.Ident)
+				y, yOk := cond.Y.(*dst.Ident)
+				if xOk && yOk && y.Name == "nil" {
+					nilChecked[v.Body] = x.Name
+				}
+			}
+		case *dst.ReturnStmt:
+			block, ok := c.Parent().(*dst.BlockStmt)
+			if !ok || c.Index() < 0 || len(v.Results) != 1 {
+				return false
+			}
+			errVar, ok := v.Results[0].(*dst.Ident)
+			if !ok || errVar.Name == "nil" || !util.IsError(util.TypeOf(errVar, pkg)) {
+				return false
+			}
+			if c.Index() > 0 && noticesError(block.List[c.Index()-1], errVar.Name) {
+				return false
+			}
+
+			comment.Debug(pkg, v, fmt.Sprintf("Capturing error %s returned by fiber handler", errVar.Name))
+			report.Action(pkg, v, "nrfiber", report.KindNoticeError, fmt.Sprintf("added NoticeError for %s before return", errVar.Name))
+			if nilChecked[block] == errVar.Name {
+				c.InsertBefore(codegen.NoticeError(errVar, dst.NewIdent(txnName), v))
+			} else {
+				capture := codegen.IfErrorNotNilNoticeError(errVar, dst.NewIdent(txnName))
+				capture.Decs.Before = dst.EmptyLine
+				v.Decs.Before = dst.EmptyLine
+				c.InsertBefore(capture)
+			}
+			changed = true
+			return false
+		}
+		return true
+	}, nil)
+	return changed
+}
+
+// noticesError returns true if a statement calls NoticeError with the error variable errName.
+func noticesError(stmt dst.Stmt, errName string) bool {
+	found := false
+	dst.Inspect(stmt, func(n dst.Node) bool {
+		call, ok := n.(*dst.CallExpr)
+		if !ok || found {
+			return !found
+		}
+		sel, ok := call.Fun.(*dst.SelectorExpr)
+		if ok && sel.Sel.Name == "NoticeError" && len(call.Args) == 1 {
+			if arg, ok := call.Args[0].(*dst.Ident); ok && arg.Name == errName {
+				found = true
+			}
+		}
+		return !found
+	})
+	return found
+}
+
+// StatelessTracingFunctions
+//////////////////////////////////////////////
+
+// InstrumentFiberHandler recognizes fiber handlers, and traces them with the transaction that the nrfiber
+// middleware adds to their *fiber.Ctx. The transaction is passed on to the functions they call, and the
+// errors they return are captured with NoticeError.
+func InstrumentFiberHandler(manager *parser.InstrumentationManager, c *dstutil.Cursor) {
+	var name string
+	var fnType *dst.FuncType
+	var body **dst.BlockStmt
+	switch fn := c.Node().(type) {
+	case *dst.FuncDecl:
+		if fn.Body == nil || manager.IsFunctionTraced(fn) {
+			return
+		}
+		name, fnType, body = fn.Name.Name, fn.Type, &fn.Body
+	case *dst.FuncLit:
+		if manager.WasInstrumented(fn) {
+			return
+		}
+		name, fnType, body = "function literal", fn.Type, &fn.Body
+	default:
+		return
+	}
+	ctxName := GetFiberCtxArgName(fnType)
+	if ctxName == "" {
+		return
+	}
+
+	txnName := manager.TransactionVariableName()
+	_, traced := parser.TraceFunction(manager, c.Node(), tracestate.FunctionBody(txnName))
+	noticed := noticeReturnedErrors(manager, *body, txnName)
+	if !traced && !noticed {
+		return
+	}
+	comment.Debug(manager.GetDecoratorPackage(), c.Node(), fmt.Sprintf("Instrumenting fiber handler: %s", name))
+	report.Action(manager.GetDecoratorPackage(), c.Node(), "nrfiber", report.KindTransaction, fmt.Sprintf("used the transaction of the request in fiber handler %s", name))
+	(*body).List = append([]dst.Stmt{TxnFromFiberContext(txnName, ctxName)}, (*body).List...)
+	manager.AddImport(NrFiberImportPath)
+}
+
+// StatefulTracingFunctions
+//////////////////////////////////////////////
+
+// InstrumentFiberMiddleware detects whether a fiber app has been created and adds New Relic
+// Go Agent Middleware via the app.Use() method to start a transaction for every request it handles.
+func InstrumentFiberMiddleware(manager *parser.InstrumentationManager, stmt dst.Stmt, c *dstutil.Cursor, tracing *tracestate.State) bool {
+	appName := GetFiberAppName(stmt)
+	if appName == "" {
+		return false
+	}
+
+	// Append at the current stmt location
+	middleware, goGet := NrFiberMiddleware(appName, tracing.AgentVariable())
+	comment.Debug(manager.GetDecoratorPackage(), stmt, fmt.Sprintf("Injecting nrfiber middleware for app: %s", appName))
+	report.Action(manager.GetDecoratorPackage(), stmt, "nrfiber", report.KindMiddleware, fmt.Sprintf("added nrfiber middleware to app %s", appName))
+	c.InsertAfter(middleware)
+	manager.AddImport(goGet)
+	return true
+}
+
+// Uninstrument Functions
+// ////////////////////////////////////////////
+
+// RemoveFiberMiddleware removes the New Relic middleware that was added to a fiber app.
+func RemoveFiberMiddleware(manager *parser.InstrumentationManager, c *dstutil.Cursor) bool {
+	return parser.RemoveMiddleware(c, NrFiberImportPath)
+}
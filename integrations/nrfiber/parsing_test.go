@@ -0,0 +1,366 @@
+package nrfiber_test
+
+import (
+	"testing"
+
+	"github.com/dave/dst"
+	"github.com/newrelic/go-easy-instrumentation/integrations/nragent"
+	"github.com/newrelic/go-easy-instrumentation/integrations/nrfiber"
+	"github.com/newrelic/go-easy-instrumentation/parser"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetFiberAppName(t *testing.T) {
+	newCall := func(args ...dst.Expr) *dst.CallExpr {
+		return &dst.CallExpr{Fun: &dst.Ident{Name: "New", Path: nrfiber.FiberImportPath}, Args: args}
+	}
+	config := &dst.CompositeLit{Type: &dst.Ident{Name: "Config", Path: nrfiber.FiberImportPath}}
+
+	tests := []struct {
+		name string
+		stmt dst.Stmt
+		want string
+	}{
+		{
+			name: "fiber app",
+			stmt: &dst.AssignStmt{Lhs: []dst.Expr{dst.NewIdent("app")}, Rhs: []dst.Expr{newCall()}},
+			want: "app",
+		},
+		{
+			name: "fiber app with config",
+			stmt: &dst.AssignStmt{Lhs: []dst.Expr{dst.NewIdent("app")}, Rhs: []dst.Expr{newCall(config)}},
+			want: "app",
+		},
+		{
+			name: "discarded app",
+			stmt: &dst.AssignStmt{Lhs: []dst.Expr{dst.NewIdent("_")}, Rhs: []dst.Expr{newCall()}},
+			want: "",
+		},
+		{
+			name: "other New function",
+			stmt: &dst.AssignStmt{Lhs: []dst.Expr{dst.NewIdent("app")}, Rhs: []dst.Expr{&dst.CallExpr{Fun: &dst.Ident{Name: "New", Path: "github.com/labstack/echo/v4"}}}},
+			want: "",
+		},
+		{
+			name: "not an assignment",
+			stmt: &dst.ExprStmt{X: newCall()},
+			want: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, nrfiber.GetFiberAppName(tt.stmt))
+		})
+	}
+}
+
+func TestGetFiberCtxArgName(t *testing.T) {
+	ctx := &dst.StarExpr{X: &dst.Ident{Name: "Ctx", Path: nrfiber.FiberImportPath}}
+	field := func(typ dst.Expr, names ...string) *dst.Field {
+		f := &dst.Field{Type: typ}
+		for _, name := range names {
+			f.Names = append(f.Names, dst.NewIdent(name))
+		}
+		return f
+	}
+	errorResult := &dst.FieldList{List: []*dst.Field{field(dst.NewIdent("error"))}}
+
+	tests := []struct {
+		name    string
+		params  []*dst.Field
+		results *dst.FieldList
+		want    string
+	}{
+		{
+			name:    "fiber handler",
+			params:  []*dst.Field{field(ctx, "c")},
+			results: errorResult,
+			want:    "c",
+		},
+		{
+			name:    "unnamed ctx",
+			params:  []*dst.Field{field(ctx, "_")},
+			results: errorResult,
+			want:    "",
+		},
+		{
+			name:   "no error result",
+			params: []*dst.Field{field(ctx, "c")},
+			want:   "",
+		},
+		{
+			name:    "other result",
+			params:  []*dst.Field{field(ctx, "c")},
+			results: &dst.FieldList{List: []*dst.Field{field(dst.NewIdent("string"))}},
+			want:    "",
+		},
+		{
+			name:    "other params",
+			params:  []*dst.Field{field(ctx, "c"), field(dst.NewIdent("string"), "name")},
+			results: errorResult,
+			want:    "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fnType := &dst.FuncType{Params: &dst.FieldList{List: tt.params}, Results: tt.results}
+			assert.Equal(t, tt.want, nrfiber.GetFiberCtxArgName(fnType))
+		})
+	}
+}
+
+func TestInstrumentFiberMiddleware(t *testing.T) {
+	tests := []struct {
+		name   string
+		code   string
+		expect string
+	}{
+		{
+			name: "add middleware in main function",
+			code: `package main
+
+import "github.com/gofiber/fiber/v2"
+
+func main() {
+	app := fiber.New()
+	app.Listen(":3000")
+}
+`,
+			expect: `package main
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/newrelic/go-agent/v3/integrations/nrfiber"
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+func main() {
+	NewRelicAgent, agentInitError := newrelic.NewApplication(newrelic.ConfigFromEnvironment())
+	if agentInitError != nil {
+		panic(agentInitError)
+	}
+
+	app := fiber.New()
+	app.Use(nrfiber.Middleware(NewRelicAgent))
+	app.Listen(":3000")
+
+	NewRelicAgent.Shutdown(5 * time.Second)
+}
+`,
+		},
+		{
+			name: "add middleware to app created with a config",
+			code: `package main
+
+import "github.com/gofiber/fiber/v2"
+
+func setupApp() *fiber.App {
+	app := fiber.New(fiber.Config{AppName: "api"})
+	return app
+}
+
+func main() {
+	app := setupApp()
+	app.Listen(":3000")
+}
+`,
+			expect: `package main
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/newrelic/go-agent/v3/integrations/nrfiber"
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+func setupApp(nrTxn *newrelic.Transaction) *fiber.App {
+	defer nrTxn.StartSegment("setupApp").End()
+
+	app := fiber.New(fiber.Config{AppName: "api"})
+	app.Use(nrfiber.Middleware(nrTxn.Application()))
+	return app
+}
+
+func main() {
+	NewRelicAgent, agentInitError := newrelic.NewApplication(newrelic.ConfigFromEnvironment())
+	if agentInitError != nil {
+		panic(agentInitError)
+	}
+
+	nrTxn := NewRelicAgent.StartTransaction("setupApp")
+	app := setupApp(nrTxn)
+	nrTxn.End()
+	app.Listen(":3000")
+
+	NewRelicAgent.Shutdown(5 * time.Second)
+}
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer parser.PanicRecovery(t)
+			got := parser.RunStatelessTracingFunction(t, tt.code, nragent.InstrumentMain, nrfiber.InstrumentFiberMiddleware)
+			assert.Equal(t, tt.expect, got)
+		})
+	}
+}
+
+func TestInstrumentFiberHandler(t *testing.T) {
+	code := `package main
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var errNoName = errors.New("a name is required")
+
+func greeting(name string) string {
+	return "hello, " + name
+}
+
+func check(name string) error {
+	if name == "" {
+		return errNoName
+	}
+	return nil
+}
+
+func validate(c *fiber.Ctx) error {
+	err := check(c.Query("name"))
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func hello(c *fiber.Ctx) error {
+	message := greeting(c.Query("name"))
+	c.SendString(message)
+	return nil
+}
+
+func index(c *fiber.Ctx) error {
+	return nil
+}
+
+func setupApp(app *fiber.App) {
+	app.Get("/hi", func(c *fiber.Ctx) error {
+		c.SendString(greeting("there"))
+		return nil
+	})
+}
+
+func main() {}
+`
+	expect := `package main
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/newrelic/go-agent/v3/integrations/nrfiber"
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+var errNoName = errors.New("a name is required")
+
+func greeting(name string, nrTxn *newrelic.Transaction) string {
+	defer nrTxn.StartSegment("greeting").End()
+
+	return "hello, " + name
+}
+
+func check(name string, nrTxn *newrelic.Transaction) error {
+	defer nrTxn.StartSegment("check").End()
+
+	if name == "" {
+		return errNoName
+	}
+	return nil
+}
+
+func validate(c *fiber.Ctx) error {
+	nrTxn := nrfiber.FromContext(c)
+
+	err := check(c.Query("name"), nrTxn)
+	if err != nil {
+		nrTxn.NoticeError(err)
+		return err
+	}
+	return nil
+}
+
+func hello(c *fiber.Ctx) error {
+	nrTxn := nrfiber.FromContext(c)
+
+	message := greeting(c.Query("name"), nrTxn)
+	c.SendString(message)
+	return nil
+}
+
+func index(c *fiber.Ctx) error {
+	return nil
+}
+
+func setupApp(app *fiber.App) {
+	app.Get("/hi", func(c *fiber.Ctx) error {
+		nrTxn := nrfiber.FromContext(c)
+
+		c.SendString(greeting("there", nrTxn))
+		return nil
+	})
+}
+
+func main() {}
+`
+	defer parser.PanicRecovery(t)
+	got := parser.RunStatelessTracingFunction(t, code, nrfiber.InstrumentFiberHandler)
+	assert.Equal(t, expect, got)
+}
+
+func TestRemoveFiberMiddleware(t *testing.T) {
+	code := `package main
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/newrelic/go-agent/v3/integrations/nrfiber"
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+func main() {
+	NewRelicAgent, agentInitError := newrelic.NewApplication(newrelic.ConfigFromEnvironment())
+	if agentInitError != nil {
+		panic(agentInitError)
+	}
+
+	app := fiber.New()
+	app.Use(nrfiber.Middleware(NewRelicAgent))
+	app.Listen(":3000")
+
+	NewRelicAgent.Shutdown(5 * time.Second)
+}
+`
+	expect := `package main
+
+import (
+	"github.com/gofiber/fiber/v2"
+)
+
+func main() {
+	app := fiber.New()
+	app.Listen(":3000")
+}
+`
+	defer parser.PanicRecovery(t)
+	got, warnings := parser.RunUninstrumentFunctions(t, code, nrfiber.RemoveFiberMiddleware, nragent.RemoveAgent)
+	assert.Equal(t, expect, got)
+	assert.Empty(t, warnings)
+}
@@ -6,8 +6,8 @@ import (
 	"github.com/dave/dst"
 	"github.com/dave/dst/decorator"
 	"github.com/dave/dst/dstutil"
-	"github.com/newrelic/go-easy-instrumentation/internal/codegen"
 	"github.com/newrelic/go-easy-instrumentation/internal/comment"
+	"github.com/newrelic/go-easy-instrumentation/internal/report"
 	"github.com/newrelic/go-easy-instrumentation/internal/util"
 	"github.com/newrelic/go-easy-instrumentation/parser"
 	"github.com/newrelic/go-easy-instrumentation/parser/tracestate"
@@ -154,12 +154,14 @@ func InstrumentGinMiddleware(manager *parser.InstrumentationManager, stmt dst.St
 	// Check if middleware is already present by looking at the next statement
 	if hasExistingGinMiddleware(c) {
 		comment.Debug(manager.GetDecoratorPackage(), stmt, fmt.Sprintf("Skipping gin middleware for router %s: already instrumented", routerName))
+		report.Skipped(manager.GetDecoratorPackage(), stmt, "nrgin", report.CodeAlreadyInstrumented, fmt.Sprintf("gin router %s already has New Relic middleware", routerName))
 		return false
 	}
 
 	// Append at the current stmt location
 	middleware, goGet := NrGinMiddleware(routerName, tracing.AgentVariable())
 	comment.Debug(manager.GetDecoratorPackage(), stmt, fmt.Sprintf("Injecting nrgin middleware for router: %s", routerName))
+	report.Action(manager.GetDecoratorPackage(), stmt, "nrgin", report.KindMiddleware, fmt.Sprintf("added nrgin middleware to router %s", routerName))
 	c.InsertAfter(middleware)
 	manager.AddImport(goGet)
 	return true
@@ -183,14 +185,16 @@ func InstrumentGinFunction(manager *parser.InstrumentationManager, c *dstutil.Cu
 		// Check if nrgin.Transaction is already present in the function body
 		if hasExistingGinTransaction(v) {
 			comment.Debug(manager.GetDecoratorPackage(), v, fmt.Sprintf("Skipping gin handler %s: already has nrgin.Transaction", v.Name.Name))
+			report.Skipped(manager.GetDecoratorPackage(), v, "nrgin", report.CodeAlreadyInstrumented, fmt.Sprintf("gin handler %s already uses the transaction of the request", v.Name.Name))
 			return
 		}
 
-		comment.Debug(manager.GetDecoratorPackage(), v, fmt.Sprintf("Instrumenting gin handler: %s", v.Name.Name))
 		funcDecl := currentNode.(*dst.FuncDecl)
-		txnName := codegen.DefaultTransactionVariable
+		txnName := manager.TransactionVariableName()
 		_, ok := parser.TraceFunction(manager, funcDecl, tracestate.FunctionBody(txnName))
 		if ok {
+			comment.Debug(manager.GetDecoratorPackage(), v, fmt.Sprintf("Instrumenting gin handler: %s", v.Name.Name))
+			report.Action(manager.GetDecoratorPackage(), v, "nrgin", report.KindTransaction, fmt.Sprintf("used the transaction of the request in gin handler %s", v.Name.Name))
 			DefineTxnFromGinCtx(funcDecl.Body, txnName, ctxName)
 		}
 
@@ -201,11 +205,21 @@ func InstrumentGinFunction(manager *parser.InstrumentationManager, c *dstutil.Cu
 		}
 
 		comment.Debug(manager.GetDecoratorPackage(), v, "Instrumenting gin handler function literal")
+		report.Action(manager.GetDecoratorPackage(), v, "nrgin", report.KindSegment, "added a segment to gin handler function literal")
 		funcLit := currentNode.(*dst.FuncLit)
-		txnName := codegen.DefaultTransactionVariable
-		tc := tracestate.FunctionBody(codegen.DefaultTransactionVariable).FuncLiteralDeclaration(manager.GetDecoratorPackage(), funcLit)
+		txnName := manager.TransactionVariableName()
+		tc := tracestate.FunctionBody(txnName).FuncLiteralDeclaration(manager.GetDecoratorPackage(), funcLit)
 		tc.CreateSegment(funcLit)
 		DefineTxnFromGinCtx(funcLit.Body, txnName, ctxName)
 		comment.Warn(manager.GetDecoratorPackage(), c.Parent(), c.Node(), "function literal segments will be named \"function literal\" by default", "declare a function instead to improve segment name generation")
+		report.Warning(manager.GetDecoratorPackage(), c.Node(), "nrgin", report.CodeFunctionLiteralName, "function literal segments will be named \"function literal\" by default; declare a function instead")
 	}
 }
+
+// Uninstrument Functions
+// ////////////////////////////////////////////
+
+// RemoveGinMiddleware removes the New Relic middleware that was added to a gin router.
+func RemoveGinMiddleware(manager *parser.InstrumentationManager, c *dstutil.Cursor) bool {
+	return parser.RemoveMiddleware(c, NrginImportPath)
+}
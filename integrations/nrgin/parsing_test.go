@@ -415,3 +415,42 @@ func TestDefineTxnFromGinCtx(t *testing.T) {
 		})
 	}
 }
+
+func TestRemoveGinMiddleware(t *testing.T) {
+	code := `package main
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/newrelic/go-agent/v3/integrations/nrgin"
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+func main() {
+	NewRelicAgent, agentInitError := newrelic.NewApplication(newrelic.ConfigFromEnvironment())
+	if agentInitError != nil {
+		panic(agentInitError)
+	}
+
+	router := gin.Default()
+	router.Use(nrgin.Middleware(NewRelicAgent))
+	router.Run(":8000")
+
+	NewRelicAgent.Shutdown(5 * time.Second)
+}
+`
+	expect := `package main
+
+import "github.com/gin-gonic/gin"
+
+func main() {
+	router := gin.Default()
+	router.Run(":8000")
+}
+`
+	defer parser.PanicRecovery(t)
+	got, warnings := parser.RunUninstrumentFunctions(t, code, nrgin.RemoveGinMiddleware, nragent.RemoveAgent)
+	assert.Equal(t, expect, got)
+	assert.Empty(t, warnings)
+}
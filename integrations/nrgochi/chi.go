@@ -10,6 +10,7 @@ import (
 	"github.com/dave/dst/dstutil"
 	"github.com/newrelic/go-easy-instrumentation/internal/codegen"
 	"github.com/newrelic/go-easy-instrumentation/internal/comment"
+	"github.com/newrelic/go-easy-instrumentation/internal/report"
 	"github.com/newrelic/go-easy-instrumentation/parser"
 	"github.com/newrelic/go-easy-instrumentation/parser/tracestate"
 )
@@ -120,6 +121,7 @@ func InstrumentChiMiddleware(manager *parser.InstrumentationManager, stmt dst.St
 	// Append at the current stmt location
 	middleware, goGet := NrChiMiddleware(routerName, tracing.AgentVariable())
 	comment.Debug(manager.GetDecoratorPackage(), stmt, fmt.Sprintf("Injecting nrgochi middleware for router: %s", routerName))
+	report.Action(manager.GetDecoratorPackage(), stmt, "nrgochi", report.KindMiddleware, fmt.Sprintf("added nrgochi middleware to router %s", routerName))
 	c.InsertAfter(middleware)
 	manager.AddImport(goGet)
 	return true
@@ -144,8 +146,12 @@ func InstrumentChiRouterLiteral(manager *parser.InstrumentationManager, stmt dst
 	if reqArgName == "" || !ok {
 		return false
 	}
+	if manager.WasInstrumented(fnLit) {
+		report.Skipped(manager.GetDecoratorPackage(), stmt, "nrgochi", report.CodeAlreadyInstrumented, fmt.Sprintf("chi route handler %s is already instrumented", methodName+":"+routeName))
+		return false
+	}
 
-	txn := codegen.TxnFromContext(codegen.DefaultTransactionVariable, codegen.HttpRequestContext(reqArgName))
+	txn := codegen.TxnFromContext(manager.TransactionVariableName(), codegen.HttpRequestContext(reqArgName))
 	if txn == nil {
 		return false
 	}
@@ -153,6 +159,7 @@ func InstrumentChiRouterLiteral(manager *parser.InstrumentationManager, stmt dst
 	segmentName := methodName + ":" + routeName
 
 	comment.Debug(manager.GetDecoratorPackage(), stmt, fmt.Sprintf("Injecting segment for Chi route: %s", segmentName))
+	report.Action(manager.GetDecoratorPackage(), stmt, "nrgochi", report.KindSegment, fmt.Sprintf("added segment %s to chi route handler", segmentName))
 	codegen.PrependStatementToFunctionLit(fnLit, codegen.DeferSegment(segmentName, tracing.TransactionVariable()))
 	codegen.PrependStatementToFunctionLit(fnLit, txn)
 
@@ -190,3 +197,11 @@ func getHTTPRequestArgName(fnLit *dst.FuncLit) (bool, string) {
 
 	return false, ""
 }
+
+// Uninstrument Functions
+// ////////////////////////////////////////////
+
+// RemoveChiMiddleware removes the New Relic middleware that was added to a chi router.
+func RemoveChiMiddleware(manager *parser.InstrumentationManager, c *dstutil.Cursor) bool {
+	return parser.RemoveMiddleware(c, NrChiImportPath)
+}
@@ -0,0 +1,34 @@
+package nrgorilla
+
+import "github.com/dave/dst"
+
+const (
+	NrGorillaImportPath = "github.com/newrelic/go-agent/v3/integrations/nrgorilla"
+)
+
+// Inject NR Middleware instrumentation logic to the gorilla/mux router via the `Use` directive.
+// Ex:
+//
+//	router := mux.NewRouter()
+//	router.Use(nrgorilla.Middleware(app)) <--- Middleware injection
+func NrGorillaMiddleware(routerName string, agentVariableName dst.Expr) (*dst.ExprStmt, string) {
+	return &dst.ExprStmt{
+		X: &dst.CallExpr{
+			Fun: &dst.SelectorExpr{
+				X:   &dst.Ident{Name: routerName},
+				Sel: &dst.Ident{Name: "Use"},
+			},
+			Args: []dst.Expr{
+				&dst.CallExpr{
+					Fun: &dst.Ident{
+						Name: "Middleware",
+						Path: NrGorillaImportPath,
+					},
+					Args: []dst.Expr{
+						agentVariableName,
+					},
+				},
+			},
+		},
+	}, NrGorillaImportPath
+}
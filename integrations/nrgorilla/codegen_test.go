@@ -0,0 +1,39 @@
+package nrgorilla_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/dave/dst"
+	"github.com/newrelic/go-easy-instrumentation/integrations/nrgorilla"
+)
+
+func TestNrGorillaMiddleware(t *testing.T) {
+	want := &dst.ExprStmt{
+		X: &dst.CallExpr{
+			Fun: &dst.SelectorExpr{
+				X:   &dst.Ident{Name: "router"},
+				Sel: &dst.Ident{Name: "Use"},
+			},
+			Args: []dst.Expr{
+				&dst.CallExpr{
+					Fun: &dst.Ident{
+						Name: "Middleware",
+						Path: nrgorilla.NrGorillaImportPath,
+					},
+					Args: []dst.Expr{
+						&dst.Ident{Name: "NewRelicApplication"},
+					},
+				},
+			},
+		},
+	}
+
+	got, imp := nrgorilla.NrGorillaMiddleware("router", &dst.Ident{Name: "NewRelicApplication"})
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("nrgorilla.NrGorillaMiddleware() = %v, want %v", got, want)
+	}
+	if imp != nrgorilla.NrGorillaImportPath {
+		t.Errorf("nrgorilla.NrGorillaMiddleware() = %v, want %v", imp, nrgorilla.NrGorillaImportPath)
+	}
+}
@@ -0,0 +1,5 @@
+module gorilla
+
+go 1.25
+
+require github.com/gorilla/mux v1.8.1
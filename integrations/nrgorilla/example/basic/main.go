@@ -0,0 +1,40 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+func endpoint404(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(404)
+	w.Write([]byte("returning 404"))
+}
+
+func basicExternal(w http.ResponseWriter, r *http.Request) {
+	// Make an http request to an external address
+	resp, err := http.Get("https://example.com")
+	if err != nil {
+		slog.Error(err.Error())
+		io.WriteString(w, err.Error())
+		return
+	}
+
+	defer resp.Body.Close()
+	io.Copy(w, resp.Body)
+}
+
+func main() {
+	r := mux.NewRouter()
+	r.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("welcome"))
+	}).Methods("GET")
+	r.HandleFunc("/404", endpoint404)
+	r.HandleFunc("/external", basicExternal)
+	r.HandleFunc("/literal", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("function literal example"))
+	})
+	http.ListenAndServe(":3000", r)
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+type user struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+func getUser(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	json.NewEncoder(w).Encode(user{ID: id, Name: "gopher"})
+}
+
+func main() {
+	r := mux.NewRouter().StrictSlash(true)
+	r.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+
+	api := r.PathPrefix("/api").Subrouter()
+	v1 := api.PathPrefix("/v1").Subrouter()
+	v1.HandleFunc("/users/{id}", getUser).Methods("GET")
+	v1.HandleFunc("/users", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	}).Methods("POST")
+
+	http.ListenAndServe(":3000", r)
+}
@@ -0,0 +1,309 @@
+package nrgorilla
+
+import (
+	"fmt"
+	"go/token"
+	"strconv"
+
+	"github.com/dave/dst"
+	"github.com/dave/dst/dstutil"
+	"github.com/newrelic/go-easy-instrumentation/internal/codegen"
+	"github.com/newrelic/go-easy-instrumentation/internal/comment"
+	"github.com/newrelic/go-easy-instrumentation/internal/report"
+	"github.com/newrelic/go-easy-instrumentation/internal/util"
+	"github.com/newrelic/go-easy-instrumentation/parser"
+	"github.com/newrelic/go-easy-instrumentation/parser/tracestate"
+)
+
+const (
+	GorillaImportPath = "github.com/gorilla/mux"
+)
+
+// Return the variable name of the gorilla/mux router object. Router options that return the router
+// itself may be chained to the call that creates it.
+// Ex:
+//
+//	router := mux.NewRouter()
+//	^^^^^^
+//	router := mux.NewRouter().StrictSlash(true)
+//	^^^^^^
+func GetGorillaRouterName(stmt dst.Stmt) string {
+	// Verify we're dealing with an assignment operation
+	v, ok := stmt.(*dst.AssignStmt)
+	if !ok || len(v.Rhs) != 1 || len(v.Lhs) != 1 {
+		return ""
+	}
+
+	// Verify the Rhs of the assignment is a Call Expression
+	call, ok := v.Rhs[0].(*dst.CallExpr)
+	if !ok {
+		return ""
+	}
+
+	// Unwrap the router options chained to the router
+	for {
+		sel, ok := call.Fun.(*dst.SelectorExpr)
+		if !ok {
+			break
+		}
+		switch sel.Sel.Name {
+		case "StrictSlash", "SkipClean", "UseEncodedPath":
+		default:
+			return ""
+		}
+		call, ok = sel.X.(*dst.CallExpr)
+		if !ok {
+			return ""
+		}
+	}
+
+	// Reject calls that are not to the `NewRouter` Fn. Verify gorilla relationship with the import path.
+	ident, ok := call.Fun.(*dst.Ident)
+	if !ok || ident.Name != "NewRouter" || ident.Path != GorillaImportPath {
+		return ""
+	}
+
+	router, ok := v.Lhs[0].(*dst.Ident)
+	if !ok || router.Name == "_" {
+		return ""
+	}
+	return router.Name
+}
+
+// Return the variable name of a gorilla/mux subrouter, the name of the router it belongs to, and the path
+// prefix it adds to its routes, if it is known.
+// Ex:
+//
+//	api := router.PathPrefix("/api").Subrouter()
+//	^^^    ^^^^^^             ^^^^
+func getGorillaSubrouter(stmt dst.Stmt) (name, parent, prefix string) {
+	v, ok := stmt.(*dst.AssignStmt)
+	if !ok || len(v.Rhs) != 1 || len(v.Lhs) != 1 {
+		return "", "", ""
+	}
+	lhs, ok := v.Lhs[0].(*dst.Ident)
+	if !ok {
+		return "", "", ""
+	}
+	call, ok := v.Rhs[0].(*dst.CallExpr)
+	if !ok {
+		return "", "", ""
+	}
+	sel, ok := call.Fun.(*dst.SelectorExpr)
+	if !ok || sel.Sel.Name != "Subrouter" || len(call.Args) != 0 {
+		return "", "", ""
+	}
+
+	// walk the matchers of the route the subrouter is created from back to the router it belongs to
+	expr := sel.X
+	for {
+		switch x := expr.(type) {
+		case *dst.Ident:
+			return lhs.Name, x.Name, prefix
+		case *dst.CallExpr:
+			matcher, ok := x.Fun.(*dst.SelectorExpr)
+			if !ok {
+				return "", "", ""
+			}
+			if matcher.Sel.Name == "PathPrefix" && len(x.Args) == 1 {
+				if path, ok := stringLiteral(x.Args[0]); ok {
+					prefix = path + prefix
+				}
+			}
+			expr = matcher.X
+		default:
+			return "", "", ""
+		}
+	}
+}
+
+// routePrefix returns the path prefix of the routes registered to a router, which is the path prefix of
+// the subrouters it was created from in the statements of the block before the statement at index.
+func routePrefix(block []dst.Stmt, index int, router string) string {
+	prefix := ""
+	for i := index - 1; i >= 0; i-- {
+		name, parent, path := getGorillaSubrouter(block[i])
+		if name == router {
+			prefix = path + prefix
+			router = parent
+		}
+	}
+	return prefix
+}
+
+// Extract the CallExpr node that registers a route handler, and the HTTP method of the route if a single
+// one is matched by chaining Methods to it.
+//
+//	router.HandleFunc("/", func(w, r){...}).Methods("GET")
+//	_______^^^^^^^^^^__________________________________^^^
+func getGorillaHandleFunc(manager *parser.InstrumentationManager, node dst.Node) (string, *dst.CallExpr) {
+	stmt, ok := node.(*dst.ExprStmt)
+	if !ok {
+		return "", nil
+	}
+	call, ok := stmt.X.(*dst.CallExpr)
+	if !ok {
+		return "", nil
+	}
+
+	method := ""
+	for {
+		sel, ok := call.Fun.(*dst.SelectorExpr)
+		if !ok {
+			return "", nil
+		}
+		switch sel.Sel.Name {
+		case "HandleFunc":
+			if util.PackagePath(sel.Sel, manager.GetDecoratorPackage()) != GorillaImportPath {
+				return "", nil
+			}
+			return method, call
+		case "Methods":
+			if len(call.Args) == 1 {
+				method, _ = stringLiteral(call.Args[0])
+			}
+		case "Name", "Schemes", "Host", "Headers", "Queries":
+		default:
+			return "", nil
+		}
+		call, ok = sel.X.(*dst.CallExpr)
+		if !ok {
+			return "", nil
+		}
+	}
+}
+
+// Get the name of the route being registered to the handler for naming purposes
+//
+//	router.HandleFunc("/routename", func(w, r){...})
+//	___________________^^^^^^^^^^
+func getGorillaHandlerRouteName(callExpr *dst.CallExpr) (string, *dst.FuncLit) {
+	if callExpr == nil || len(callExpr.Args) != 2 {
+		return "", nil
+	}
+
+	routeName, ok := stringLiteral(callExpr.Args[0])
+	if !ok {
+		return "", nil
+	}
+
+	fnLit, ok := callExpr.Args[1].(*dst.FuncLit)
+	if !ok {
+		return "", nil
+	}
+
+	return routeName, fnLit
+}
+
+// stringLiteral returns the value of a string literal.
+func stringLiteral(expr dst.Expr) (string, bool) {
+	lit, ok := expr.(*dst.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		return "", false
+	}
+	value, err := strconv.Unquote(lit.Value)
+	if err != nil {
+		return "", false
+	}
+	return value, true
+}
+
+// InstrumentGorillaMiddleware detects whether a gorilla/mux Router has been initialized
+// and adds New Relic Go Agent Middleware via the router.Use() method to
+// instrument the routes registered to the router. Subrouters created from the router
+// run its middleware for their routes too, so no middleware is added to them.
+func InstrumentGorillaMiddleware(manager *parser.InstrumentationManager, stmt dst.Stmt, c *dstutil.Cursor, tracing *tracestate.State) bool {
+	routerName := GetGorillaRouterName(stmt)
+	if routerName == "" {
+		return false
+	}
+
+	// Append at the current stmt location
+	middleware, goGet := NrGorillaMiddleware(routerName, tracing.AgentVariable())
+	comment.Debug(manager.GetDecoratorPackage(), stmt, fmt.Sprintf("Injecting nrgorilla middleware for router: %s", routerName))
+	report.Action(manager.GetDecoratorPackage(), stmt, "nrgorilla", report.KindMiddleware, fmt.Sprintf("added nrgorilla middleware to router %s", routerName))
+	c.InsertAfter(middleware)
+	manager.AddImport(goGet)
+	return true
+}
+
+// InstrumentGorillaRouterLiteral detects if a route of a gorilla/mux Router or Subrouter uses
+// a function literal and adds Txn/Segment tracing logic directly to the function literal
+// block. The segment is named after the whole path of the route, including the path prefix
+// of the subrouter it is registered to.
+func InstrumentGorillaRouterLiteral(manager *parser.InstrumentationManager, stmt dst.Stmt, c *dstutil.Cursor, tracing *tracestate.State) bool {
+	methodName, callExpr := getGorillaHandleFunc(manager, c.Node())
+	if callExpr == nil {
+		return false
+	}
+
+	routeName, fnLit := getGorillaHandlerRouteName(callExpr)
+	if routeName == "" || fnLit == nil {
+		return false
+	}
+
+	reqArgName := getHTTPRequestArgName(fnLit)
+	if reqArgName == "" {
+		return false
+	}
+
+	if block, ok := c.Parent().(*dst.BlockStmt); ok && c.Index() >= 0 {
+		if router, ok := callExpr.Fun.(*dst.SelectorExpr).X.(*dst.Ident); ok {
+			routeName = routePrefix(block.List, c.Index(), router.Name) + routeName
+		}
+	}
+
+	segmentName := routeName
+	if methodName != "" {
+		segmentName = methodName + ":" + routeName
+	}
+	if manager.WasInstrumented(fnLit) {
+		report.Skipped(manager.GetDecoratorPackage(), stmt, "nrgorilla", report.CodeAlreadyInstrumented, fmt.Sprintf("gorilla route handler %s is already instrumented", segmentName))
+		return false
+	}
+
+	txn := codegen.TxnFromContext(manager.TransactionVariableName(), codegen.HttpRequestContext(reqArgName))
+	if txn == nil {
+		return false
+	}
+
+	comment.Debug(manager.GetDecoratorPackage(), stmt, fmt.Sprintf("Injecting segment for gorilla route: %s", segmentName))
+	report.Action(manager.GetDecoratorPackage(), stmt, "nrgorilla", report.KindSegment, fmt.Sprintf("added segment %s to gorilla route handler", segmentName))
+	codegen.PrependStatementToFunctionLit(fnLit, codegen.DeferSegment(segmentName, tracing.TransactionVariable()))
+	codegen.PrependStatementToFunctionLit(fnLit, txn)
+
+	return true
+}
+
+// getHTTPRequestArgName returns the name of the *http.Request argument of a function literal, if it has one.
+func getHTTPRequestArgName(fnLit *dst.FuncLit) string {
+	if fnLit == nil || fnLit.Type == nil || fnLit.Type.Params == nil {
+		return ""
+	}
+
+	for _, field := range fnLit.Type.Params.List {
+		if len(field.Names) == 0 {
+			continue
+		}
+
+		starExpr, ok := field.Type.(*dst.StarExpr)
+		if !ok {
+			continue
+		}
+
+		ident, ok := starExpr.X.(*dst.Ident)
+		if ok && ident.Name == "Request" && ident.Path == "net/http" && field.Names[0].Name != "_" {
+			return field.Names[0].Name
+		}
+	}
+
+	return ""
+}
+
+// Uninstrument Functions
+// ////////////////////////////////////////////
+
+// RemoveGorillaMiddleware removes the New Relic middleware that was added to a gorilla/mux router.
+func RemoveGorillaMiddleware(manager *parser.InstrumentationManager, c *dstutil.Cursor) bool {
+	return parser.RemoveMiddleware(c, NrGorillaImportPath)
+}
@@ -0,0 +1,377 @@
+package nrgorilla_test
+
+import (
+	"go/token"
+	"testing"
+
+	"github.com/dave/dst"
+	"github.com/newrelic/go-easy-instrumentation/integrations/nragent"
+	"github.com/newrelic/go-easy-instrumentation/integrations/nrgorilla"
+	"github.com/newrelic/go-easy-instrumentation/parser"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetGorillaRouterName(t *testing.T) {
+	newRouter := func(fun dst.Expr) dst.Stmt {
+		return &dst.AssignStmt{
+			Lhs: []dst.Expr{&dst.Ident{Name: "router"}},
+			Tok: token.DEFINE,
+			Rhs: []dst.Expr{&dst.CallExpr{Fun: fun}},
+		}
+	}
+
+	tests := []struct {
+		name string
+		stmt dst.Stmt
+		want string
+	}{
+		{
+			name: "detect gorilla router",
+			stmt: newRouter(&dst.Ident{Name: "NewRouter", Path: nrgorilla.GorillaImportPath}),
+			want: "router",
+		},
+		{
+			name: "detect gorilla router with chained options",
+			stmt: newRouter(&dst.SelectorExpr{
+				X: &dst.CallExpr{
+					Fun: &dst.Ident{Name: "NewRouter", Path: nrgorilla.GorillaImportPath},
+				},
+				Sel: &dst.Ident{Name: "StrictSlash"},
+			}),
+			want: "router",
+		},
+		{
+			name: "incorrect import path",
+			stmt: newRouter(&dst.Ident{Name: "NewRouter", Path: "github.com/go-chi/chi/v5"}),
+			want: "",
+		},
+		{
+			name: "incorrect function",
+			stmt: newRouter(&dst.Ident{Name: "NewRoute", Path: nrgorilla.GorillaImportPath}),
+			want: "",
+		},
+		{
+			name: "subrouter",
+			stmt: newRouter(&dst.SelectorExpr{
+				X: &dst.CallExpr{
+					Fun: &dst.Ident{Name: "NewRouter", Path: nrgorilla.GorillaImportPath},
+				},
+				Sel: &dst.Ident{Name: "Subrouter"},
+			}),
+			want: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer parser.PanicRecovery(t)
+			got := nrgorilla.GetGorillaRouterName(tt.stmt)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestInstrumentGorillaMiddleware(t *testing.T) {
+	tests := []struct {
+		name   string
+		code   string
+		expect string
+	}{
+		{
+			name: "detect and trace gorilla router in main function",
+			code: `package main
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+func main() {
+	router := mux.NewRouter()
+	api := router.PathPrefix("/api").Subrouter()
+	api.Use(mux.CORSMethodMiddleware(router))
+	http.ListenAndServe(":3000", router)
+}
+`,
+			expect: `package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/newrelic/go-agent/v3/integrations/nrgorilla"
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+func main() {
+	NewRelicAgent, agentInitError := newrelic.NewApplication(newrelic.ConfigFromEnvironment())
+	if agentInitError != nil {
+		panic(agentInitError)
+	}
+
+	router := mux.NewRouter()
+	router.Use(nrgorilla.Middleware(NewRelicAgent))
+	api := router.PathPrefix("/api").Subrouter()
+	api.Use(mux.CORSMethodMiddleware(router))
+	http.ListenAndServe(":3000", router)
+
+	NewRelicAgent.Shutdown(5 * time.Second)
+}
+`,
+		},
+		{
+			name: "detect and trace gorilla router in setup function",
+			code: `package main
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+func setupRouter() {
+	router := mux.NewRouter().StrictSlash(true)
+	http.ListenAndServe(":3000", router)
+}
+
+func main() {
+	setupRouter()
+}
+`,
+			expect: `package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/newrelic/go-agent/v3/integrations/nrgorilla"
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+func setupRouter(nrTxn *newrelic.Transaction) {
+	defer nrTxn.StartSegment("setupRouter").End()
+
+	router := mux.NewRouter().StrictSlash(true)
+	router.Use(nrgorilla.Middleware(nrTxn.Application()))
+	http.ListenAndServe(":3000", router)
+}
+
+func main() {
+	NewRelicAgent, agentInitError := newrelic.NewApplication(newrelic.ConfigFromEnvironment())
+	if agentInitError != nil {
+		panic(agentInitError)
+	}
+
+	nrTxn := NewRelicAgent.StartTransaction("setupRouter")
+	setupRouter(nrTxn)
+	nrTxn.End()
+
+	NewRelicAgent.Shutdown(5 * time.Second)
+}
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer parser.PanicRecovery(t)
+			got := parser.RunStatelessTracingFunction(t, tt.code, nragent.InstrumentMain, nrgorilla.InstrumentGorillaMiddleware)
+			assert.Equal(t, tt.expect, got)
+		})
+	}
+}
+
+func TestInstrumentGorillaRouterLiteral(t *testing.T) {
+	tests := []struct {
+		name   string
+		code   string
+		expect string
+	}{
+		{
+			name: "instrument route literal with method",
+			code: `package main
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+func main() {
+	router := mux.NewRouter()
+	router.HandleFunc("/literal", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("welcome"))
+	}).Methods("GET")
+	http.ListenAndServe(":3000", router)
+}`,
+			expect: `package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+func main() {
+	NewRelicAgent, agentInitError := newrelic.NewApplication(newrelic.ConfigFromEnvironment())
+	if agentInitError != nil {
+		panic(agentInitError)
+	}
+
+	router := mux.NewRouter()
+	router.HandleFunc("/literal", func(w http.ResponseWriter, r *http.Request) {
+		nrTxn := newrelic.FromContext(r.Context())
+
+		defer nrTxn.StartSegment("GET:/literal").End()
+
+		w.Write([]byte("welcome"))
+	}).Methods("GET")
+	http.ListenAndServe(":3000", router)
+
+	NewRelicAgent.Shutdown(5 * time.Second)
+}
+`,
+		},
+		{
+			name: "instrument route literal of subrouter",
+			code: `package main
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+func main() {
+	router := mux.NewRouter()
+	api := router.PathPrefix("/api").Subrouter()
+	v1 := api.PathPrefix("/v1").Subrouter()
+	v1.HandleFunc("/users", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	})
+	http.ListenAndServe(":3000", router)
+}`,
+			expect: `package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+func main() {
+	NewRelicAgent, agentInitError := newrelic.NewApplication(newrelic.ConfigFromEnvironment())
+	if agentInitError != nil {
+		panic(agentInitError)
+	}
+
+	router := mux.NewRouter()
+	api := router.PathPrefix("/api").Subrouter()
+	v1 := api.PathPrefix("/v1").Subrouter()
+	v1.HandleFunc("/users", func(w http.ResponseWriter, r *http.Request) {
+		nrTxn := newrelic.FromContext(r.Context())
+
+		defer nrTxn.StartSegment("/api/v1/users").End()
+
+		w.WriteHeader(http.StatusCreated)
+	})
+	http.ListenAndServe(":3000", router)
+
+	NewRelicAgent.Shutdown(5 * time.Second)
+}
+`,
+		},
+		{
+			name: "do not instrument net/http mux",
+			code: `package main
+import (
+	"net/http"
+)
+
+func main() {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/literal", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("welcome"))
+	})
+	http.ListenAndServe(":3000", mux)
+}`,
+			expect: `package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+func main() {
+	NewRelicAgent, agentInitError := newrelic.NewApplication(newrelic.ConfigFromEnvironment())
+	if agentInitError != nil {
+		panic(agentInitError)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/literal", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("welcome"))
+	})
+	http.ListenAndServe(":3000", mux)
+
+	NewRelicAgent.Shutdown(5 * time.Second)
+}
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer parser.PanicRecovery(t)
+			got := parser.RunStatelessTracingFunction(t, tt.code, nragent.InstrumentMain, nrgorilla.InstrumentGorillaRouterLiteral)
+			assert.Equal(t, tt.expect, got)
+		})
+	}
+}
+
+func TestRemoveGorillaMiddleware(t *testing.T) {
+	code := `package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/newrelic/go-agent/v3/integrations/nrgorilla"
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+func main() {
+	NewRelicAgent, agentInitError := newrelic.NewApplication(newrelic.ConfigFromEnvironment())
+	if agentInitError != nil {
+		panic(agentInitError)
+	}
+
+	router := mux.NewRouter()
+	router.Use(nrgorilla.Middleware(NewRelicAgent))
+	http.ListenAndServe(":3000", router)
+
+	NewRelicAgent.Shutdown(5 * time.Second)
+}
+`
+	expect := `package main
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+func main() {
+	router := mux.NewRouter()
+	http.ListenAndServe(":3000", router)
+}
+`
+	defer parser.PanicRecovery(t)
+	got, warnings := parser.RunUninstrumentFunctions(t, code, nrgorilla.RemoveGorillaMiddleware, nragent.RemoveAgent)
+	assert.Equal(t, expect, got)
+	assert.Empty(t, warnings)
+}
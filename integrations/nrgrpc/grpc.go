@@ -3,6 +3,7 @@ package nrgrpc
 import (
 	"fmt"
 	"go/token"
+	"slices"
 	"strings"
 
 	"github.com/dave/dst"
@@ -11,6 +12,7 @@ import (
 	"github.com/newrelic/go-easy-instrumentation/internal/codegen"
 	"github.com/newrelic/go-easy-instrumentation/parser"
 	"github.com/newrelic/go-easy-instrumentation/internal/comment"
+	"github.com/newrelic/go-easy-instrumentation/internal/report"
 	"github.com/newrelic/go-easy-instrumentation/internal/util"
 	"github.com/newrelic/go-easy-instrumentation/parser/facts"
 	"github.com/newrelic/go-easy-instrumentation/parser/tracestate"
@@ -153,17 +155,18 @@ func InstrumentGrpcServerMethod(manager *parser.InstrumentationManager, c *dstut
 	}
 
 	// find either a context or a server stream object
-	txnData, ok := GetTxnFromGrpcServer(manager, funcDecl.Type.Params.List, codegen.DefaultTransactionVariable)
+	txnData, ok := GetTxnFromGrpcServer(manager, funcDecl.Type.Params.List, manager.TransactionVariableName())
 	if !ok {
 		return
 	}
 
 	// ok is true if the body of this function has any tracing code added to it. If this is true, we know it needs a transaction to get
 	// pulled from the grpc server object
-	node, ok := parser.TraceFunction(manager, funcDecl, tracestate.FunctionBody(codegen.DefaultTransactionVariable, txnData.TraceObject))
+	node, ok := parser.TraceFunction(manager, funcDecl, tracestate.FunctionBody(manager.TransactionVariableName(), txnData.TraceObject))
 	decl := node.(*dst.FuncDecl)
 	if ok && txnData.TxnAssignment != nil {
 		comment.Debug(manager.GetDecoratorPackage(), funcDecl, fmt.Sprintf("Instrumenting gRPC server method: %s", funcDecl.Name.Name))
+		report.Action(manager.GetDecoratorPackage(), funcDecl, "nrgrpc", report.KindTransaction, fmt.Sprintf("used the transaction of the gRPC server in method %s", funcDecl.Name.Name))
 		decl.Body.List = append([]dst.Stmt{txnData.TxnAssignment}, decl.Body.List...)
 	}
 }
@@ -174,6 +177,7 @@ func InstrumentGrpcDial(manager *parser.InstrumentationManager, c *dstutil.Curso
 	currentNode := c.Node()
 	if callExpr, ok := GrpcDialCall(currentNode); ok {
 		comment.Debug(manager.GetDecoratorPackage(), currentNode, "Injecting gRPC client interceptors into grpc.Dial")
+		report.Action(manager.GetDecoratorPackage(), currentNode, "nrgrpc", report.KindMiddleware, "added New Relic gRPC client interceptors to grpc.Dial")
 		callExpr.Args = append(callExpr.Args, NrGrpcUnaryClientInterceptor(callExpr))
 		callExpr.Args = append(callExpr.Args, NrGrpcStreamClientInterceptor(callExpr))
 		manager.AddImport(NrgrpcImportPath)
@@ -193,6 +197,7 @@ func InstrumentGrpcServer(manager *parser.InstrumentationManager, stmt dst.Stmt,
 
 	// inject middleware
 	comment.Debug(manager.GetDecoratorPackage(), stmt, "Injecting gRPC server interceptors into grpc.NewServer")
+	report.Action(manager.GetDecoratorPackage(), stmt, "nrgrpc", report.KindMiddleware, "added New Relic gRPC server interceptors to grpc.NewServer")
 	callExpr.Args = append(callExpr.Args, NrGrpcUnaryServerInterceptor(tracing.AgentVariable(), callExpr))
 	callExpr.Args = append(callExpr.Args, NrGrpcStreamServerInterceptor(tracing.AgentVariable(), callExpr))
 	manager.AddImport(NrgrpcImportPath)
@@ -272,3 +277,48 @@ func FindGrpcServerObject(pkg *decorator.Package, node dst.Node) (facts.Entry, b
 	}
 	return facts.Entry{Name: handlerTypeString, Fact: facts.GrpcServerType}, true
 }
+
+// Uninstrument Functions
+// ////////////////////////////////////////////
+
+// isNrGrpcInterceptor returns true if the expression is a gRPC option that adds a New Relic interceptor, such as
+// `grpc.WithUnaryInterceptor(nrgrpc.UnaryClientInterceptor)`.
+func isNrGrpcInterceptor(expr dst.Expr) bool {
+	call, ok := expr.(*dst.CallExpr)
+	if !ok || len(call.Args) != 1 {
+		return false
+	}
+	ident, ok := call.Fun.(*dst.Ident)
+	if !ok || ident.Path != GrpcImportPath {
+		return false
+	}
+	switch ident.Name {
+	case "WithUnaryInterceptor", "WithStreamInterceptor", "UnaryInterceptor", "StreamInterceptor":
+	default:
+		return false
+	}
+
+	interceptor := call.Args[0]
+	if inner, ok := interceptor.(*dst.CallExpr); ok {
+		interceptor = inner.Fun
+	}
+	nrIdent, ok := interceptor.(*dst.Ident)
+	return ok && nrIdent.Path == NrgrpcImportPath
+}
+
+// RemoveGrpcInterceptors removes the New Relic interceptors that were added to a gRPC client or server.
+func RemoveGrpcInterceptors(manager *parser.InstrumentationManager, c *dstutil.Cursor) bool {
+	call, ok := c.Node().(*dst.CallExpr)
+	if !ok || !slices.ContainsFunc(call.Args, isNrGrpcInterceptor) {
+		return false
+	}
+
+	call.Args = slices.DeleteFunc(call.Args, isNrGrpcInterceptor)
+
+	// undo the spacing that GetCallExpressionArgumentSpacing adds to calls with a single argument
+	if len(call.Args) == 1 {
+		call.Args[0].Decorations().Before = dst.None
+		call.Args[0].Decorations().After = dst.None
+	}
+	return true
+}
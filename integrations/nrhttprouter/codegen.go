@@ -0,0 +1,24 @@
+package nrhttprouter
+
+import "github.com/dave/dst"
+
+const (
+	NrHttprouterImportPath = "github.com/newrelic/go-agent/v3/integrations/nrhttprouter"
+)
+
+// NrHttprouterNew does an in place edit of a call to httprouter.New, replacing it with a call to
+// nrhttprouter.New, which creates a router that starts a transaction for every request it routes.
+// Ex:
+//
+//	router := httprouter.New()
+//	router := nrhttprouter.New(app)
+//
+// agentVariable should be passed from tracestate.State and WILL NOT BE CLONED
+func NrHttprouterNew(agentVariable dst.Expr, call *dst.CallExpr) string {
+	call.Fun = &dst.Ident{
+		Name: "New",
+		Path: NrHttprouterImportPath,
+	}
+	call.Args = []dst.Expr{agentVariable}
+	return NrHttprouterImportPath
+}
@@ -0,0 +1,27 @@
+package nrhttprouter_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/dave/dst"
+	"github.com/newrelic/go-easy-instrumentation/integrations/nrhttprouter"
+)
+
+func TestNrHttprouterNew(t *testing.T) {
+	call := &dst.CallExpr{
+		Fun: &dst.Ident{Name: "New", Path: nrhttprouter.HttprouterImportPath},
+	}
+	want := &dst.CallExpr{
+		Fun:  &dst.Ident{Name: "New", Path: nrhttprouter.NrHttprouterImportPath},
+		Args: []dst.Expr{dst.NewIdent("NewRelicApplication")},
+	}
+
+	imp := nrhttprouter.NrHttprouterNew(dst.NewIdent("NewRelicApplication"), call)
+	if !reflect.DeepEqual(call, want) {
+		t.Errorf("nrhttprouter.NrHttprouterNew() = %v, want %v", call, want)
+	}
+	if imp != nrhttprouter.NrHttprouterImportPath {
+		t.Errorf("nrhttprouter.NrHttprouterNew() = %v, want %v", imp, nrhttprouter.NrHttprouterImportPath)
+	}
+}
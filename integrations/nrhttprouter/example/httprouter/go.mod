@@ -0,0 +1,5 @@
+module httprouter-app
+
+go 1.25
+
+require github.com/julienschmidt/httprouter v1.3.0
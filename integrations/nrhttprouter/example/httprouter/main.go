@@ -0,0 +1,49 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+var books = map[string]string{
+	"1": "The Go Programming Language",
+}
+
+var errNotFound = errors.New("book not found")
+
+func findBook(id string) (string, error) {
+	title, ok := books[id]
+	if !ok {
+		return "", errNotFound
+	}
+	return title, nil
+}
+
+func index(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	fmt.Fprint(w, "Welcome!\n")
+}
+
+func book(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	title, err := findBook(ps.ByName("id"))
+	if err != nil {
+		slog.Error(err.Error())
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	fmt.Fprintf(w, "%s\n", title)
+}
+
+func main() {
+	router := httprouter.New()
+	router.GET("/", index)
+	router.GET("/books/:id", book)
+	router.GET("/hello/:name", func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		fmt.Fprintf(w, "hello, %s!\n", ps.ByName("name"))
+	})
+
+	http.ListenAndServe(":8080", router)
+}
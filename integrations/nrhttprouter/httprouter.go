@@ -0,0 +1,344 @@
+package nrhttprouter
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"github.com/dave/dst"
+	"github.com/dave/dst/decorator"
+	"github.com/dave/dst/dstutil"
+	"github.com/newrelic/go-easy-instrumentation/integrations/nrnethttp"
+	"github.com/newrelic/go-easy-instrumentation/internal/codegen"
+	"github.com/newrelic/go-easy-instrumentation/internal/comment"
+	"github.com/newrelic/go-easy-instrumentation/internal/report"
+	"github.com/newrelic/go-easy-instrumentation/parser"
+	"github.com/newrelic/go-easy-instrumentation/parser/tracestate"
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+const (
+	HttprouterImportPath = "github.com/julienschmidt/httprouter"
+)
+
+// Return the variable name of the httprouter router object, and the call that creates it.
+// Ex:
+//
+//	router := httprouter.New()
+//	^^^^^^
+func GetHttprouterName(stmt dst.Stmt) (string, *dst.CallExpr) {
+	// Verify we're dealing with an assignment operation
+	v, ok := stmt.(*dst.AssignStmt)
+	if !ok || len(v.Rhs) != 1 || len(v.Lhs) != 1 {
+		return "", nil
+	}
+
+	// Verify the Rhs of the assignment is a Call Expression
+	call, ok := v.Rhs[0].(*dst.CallExpr)
+	if !ok || len(call.Args) != 0 {
+		return "", nil
+	}
+
+	// Reject calls that are not to the `New` Fn. Verify httprouter relationship with the import path.
+	ident, ok := call.Fun.(*dst.Ident)
+	if !ok || ident.Name != "New" || ident.Path != HttprouterImportPath {
+		return "", nil
+	}
+
+	router, ok := v.Lhs[0].(*dst.Ident)
+	if !ok {
+		return "", nil
+	}
+	return router.Name, call
+}
+
+// GetHandleRequestArgName returns the name of the *http.Request parameter of a function declaration or
+// literal if it is an httprouter.Handle.
+//
+//	func myHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params)
+//	______________________________________^
+func GetHandleRequestArgName(fnType *dst.FuncType) string {
+	if fnType == nil || fnType.Params == nil || fnType.Results != nil {
+		return ""
+	}
+
+	names := []string{}
+	types := []dst.Expr{}
+	for _, field := range fnType.Params.List {
+		if len(field.Names) == 0 {
+			names = append(names, "")
+			types = append(types, field.Type)
+		}
+		for _, name := range field.Names {
+			names = append(names, name.Name)
+			types = append(types, field.Type)
+		}
+	}
+	if len(types) != 3 {
+		return ""
+	}
+
+	// NOTE: These should be Idents, not SelectorExprs, since package.Type is
+	// considered a Qualified Identifier in Go, not a Selector
+	if ident, ok := types[0].(*dst.Ident); !ok || ident.Name != "ResponseWriter" || ident.Path != nrnethttp.HttpImportPath {
+		return ""
+	}
+	star, ok := types[1].(*dst.StarExpr)
+	if !ok {
+		return ""
+	}
+	if ident, ok := star.X.(*dst.Ident); !ok || ident.Name != "Request" || ident.Path != nrnethttp.HttpImportPath {
+		return ""
+	}
+	if ident, ok := types[2].(*dst.Ident); !ok || ident.Name != "Params" || ident.Path != HttprouterImportPath {
+		return ""
+	}
+	if names[1] == "" || names[1] == "_" {
+		return ""
+	}
+	return names[1]
+}
+
+// isRouterType returns true if t is *httprouter.Router.
+func isRouterType(t types.Type) bool {
+	ptr, ok := t.(*types.Pointer)
+	if !ok {
+		return false
+	}
+	named, ok := ptr.Elem().(*types.Named)
+	if !ok || named.Obj().Pkg() == nil {
+		return false
+	}
+	return named.Obj().Name() == "Router" && named.Obj().Pkg().Path() == HttprouterImportPath
+}
+
+// expectsRouterType returns true if an expression is used where a *httprouter.Router is expected. The stack holds
+// the nodes that contain the expression, from the outermost in.
+func expectsRouterType(info *types.Info, expr ast.Expr, stack []ast.Node) bool {
+	if len(stack) == 0 {
+		return false
+	}
+	switch p := stack[len(stack)-1].(type) {
+	case *ast.ReturnStmt:
+		var sig *types.Signature
+		for i := len(stack) - 1; i >= 0 && sig == nil; i-- {
+			switch fn := stack[i].(type) {
+			case *ast.FuncDecl:
+				if obj := info.Defs[fn.Name]; obj != nil {
+					sig, _ = obj.Type().(*types.Signature)
+				}
+			case *ast.FuncLit:
+				sig, _ = info.TypeOf(fn).(*types.Signature)
+			}
+		}
+		if sig == nil || sig.Results().Len() != len(p.Results) {
+			return false
+		}
+		for i, result := range p.Results {
+			if result == expr {
+				return isRouterType(sig.Results().At(i).Type())
+			}
+		}
+	case *ast.CallExpr:
+		sig, ok := info.TypeOf(p.Fun).(*types.Signature)
+		if !ok {
+			return false
+		}
+		params := sig.Params()
+		for i, arg := range p.Args {
+			if arg != expr {
+				continue
+			}
+			if sig.Variadic() && i >= params.Len()-1 && p.Ellipsis == token.NoPos {
+				slice, ok := params.At(params.Len() - 1).Type().(*types.Slice)
+				return ok && isRouterType(slice.Elem())
+			}
+			return i < params.Len() && isRouterType(params.At(i).Type())
+		}
+	case *ast.AssignStmt:
+		if p.Tok != token.ASSIGN || len(p.Lhs) != len(p.Rhs) {
+			return false
+		}
+		for i, rhs := range p.Rhs {
+			if rhs == expr {
+				return isRouterType(info.TypeOf(p.Lhs[i]))
+			}
+		}
+	case *ast.ValueSpec:
+		return p.Type != nil && isRouterType(info.TypeOf(p.Type))
+	case *ast.SendStmt:
+		ch, ok := info.TypeOf(p.Chan).Underlying().(*types.Chan)
+		return ok && p.Value == expr && isRouterType(ch.Elem())
+	case *ast.KeyValueExpr:
+		if p.Value != expr {
+			return false
+		}
+		if key, ok := p.Key.(*ast.Ident); ok {
+			if field, ok := info.Uses[key].(*types.Var); ok && field.IsField() {
+				return isRouterType(field.Type())
+			}
+		}
+		// the value of a map or slice element is expected to have the element type of the literal
+		return expectsRouterType(info, p, stack[:len(stack)-1])
+	case *ast.CompositeLit:
+		switch t := info.TypeOf(p).Underlying().(type) {
+		case *types.Struct:
+			for i, elt := range p.Elts {
+				if elt == expr && i < t.NumFields() {
+					return isRouterType(t.Field(i).Type())
+				}
+			}
+		case *types.Slice:
+			return isRouterType(t.Elem())
+		case *types.Array:
+			return isRouterType(t.Elem())
+		case *types.Map:
+			return isRouterType(t.Elem())
+		}
+	}
+	return false
+}
+
+// routerKeepsType returns true if the router created in stmt is assigned to a variable that is declared as a
+// *httprouter.Router, or is used where one is expected in the function that creates it, such as when it is
+// returned from a function whose result is a *httprouter.Router. Replacing that router with the
+// *nrhttprouter.Router that nrhttprouter.New returns would not compile.
+func routerKeepsType(pkg *decorator.Package, stmt dst.Stmt) bool {
+	if pkg == nil || pkg.Decorator == nil || pkg.TypesInfo == nil {
+		return false
+	}
+	assign, ok := pkg.Decorator.Ast.Nodes[stmt].(*ast.AssignStmt)
+	if !ok || len(assign.Lhs) != 1 {
+		return false
+	}
+	lhs, ok := assign.Lhs[0].(*ast.Ident)
+	if !ok {
+		return false
+	}
+	router := pkg.TypesInfo.ObjectOf(lhs)
+	if router == nil {
+		return false
+	}
+	if assign.Tok == token.ASSIGN && isRouterType(router.Type()) {
+		return true
+	}
+
+	var fn ast.Node
+	for _, file := range pkg.Package.Syntax {
+		if file.Pos() > assign.Pos() || assign.End() > file.End() {
+			continue
+		}
+		path, _ := astutil.PathEnclosingInterval(file, assign.Pos(), assign.End())
+		for _, node := range path {
+			if _, ok := node.(*ast.FuncDecl); ok {
+				fn = node
+				break
+			}
+		}
+	}
+	if fn == nil {
+		return false
+	}
+
+	keepsType := false
+	stack := []ast.Node{}
+	ast.Inspect(fn, func(n ast.Node) bool {
+		if keepsType {
+			return false
+		}
+		if n == nil {
+			stack = stack[:len(stack)-1]
+			return true
+		}
+		if ident, ok := n.(*ast.Ident); ok && pkg.TypesInfo.Uses[ident] == router && expectsRouterType(pkg.TypesInfo, ident, stack) {
+			keepsType = true
+			return false
+		}
+		stack = append(stack, n)
+		return true
+	})
+	return keepsType
+}
+
+// StatelessTracingFunctions
+//////////////////////////////////////////////
+
+// InstrumentHttprouterHandle recognizes functions with the signature of an httprouter.Handle, and traces them
+// with the transaction that nrhttprouter adds to the context of the request they handle. The transaction is
+// passed on to the functions they call.
+func InstrumentHttprouterHandle(manager *parser.InstrumentationManager, c *dstutil.Cursor) {
+	var name string
+	var fnType *dst.FuncType
+	var body **dst.BlockStmt
+	switch fn := c.Node().(type) {
+	case *dst.FuncDecl:
+		if fn.Body == nil || manager.IsFunctionTraced(fn) {
+			return
+		}
+		name, fnType, body = fn.Name.Name, fn.Type, &fn.Body
+	case *dst.FuncLit:
+		if manager.WasInstrumented(fn) {
+			return
+		}
+		name, fnType, body = "function literal", fn.Type, &fn.Body
+	default:
+		return
+	}
+	reqArgName := GetHandleRequestArgName(fnType)
+	if reqArgName == "" {
+		return
+	}
+
+	txnName := manager.TransactionVariableName()
+	if _, ok := parser.TraceFunction(manager, c.Node(), tracestate.FunctionBody(txnName)); !ok {
+		return
+	}
+	comment.Debug(manager.GetDecoratorPackage(), c.Node(), fmt.Sprintf("Instrumenting httprouter handle: %s", name))
+	report.Action(manager.GetDecoratorPackage(), c.Node(), "nrhttprouter", report.KindTransaction, fmt.Sprintf("used the transaction of the request in httprouter handle %s", name))
+	(*body).List = append([]dst.Stmt{codegen.TxnFromContext(txnName, codegen.HttpRequestContext(reqArgName))}, (*body).List...)
+	manager.AddImport(codegen.NewRelicAgentImportPath)
+}
+
+// StatefulTracingFunctions
+//////////////////////////////////////////////
+
+// InstrumentHttprouter replaces the router created by httprouter.New with one created by nrhttprouter.New,
+// which starts a transaction for every request it routes. The router it creates embeds the httprouter.Router,
+// so routes are registered to it the same way. Routers that are used as a *httprouter.Router, such as one
+// returned from a function with that result type, are not replaced, since the code would no longer compile.
+func InstrumentHttprouter(manager *parser.InstrumentationManager, stmt dst.Stmt, c *dstutil.Cursor, tracing *tracestate.State) bool {
+	routerName, call := GetHttprouterName(stmt)
+	if call == nil {
+		return false
+	}
+	if routerKeepsType(manager.GetDecoratorPackage(), stmt) {
+		comment.Warn(manager.GetDecoratorPackage(), stmt, stmt, fmt.Sprintf("%s is used as a *httprouter.Router, so it can not be replaced with the *nrhttprouter.Router created by nrhttprouter.New; please instrument it manually.", routerName))
+		report.Warning(manager.GetDecoratorPackage(), stmt, "nrhttprouter", report.CodeRouterType, fmt.Sprintf("router %s is not instrumented, since it is used as a *httprouter.Router; please instrument it manually", routerName))
+		return false
+	}
+
+	comment.Debug(manager.GetDecoratorPackage(), stmt, fmt.Sprintf("Replacing httprouter.New with nrhttprouter.New for router: %s", routerName))
+	report.Action(manager.GetDecoratorPackage(), stmt, "nrhttprouter", report.KindMiddleware, fmt.Sprintf("created router %s with nrhttprouter.New", routerName))
+	manager.AddImport(NrHttprouterNew(tracing.AgentVariable(), call))
+	return true
+}
+
+// Uninstrument Functions
+// ////////////////////////////////////////////
+
+// RemoveNrHttprouter creates the routers created with nrhttprouter.New with httprouter.New again.
+func RemoveNrHttprouter(manager *parser.InstrumentationManager, c *dstutil.Cursor) bool {
+	call, ok := c.Node().(*dst.CallExpr)
+	if !ok || len(call.Args) != 1 {
+		return false
+	}
+	ident, ok := call.Fun.(*dst.Ident)
+	if !ok || ident.Name != "New" || ident.Path != NrHttprouterImportPath {
+		return false
+	}
+
+	call.Fun = &dst.Ident{Name: "New", Path: HttprouterImportPath}
+	call.Args = nil
+	return true
+}
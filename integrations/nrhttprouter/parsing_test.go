@@ -0,0 +1,369 @@
+package nrhttprouter_test
+
+import (
+	"testing"
+
+	"github.com/dave/dst"
+	"github.com/newrelic/go-easy-instrumentation/integrations/nragent"
+	"github.com/newrelic/go-easy-instrumentation/integrations/nrhttprouter"
+	"github.com/newrelic/go-easy-instrumentation/parser"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetHandleRequestArgName(t *testing.T) {
+	responseWriter := &dst.Ident{Name: "ResponseWriter", Path: "net/http"}
+	request := &dst.StarExpr{X: &dst.Ident{Name: "Request", Path: "net/http"}}
+	params := &dst.Ident{Name: "Params", Path: nrhttprouter.HttprouterImportPath}
+	field := func(typ dst.Expr, names ...string) *dst.Field {
+		f := &dst.Field{Type: typ}
+		for _, name := range names {
+			f.Names = append(f.Names, dst.NewIdent(name))
+		}
+		return f
+	}
+
+	tests := []struct {
+		name   string
+		params []*dst.Field
+		want   string
+	}{
+		{
+			name:   "httprouter handle",
+			params: []*dst.Field{field(responseWriter, "w"), field(request, "r"), field(params, "ps")},
+			want:   "r",
+		},
+		{
+			name:   "unnamed params",
+			params: []*dst.Field{field(responseWriter, "w"), field(request, "req"), field(params, "_")},
+			want:   "req",
+		},
+		{
+			name:   "unnamed request",
+			params: []*dst.Field{field(responseWriter, "w"), field(request, "_"), field(params, "ps")},
+			want:   "",
+		},
+		{
+			name:   "net/http handler",
+			params: []*dst.Field{field(responseWriter, "w"), field(request, "r")},
+			want:   "",
+		},
+		{
+			name:   "other params",
+			params: []*dst.Field{field(responseWriter, "w"), field(request, "r"), field(dst.NewIdent("string"), "ps")},
+			want:   "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fnType := &dst.FuncType{Params: &dst.FieldList{List: tt.params}}
+			assert.Equal(t, tt.want, nrhttprouter.GetHandleRequestArgName(fnType))
+		})
+	}
+}
+
+func TestInstrumentHttprouter(t *testing.T) {
+	tests := []struct {
+		name   string
+		code   string
+		expect string
+	}{
+		{
+			name: "replace router in main function",
+			code: `package main
+
+import (
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+func main() {
+	router := httprouter.New()
+	http.ListenAndServe(":8080", router)
+}
+`,
+			expect: `package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/newrelic/go-agent/v3/integrations/nrhttprouter"
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+func main() {
+	NewRelicAgent, agentInitError := newrelic.NewApplication(newrelic.ConfigFromEnvironment())
+	if agentInitError != nil {
+		panic(agentInitError)
+	}
+
+	router := nrhttprouter.New(NewRelicAgent)
+	http.ListenAndServe(":8080", router)
+
+	NewRelicAgent.Shutdown(5 * time.Second)
+}
+`,
+		},
+		{
+			name: "replace router in setup function",
+			code: `package main
+
+import (
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+func setupRouter() {
+	router := httprouter.New()
+	http.ListenAndServe(":8080", router)
+}
+
+func main() {
+	setupRouter()
+}
+`,
+			expect: `package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/newrelic/go-agent/v3/integrations/nrhttprouter"
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+func setupRouter(nrTxn *newrelic.Transaction) {
+	defer nrTxn.StartSegment("setupRouter").End()
+
+	router := nrhttprouter.New(nrTxn.Application())
+	http.ListenAndServe(":8080", router)
+}
+
+func main() {
+	NewRelicAgent, agentInitError := newrelic.NewApplication(newrelic.ConfigFromEnvironment())
+	if agentInitError != nil {
+		panic(agentInitError)
+	}
+
+	nrTxn := NewRelicAgent.StartTransaction("setupRouter")
+	setupRouter(nrTxn)
+	nrTxn.End()
+
+	NewRelicAgent.Shutdown(5 * time.Second)
+}
+`,
+		},
+		{
+			name: "keep router returned from a constructor",
+			code: `package main
+
+import (
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+func newRouter() *httprouter.Router {
+	router := httprouter.New()
+	return router
+}
+
+func main() {
+	router := newRouter()
+	http.ListenAndServe(":8080", router)
+}
+`,
+			expect: `package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+func newRouter(nrTxn *newrelic.Transaction) *httprouter.Router {
+	defer nrTxn.StartSegment("newRouter").End()
+
+	// NR WARN: router is used as a *httprouter.Router, so it can not be replaced with the *nrhttprouter.Router created by nrhttprouter.New; please instrument it manually.
+	router := httprouter.New()
+	return router
+}
+
+func main() {
+	NewRelicAgent, agentInitError := newrelic.NewApplication(newrelic.ConfigFromEnvironment())
+	if agentInitError != nil {
+		panic(agentInitError)
+	}
+
+	nrTxn := NewRelicAgent.StartTransaction("newRouter")
+	router := newRouter(nrTxn)
+	nrTxn.End()
+	http.ListenAndServe(":8080", router)
+
+	NewRelicAgent.Shutdown(5 * time.Second)
+}
+`,
+		},
+		{
+			name: "keep router passed as a *httprouter.Router",
+			code: `package main
+
+import (
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+func serve(router *httprouter.Router) {
+	http.ListenAndServe(":8080", router)
+}
+
+func main() {
+	router := httprouter.New()
+	serve(router)
+}
+`,
+			expect: `package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+func serve(router *httprouter.Router, nrTxn *newrelic.Transaction) {
+	defer nrTxn.StartSegment("serve").End()
+
+	http.ListenAndServe(":8080", router)
+}
+
+func main() {
+	NewRelicAgent, agentInitError := newrelic.NewApplication(newrelic.ConfigFromEnvironment())
+	if agentInitError != nil {
+		panic(agentInitError)
+	}
+
+	// NR WARN: router is used as a *httprouter.Router, so it can not be replaced with the *nrhttprouter.Router created by nrhttprouter.New; please instrument it manually.
+	router := httprouter.New()
+	nrTxn := NewRelicAgent.StartTransaction("serve")
+	serve(router, nrTxn)
+	nrTxn.End()
+
+	NewRelicAgent.Shutdown(5 * time.Second)
+}
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer parser.PanicRecovery(t)
+			got := parser.RunStatelessTracingFunction(t, tt.code, nragent.InstrumentMain, nrhttprouter.InstrumentHttprouter)
+			assert.Equal(t, tt.expect, got)
+		})
+	}
+}
+
+func TestInstrumentHttprouterHandle(t *testing.T) {
+	code := `package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+func greeting(name string) string {
+	return "hello, " + name
+}
+
+func hello(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	fmt.Fprint(w, greeting(ps.ByName("name")))
+}
+
+func index(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	fmt.Fprint(w, "welcome")
+}
+
+func main() {}
+`
+	expect := `package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+func greeting(name string, nrTxn *newrelic.Transaction) string {
+	defer nrTxn.StartSegment("greeting").End()
+
+	return "hello, " + name
+}
+
+func hello(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	nrTxn := newrelic.FromContext(r.Context())
+
+	fmt.Fprint(w, greeting(ps.ByName("name"), nrTxn))
+}
+
+func index(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	fmt.Fprint(w, "welcome")
+}
+
+func main() {}
+`
+	defer parser.PanicRecovery(t)
+	got := parser.RunStatelessTracingFunction(t, code, nrhttprouter.InstrumentHttprouterHandle)
+	assert.Equal(t, expect, got)
+}
+
+func TestRemoveNrHttprouter(t *testing.T) {
+	code := `package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/newrelic/go-agent/v3/integrations/nrhttprouter"
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+func main() {
+	NewRelicAgent, agentInitError := newrelic.NewApplication(newrelic.ConfigFromEnvironment())
+	if agentInitError != nil {
+		panic(agentInitError)
+	}
+
+	router := nrhttprouter.New(NewRelicAgent)
+	http.ListenAndServe(":8080", router)
+
+	NewRelicAgent.Shutdown(5 * time.Second)
+}
+`
+	expect := `package main
+
+import (
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+func main() {
+	router := httprouter.New()
+	http.ListenAndServe(":8080", router)
+}
+`
+	defer parser.PanicRecovery(t)
+	got, warnings := parser.RunUninstrumentFunctions(t, code, nrhttprouter.RemoveNrHttprouter, nragent.RemoveAgent)
+	assert.Equal(t, expect, got)
+	assert.Empty(t, warnings)
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/dave/dst"
 	"github.com/dave/dst/dstutil"
 	"github.com/newrelic/go-easy-instrumentation/internal/comment"
+	"github.com/newrelic/go-easy-instrumentation/internal/report"
 	"github.com/newrelic/go-easy-instrumentation/internal/util"
 	"github.com/newrelic/go-easy-instrumentation/parser"
 )
@@ -173,6 +174,7 @@ func InstrumentLogrusHandler(manager *parser.InstrumentationManager, c *dstutil.
 		return
 	}
 	comment.Debug(manager.GetDecoratorPackage(), decl, fmt.Sprintf("Instrumented logrus formatters in %s", decl.Name.Name))
+	report.Action(manager.GetDecoratorPackage(), decl, "nrlogrus", report.KindLogs, fmt.Sprintf("added New Relic logrus formatters in %s", decl.Name.Name))
 	manager.AddImport(NrlogrusImportPath)
 }
 
@@ -194,3 +196,47 @@ func insertDefaults(body []dst.Stmt, injectAfter map[dst.Stmt]string, packageAnc
 	}
 	return out
 }
+
+// Uninstrument Functions
+// ////////////////////////////////////////////
+
+// RemoveLogrusFormatter removes the nrlogrus.NewFormatter that InstrumentLogrusHandler wrapped a logrus formatter
+// with. A formatter that was wrapped in place is unwrapped. A SetFormatter call that sets a wrapped
+// &logrus.TextFormatter{} is the default that was injected, and is deleted; logrus formats with a TextFormatter
+// when none is set, so this is safe even if the call was written by hand.
+func RemoveLogrusFormatter(manager *parser.InstrumentationManager, c *dstutil.Cursor) bool {
+	stmt, ok := c.Node().(*dst.ExprStmt)
+	if !ok {
+		return false
+	}
+	call, ok := stmt.X.(*dst.CallExpr)
+	if !ok || len(call.Args) != 1 || util.FunctionName(call) != "SetFormatter" || !alreadyWrapped(call) {
+		return false
+	}
+	wrapper := call.Args[0].(*dst.CallExpr)
+	if len(wrapper.Args) != 2 {
+		return false
+	}
+	formatter := wrapper.Args[1]
+
+	if isDefaultTextFormatter(formatter) && c.Index() >= 0 {
+		parser.DeleteStatement(c)
+		return true
+	}
+	call.Args[0] = formatter
+	return true
+}
+
+// isDefaultTextFormatter reports whether expr is &logrus.TextFormatter{}, as built by defaultTextFormatterExpr.
+func isDefaultTextFormatter(expr dst.Expr) bool {
+	unary, ok := expr.(*dst.UnaryExpr)
+	if !ok || unary.Op != token.AND {
+		return false
+	}
+	lit, ok := unary.X.(*dst.CompositeLit)
+	if !ok || len(lit.Elts) != 0 {
+		return false
+	}
+	typ, ok := lit.Type.(*dst.Ident)
+	return ok && typ.Name == "TextFormatter" && typ.Path == LogrusImportPath
+}
@@ -1,8 +1,10 @@
 package nrlogrus_test
 
 import (
+	"path/filepath"
 	"testing"
 
+	"github.com/newrelic/go-easy-instrumentation/integrations/nragent"
 	"github.com/newrelic/go-easy-instrumentation/integrations/nrlogrus"
 	"github.com/newrelic/go-easy-instrumentation/parser"
 
@@ -806,3 +808,47 @@ func main() {
 		},
 	})
 }
+
+func TestRemoveLogrusFormatter(t *testing.T) {
+	original, instrumented := parser.InstrumentedExample(t, filepath.Join("example", "logrus"))
+
+	defer parser.PanicRecovery(t)
+	got, warnings := parser.RunUninstrumentFunctions(t, instrumented, nrlogrus.RemoveLogrusFormatter, nragent.RemoveAgent)
+	assert.Equal(t, original, got)
+	assert.Empty(t, warnings)
+}
+
+func TestRemoveLogrusFormatter_DefaultFormatter(t *testing.T) {
+	code := `package main
+
+import (
+	"github.com/newrelic/go-agent/v3/integrations/logcontext-v2/nrlogrus"
+	"github.com/newrelic/go-agent/v3/newrelic"
+	"github.com/sirupsen/logrus"
+)
+
+func main() {
+	NewRelicAgent, agentInitError := newrelic.NewApplication(newrelic.ConfigFromEnvironment())
+	if agentInitError != nil {
+		panic(agentInitError)
+	}
+
+	logger := logrus.New()
+	logger.SetFormatter(nrlogrus.NewFormatter(NewRelicAgent, &logrus.TextFormatter{}))
+	logger.Info("hello")
+}
+`
+	expect := `package main
+
+import "github.com/sirupsen/logrus"
+
+func main() {
+	logger := logrus.New()
+	logger.Info("hello")
+}
+`
+	defer parser.PanicRecovery(t)
+	got, warnings := parser.RunUninstrumentFunctions(t, code, nrlogrus.RemoveLogrusFormatter, nragent.RemoveAgent)
+	assert.Equal(t, expect, got)
+	assert.Empty(t, warnings)
+}
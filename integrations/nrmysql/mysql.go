@@ -4,12 +4,20 @@ import (
 	"github.com/dave/dst"
 	"github.com/dave/dst/dstutil"
 	"github.com/newrelic/go-easy-instrumentation/internal/codegen"
+	"github.com/newrelic/go-easy-instrumentation/internal/sqlhelpers"
 	"github.com/newrelic/go-easy-instrumentation/parser"
 	"github.com/newrelic/go-easy-instrumentation/parser/tracestate"
 )
 
 const (
 	SqlImportPath = "database/sql"
+	// MySQLImportPath is the import path for the go-sql-driver MySQL driver.
+	MySQLImportPath = "github.com/go-sql-driver/mysql"
+	// NrmysqlImportPath is the import path for the New Relic nrmysql MySQL driver wrapper.
+	NrmysqlImportPath = "github.com/newrelic/go-agent/v3/integrations/nrmysql"
+
+	mysqlDriver   = `"mysql"`
+	nrmysqlDriver = `"nrmysql"`
 )
 
 // detectSQLExecutionCall checks if a statement contains a SQL query operation using the given DB variable.
@@ -202,7 +210,7 @@ func InstrumentSQLHandler(manager *parser.InstrumentationManager, c *dstutil.Cur
 	}
 
 	// Generate instrumentation code
-	txnName := codegen.DefaultTransactionVariable
+	txnName := manager.TransactionVariableName()
 	ctxName := "ctx"
 
 	txnStart := CreateSQLTransaction(manager.AgentVariableName(), txnName, sqlMethodName)
@@ -232,3 +240,55 @@ func InstrumentSQLHandler(manager *parser.InstrumentationManager, c *dstutil.Cur
 	tc := tracestate.FunctionBody(txnName)
 	tc.WrapWithTransaction(c, "main", txnName)
 }
+
+// RemoveMySQLHandler removes New Relic instrumentation from MySQL database operations: the "nrmysql" driver and
+// its import are replaced by the go-sql-driver/mysql driver, and the SQL execution call that was given a context
+// with a transaction no longer takes a context. The transaction is removed along with the other New Relic
+// variables once nothing uses it anymore.
+func RemoveMySQLHandler(manager *parser.InstrumentationManager, c *dstutil.Cursor) bool {
+	var body *dst.BlockStmt
+	switch node := c.Node().(type) {
+	case *dst.FuncDecl:
+		body = node.Body
+	case *dst.FuncLit:
+		body = node.Body
+	}
+	if body == nil {
+		return false
+	}
+
+	changed := false
+	for _, stmt := range body.List {
+		if _, driverArg := sqlhelpers.DetectSQLOpen(stmt); driverArg != nil && driverArg.Value == nrmysqlDriver {
+			driverArg.Value = mysqlDriver
+			restoreMySQLImportInPackage(manager)
+			changed = true
+		}
+	}
+
+	if ctxStmt := sqlhelpers.RevertContextWithTransaction(body.List); ctxStmt != nil {
+		body.List = parser.DeleteStatements(body.List, func(stmt dst.Stmt) bool { return stmt == ctxStmt })
+		changed = true
+	}
+	return changed
+}
+
+// restoreMySQLImportInPackage imports the go-sql-driver/mysql driver in place of the New Relic nrmysql driver
+// wrapper in every file of the current package.
+//
+// _ "github.com/newrelic/go-agent/v3/integrations/nrmysql" -> _ "github.com/go-sql-driver/mysql"
+func restoreMySQLImportInPackage(manager *parser.InstrumentationManager) {
+	pkg := manager.GetDecoratorPackage()
+	if pkg == nil {
+		return
+	}
+
+	for _, file := range pkg.Syntax {
+		for _, imp := range file.Imports {
+			if imp.Path.Value == `"`+NrmysqlImportPath+`"` && imp.Name != nil && imp.Name.Name == "_" {
+				imp.Path.Value = `"` + MySQLImportPath + `"`
+				break
+			}
+		}
+	}
+}
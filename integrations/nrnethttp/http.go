@@ -4,12 +4,15 @@ import (
 	"fmt"
 	"go/ast"
 	"go/token"
+	"slices"
+	"strings"
 
 	"github.com/dave/dst"
 	"github.com/dave/dst/decorator"
 	"github.com/dave/dst/dstutil"
 	"github.com/newrelic/go-easy-instrumentation/internal/codegen"
 	"github.com/newrelic/go-easy-instrumentation/internal/comment"
+	"github.com/newrelic/go-easy-instrumentation/internal/report"
 	"github.com/newrelic/go-easy-instrumentation/internal/util"
 	"github.com/newrelic/go-easy-instrumentation/parser"
 	"github.com/newrelic/go-easy-instrumentation/parser/tracestate"
@@ -360,11 +363,11 @@ func InstrumentHandleFunction(manager *parser.InstrumentationManager, c *dstutil
 	n := c.Node()
 	fn, isFn := n.(*dst.FuncDecl) // TODO: 'isFn' should be renamed to 'ok' to match the paradigm in the rest of the codebase.
 	if isFn && IsHTTPHandler(fn) && !HandlerIsInstrumented(manager, fn) {
-
-		comment.Debug(manager.GetDecoratorPackage(), fn, fmt.Sprintf("Instrumenting HTTP handler: %s", fn.Name.Name))
-		txnName := codegen.DefaultTransactionVariable
+		txnName := manager.TransactionVariableName()
 		newFn, ok := parser.TraceFunction(manager, fn, tracestate.FunctionBody(txnName))
 		if ok {
+			comment.Debug(manager.GetDecoratorPackage(), fn, fmt.Sprintf("Instrumenting HTTP handler: %s", fn.Name.Name))
+			report.Action(manager.GetDecoratorPackage(), fn, "nrnethttp", report.KindTransaction, fmt.Sprintf("used the transaction of the request in HTTP handler %s", fn.Name.Name))
 			DefineTxnFromCtx(newFn.(*dst.FuncDecl), txnName) // pass the transaction
 		}
 	}
@@ -436,9 +439,12 @@ func CannotInstrumentHttpMethod(manager *parser.InstrumentationManager, c *dstut
 	n := c.Node()
 	funcName, ok := IsNetHttpMethodCannotInstrument(n)
 	if ok {
-		if decl := n.Decorations(); decl != nil {
-			decl.Start.Prepend(CannotTraceOutboundHttp(funcName, n.Decorations())...)
+		// the comment is not added again to code that already has it
+		lines := CannotTraceOutboundHttp(funcName, n.Decorations())
+		if decl := n.Decorations(); decl != nil && comment.CodeCommentsEnabled() && !slices.Contains(decl.Start, lines[0]) {
+			decl.Start.Prepend(lines...)
 		}
+		report.Warning(manager.GetDecoratorPackage(), n, "nrnethttp", report.CodeUninstrumentableHttpCall, fmt.Sprintf("the HTTP call %s can not be traced; use http.Client.Do with a request that carries the transaction instead", funcName))
 	}
 }
 
@@ -498,6 +504,7 @@ func ExternalHttpCall(manager *parser.InstrumentationManager, stmt dst.Stmt, c *
 		if clientVar == httpDefaultClientVariable {
 			// create external segment to wrap calls made with default client
 			comment.Debug(manager.GetDecoratorPackage(), stmt, "Wrapping default HTTP client call with external segment")
+			report.Action(manager.GetDecoratorPackage(), stmt, "nrnethttp", report.KindExternal, "added an external segment around the HTTP call")
 			segmentName := "externalSegment"
 			c.InsertBefore(codegen.StartExternalSegment(requestObject, tracing.TransactionVariable(), segmentName, stmt.Decorations()))
 			c.InsertAfter(codegen.EndExternalSegment(segmentName, stmt.Decorations()))
@@ -509,6 +516,7 @@ func ExternalHttpCall(manager *parser.InstrumentationManager, stmt dst.Stmt, c *
 			return true
 		} else {
 			comment.Debug(manager.GetDecoratorPackage(), stmt, "Injecting transaction context into HTTP request")
+			report.Action(manager.GetDecoratorPackage(), stmt, "nrnethttp", report.KindExternal, "added the transaction to the context of the HTTP request")
 			c.InsertBefore(WrapRequestContext(requestObject, tracing.TransactionVariable(), stmt.Decorations()))
 			manager.AddImport(codegen.NewRelicAgentImportPath)
 			return true
@@ -534,6 +542,7 @@ func WrapNestedHandleFunction(manager *parser.InstrumentationManager, stmt dst.S
 				if len(callExpr.Args) == 2 {
 					// Instrument handle funcs
 					comment.Debug(manager.GetDecoratorPackage(), stmt, "Wrapping http.HandleFunc with newrelic.WrapHandleFunc")
+					report.Action(manager.GetDecoratorPackage(), stmt, "nrnethttp", report.KindTransaction, "wrapped http.HandleFunc with newrelic.WrapHandleFunc")
 					WrapHttpHandleFunc(tracing.AgentVariable(), callExpr)
 
 					wasModified = true
@@ -544,6 +553,7 @@ func WrapNestedHandleFunction(manager *parser.InstrumentationManager, stmt dst.S
 				if len(callExpr.Args) == 2 {
 					// Instrument handle funcs
 					comment.Debug(manager.GetDecoratorPackage(), stmt, "Wrapping http.Handle with newrelic.WrapHandle")
+					report.Action(manager.GetDecoratorPackage(), stmt, "nrnethttp", report.KindTransaction, "wrapped http.Handle with newrelic.WrapHandle")
 					WrapHttpHandle(tracing.AgentVariable(), callExpr)
 
 					wasModified = true
@@ -608,3 +618,91 @@ func DetectWrappedRoutes(manager *parser.InstrumentationManager, c *dstutil.Curs
 		}, nil)
 	}
 }
+
+// Uninstrument Functions
+//////////////////////////////////////////////
+
+// isAgentCall returns the call if the expression calls the function of the go agent with the given name.
+func isAgentCall(expr dst.Expr, name string) (*dst.CallExpr, bool) {
+	call, ok := expr.(*dst.CallExpr)
+	if !ok {
+		return nil, false
+	}
+	ident, ok := call.Fun.(*dst.Ident)
+	return call, ok && ident.Name == name && ident.Path == codegen.NewRelicAgentImportPath
+}
+
+// UnwrapHandleFunction passes the pattern and handler of a route directly to http.HandleFunc or http.Handle
+// again, instead of wrapping them with newrelic.WrapHandleFunc or newrelic.WrapHandle.
+func UnwrapHandleFunction(manager *parser.InstrumentationManager, c *dstutil.Cursor) bool {
+	call, ok := c.Node().(*dst.CallExpr)
+	if !ok || len(call.Args) != 1 {
+		return false
+	}
+	wrap, ok := isAgentCall(call.Args[0], "WrapHandleFunc")
+	if !ok {
+		wrap, ok = isAgentCall(call.Args[0], "WrapHandle")
+	}
+	if !ok || len(wrap.Args) != 3 {
+		return false
+	}
+
+	call.Args = wrap.Args[1:]
+	return true
+}
+
+// RemoveRoundTripper removes the statement that replaces the transport of an http client with
+// newrelic.NewRoundTripper.
+func RemoveRoundTripper(manager *parser.InstrumentationManager, c *dstutil.Cursor) bool {
+	assign, ok := c.Node().(*dst.AssignStmt)
+	if !ok || c.Index() < 0 || assign.Tok != token.ASSIGN || len(assign.Lhs) != 1 || len(assign.Rhs) != 1 {
+		return false
+	}
+	if sel, ok := assign.Lhs[0].(*dst.SelectorExpr); !ok || sel.Sel.Name != "Transport" {
+		return false
+	}
+	if _, ok := isAgentCall(assign.Rhs[0], "NewRoundTripper"); !ok {
+		return false
+	}
+
+	parser.DeleteStatement(c)
+	return true
+}
+
+// RemoveRequestContext removes the statement that adds a transaction to the context of an http request with
+// newrelic.RequestWithTransactionContext.
+func RemoveRequestContext(manager *parser.InstrumentationManager, c *dstutil.Cursor) bool {
+	assign, ok := c.Node().(*dst.AssignStmt)
+	if !ok || c.Index() < 0 || assign.Tok != token.ASSIGN || len(assign.Lhs) != 1 || len(assign.Rhs) != 1 {
+		return false
+	}
+	if _, ok := isAgentCall(assign.Rhs[0], "RequestWithTransactionContext"); !ok {
+		return false
+	}
+
+	parser.DeleteStatement(c)
+	return true
+}
+
+// RemoveCannotTraceComment removes the comment that CannotInstrumentHttpMethod adds above calls to net/http
+// methods that can not be traced.
+func RemoveCannotTraceComment(manager *parser.InstrumentationManager, c *dstutil.Cursor) bool {
+	decs := c.Node().Decorations()
+	if decs == nil {
+		return false
+	}
+
+	commentLines := len(CannotTraceOutboundHttp("", nil))
+	for i, line := range decs.Start {
+		if !strings.HasPrefix(line, `// the "http.`) || !strings.Contains(line, "net/http method can not be instrumented") {
+			continue
+		}
+		end := min(i+commentLines, len(decs.Start))
+		if end < len(decs.Start) && decs.Start[end] == "//" {
+			end++
+		}
+		decs.Start = slices.Delete(decs.Start, i, end)
+		return true
+	}
+	return false
+}
@@ -2140,3 +2140,55 @@ func main() {
 		})
 	}
 }
+
+func TestRemoveNetHttpInstrumentation(t *testing.T) {
+	code := `package main
+
+import (
+	"net/http"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+func index(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("hi"))
+}
+
+func fetch(txn *newrelic.Transaction) {
+	client := &http.Client{}
+	client.Transport = newrelic.NewRoundTripper(client.Transport)
+	req, _ := http.NewRequest("GET", "http://example.com", nil)
+	req = newrelic.RequestWithTransactionContext(req, txn)
+	client.Do(req)
+}
+
+func main() {
+	NewRelicAgent, _ := newrelic.NewApplication(newrelic.ConfigFromEnvironment())
+	http.HandleFunc(newrelic.WrapHandleFunc(NewRelicAgent, "/", index))
+	http.ListenAndServe(":8080", nil)
+}
+`
+	expect := `package main
+
+import "net/http"
+
+func index(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("hi"))
+}
+
+func fetch() {
+	client := &http.Client{}
+	req, _ := http.NewRequest("GET", "http://example.com", nil)
+	client.Do(req)
+}
+
+func main() {
+	http.HandleFunc("/", index)
+	http.ListenAndServe(":8080", nil)
+}
+`
+	defer parser.PanicRecovery(t)
+	got, warnings := parser.RunUninstrumentFunctions(t, code, nrnethttp.UnwrapHandleFunction, nrnethttp.RemoveRoundTripper, nrnethttp.RemoveRequestContext)
+	assert.Equal(t, expect, got)
+	assert.Empty(t, warnings)
+}
@@ -2,9 +2,12 @@ package nrpgx5
 
 import (
 	"go/token"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/dave/dst"
+	"github.com/newrelic/go-easy-instrumentation/integrations/nragent"
 	"github.com/newrelic/go-easy-instrumentation/parser"
 	"github.com/stretchr/testify/assert"
 )
@@ -489,3 +492,19 @@ func TestHasExistingPgxTracer(t *testing.T) {
 		})
 	}
 }
+
+func TestRemovePgxTracer(t *testing.T) {
+	for _, example := range []string{"pgx", "pgxpool"} {
+		t.Run(example, func(t *testing.T) {
+			original, instrumented := parser.InstrumentedExample(t, filepath.Join("example", example))
+			// the alias is needed for the same reason as in TestInstrumentPgxHandler
+			alias := strings.NewReplacer(`"github.com/jackc/pgx/v5"`, `pgx "github.com/jackc/pgx/v5"`)
+			original, instrumented = alias.Replace(original), alias.Replace(instrumented)
+
+			defer parser.PanicRecovery(t)
+			got, warnings := parser.RunUninstrumentFunctions(t, instrumented, RemovePgxTracer, nragent.RemoveAgent)
+			assert.Equal(t, original, got)
+			assert.Empty(t, warnings)
+		})
+	}
+}
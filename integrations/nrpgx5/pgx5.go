@@ -6,6 +6,7 @@ import (
 	"github.com/dave/dst"
 	"github.com/dave/dst/dstutil"
 	"github.com/newrelic/go-easy-instrumentation/internal/comment"
+	"github.com/newrelic/go-easy-instrumentation/internal/report"
 	"github.com/newrelic/go-easy-instrumentation/parser"
 )
 
@@ -30,6 +31,7 @@ func InstrumentPgxHandler(manager *parser.InstrumentationManager, c *dstutil.Cur
 
 	if HasExistingPgxTracer(body) {
 		comment.Debug(manager.GetDecoratorPackage(), body, "pgx tracer already configured, skipping")
+		report.Skipped(manager.GetDecoratorPackage(), body, "nrpgx5", report.CodeAlreadyInstrumented, "pgx tracer is already configured")
 		return
 	}
 
@@ -38,6 +40,7 @@ func InstrumentPgxHandler(manager *parser.InstrumentationManager, c *dstutil.Cur
 		if replacement == nil {
 			continue
 		}
+		report.Action(manager.GetDecoratorPackage(), stmt, "nrpgx5", report.KindDriverSwap, "added the nrpgx5 tracer to the pgx connection config")
 		body.List = slices.Concat(body.List[:i], replacement, body.List[i+1:])
 		manager.AddImport(Nrpgx5ImportPath)
 		return
@@ -52,30 +55,31 @@ func HasExistingPgxTracer(body *dst.BlockStmt) bool {
 	}
 	for _, stmt := range body.List {
 		assign, ok := stmt.(*dst.AssignStmt)
-		if !ok || len(assign.Rhs) != 1 {
-			continue
-		}
-		call, ok := assign.Rhs[0].(*dst.CallExpr)
-		if !ok {
-			continue
-		}
-		switch fun := call.Fun.(type) {
-		case *dst.Ident:
-			// DST represents package-qualified calls as *dst.Ident with Path set to the import path.
-			if fun.Name == "NewTracer" && fun.Path == Nrpgx5ImportPath {
-				return true
-			}
-		case *dst.SelectorExpr:
-			// Without full type info, DST uses SelectorExpr instead of Ident with Path.
-			x, ok := fun.X.(*dst.Ident)
-			if ok && x.Name == nrpgx5PackageName && fun.Sel.Name == "NewTracer" {
-				return true
-			}
+		if ok && len(assign.Rhs) == 1 && isNewTracerCall(assign.Rhs[0]) {
+			return true
 		}
 	}
 	return false
 }
 
+// isNewTracerCall reports whether expr is a nrpgx5.NewTracer() call.
+func isNewTracerCall(expr dst.Expr) bool {
+	call, ok := expr.(*dst.CallExpr)
+	if !ok {
+		return false
+	}
+	switch fun := call.Fun.(type) {
+	case *dst.Ident:
+		// DST represents package-qualified calls as *dst.Ident with Path set to the import path.
+		return fun.Name == "NewTracer" && fun.Path == Nrpgx5ImportPath
+	case *dst.SelectorExpr:
+		// Without full type info, DST uses SelectorExpr instead of Ident with Path.
+		x, ok := fun.X.(*dst.Ident)
+		return ok && x.Name == nrpgx5PackageName && fun.Sel.Name == "NewTracer"
+	}
+	return false
+}
+
 // buildPgxReplacement detects a pgx.Connect or pgxpool.New call and returns the three replacement
 // statements that inject the nrpgx5 tracer. Returns nil if the statement is not a recognized call.
 func buildPgxReplacement(stmt dst.Stmt) []dst.Stmt {
@@ -137,3 +141,108 @@ func detectPgxCallPattern(stmt dst.Stmt, importPath, methodName string) (varName
 
 	return lhsIdent.Name, call.Args[0], call.Args[1]
 }
+
+// Uninstrument Functions
+// ////////////////////////////////////////////
+
+// RemovePgxTracer removes the nrpgx5 tracer added to a pgx connection config by InstrumentPgxHandler. The
+// ParseConfig statement at the cursor and the Tracer assignment after it are deleted, and the ConnectConfig or
+// NewWithConfig call that follows is turned back into the pgx.Connect or pgxpool.New call it was made from.
+func RemovePgxTracer(manager *parser.InstrumentationManager, c *dstutil.Cursor) bool {
+	block, ok := c.Parent().(*dst.BlockStmt)
+	if !ok || c.Index() < 0 || c.Index()+2 >= len(block.List) {
+		return false
+	}
+	config, importPath, connStrExpr := detectParseConfig(c.Node())
+	if config == "" {
+		return false
+	}
+	tracer := block.List[c.Index()+1]
+	if !isTracerAssignment(tracer, config, importPath) {
+		return false
+	}
+	if !restoreConnect(block.List[c.Index()+2], config, importPath, connStrExpr) {
+		return false
+	}
+
+	block.List = parser.DeleteStatements(block.List, func(stmt dst.Stmt) bool { return stmt == tracer })
+	parser.DeleteStatement(c)
+	return true
+}
+
+// detectParseConfig matches `config, err := pkg.ParseConfig(connStr)`, where pkg is pgx or pgxpool, and returns
+// the name of the config variable, the import path of pkg and the connStr expression, or zero values if the node
+// does not match.
+func detectParseConfig(node dst.Node) (config string, importPath string, connStrExpr dst.Expr) {
+	assign, ok := node.(*dst.AssignStmt)
+	if !ok || len(assign.Lhs) != 2 || len(assign.Rhs) != 1 {
+		return "", "", nil
+	}
+	call, ok := assign.Rhs[0].(*dst.CallExpr)
+	if !ok || len(call.Args) != 1 {
+		return "", "", nil
+	}
+	fun, ok := call.Fun.(*dst.Ident)
+	if !ok || fun.Name != "ParseConfig" || (fun.Path != PgxImportPath && fun.Path != PgxPoolImportPath) {
+		return "", "", nil
+	}
+	lhs, ok := assign.Lhs[0].(*dst.Ident)
+	if !ok {
+		return "", "", nil
+	}
+	return lhs.Name, fun.Path, call.Args[0]
+}
+
+// isTracerAssignment reports whether stmt is the `config.Tracer = nrpgx5.NewTracer()` assignment created by
+// CreateTracerAssignment for the config variable, or `config.ConnConfig.Tracer = ...` for pgxpool.
+func isTracerAssignment(stmt dst.Stmt, config, importPath string) bool {
+	assign, ok := stmt.(*dst.AssignStmt)
+	if !ok || len(assign.Lhs) != 1 || len(assign.Rhs) != 1 || !isNewTracerCall(assign.Rhs[0]) {
+		return false
+	}
+	field, ok := assign.Lhs[0].(*dst.SelectorExpr)
+	if !ok || field.Sel.Name != "Tracer" {
+		return false
+	}
+	receiver := field.X
+	if importPath == PgxPoolImportPath {
+		connConfig, ok := receiver.(*dst.SelectorExpr)
+		if !ok || connConfig.Sel.Name != "ConnConfig" {
+			return false
+		}
+		receiver = connConfig.X
+	}
+	ident, ok := receiver.(*dst.Ident)
+	return ok && ident.Name == config
+}
+
+// restoreConnect turns `x, err := pgx.ConnectConfig(ctx, config)` or `x, err := pgxpool.NewWithConfig(ctx, config)`
+// back into `x, err := pgx.Connect(ctx, connStr)` or `x, err := pgxpool.New(ctx, connStr)`. It returns false, and
+// leaves stmt as it is, if stmt is not such a call.
+func restoreConnect(stmt dst.Stmt, config, importPath string, connStrExpr dst.Expr) bool {
+	assign, ok := stmt.(*dst.AssignStmt)
+	if !ok || len(assign.Rhs) != 1 {
+		return false
+	}
+	call, ok := assign.Rhs[0].(*dst.CallExpr)
+	if !ok || len(call.Args) != 2 {
+		return false
+	}
+	if arg, ok := call.Args[1].(*dst.Ident); !ok || arg.Name != config {
+		return false
+	}
+	fun, ok := call.Fun.(*dst.Ident)
+	if !ok || fun.Path != importPath {
+		return false
+	}
+	switch {
+	case importPath == PgxImportPath && fun.Name == "ConnectConfig":
+		fun.Name = "Connect"
+	case importPath == PgxPoolImportPath && fun.Name == "NewWithConfig":
+		fun.Name = "New"
+	default:
+		return false
+	}
+	call.Args[1] = connStrExpr
+	return true
+}
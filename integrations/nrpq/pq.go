@@ -7,6 +7,7 @@ import (
 	"github.com/dave/dst"
 	"github.com/dave/dst/dstutil"
 	"github.com/newrelic/go-easy-instrumentation/internal/codegen"
+	"github.com/newrelic/go-easy-instrumentation/internal/report"
 	"github.com/newrelic/go-easy-instrumentation/internal/sqlhelpers"
 	"github.com/newrelic/go-easy-instrumentation/parser"
 )
@@ -129,6 +130,7 @@ func InstrumentPQHandler(manager *parser.InstrumentationManager, c *dstutil.Curs
 		return
 	}
 
+	report.Action(manager.GetDecoratorPackage(), scan.driverArg, "nrpq", report.KindDriverSwap, "replaced the postgres driver with nrpostgres")
 	scan.driverArg.Value = nrpqDriver
 	swapLibpqImportInPackage(manager)
 
@@ -148,7 +150,8 @@ func wrapWithTransaction(manager *parser.InstrumentationManager, body *dst.Block
 		return
 	}
 
-	txnName := codegen.DefaultTransactionVariable
+	txnName := manager.TransactionVariableName()
+	report.Action(manager.GetDecoratorPackage(), body.List[execIdx], "nrpq", report.KindTransaction, fmt.Sprintf("started transaction postgres/%s for the SQL call", methodName))
 
 	lastUsage := sqlhelpers.FindLastUsageOfExecutionResult(body.List, resultVar, execIdx)
 
@@ -170,3 +173,46 @@ func wrapWithTransaction(manager *parser.InstrumentationManager, body *dst.Block
 
 	manager.AddImport(codegen.NewRelicAgentImportPath)
 }
+
+// restoreLibpqImportInPackage undoes swapLibpqImportInPackage, importing the lib/pq driver again in place of
+// the New Relic nrpq driver wrapper.
+func restoreLibpqImportInPackage(manager *parser.InstrumentationManager) {
+	pkg := manager.GetDecoratorPackage()
+	if pkg == nil {
+		return
+	}
+
+	for _, file := range pkg.Syntax {
+		for _, imp := range file.Imports {
+			if imp.Path.Value == `"`+NrpqImportPath+`"` && imp.Name != nil && imp.Name.Name == "_" {
+				imp.Path.Value = `"` + LibpqImportPath + `"`
+				break
+			}
+		}
+	}
+}
+
+// RemovePQHandler undoes InstrumentPQHandler: the "postgres" driver and the lib/pq import are used again, and
+// the SQL execution call that was given a context with a transaction no longer takes a context. The transaction
+// is removed along with the other New Relic variables once nothing uses it anymore.
+func RemovePQHandler(manager *parser.InstrumentationManager, c *dstutil.Cursor) bool {
+	var body *dst.BlockStmt
+	switch node := c.Node().(type) {
+	case *dst.FuncDecl:
+		body = node.Body
+	case *dst.FuncLit:
+		body = node.Body
+	}
+
+	scan := scanForPostgresOpen(body)
+	if scan.state != driverAlreadyNRPQ {
+		return false
+	}
+	scan.driverArg.Value = postgresDriver
+	restoreLibpqImportInPackage(manager)
+
+	if ctxStmt := sqlhelpers.RevertContextWithTransaction(body.List); ctxStmt != nil {
+		body.List = parser.DeleteStatements(body.List, func(stmt dst.Stmt) bool { return stmt == ctxStmt })
+	}
+	return true
+}
@@ -0,0 +1,37 @@
+package nrredis
+
+import "github.com/dave/dst"
+
+const (
+	NrRedisV8ImportPath = "github.com/newrelic/go-agent/v3/integrations/nrredis-v8"
+	NrRedisV9ImportPath = "github.com/newrelic/go-agent/v3/integrations/nrredis-v9"
+)
+
+// AddNrRedisHook adds the New Relic hook to a go-redis client, which creates a datastore segment for every
+// command run with a context that carries a transaction. The options of the client are optional, and are only
+// used to name the instance the commands run on, so nil may be passed for them.
+// Ex:
+//
+//	client := redis.NewClient(opts)
+//	client.AddHook(nrredis.NewHook(opts)) <--- Hook injection
+func AddNrRedisHook(client dst.Expr, options dst.Expr, nrRedisImportPath string) (*dst.ExprStmt, string) {
+	return &dst.ExprStmt{
+		X: &dst.CallExpr{
+			Fun: &dst.SelectorExpr{
+				X:   client,
+				Sel: &dst.Ident{Name: "AddHook"},
+			},
+			Args: []dst.Expr{
+				&dst.CallExpr{
+					Fun: &dst.Ident{
+						Name: "NewHook",
+						Path: nrRedisImportPath,
+					},
+					Args: []dst.Expr{
+						options,
+					},
+				},
+			},
+		},
+	}, nrRedisImportPath
+}
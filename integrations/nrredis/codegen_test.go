@@ -0,0 +1,39 @@
+package nrredis_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/dave/dst"
+	"github.com/newrelic/go-easy-instrumentation/integrations/nrredis"
+)
+
+func TestAddNrRedisHook(t *testing.T) {
+	want := &dst.ExprStmt{
+		X: &dst.CallExpr{
+			Fun: &dst.SelectorExpr{
+				X:   &dst.Ident{Name: "client"},
+				Sel: &dst.Ident{Name: "AddHook"},
+			},
+			Args: []dst.Expr{
+				&dst.CallExpr{
+					Fun: &dst.Ident{
+						Name: "NewHook",
+						Path: nrredis.NrRedisV9ImportPath,
+					},
+					Args: []dst.Expr{
+						&dst.Ident{Name: "opts"},
+					},
+				},
+			},
+		},
+	}
+
+	got, imp := nrredis.AddNrRedisHook(&dst.Ident{Name: "client"}, &dst.Ident{Name: "opts"}, nrredis.NrRedisV9ImportPath)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("nrredis.AddNrRedisHook() = %v, want %v", got, want)
+	}
+	if imp != nrredis.NrRedisV9ImportPath {
+		t.Errorf("nrredis.AddNrRedisHook() = %v, want %v", imp, nrredis.NrRedisV9ImportPath)
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func newClient(addr string) *redis.Client {
+	opts := &redis.Options{Addr: addr}
+	client := redis.NewClient(opts)
+	return client
+}
+
+func cacheGreeting(client *redis.Client, name string) {
+	err := client.Set(context.Background(), "greeting", "hello, "+name, time.Hour).Err()
+	if err != nil {
+		slog.Error(err.Error())
+	}
+}
+
+func visit(ctx context.Context, client *redis.Client, page string) int64 {
+	count, err := client.Incr(ctx, "visits:"+page).Result()
+	if err != nil {
+		slog.Error(err.Error())
+	}
+	return count
+}
+
+func main() {
+	client := newClient("localhost:6379")
+	cacheGreeting(client, "gopher")
+	count := visit(context.Background(), client, "home")
+	fmt.Println(count)
+}
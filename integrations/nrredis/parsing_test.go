@@ -0,0 +1,373 @@
+package nrredis_test
+
+import (
+	"testing"
+
+	"github.com/dave/dst"
+	"github.com/newrelic/go-easy-instrumentation/integrations/nragent"
+	"github.com/newrelic/go-easy-instrumentation/integrations/nrredis"
+	"github.com/newrelic/go-easy-instrumentation/parser"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetRedisClient(t *testing.T) {
+	assign := func(lhs dst.Expr, fun string, path string, arg dst.Expr) dst.Stmt {
+		return &dst.AssignStmt{
+			Lhs: []dst.Expr{lhs},
+			Rhs: []dst.Expr{&dst.CallExpr{Fun: &dst.Ident{Name: fun, Path: path}, Args: []dst.Expr{arg}}},
+		}
+	}
+	options := &dst.UnaryExpr{X: &dst.CompositeLit{Type: &dst.Ident{Name: "Options", Path: nrredis.RedisV9ImportPath}}}
+	field := &dst.SelectorExpr{X: dst.NewIdent("s"), Sel: dst.NewIdent("client")}
+
+	tests := []struct {
+		name       string
+		stmt       dst.Stmt
+		client     dst.Expr
+		options    string
+		importPath string
+	}{
+		{
+			name:       "client with options variable",
+			stmt:       assign(dst.NewIdent("client"), "NewClient", nrredis.RedisV9ImportPath, dst.NewIdent("opts")),
+			client:     dst.NewIdent("client"),
+			options:    "opts",
+			importPath: nrredis.RedisV9ImportPath,
+		},
+		{
+			name:       "client with options literal",
+			stmt:       assign(dst.NewIdent("client"), "NewClient", nrredis.RedisV9ImportPath, options),
+			client:     dst.NewIdent("client"),
+			options:    "nil",
+			importPath: nrredis.RedisV9ImportPath,
+		},
+		{
+			name:       "cluster client assigned to a field",
+			stmt:       assign(field, "NewClusterClient", nrredis.RedisV9ImportPath, dst.NewIdent("opts")),
+			client:     field,
+			options:    "nil",
+			importPath: nrredis.RedisV9ImportPath,
+		},
+		{
+			name:       "v8 universal client",
+			stmt:       assign(dst.NewIdent("rdb"), "NewUniversalClient", nrredis.RedisV8ImportPath, dst.NewIdent("opts")),
+			client:     dst.NewIdent("rdb"),
+			options:    "nil",
+			importPath: nrredis.RedisV8ImportPath,
+		},
+		{
+			name: "discarded client",
+			stmt: assign(dst.NewIdent("_"), "NewClient", nrredis.RedisV9ImportPath, dst.NewIdent("opts")),
+		},
+		{
+			name: "other constructor",
+			stmt: assign(dst.NewIdent("opts"), "ParseURL", nrredis.RedisV9ImportPath, dst.NewIdent("url")),
+		},
+		{
+			name: "other package",
+			stmt: assign(dst.NewIdent("client"), "NewClient", "github.com/example/redis", dst.NewIdent("opts")),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, options, importPath := nrredis.GetRedisClient(tt.stmt)
+			assert.Equal(t, tt.client, client)
+			assert.Equal(t, tt.importPath, importPath)
+			if tt.options == "" {
+				assert.Nil(t, options)
+			} else {
+				assert.Equal(t, tt.options, options.(*dst.Ident).Name)
+			}
+		})
+	}
+}
+
+func TestInstrumentRedisClient(t *testing.T) {
+	code := `package main
+
+import (
+	"context"
+
+	"github.com/redis/go-redis/v9"
+)
+
+type store struct {
+	cluster *redis.ClusterClient
+}
+
+func newStore(addrs []string) *store {
+	s := &store{}
+	s.cluster = redis.NewClusterClient(&redis.ClusterOptions{Addrs: addrs})
+	return s
+}
+
+func newClient(addr string) *redis.Client {
+	return redis.NewClient(&redis.Options{Addr: addr})
+}
+
+func newClients(client *redis.Client, opts *redis.Options) (*redis.Client, *redis.Client) {
+	// the second client
+	return client, redis.NewClient(opts)
+}
+
+func main() {
+	opts := &redis.Options{Addr: "localhost:6379"}
+	client := redis.NewClient(opts)
+	client.Ping(context.Background())
+}
+`
+	expect := `package main
+
+import (
+	"context"
+
+	"github.com/newrelic/go-agent/v3/integrations/nrredis-v9"
+	"github.com/redis/go-redis/v9"
+)
+
+type store struct {
+	cluster *redis.ClusterClient
+}
+
+func newStore(addrs []string) *store {
+	s := &store{}
+	s.cluster = redis.NewClusterClient(&redis.ClusterOptions{Addrs: addrs})
+	s.cluster.AddHook(nrredis.NewHook(nil))
+	return s
+}
+
+func newClient(addr string) *redis.Client {
+	client := redis.NewClient(&redis.Options{Addr: addr})
+	client.AddHook(nrredis.NewHook(nil))
+	return client
+}
+
+func newClients(client *redis.Client, opts *redis.Options) (*redis.Client, *redis.Client) {
+	// the second client
+	client2 := redis.NewClient(opts)
+	client2.AddHook(nrredis.NewHook(opts))
+	return client, client2
+}
+
+func main() {
+	opts := &redis.Options{Addr: "localhost:6379"}
+	client := redis.NewClient(opts)
+	client.AddHook(nrredis.NewHook(opts))
+	client.Ping(context.Background())
+}
+`
+	defer parser.PanicRecovery(t)
+	got := parser.RunStatelessTracingFunction(t, code, nrredis.InstrumentRedisClient)
+	assert.Equal(t, expect, got)
+}
+
+func TestRedisCommandContext(t *testing.T) {
+	tests := []struct {
+		name   string
+		code   string
+		expect string
+	}{
+		{
+			name: "command with a background context",
+			code: `package main
+
+import (
+	"context"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func ping(client *redis.Client) {
+	client.Ping(context.Background())
+}
+`,
+			expect: `package main
+
+import (
+	"context"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+	"github.com/redis/go-redis/v9"
+)
+
+func ping(client *redis.Client) {
+	client.Ping(newrelic.NewContext(context.Background(), txn))
+}
+`,
+		},
+		{
+			name: "command that receives a context",
+			code: `package main
+
+import (
+	"context"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func set(ctx context.Context, client *redis.Client) {
+	client.Set(ctx, "key", "value", 0)
+}
+`,
+			expect: `package main
+
+import (
+	"context"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+	"github.com/redis/go-redis/v9"
+)
+
+func set(ctx context.Context, client *redis.Client) {
+	client.Set(newrelic.NewContext(ctx, txn), "key", "value", 0)
+}
+`,
+		},
+		{
+			name: "command chained with its result",
+			code: `package main
+
+import (
+	"context"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func get(ctx context.Context, client *redis.Client) (string, error) {
+	return client.Get(ctx, "key").Result()
+}
+`,
+			expect: `package main
+
+import (
+	"context"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+	"github.com/redis/go-redis/v9"
+)
+
+func get(ctx context.Context, client *redis.Client) (string, error) {
+	return client.Get(newrelic.NewContext(ctx, txn), "key").Result()
+}
+`,
+		},
+		{
+			name: "command that already receives the transaction",
+			code: `package main
+
+import (
+	"context"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+	"github.com/redis/go-redis/v9"
+)
+
+func get(ctx context.Context, client *redis.Client) (string, error) {
+	return client.Get(newrelic.NewContext(ctx, txn), "key").Result()
+}
+`,
+			expect: `package main
+
+import (
+	"context"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+	"github.com/redis/go-redis/v9"
+)
+
+func get(ctx context.Context, client *redis.Client) (string, error) {
+	return client.Get(newrelic.NewContext(ctx, txn), "key").Result()
+}
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer parser.PanicRecovery(t)
+			got := parser.RunStatefulTracingFunction(t, tt.code, nrredis.RedisCommandContext, true)
+			assert.Equal(t, tt.expect, got)
+		})
+	}
+}
+
+func TestRedisCommandContext_EntryPoint(t *testing.T) {
+	code := `package cache
+
+import (
+	"context"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func Get(ctx context.Context, client *redis.Client) string {
+	client.Ping(context.Background())
+	return client.Get(ctx, "key").Val()
+}
+`
+	expect := `package cache
+
+import (
+	"context"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+	"github.com/redis/go-redis/v9"
+)
+
+func Get(ctx context.Context, client *redis.Client) string {
+	nrTxn := newrelic.FromContext(ctx)
+	defer nrTxn.StartSegment("Get").End()
+
+	client.Ping(newrelic.NewContext(context.Background(), nrTxn))
+	return client.Get(ctx, "key").Val()
+}
+`
+	defer parser.PanicRecovery(t)
+	got := parser.RunLibraryTracingFunction(t, code, nragent.InstrumentLibraryEntryPoint, nrredis.RedisCommandContext)
+	assert.Equal(t, expect, got, "the context that carries the transaction is passed as it is")
+}
+
+func TestRemoveRedisHook(t *testing.T) {
+	code := `package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/newrelic/go-agent/v3/integrations/nrredis-v9"
+	"github.com/newrelic/go-agent/v3/newrelic"
+	"github.com/redis/go-redis/v9"
+)
+
+func main() {
+	NewRelicAgent, agentInitError := newrelic.NewApplication(newrelic.ConfigFromEnvironment())
+	if agentInitError != nil {
+		panic(agentInitError)
+	}
+
+	opts := &redis.Options{Addr: "localhost:6379"}
+	client := redis.NewClient(opts)
+	client.AddHook(nrredis.NewHook(opts))
+	client.Ping(context.Background())
+
+	NewRelicAgent.Shutdown(5 * time.Second)
+}
+`
+	expect := `package main
+
+import (
+	"context"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func main() {
+	opts := &redis.Options{Addr: "localhost:6379"}
+	client := redis.NewClient(opts)
+	client.Ping(context.Background())
+}
+`
+	defer parser.PanicRecovery(t)
+	got, warnings := parser.RunUninstrumentFunctions(t, code, nrredis.RemoveRedisHook, nragent.RemoveAgent)
+	assert.Equal(t, expect, got)
+	assert.Empty(t, warnings)
+}
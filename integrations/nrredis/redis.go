@@ -0,0 +1,294 @@
+package nrredis
+
+import (
+	"fmt"
+	"go/token"
+	"go/types"
+
+	"github.com/dave/dst"
+	"github.com/dave/dst/decorator"
+	"github.com/dave/dst/dstutil"
+	"github.com/newrelic/go-easy-instrumentation/internal/codegen"
+	"github.com/newrelic/go-easy-instrumentation/internal/comment"
+	"github.com/newrelic/go-easy-instrumentation/internal/report"
+	"github.com/newrelic/go-easy-instrumentation/internal/util"
+	"github.com/newrelic/go-easy-instrumentation/parser"
+	"github.com/newrelic/go-easy-instrumentation/parser/tracestate"
+)
+
+const (
+	RedisV8ImportPath = "github.com/go-redis/redis/v8"
+	RedisV9ImportPath = "github.com/redis/go-redis/v9"
+)
+
+// nrRedisImportPaths maps the import paths of the supported go-redis versions to the New Relic integration for them.
+var nrRedisImportPaths = map[string]string{
+	RedisV8ImportPath: NrRedisV8ImportPath,
+	RedisV9ImportPath: NrRedisV9ImportPath,
+}
+
+// Return the expression a go-redis client is assigned to, the options to pass to nrredis.NewHook, and the import
+// path of the go-redis version it is created with. See GetRedisConstructor for the options that are passed.
+// Ex:
+//
+//	client := redis.NewClient(opts)
+//	^^^^^^                    ^^^^
+//	cluster := redis.NewClusterClient(&redis.ClusterOptions{...})
+//	^^^^^^^
+func GetRedisClient(stmt dst.Stmt) (client dst.Expr, options dst.Expr, importPath string) {
+	// Verify we're dealing with an assignment operation
+	v, ok := stmt.(*dst.AssignStmt)
+	if !ok || len(v.Rhs) != 1 || len(v.Lhs) != 1 {
+		return nil, nil, ""
+	}
+
+	options, importPath = GetRedisConstructor(v.Rhs[0])
+	if importPath == "" {
+		return nil, nil, ""
+	}
+
+	switch lhs := v.Lhs[0].(type) {
+	case *dst.Ident:
+		if lhs.Name == "_" {
+			return nil, nil, ""
+		}
+	case *dst.SelectorExpr:
+	default:
+		return nil, nil, ""
+	}
+	return v.Lhs[0], options, importPath
+}
+
+// GetRedisConstructor returns the options to pass to nrredis.NewHook and the import path of the go-redis version
+// if an expression creates a go-redis client. The options the client is created with are only passed when they are
+// held in a variable, and only for clients of a single redis instance; nil is passed otherwise.
+// Ex:
+//
+//	redis.NewClient(opts)
+//	                ^^^^
+func GetRedisConstructor(expr dst.Expr) (options dst.Expr, importPath string) {
+	// Verify the expression is a Call Expression
+	call, ok := expr.(*dst.CallExpr)
+	if !ok || len(call.Args) != 1 {
+		return nil, ""
+	}
+
+	// Reject calls that are not to a client constructor. Verify go-redis relationship with the import path.
+	ident, ok := call.Fun.(*dst.Ident)
+	if !ok || nrRedisImportPaths[ident.Path] == "" {
+		return nil, ""
+	}
+	options = dst.NewIdent("nil")
+	switch ident.Name {
+	case "NewClient":
+		if opts, ok := call.Args[0].(*dst.Ident); ok {
+			options = dst.Clone(opts).(dst.Expr)
+		}
+	case "NewClusterClient", "NewUniversalClient":
+	default:
+		return nil, ""
+	}
+	return options, ident.Path
+}
+
+// isNrRedisHook returns true if a statement adds the New Relic hook to a go-redis client.
+//
+//	client.AddHook(nrredis.NewHook(opts))
+func isNrRedisHook(stmt dst.Stmt, client dst.Expr) bool {
+	expr, ok := stmt.(*dst.ExprStmt)
+	if !ok {
+		return false
+	}
+	call, ok := expr.X.(*dst.CallExpr)
+	if !ok || len(call.Args) != 1 {
+		return false
+	}
+	sel, ok := call.Fun.(*dst.SelectorExpr)
+	if !ok || sel.Sel.Name != "AddHook" || (client != nil && !util.AssertExpressionEqual(sel.X, client)) {
+		return false
+	}
+	hook, ok := call.Args[0].(*dst.CallExpr)
+	if !ok {
+		return false
+	}
+	ident, ok := hook.Fun.(*dst.Ident)
+	return ok && ident.Name == "NewHook" && (ident.Path == NrRedisV8ImportPath || ident.Path == NrRedisV9ImportPath)
+}
+
+// isRedisCommand returns true if a call runs a go-redis command, which is a method of a go-redis client, pipeline
+// or transaction that takes a context.Context as its first argument.
+//
+//	client.Get(ctx, "key")
+func isRedisCommand(pkg *decorator.Package, call *dst.CallExpr) bool {
+	if len(call.Args) == 0 {
+		return false
+	}
+	fn := util.CalledFunction(call, pkg)
+	if fn == nil || fn.Pkg() == nil || nrRedisImportPaths[fn.Pkg().Path()] == "" {
+		return false
+	}
+	sig, ok := fn.Type().(*types.Signature)
+	if !ok || sig.Recv() == nil || sig.Params().Len() == 0 || sig.Params().At(0).Type().String() != "context.Context" {
+		return false
+	}
+	t := util.TypeOf(call.Args[0], pkg)
+	return t != nil && t.String() == "context.Context"
+}
+
+// isTransactionContext returns true if an expression creates a context with a transaction.
+//
+//	newrelic.NewContext(ctx, nrTxn)
+func isTransactionContext(expr dst.Expr) bool {
+	call, ok := expr.(*dst.CallExpr)
+	if !ok {
+		return false
+	}
+	ident, ok := call.Fun.(*dst.Ident)
+	return ok && ident.Name == "NewContext" && ident.Path == codegen.NewRelicAgentImportPath
+}
+
+// StatelessTracingFunctions
+//////////////////////////////////////////////
+
+// InstrumentRedisClient detects go-redis clients created with redis.NewClient, redis.NewClusterClient or
+// redis.NewUniversalClient in any function, and adds the New Relic hook to them, which creates a datastore
+// segment for every command run with a context that carries a transaction.
+func InstrumentRedisClient(manager *parser.InstrumentationManager, c *dstutil.Cursor) {
+	if c.Index() < 0 {
+		return
+	}
+	block, ok := c.Parent().(*dst.BlockStmt)
+	if !ok {
+		return
+	}
+	switch stmt := c.Node().(type) {
+	case *dst.AssignStmt:
+		hookAssignedClient(manager, c, block, stmt)
+	case *dst.ReturnStmt:
+		hookReturnedClient(manager, c, block, stmt)
+	}
+}
+
+// hookAssignedClient adds the New Relic hook after the statement that assigns a go-redis client, unless it is
+// already there.
+//
+//	client := redis.NewClient(opts)
+//	client.AddHook(nrredis.NewHook(opts)) <--- Hook injection
+func hookAssignedClient(manager *parser.InstrumentationManager, c *dstutil.Cursor, block *dst.BlockStmt, stmt *dst.AssignStmt) {
+	client, options, importPath := GetRedisClient(stmt)
+	if client == nil {
+		return
+	}
+
+	pkg := manager.GetDecoratorPackage()
+	clientName := util.WriteExpr(client, pkg)
+	if c.Index()+1 < len(block.List) && isNrRedisHook(block.List[c.Index()+1], client) {
+		report.Skipped(pkg, stmt, "nrredis", report.CodeAlreadyInstrumented, fmt.Sprintf("redis client %s already has the nrredis hook", clientName))
+		return
+	}
+
+	hook, goGet := AddNrRedisHook(dst.Clone(client).(dst.Expr), options, nrRedisImportPaths[importPath])
+	comment.Debug(pkg, stmt, fmt.Sprintf("Injecting nrredis hook for redis client: %s", clientName))
+	report.Action(pkg, stmt, "nrredis", report.KindMiddleware, fmt.Sprintf("added nrredis hook to redis client %s", clientName))
+	c.InsertAfter(hook)
+	manager.AddImport(goGet)
+}
+
+// hookReturnedClient assigns a go-redis client that is created in a return statement, such as by a constructor
+// function, to a variable so that the New Relic hook can be added to it before it is returned.
+//
+//	client := redis.NewClient(opts)
+//	client.AddHook(nrredis.NewHook(opts)) <--- Hook injection
+//	return client
+func hookReturnedClient(manager *parser.InstrumentationManager, c *dstutil.Cursor, block *dst.BlockStmt, stmt *dst.ReturnStmt) {
+	for i, result := range stmt.Results {
+		options, importPath := GetRedisConstructor(result)
+		if importPath == "" {
+			continue
+		}
+
+		pkg := manager.GetDecoratorPackage()
+		client := dst.NewIdent(util.UniqueName(pkg, block, "client"))
+		assign := &dst.AssignStmt{
+			Lhs: []dst.Expr{client},
+			Tok: token.DEFINE,
+			Rhs: []dst.Expr{result},
+		}
+		assign.Decs.Before = stmt.Decs.Before
+		assign.Decs.Start = stmt.Decs.Start
+		stmt.Decs.Before = dst.NewLine
+		stmt.Decs.Start = nil
+		hook, goGet := AddNrRedisHook(dst.Clone(client).(dst.Expr), options, nrRedisImportPaths[importPath])
+		comment.Debug(pkg, stmt, fmt.Sprintf("Injecting nrredis hook for returned redis client: %s", client.Name))
+		report.Action(pkg, stmt, "nrredis", report.KindMiddleware, fmt.Sprintf("added nrredis hook to returned redis client %s", client.Name))
+		c.InsertBefore(assign)
+		c.InsertBefore(hook)
+		stmt.Results[i] = dst.Clone(client).(dst.Expr)
+		manager.AddImport(goGet)
+		return
+	}
+}
+
+// StatefulTracingFunctions
+//////////////////////////////////////////////
+
+// RedisCommandContext passes the transaction of a traced function to the go-redis commands it runs in their
+// context, so that the nrredis hook can create a datastore segment for them. A context variable is passed following
+// the rules of the object that holds the transaction: the context that already carries it is passed as it is, and
+// the transaction is added to any other context.
+func RedisCommandContext(manager *parser.InstrumentationManager, stmt dst.Stmt, c *dstutil.Cursor, tracing *tracestate.State) bool {
+	if tracing.IsMain() {
+		return false
+	}
+
+	pkg := manager.GetDecoratorPackage()
+	changed := false
+	dst.Inspect(stmt, func(n dst.Node) bool {
+		switch v := n.(type) {
+		case *dst.FuncLit:
+			// function literals are traced with a state of their own
+			return false
+		case dst.Stmt:
+			// nested statements are traced on their own
+			return v == stmt
+		case *dst.CallExpr:
+			if !isRedisCommand(pkg, v) || isTransactionContext(v.Args[0]) {
+				return true
+			}
+			ctx := v.Args[0]
+			goGet := codegen.NewRelicAgentImportPath
+			if _, ok := ctx.(*dst.Ident); ok {
+				_, goGet = tracing.AddToCall(pkg, v, false)
+				if v.Args[0] == ctx {
+					return true
+				}
+			} else if txn, ok := tracing.TransactionVariable().(*dst.Ident); ok {
+				// contexts that are not held in a variable, like context.Background(), never carry the transaction
+				v.Args[0] = codegen.WrapContextExpression(ctx, txn.Name, false)
+			} else {
+				return true
+			}
+			command := util.WriteExpr(v.Fun, pkg)
+			comment.Debug(pkg, stmt, fmt.Sprintf("Passing the transaction to redis command %s in its context", command))
+			report.Action(pkg, stmt, "nrredis", report.KindTransaction, fmt.Sprintf("passed the transaction to redis command %s in its context", command))
+			manager.AddImport(goGet)
+			changed = true
+		}
+		return true
+	})
+	return changed
+}
+
+// Uninstrument Functions
+// ////////////////////////////////////////////
+
+// RemoveRedisHook removes the New Relic hook that was added to a go-redis client. The contexts the transaction
+// was added to are restored by the core uninstrument functions.
+func RemoveRedisHook(manager *parser.InstrumentationManager, c *dstutil.Cursor) bool {
+	stmt, ok := c.Node().(dst.Stmt)
+	if !ok || c.Index() < 0 || !isNrRedisHook(stmt, nil) {
+		return false
+	}
+	parser.DeleteStatement(c)
+	return true
+}
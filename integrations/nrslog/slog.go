@@ -1,10 +1,12 @@
 package nrslog
 
 import (
+	"fmt"
 	"slices"
 
 	"github.com/dave/dst"
 	"github.com/dave/dst/dstutil"
+	"github.com/newrelic/go-easy-instrumentation/internal/report"
 	"github.com/newrelic/go-easy-instrumentation/parser"
 )
 
@@ -66,11 +68,14 @@ func InstrumentSlogHandler(manager *parser.InstrumentationManager, c *dstutil.Cu
 			stmt := decl.Body.List[i]
 
 			slogHandler := slogMiddlewareCall(stmt)
-			if slogHandler != "" {
+			if slogHandler != "" && manager.WasInstrumented(stmt) {
+				report.Skipped(manager.GetDecoratorPackage(), stmt, "nrslog", report.CodeAlreadyInstrumented, fmt.Sprintf("slog handler %s is already wrapped with nrslog", slogHandler))
+			} else if slogHandler != "" {
 				// We detected an slog handler
 				nrHandler := "NR" + slogHandler
 				handlerNames = append(handlerNames, slogHandler)
 				middleware, goGet := SlogHandlerWrapper(slogHandler, nrHandler)
+				report.Action(manager.GetDecoratorPackage(), stmt, "nrslog", report.KindLogs, fmt.Sprintf("wrapped slog handler %s with nrslog", slogHandler))
 				decl.Body.List = append(decl.Body.List[:i+1], append([]dst.Stmt{middleware}, decl.Body.List[i+1:]...)...)
 				manager.AddImport(goGet)
 				i++
@@ -125,3 +130,45 @@ func InstrumentSlogHandler(manager *parser.InstrumentationManager, c *dstutil.Cu
 
 	}
 }
+
+// Uninstrument Functions
+// ////////////////////////////////////////////
+
+// RemoveSlogHandler removes a slog handler that was wrapped with nrslog.WrapHandler, and uses the original
+// handler again in the statements after it.
+func RemoveSlogHandler(manager *parser.InstrumentationManager, c *dstutil.Cursor) bool {
+	assign, ok := c.Node().(*dst.AssignStmt)
+	if !ok || len(assign.Lhs) != 1 || len(assign.Rhs) != 1 {
+		return false
+	}
+	block, ok := c.Parent().(*dst.BlockStmt)
+	if !ok || c.Index() < 0 {
+		return false
+	}
+	call, ok := assign.Rhs[0].(*dst.CallExpr)
+	if !ok || len(call.Args) != 2 {
+		return false
+	}
+	if fun, ok := call.Fun.(*dst.Ident); !ok || fun.Name != "WrapHandler" || fun.Path != NrslogImportPath {
+		return false
+	}
+	nrHandler, ok := assign.Lhs[0].(*dst.Ident)
+	if !ok {
+		return false
+	}
+	handler, ok := call.Args[1].(*dst.Ident)
+	if !ok {
+		return false
+	}
+
+	for _, stmt := range block.List[c.Index()+1:] {
+		dst.Inspect(stmt, func(n dst.Node) bool {
+			if ident, ok := n.(*dst.Ident); ok && ident.Path == "" && ident.Name == nrHandler.Name {
+				ident.Name = handler.Name
+			}
+			return true
+		})
+	}
+	parser.DeleteStatement(c)
+	return true
+}
@@ -0,0 +1,316 @@
+// Package cache stores the results of instrumenting each package of an application on disk, so that running the
+// tool again only loads and instruments the packages that changed since the last run, and the packages that their
+// instrumentation can affect. Loading and type checking an application is by far the slowest part of a run.
+//
+// Packages are identified by the path they are loaded with: the import path of a package, which also loads its
+// test variants. A package is stale when the contents of its files, or of the go.mod and go.sum of its module, have
+// changed, or when it was instrumented with different settings. The application name that each binary reports depends
+// on the other main packages of the application, so every main package is stale when main packages are added or
+// removed. Instrumentation follows calls from one package into
+// the packages it imports, so the packages that import a stale package are instrumented again, along with every
+// package they import.
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/newrelic/go-easy-instrumentation/internal/report"
+	"github.com/newrelic/go-easy-instrumentation/parser/facts"
+	"golang.org/x/tools/go/packages"
+)
+
+// scanMode loads the files and imports of packages, without parsing or type checking them.
+const scanMode = packages.NeedName | packages.NeedFiles | packages.NeedImports | packages.NeedModule | packages.NeedForTest
+
+// File is a file of the application that was changed by instrumentation.
+type File struct {
+	Package  string `json:"package"`  // the ID of the package it was changed in
+	Path     string `json:"path"`     // relative to the application root
+	Modified string `json:"modified"` // its instrumented contents
+}
+
+// Result is what instrumenting a package, and its test variants, produced.
+type Result struct {
+	Hash                string         `json:"hash"`
+	Dir                 string         `json:"dir"`  // relative to the application root
+	Main                bool           `json:"main"` // declares a main function
+	Facts               []facts.Entry  `json:"facts,omitempty"`
+	Files               []File         `json:"files,omitempty"`
+	ImportsAdded        []string       `json:"importsAdded,omitempty"`
+	Preserved           []string       `json:"preserved,omitempty"` // the functions whose signatures were preserved
+	AlreadyInstrumented int            `json:"alreadyInstrumented,omitempty"`
+	Report              []report.Entry `json:"report,omitempty"`
+}
+
+// Cache holds the results of the last run on an application, and the settings they were produced with.
+type Cache struct {
+	path     string
+	Settings string             `json:"settings"`
+	Mains    []string           `json:"mains,omitempty"` // the load paths of the main packages, sorted
+	Results  map[string]*Result `json:"results"`         // by load path
+}
+
+// Application is the layout of an application, loaded without type checking it.
+type Application struct {
+	Root    string              // absolute path to the application
+	Hashes  map[string]string   // the hash of the contents of each package, by load path
+	Imports map[string][]string // the load paths of the packages of the application that each package imports
+	Files   map[string]string   // the load path of the package each file belongs to, by path relative to Root
+	Mains   []string            // the load paths of the main packages, sorted
+}
+
+// Open opens the cache kept in dir for the application at appPath, loaded with patterns. If there is none yet,
+// an empty cache is returned, which is written to dir when it is saved.
+func Open(dir, appPath string, patterns []string) (*Cache, error) {
+	root, err := filepath.Abs(appPath)
+	if err != nil {
+		return nil, err
+	}
+	key := sha256.Sum256([]byte(root + "\x00" + strings.Join(patterns, "\x00")))
+	c := &Cache{
+		path:    filepath.Join(dir, hex.EncodeToString(key[:8])+".json"),
+		Results: map[string]*Result{},
+	}
+
+	contents, err := os.ReadFile(c.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(contents, c); err != nil {
+		return nil, fmt.Errorf("reading cache %s: %w", c.path, err)
+	}
+	if c.Results == nil {
+		c.Results = map[string]*Result{}
+	}
+	return c, nil
+}
+
+// Save writes the cache to disk.
+func (c *Cache) Save() error {
+	contents, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		return err
+	}
+	// the cache is written in full before it replaces the old one, so that an interrupted run does not corrupt it
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, contents, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.path)
+}
+
+// LoadPath returns the path that loads a package: its import path, or the import path of the package that a
+// test variant or test binary was made for.
+func LoadPath(pkg *packages.Package) string {
+	if pkg.ForTest != "" {
+		return pkg.ForTest
+	}
+	// the IDs of test variants are followed by the test binary they are made for, e.g. "example.com/app [example.com/app.test]"
+	id, _, _ := strings.Cut(pkg.ID, " [")
+	return strings.TrimSuffix(id, ".test")
+}
+
+// relativePath returns the path of a file relative to the application root, with forward slashes.
+func relativePath(root, path string) string {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return filepath.ToSlash(path)
+	}
+	return filepath.ToSlash(rel)
+}
+
+// Scan loads the packages of the application at appPath that match patterns, without type checking them, and
+// hashes the contents of their files and of the go.mod and go.sum of their module.
+func Scan(appPath string, patterns []string) (*Application, error) {
+	root, err := filepath.Abs(appPath)
+	if err != nil {
+		return nil, err
+	}
+	pkgs, err := packages.Load(&packages.Config{Dir: appPath, Mode: scanMode, Tests: true}, patterns...)
+	if err != nil {
+		return nil, fmt.Errorf("loading packages: %w", err)
+	}
+
+	files := map[string][]string{}
+	modules := map[string]string{}
+	imports := map[string][]string{}
+	mains := map[string]bool{}
+	for _, pkg := range pkgs {
+		path := LoadPath(pkg)
+		for _, file := range slices.Concat(pkg.GoFiles, pkg.OtherFiles) {
+			// the files that the go command generates, such as the main file of test binaries, are not part of the application
+			if rel := relativePath(root, file); rel == ".." || strings.HasPrefix(rel, "../") {
+				continue
+			}
+			if !slices.Contains(files[path], file) {
+				files[path] = append(files[path], file)
+			}
+		}
+		if pkg.Module != nil && pkg.Module.GoMod != "" {
+			modules[path] = pkg.Module.GoMod
+		}
+		// the main package of a test binary is generated, and named after the package it tests
+		if pkg.Name == "main" && !strings.HasSuffix(pkg.ID, ".test") {
+			mains[path] = true
+		}
+		for _, imported := range pkg.Imports {
+			imports[path] = append(imports[path], LoadPath(imported))
+		}
+	}
+
+	app := &Application{
+		Root:    root,
+		Hashes:  map[string]string{},
+		Imports: map[string][]string{},
+		Files:   map[string]string{},
+	}
+	for path, pkgFiles := range files {
+		slices.Sort(pkgFiles)
+		hash := sha256.New()
+		for _, file := range pkgFiles {
+			rel := relativePath(root, file)
+			app.Files[rel] = path
+			if err := hashFile(hash, rel, file); err != nil {
+				return nil, err
+			}
+		}
+		// a change to the dependencies of the module may change how the package is instrumented
+		if goMod, ok := modules[path]; ok {
+			goSum := filepath.Join(filepath.Dir(goMod), "go.sum")
+			for _, file := range []string{goMod, goSum} {
+				if err := hashFile(hash, relativePath(root, file), file); err != nil {
+					return nil, err
+				}
+			}
+		}
+		app.Hashes[path] = hex.EncodeToString(hash.Sum(nil))
+		if mains[path] {
+			app.Mains = append(app.Mains, path)
+		}
+	}
+	slices.Sort(app.Mains)
+
+	for path, imported := range imports {
+		for _, importPath := range imported {
+			if _, ok := files[importPath]; ok && importPath != path && !slices.Contains(app.Imports[path], importPath) {
+				app.Imports[path] = append(app.Imports[path], importPath)
+			}
+		}
+		slices.Sort(app.Imports[path])
+	}
+	return app, nil
+}
+
+// hashFile writes the name and contents of a file to a hash. A file that does not exist is hashed as empty.
+func hashFile(hash io.Writer, name, path string) error {
+	fmt.Fprintf(hash, "%s\x00", name)
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(hash, f)
+	fmt.Fprint(hash, "\x00")
+	return err
+}
+
+// Stale returns the sorted load paths of the packages of the application that must be instrumented again with the
+// given settings: the packages that changed since the cached run, the packages that import them, directly or not,
+// and every package that these import. When main packages were added or removed, every main package changed.
+func (c *Cache) Stale(app *Application, settings string) []string {
+	importers := map[string][]string{}
+	for path, imported := range app.Imports {
+		for _, importPath := range imported {
+			importers[importPath] = append(importers[importPath], path)
+		}
+	}
+
+	changed := []string{}
+	for path, hash := range app.Hashes {
+		if result, ok := c.Results[path]; settings != c.Settings || !ok || result.Hash != hash {
+			changed = append(changed, path)
+		}
+	}
+	if !slices.Equal(app.Mains, c.Mains) {
+		changed = append(changed, app.Mains...)
+	}
+
+	stale := map[string]bool{}
+	affected := reachable(changed, importers)
+	for path := range reachable(slices.Collect(maps.Keys(affected)), app.Imports) {
+		stale[path] = true
+	}
+	return slices.Sorted(maps.Keys(stale))
+}
+
+// reachable returns the paths that can be reached from the given paths by following edges, including themselves.
+func reachable(from []string, edges map[string][]string) map[string]bool {
+	seen := map[string]bool{}
+	for len(from) > 0 {
+		path := from[len(from)-1]
+		from = from[:len(from)-1]
+		if seen[path] {
+			continue
+		}
+		seen[path] = true
+		from = append(from, edges[path]...)
+	}
+	return seen
+}
+
+// Cached returns the cached results of the packages of the application that are not stale, by load path.
+func (c *Cache) Cached(app *Application, stale []string) map[string]*Result {
+	cached := map[string]*Result{}
+	for path := range app.Hashes {
+		if !slices.Contains(stale, path) {
+			cached[path] = c.Results[path]
+		}
+	}
+	return cached
+}
+
+// Update replaces the cached results with the results of instrumenting the packages of the application with the
+// given settings, and assigns each entry of the report to the package of its file. Packages that are no longer
+// part of the application are dropped.
+func (c *Cache) Update(app *Application, settings string, results map[string]*Result, entries []report.Entry) {
+	if settings != c.Settings {
+		c.Results = map[string]*Result{}
+	}
+	c.Settings = settings
+	c.Mains = app.Mains
+
+	for path, result := range results {
+		result.Hash = app.Hashes[path]
+		result.Report = nil
+		c.Results[path] = result
+	}
+	for _, entry := range entries {
+		if result, ok := results[app.Files[entry.File]]; ok {
+			result.Report = append(result.Report, entry)
+		}
+	}
+	for path := range c.Results {
+		if _, ok := app.Hashes[path]; !ok {
+			delete(c.Results, path)
+		}
+	}
+}
@@ -0,0 +1,295 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/newrelic/go-easy-instrumentation/internal/report"
+)
+
+// writeApp writes the files of an application to a temporary directory, and returns its path.
+func writeApp(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, contents := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+var testApp = map[string]string{
+	"go.mod":          "module example.com/app\n\ngo 1.22\n",
+	"main.go":         "package main\n\nimport \"example.com/app/lib\"\n\nfunc main() { lib.Do() }\n",
+	"lib/lib.go":      "package lib\n\nimport \"example.com/app/util\"\n\nfunc Do() { util.Help() }\n",
+	"lib/lib_test.go": "package lib\n\nimport \"testing\"\n\nfunc TestDo(t *testing.T) { Do() }\n",
+	"util/util.go":    "package util\n\nfunc Help() {}\n",
+	"other/other.go":  "package other\n\nimport \"fmt\"\n\nfunc Print() { fmt.Println() }\n",
+}
+
+func TestScan(t *testing.T) {
+	dir := writeApp(t, testApp)
+	app, err := Scan(dir, []string{"./..."})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantImports := map[string][]string{
+		"example.com/app":     {"example.com/app/lib"},
+		"example.com/app/lib": {"example.com/app/util"},
+	}
+	if !reflect.DeepEqual(app.Imports, wantImports) {
+		t.Errorf("imports: got %v, want %v", app.Imports, wantImports)
+	}
+	wantFiles := map[string]string{
+		"main.go":         "example.com/app",
+		"lib/lib.go":      "example.com/app/lib",
+		"lib/lib_test.go": "example.com/app/lib",
+		"util/util.go":    "example.com/app/util",
+		"other/other.go":  "example.com/app/other",
+	}
+	if !reflect.DeepEqual(app.Files, wantFiles) {
+		t.Errorf("files: got %v, want %v", app.Files, wantFiles)
+	}
+	if len(app.Hashes) != 4 {
+		t.Fatalf("got hashes for %d packages, want 4", len(app.Hashes))
+	}
+
+	// changing a test file changes the hash of its package only
+	if err := os.WriteFile(filepath.Join(dir, "lib", "lib_test.go"), []byte("package lib\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	changed, err := Scan(dir, []string{"./..."})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for path, hash := range app.Hashes {
+		if same := changed.Hashes[path] == hash; same == (path == "example.com/app/lib") {
+			t.Errorf("%s: hash changed is %t", path, !same)
+		}
+	}
+
+	// changing go.mod changes the hash of every package of the module
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte(testApp["go.mod"]+"\nrequire example.com/dep v1.0.0\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	changed, err = Scan(dir, []string{"./..."})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for path, hash := range app.Hashes {
+		if changed.Hashes[path] == hash {
+			t.Errorf("%s: hash did not change with go.mod", path)
+		}
+	}
+}
+
+func testApplication() *Application {
+	return &Application{
+		Hashes: map[string]string{
+			"app":   "1",
+			"lib":   "2",
+			"util":  "3",
+			"other": "4",
+			"log":   "5",
+		},
+		Imports: map[string][]string{
+			"app":   {"lib", "log"},
+			"lib":   {"util"},
+			"other": {"util"},
+		},
+		Files: map[string]string{
+			"main.go":        "app",
+			"lib/lib.go":     "lib",
+			"util/util.go":   "util",
+			"other/other.go": "other",
+			"log/log.go":     "log",
+		},
+	}
+}
+
+func TestStale(t *testing.T) {
+	app := testApplication()
+	c := &Cache{Settings: "settings", Results: map[string]*Result{}}
+	for path, hash := range app.Hashes {
+		c.Results[path] = &Result{Hash: hash}
+	}
+
+	tests := []struct {
+		name     string
+		changed  []string
+		settings string
+		want     []string
+	}{
+		{
+			name:     "nothing changed",
+			settings: "settings",
+		},
+		{
+			name:     "settings changed",
+			settings: "other settings",
+			want:     []string{"app", "lib", "log", "other", "util"},
+		},
+		{
+			name:     "leaf package changed",
+			changed:  []string{"util"},
+			settings: "settings",
+			want:     []string{"app", "lib", "log", "other", "util"},
+		},
+		{
+			name:     "package imported by main changed",
+			changed:  []string{"lib"},
+			settings: "settings",
+			want:     []string{"app", "lib", "log", "util"},
+		},
+		{
+			name:     "package that imports nothing of the application changed",
+			changed:  []string{"log"},
+			settings: "settings",
+			want:     []string{"app", "lib", "log", "util"},
+		},
+		{
+			name:     "package imported by nothing changed",
+			changed:  []string{"other"},
+			settings: "settings",
+			want:     []string{"other", "util"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changed := testApplication()
+			for _, path := range tt.changed {
+				changed.Hashes[path] += "changed"
+			}
+			stale := c.Stale(changed, tt.settings)
+			if !reflect.DeepEqual(stale, tt.want) {
+				t.Errorf("got %v, want %v", stale, tt.want)
+			}
+
+			cached := c.Cached(changed, stale)
+			if len(cached)+len(stale) != len(changed.Hashes) {
+				t.Errorf("got %d cached and %d stale packages, want %d in all", len(cached), len(stale), len(changed.Hashes))
+			}
+		})
+	}
+
+	// packages that were never cached are stale
+	app.Hashes["new"] = "6"
+	if stale := c.Stale(app, "settings"); !reflect.DeepEqual(stale, []string{"new"}) {
+		t.Errorf("got %v, want [new]", stale)
+	}
+}
+
+func TestStale_Binaries(t *testing.T) {
+	files := map[string]string{
+		"go.mod":              "module example.com/app\n\ngo 1.22\n",
+		"cmd/api/main.go":     "package main\n\nimport \"example.com/app/lib\"\n\nfunc main() { lib.Do() }\n",
+		"cmd/api/api_test.go": "package main\n\nimport \"testing\"\n\nfunc TestMain(t *testing.T) {}\n",
+		"lib/lib.go":          "package lib\n\nfunc Do() {}\n",
+		"other/other.go":      "package other\n\nfunc Print() {}\n",
+	}
+	dir := writeApp(t, files)
+	app, err := Scan(dir, []string{"./..."})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"example.com/app/cmd/api"}; !reflect.DeepEqual(app.Mains, want) {
+		t.Fatalf("got main packages %v, want %v", app.Mains, want)
+	}
+
+	c := &Cache{Results: map[string]*Result{}}
+	results := map[string]*Result{}
+	for path := range app.Hashes {
+		results[path] = &Result{}
+	}
+	c.Update(app, "settings", results, nil)
+
+	// a binary added between runs changes the name that the binaries already cached report
+	if err := os.MkdirAll(filepath.Join(dir, "cmd", "worker"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "cmd", "worker", "main.go"), []byte("package main\n\nfunc main() {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	added, err := Scan(dir, []string{"./..."})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"example.com/app/cmd/api", "example.com/app/cmd/worker", "example.com/app/lib"}
+	if stale := c.Stale(added, "settings"); !reflect.DeepEqual(stale, want) {
+		t.Errorf("binary added: got stale %v, want %v", stale, want)
+	}
+
+	// and so does a binary removed between runs
+	results["example.com/app/cmd/worker"] = &Result{}
+	c.Update(added, "settings", results, nil)
+	if stale := c.Stale(app, "settings"); !reflect.DeepEqual(stale, []string{"example.com/app/cmd/api", "example.com/app/lib"}) {
+		t.Errorf("binary removed: got stale %v", stale)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	dir := t.TempDir()
+	c, err := Open(dir, "app", []string{"./..."})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Results) != 0 {
+		t.Fatalf("got %d results from a new cache, want none", len(c.Results))
+	}
+
+	app := testApplication()
+	c.Results["removed"] = &Result{Hash: "7"}
+	c.Settings = "settings"
+	results := map[string]*Result{
+		"app": {Dir: ".", Main: true, Files: []File{{Package: "app", Path: "main.go", Modified: "package main\n"}}},
+		"lib": {Dir: "lib", ImportsAdded: []string{"github.com/newrelic/go-agent/v3/newrelic"}},
+	}
+	entries := []report.Entry{
+		{Code: report.CodeAgentInitialized, Severity: report.SeverityAction, File: "main.go", Message: "agent"},
+		{Code: report.CodeSegment, Severity: report.SeverityAction, File: "util/util.go", Message: "segment"},
+	}
+	c.Update(app, "settings", results, entries)
+	if err := c.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	saved, err := Open(dir, "app", []string{"./..."})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.Settings != "settings" {
+		t.Errorf("got settings %q, want %q", saved.Settings, "settings")
+	}
+	if _, ok := saved.Results["removed"]; ok {
+		t.Error("the results of a package that is no longer part of the application were kept")
+	}
+	if got := saved.Results["app"]; got.Hash != "1" || !got.Main || len(got.Files) != 1 || len(got.Report) != 1 || got.Report[0].Message != "agent" {
+		t.Errorf("got app result %+v", got)
+	}
+	if got := saved.Results["lib"]; got.Hash != "2" || len(got.Report) != 0 || !reflect.DeepEqual(got.ImportsAdded, results["lib"].ImportsAdded) {
+		t.Errorf("got lib result %+v", got)
+	}
+
+	// the cache of another set of patterns is kept apart
+	other, err := Open(dir, "app", []string{"./cmd/..."})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(other.Results) != 0 {
+		t.Errorf("got %d results for other patterns, want none", len(other.Results))
+	}
+
+	// results produced with other settings are dropped
+	saved.Update(app, "other settings", map[string]*Result{"util": {Dir: "util"}}, nil)
+	if len(saved.Results) != 1 || saved.Results["util"] == nil {
+		t.Errorf("got results %v, want util only", saved.Results)
+	}
+}
@@ -31,6 +31,18 @@ func EndTransaction(transactionVariableName string) *dst.ExprStmt {
 	}
 }
 
+// DeferEndTransaction returns `defer txn.End()`, which ends a transaction when the function it is started for returns.
+func DeferEndTransaction(transactionVariableName string) *dst.DeferStmt {
+	return &dst.DeferStmt{
+		Call: EndTransaction(transactionVariableName).X.(*dst.CallExpr),
+		Decs: dst.DeferStmtDecorations{
+			NodeDecs: dst.NodeDecs{
+				After: dst.EmptyLine,
+			},
+		},
+	}
+}
+
 // NewTransactionParameter returns a field definition for a transaction parameter
 func NewTransactionParameter(txnName string) *dst.Field {
 	return &dst.Field{
@@ -60,6 +72,21 @@ func TxnNewGoroutine(transaction dst.Expr) *dst.CallExpr {
 	}
 }
 
+// TxnNewGoroutineAssignment returns `txn := txn.NewGoroutine()`, which shadows a transaction captured by a
+// function literal with a copy of it that is safe to use in the goroutine the literal runs in.
+func TxnNewGoroutineAssignment(txnVariable string) *dst.AssignStmt {
+	return &dst.AssignStmt{
+		Decs: dst.AssignStmtDecorations{
+			NodeDecs: dst.NodeDecs{
+				After: dst.EmptyLine,
+			},
+		},
+		Lhs: []dst.Expr{dst.NewIdent(txnVariable)},
+		Tok: token.DEFINE,
+		Rhs: []dst.Expr{TxnNewGoroutine(dst.NewIdent(txnVariable))},
+	}
+}
+
 // starts a NewRelic transaction
 // if overwireVariable is true, the transaction variable will be overwritten by variable assignment, otherwise it will be defined
 func StartTransaction(appVariableName, transactionVariableName, transactionName string, overwriteVariable bool) *dst.AssignStmt {
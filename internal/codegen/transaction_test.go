@@ -115,6 +115,23 @@ func TestEndTransaction(t *testing.T) {
 	}
 }
 
+func TestDeferEndTransaction(t *testing.T) {
+	want := &dst.DeferStmt{
+		Call: &dst.CallExpr{
+			Fun: &dst.SelectorExpr{
+				X:   dst.NewIdent("testTxn"),
+				Sel: dst.NewIdent("End"),
+			},
+		},
+		Decs: dst.DeferStmtDecorations{
+			NodeDecs: dst.NodeDecs{
+				After: dst.EmptyLine,
+			},
+		},
+	}
+	assert.Equal(t, want, DeferEndTransaction("testTxn"))
+}
+
 func TestNewTransactionParameter(t *testing.T) {
 	type args struct {
 		txnName string
@@ -186,6 +203,27 @@ func TestTxnNewGoroutine(t *testing.T) {
 	}
 }
 
+func TestTxnNewGoroutineAssignment(t *testing.T) {
+	want := &dst.AssignStmt{
+		Decs: dst.AssignStmtDecorations{
+			NodeDecs: dst.NodeDecs{
+				After: dst.EmptyLine,
+			},
+		},
+		Lhs: []dst.Expr{dst.NewIdent("testTxn")},
+		Tok: token.DEFINE,
+		Rhs: []dst.Expr{
+			&dst.CallExpr{
+				Fun: &dst.SelectorExpr{
+					X:   dst.NewIdent("testTxn"),
+					Sel: dst.NewIdent("NewGoroutine"),
+				},
+			},
+		},
+	}
+	assert.Equal(t, want, TxnNewGoroutineAssignment("testTxn"))
+}
+
 func TestGenerateNoticeError(t *testing.T) {
 	type args struct {
 		errExpr  dst.Expr
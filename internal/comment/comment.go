@@ -6,6 +6,8 @@ package comment
 
 import (
 	"fmt"
+	"slices"
+	"strings"
 
 	"github.com/dave/dst"
 	"github.com/dave/dst/decorator"
@@ -21,7 +23,31 @@ const (
 	DebugConsoleHeader string = "Debug"
 )
 
+// codeComments is false if Info and Warn should not add comments to the code, because the
+// diagnostics are reported in another way, such as a SARIF log.
+var codeComments = true
+
+// DisableCodeComments stops Info and Warn from adding comments to the code. They are still
+// added to the console printer.
+func DisableCodeComments() {
+	codeComments = false
+}
+
+// EnableCodeComments restores the default behavior of adding comments to the code.
+func EnableCodeComments() {
+	codeComments = true
+}
+
+// CodeCommentsEnabled returns true if diagnostics should be added to the code as comments. Integrations
+// that write their own comments should check this first.
+func CodeCommentsEnabled() bool {
+	return codeComments
+}
+
 func writeComment(node dst.Node, comments []string) {
+	if !codeComments || hasComment(node, comments) {
+		return
+	}
 	decs := node.Decorations()
 	if len(decs.Start) > 0 {
 		comments = append(comments, "//")
@@ -29,6 +55,19 @@ func writeComment(node dst.Node, comments []string) {
 	decs.Start.Prepend(comments...)
 }
 
+// hasComment returns true if a node already has a comment, so that running the tool again on code it already
+// commented does not add the same comment twice. Once the code is parsed again, the first line of a comment on
+// the init statement of an if, switch or for statement is found after its keyword, so the comment is also found
+// if the node starts with its additional lines.
+func hasComment(node dst.Node, comments []string) bool {
+	lines := node.Decorations().Start
+	if slices.Contains(lines, comments[0]) {
+		return true
+	}
+	additional := comments[1:]
+	return len(additional) > 0 && len(lines) >= len(additional) && slices.Equal(lines[:len(additional)], additional)
+}
+
 // Info appends a comment to a node that alerts a user to a non-critical issue in their code.
 // It also adds the comment to the console printer if it is enabled.
 //
@@ -74,3 +113,65 @@ func Warn(pkg *decorator.Package, commentNode dst.Node, positionNode dst.Node, m
 func Debug(pkg *decorator.Package, positionNode dst.Node, message string, additionalInfo ...string) {
 	printer.add(pkg, positionNode, DebugConsoleHeader, message, additionalInfo...)
 }
+
+// Remove deletes the Info and Warn comments that were added to a node, along with their additional
+// lines, and returns true if any were found. Comments written by the user are kept.
+func Remove(node dst.Node) bool {
+	decs := node.Decorations()
+	if decs == nil {
+		return false
+	}
+
+	removed := removeLines(&decs.Start)
+	switch n := node.(type) {
+	case *dst.IfStmt:
+		removed = removeInitLines(&n.Decs.If, n.Init) || removed
+	case *dst.SwitchStmt:
+		removed = removeInitLines(&n.Decs.Switch, n.Init) || removed
+	case *dst.TypeSwitchStmt:
+		removed = removeInitLines(&n.Decs.Switch, n.Init) || removed
+	case *dst.ForStmt:
+		removed = removeInitLines(&n.Decs.For, n.Init) || removed
+	}
+	return removed
+}
+
+// removeInitLines removes the comments that were added to the init statement of an if, switch or for statement.
+// Once the code is parsed again, the first line of a comment is found after the keyword of the statement, and the
+// rest of its lines above the init statement.
+func removeInitLines(keyword *dst.Decorations, init dst.Stmt) bool {
+	if init == nil {
+		return removeLines(keyword)
+	}
+	start := &init.Decorations().Start
+	lines := append(append(dst.Decorations{}, *keyword...), *start...)
+	if !removeLines(&lines) {
+		return false
+	}
+	*keyword = nil
+	*start = lines
+	return true
+}
+
+func removeLines(decs *dst.Decorations) bool {
+	kept := dst.Decorations{}
+	removed := false
+	for i := 0; i < len(*decs); i++ {
+		line := (*decs)[i]
+		if !strings.HasPrefix(line, "// "+InfoHeader+": ") && !strings.HasPrefix(line, "// "+WarnHeader+": ") {
+			kept = append(kept, line)
+			continue
+		}
+
+		// the additional lines run until the separator from the comments that were already there, if any
+		removed = true
+		for i+1 < len(*decs) && (*decs)[i] != "//" {
+			i++
+		}
+	}
+
+	if removed {
+		*decs = kept
+	}
+	return removed
+}
@@ -83,3 +83,70 @@ func TestWarn(t *testing.T) {
 		}
 	}
 }
+
+func TestWarnExistingComment(t *testing.T) {
+	node := &dst.Ident{Name: "hi"}
+	Warn(nil, node, nil, "message", "additionalInfo")
+	Warn(nil, node, nil, "message", "additionalInfo")
+	if len(node.Decorations().Start) != 2 {
+		t.Errorf("Expected the comment to be added once, got %v", node.Decorations().Start)
+	}
+
+	// the first line of a comment on the init statement of an if statement is moved after its keyword
+	initNode := &dst.Ident{Name: "hi", Decs: dst.IdentDecorations{NodeDecs: dst.NodeDecs{Start: []string{"// additionalInfo"}}}}
+	Warn(nil, initNode, nil, "message", "additionalInfo")
+	if len(initNode.Decorations().Start) != 1 {
+		t.Errorf("Expected the comment not to be added again, got %v", initNode.Decorations().Start)
+	}
+}
+
+func TestDisableCodeComments(t *testing.T) {
+	DisableCodeComments()
+	t.Cleanup(EnableCodeComments)
+
+	node := &dst.Ident{Name: "hi"}
+	Warn(nil, node, nil, "message", "additionalInfo")
+	Info(nil, node, nil, "message")
+
+	if len(node.Decorations().Start) != 0 {
+		t.Errorf("Expected no comments, got %v", node.Decorations().Start)
+	}
+	if CodeCommentsEnabled() {
+		t.Error("Expected code comments to be disabled")
+	}
+}
+
+func TestRemove(t *testing.T) {
+	node := &dst.Ident{Name: "hi", Decs: dst.IdentDecorations{NodeDecs: dst.NodeDecs{Start: []string{"// existing comment"}}}}
+	Info(nil, node, nil, "message", "additionalInfo")
+	Warn(nil, node, nil, "message")
+
+	if !Remove(node) {
+		t.Error("Expected comments to be removed")
+	}
+	decs := node.Decorations()
+	if len(decs.Start) != 1 || decs.Start[0] != "// existing comment" {
+		t.Errorf("Expected only the existing comment to be kept, got %v", decs.Start)
+	}
+
+	if Remove(node) {
+		t.Error("Expected no comments to be removed from a node without instrumentation comments")
+	}
+
+	onlyTool := &dst.Ident{Name: "hi"}
+	Warn(nil, onlyTool, nil, "message", "additionalInfo")
+	Remove(onlyTool)
+	if len(onlyTool.Decorations().Start) != 0 {
+		t.Errorf("Expected no comments, got %v", onlyTool.Decorations().Start)
+	}
+
+	// a comment on the init statement of an if statement is split between the if keyword and the init statement
+	init := &dst.AssignStmt{Decs: dst.AssignStmtDecorations{NodeDecs: dst.NodeDecs{Start: []string{"// additionalInfo", "//", "// existing comment"}}}}
+	ifStmt := &dst.IfStmt{Init: init, Decs: dst.IfStmtDecorations{If: []string{"// NR WARN: message"}}}
+	if !Remove(ifStmt) {
+		t.Error("Expected comments to be removed from an if statement")
+	}
+	if len(ifStmt.Decs.If) != 0 || len(init.Decs.Start) != 1 || init.Decs.Start[0] != "// existing comment" {
+		t.Errorf("Expected only the existing comment to be kept, got %v and %v", ifStmt.Decs.If, init.Decs.Start)
+	}
+}
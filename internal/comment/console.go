@@ -4,6 +4,7 @@ import (
 	"log"
 	"path/filepath"
 	"strings"
+	"sync"
 
 	"github.com/dave/dst"
 	"github.com/dave/dst/decorator"
@@ -15,6 +16,7 @@ type consoleEntry struct {
 }
 
 type ConsolePrinter struct {
+	mu      sync.Mutex // packages may be walked concurrently
 	appRoot string
 	entries []consoleEntry
 }
@@ -57,6 +59,8 @@ func (p *ConsolePrinter) add(pkg *decorator.Package, node dst.Node, header, mess
 		b.WriteString(info)
 	}
 
+	p.mu.Lock()
+	defer p.mu.Unlock()
 	p.entries = append(p.entries, consoleEntry{header: header, message: b.String()})
 }
 
@@ -66,6 +70,8 @@ func (p *ConsolePrinter) flush() {
 		return
 	}
 
+	p.mu.Lock()
+	defer p.mu.Unlock()
 	for _, e := range p.entries {
 		if e.header == DebugConsoleHeader {
 			log.Println(e.message)
@@ -0,0 +1,207 @@
+// Package config loads the project configuration for an instrumentation run from a .go-easy-instrumentation.yaml
+// file, so that the decisions made for an application can be checked in next to it.
+//
+// An example configuration file:
+//
+//	app_name: checkout-service
+//	agent_variable_name: NewRelicAgent
+//	transaction_variable_name: nrTxn
+//	diff_file: new-relic-instrumentation.diff
+//	integrations:
+//	  disabled: [nrlogrus]
+//	exclude: [internal/testutil, "*_mock.go"]
+//	agent_config:
+//	  distributed_tracer_enabled: true
+//	  app_log_forwarding_enabled: false
+//	preserve_signatures: true
+//	library: false
+//	binaries:
+//	  cmd/api: {app_name: checkout-api}
+//	  cmd/migrate: {skip: true}
+//	call_graph: vta
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/token"
+	"io"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/newrelic/go-easy-instrumentation/internal/filter"
+	"gopkg.in/yaml.v3"
+)
+
+// FileName is the name of the project configuration file.
+const FileName = ".go-easy-instrumentation.yaml"
+
+const (
+	DefaultAgentVariableName       = "NewRelicAgent"
+	DefaultTransactionVariableName = "nrTxn"
+	DefaultDiffFileName            = "new-relic-instrumentation.diff"
+)
+
+// The call graph algorithms that calls to interface methods can be resolved with.
+const (
+	CallGraphCHA = "cha" // class hierarchy analysis: every implementation of the interface in the application
+	CallGraphVTA = "vta" // variable type analysis: only the implementations whose values can reach the call
+)
+
+// agentConfigFunctions maps the agent_config keys to the go agent config options that set them.
+var agentConfigFunctions = map[string]string{
+	"enabled":                        "ConfigEnabled",
+	"distributed_tracer_enabled":     "ConfigDistributedTracerEnabled",
+	"app_log_enabled":                "ConfigAppLogEnabled",
+	"app_log_forwarding_enabled":     "ConfigAppLogForwardingEnabled",
+	"app_log_decorating_enabled":     "ConfigAppLogDecoratingEnabled",
+	"app_log_metrics_enabled":        "ConfigAppLogMetricsEnabled",
+	"code_level_metrics_enabled":     "ConfigCodeLevelMetricsEnabled",
+	"custom_insights_events_enabled": "ConfigCustomInsightsEventsEnabled",
+}
+
+// Config is the configuration for an instrumentation run.
+type Config struct {
+	AppName                 string            `yaml:"app_name"`
+	AgentVariableName       string            `yaml:"agent_variable_name"`
+	TransactionVariableName string            `yaml:"transaction_variable_name"`
+	DiffFile                string            `yaml:"diff_file"` // relative to the application being instrumented
+	Integrations            Integrations      `yaml:"integrations"`
+	Include                 []string          `yaml:"include"`
+	Exclude                 []string          `yaml:"exclude"`
+	AgentConfig             map[string]bool   `yaml:"agent_config"`
+	PreserveSignatures      bool              `yaml:"preserve_signatures"` // never change the signatures of exported functions
+	Library                 bool              `yaml:"library"`             // instrument a library, which has no main function
+	CallGraph               string            `yaml:"call_graph"`          // resolve calls to interface methods with "cha" or "vta"
+	Binaries                map[string]Binary `yaml:"binaries"`            // by directory of the main package, relative to the application
+
+	path string
+}
+
+// Integrations selects the integrations that are used to instrument the application. If Enabled is empty,
+// every integration that is not disabled is used.
+type Integrations struct {
+	Enabled  []string `yaml:"enabled"`
+	Disabled []string `yaml:"disabled"`
+}
+
+// Binary configures the instrumentation of a main package of the application, which is built into a binary of its own.
+type Binary struct {
+	AppName string `yaml:"app_name"` // the application name the binary reports to New Relic
+	Skip    bool   `yaml:"skip"`     // leave the binary uninstrumented
+}
+
+// AgentConfigOption is a go agent config option that is passed to newrelic.NewApplication, e.g.
+// newrelic.ConfigDistributedTracerEnabled(true).
+type AgentConfigOption struct {
+	Function string
+	Value    bool
+}
+
+// Default returns the configuration used when no configuration file is found.
+func Default() *Config {
+	return &Config{
+		AgentVariableName:       DefaultAgentVariableName,
+		TransactionVariableName: DefaultTransactionVariableName,
+	}
+}
+
+// Load reads and validates a configuration file. Settings that are left out of the file keep their defaults.
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := Default()
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration in %s: %w", path, err)
+	}
+	cfg.path = path
+	return cfg, nil
+}
+
+// Find looks for a configuration file in dir and each of its parent directories, and returns the path of
+// the first one found. An empty path is returned if there is no configuration file.
+func Find(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for {
+		path := filepath.Join(dir, FileName)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+// Path returns the path of the file the configuration was loaded from, or an empty string for the default configuration.
+func (c *Config) Path() string {
+	return c.path
+}
+
+// Validate checks that the variable names are valid identifiers, and that the patterns and agent config options
+// are valid. Integration names are validated when the integrations are registered.
+func (c *Config) Validate() error {
+	if !token.IsIdentifier(c.AgentVariableName) {
+		return fmt.Errorf("agent_variable_name %q is not a valid Go identifier", c.AgentVariableName)
+	}
+	if !token.IsIdentifier(c.TransactionVariableName) {
+		return fmt.Errorf("transaction_variable_name %q is not a valid Go identifier", c.TransactionVariableName)
+	}
+	if c.AgentVariableName == c.TransactionVariableName {
+		return fmt.Errorf("agent_variable_name and transaction_variable_name must be different")
+	}
+	if _, err := filter.New(c.Include, c.Exclude); err != nil {
+		return err
+	}
+	if c.CallGraph != "" && c.CallGraph != CallGraphCHA && c.CallGraph != CallGraphVTA {
+		return fmt.Errorf("call_graph %q is not supported; use %q or %q", c.CallGraph, CallGraphCHA, CallGraphVTA)
+	}
+	for _, dir := range slices.Sorted(maps.Keys(c.Binaries)) {
+		if path.IsAbs(dir) || path.Clean(dir) != dir || dir == ".." || strings.HasPrefix(dir, "../") {
+			return fmt.Errorf("binaries: %q is not a directory relative to the application, such as \"cmd/api\"", dir)
+		}
+	}
+	for _, key := range slices.Sorted(maps.Keys(c.AgentConfig)) {
+		if _, ok := agentConfigFunctions[key]; !ok {
+			return fmt.Errorf("unknown agent_config option %q; supported options are %v", key, slices.Sorted(maps.Keys(agentConfigFunctions)))
+		}
+	}
+	return nil
+}
+
+// AgentConfigOptions returns the go agent config options to initialize the agent with, sorted by name.
+func (c *Config) AgentConfigOptions() []AgentConfigOption {
+	options := []AgentConfigOption{}
+	for _, key := range slices.Sorted(maps.Keys(c.AgentConfig)) {
+		if function, ok := agentConfigFunctions[key]; ok {
+			options = append(options, AgentConfigOption{Function: function, Value: c.AgentConfig[key]})
+		}
+	}
+	return options
+}
+
+// IntegrationEnabled returns true if the named integration should be used to instrument the application.
+func (c *Config) IntegrationEnabled(name string) bool {
+	if slices.Contains(c.Integrations.Disabled, name) {
+		return false
+	}
+	return len(c.Integrations.Enabled) == 0 || slices.Contains(c.Integrations.Enabled, name)
+}
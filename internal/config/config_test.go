@@ -0,0 +1,174 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeConfig(t *testing.T, dir, contents string) string {
+	t.Helper()
+	path := filepath.Join(dir, FileName)
+	if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	path := writeConfig(t, t.TempDir(), `app_name: checkout
+agent_variable_name: app
+transaction_variable_name: txn
+diff_file: changes.diff
+integrations:
+  enabled: [nrgin, nrnethttp]
+  disabled: [nrnethttp]
+include: [handlers]
+exclude: [internal/testutil, "*_mock.go"]
+agent_config:
+  distributed_tracer_enabled: true
+  app_log_forwarding_enabled: false
+preserve_signatures: true
+library: true
+call_graph: vta
+binaries:
+  cmd/api: {app_name: checkout-api}
+  cmd/migrate: {skip: true}
+`)
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &Config{
+		AppName:                 "checkout",
+		AgentVariableName:       "app",
+		TransactionVariableName: "txn",
+		DiffFile:                "changes.diff",
+		Integrations:            Integrations{Enabled: []string{"nrgin", "nrnethttp"}, Disabled: []string{"nrnethttp"}},
+		Include:                 []string{"handlers"},
+		Exclude:                 []string{"internal/testutil", "*_mock.go"},
+		AgentConfig:             map[string]bool{"distributed_tracer_enabled": true, "app_log_forwarding_enabled": false},
+		PreserveSignatures:      true,
+		Library:                 true,
+		CallGraph:               CallGraphVTA,
+		Binaries:                map[string]Binary{"cmd/api": {AppName: "checkout-api"}, "cmd/migrate": {Skip: true}},
+		path:                    path,
+	}
+	if !reflect.DeepEqual(cfg, want) {
+		t.Errorf("expected %+v, got %+v", want, cfg)
+	}
+	if cfg.Path() != path {
+		t.Errorf("expected path %q, got %q", path, cfg.Path())
+	}
+}
+
+func TestLoadDefaults(t *testing.T) {
+	for _, contents := range []string{"", "app_name: checkout\n"} {
+		cfg, err := Load(writeConfig(t, t.TempDir(), contents))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.AgentVariableName != DefaultAgentVariableName || cfg.TransactionVariableName != DefaultTransactionVariableName {
+			t.Errorf("expected default variable names for %q, got %q and %q", contents, cfg.AgentVariableName, cfg.TransactionVariableName)
+		}
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		contents string
+		wantErr  string
+	}{
+		{name: "unknown setting", contents: "app_nmae: checkout\n", wantErr: "app_nmae"},
+		{name: "invalid agent variable", contents: "agent_variable_name: new-relic\n", wantErr: "agent_variable_name"},
+		{name: "invalid transaction variable", contents: "transaction_variable_name: \"\"\n", wantErr: "transaction_variable_name"},
+		{name: "same variable names", contents: "agent_variable_name: nr\ntransaction_variable_name: nr\n", wantErr: "must be different"},
+		{name: "unknown agent config option", contents: "agent_config:\n  license: true\n", wantErr: "license"},
+		{name: "invalid pattern", contents: "exclude: [\"[bad\"]\n", wantErr: "invalid pattern"},
+		{name: "unknown call graph", contents: "call_graph: rta\n", wantErr: "call_graph"},
+		{name: "absolute binary directory", contents: "binaries:\n  /cmd/api: {skip: true}\n", wantErr: "binaries"},
+		{name: "binary directory outside the application", contents: "binaries:\n  ../api: {skip: true}\n", wantErr: "binaries"},
+		{name: "unclean binary directory", contents: "binaries:\n  ./cmd/api: {skip: true}\n", wantErr: "binaries"},
+		{name: "malformed yaml", contents: "include: [\n", wantErr: "parsing"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(writeConfig(t, t.TempDir(), tt.contents))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected an error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestFind(t *testing.T) {
+	root := t.TempDir()
+	nested := filepath.Join(root, "services", "checkout")
+	if err := os.MkdirAll(nested, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := Find(nested)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "" {
+		t.Errorf("expected no configuration file, got %q", got)
+	}
+
+	want := writeConfig(t, root, "app_name: checkout\n")
+	got, err = Find(nested)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestAgentConfigOptions(t *testing.T) {
+	cfg := Default()
+	cfg.AgentConfig = map[string]bool{
+		"distributed_tracer_enabled": true,
+		"app_log_forwarding_enabled": false,
+	}
+
+	want := []AgentConfigOption{
+		{Function: "ConfigAppLogForwardingEnabled", Value: false},
+		{Function: "ConfigDistributedTracerEnabled", Value: true},
+	}
+	if got := cfg.AgentConfigOptions(); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestIntegrationEnabled(t *testing.T) {
+	tests := []struct {
+		name         string
+		integrations Integrations
+		integration  string
+		want         bool
+	}{
+		{name: "everything enabled by default", integration: "nrgin", want: true},
+		{name: "disabled", integrations: Integrations{Disabled: []string{"nrgin"}}, integration: "nrgin", want: false},
+		{name: "not in enabled list", integrations: Integrations{Enabled: []string{"nrecho-v4"}}, integration: "nrgin", want: false},
+		{name: "in enabled list", integrations: Integrations{Enabled: []string{"nrgin"}}, integration: "nrgin", want: true},
+		{name: "disabled wins", integrations: Integrations{Enabled: []string{"nrgin"}, Disabled: []string{"nrgin"}}, integration: "nrgin", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Default()
+			cfg.Integrations = tt.integrations
+			if got := cfg.IntegrationEnabled(tt.integration); got != tt.want {
+				t.Errorf("IntegrationEnabled(%q) = %v, want %v", tt.integration, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,254 @@
+// Package diff parses the unified diffs generated for instrumented files back into hunks, and applies
+// a selection of those hunks to the original file contents. This allows the tool to reason about the
+// changes it suggests one hunk at a time, e.g. to drop a hunk that does not compile, or to let a user
+// reject it.
+package diff
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	godiffpatch "github.com/sourcegraph/go-diff-patch"
+)
+
+const noNewlineMarker = `\ No newline at end of file`
+
+// LineKind identifies whether a line of a hunk is unchanged, removed or added.
+type LineKind byte
+
+const (
+	Context LineKind = ' '
+	Delete  LineKind = '-'
+	Insert  LineKind = '+'
+)
+
+// Line is a single line of a hunk. Content includes the trailing newline, unless the line is
+// the last line of a file that does not end in a newline.
+type Line struct {
+	Kind    LineKind
+	Content string
+}
+
+// Hunk is a contiguous group of changes in a file.
+//
+// OldStart and NewStart are 1 indexed line numbers in the original and modified file. NewStart
+// is always computed from the hunks that precede it in the same file, so it is accurate even if the
+// header of the patch it was parsed from was not.
+type Hunk struct {
+	OldStart int
+	OldLines int
+	NewStart int
+	NewLines int
+	Lines    []Line
+}
+
+// Header returns the unified diff header for this hunk, e.g. "@@ -1,3 +1,4 @@".
+func (h *Hunk) Header() string {
+	return fmt.Sprintf("@@ -%d,%d +%d,%d @@", h.OldStart, h.OldLines, h.NewStart, h.NewLines)
+}
+
+// ContainsNewLine returns true if the given line number of the modified file is part of this hunk.
+func (h *Hunk) ContainsNewLine(line int) bool {
+	return line >= h.NewStart && line < h.NewStart+h.NewLines
+}
+
+// String returns the hunk in unified diff format.
+func (h *Hunk) String() string {
+	var b strings.Builder
+	b.WriteString(h.Header())
+	b.WriteByte('\n')
+	for _, line := range h.Lines {
+		b.WriteByte(byte(line.Kind))
+		b.WriteString(line.Content)
+		if !strings.HasSuffix(line.Content, "\n") {
+			b.WriteString("\n" + noNewlineMarker + "\n")
+		}
+	}
+	return b.String()
+}
+
+// Parse reads the hunks of a single file patch, as generated by Generate. The file headers
+// are skipped. An empty patch contains no hunks.
+func Parse(patch string) ([]*Hunk, error) {
+	hunks := []*Hunk{}
+	var current *Hunk
+	offset := 0
+
+	lines := strings.SplitAfter(patch, "\n")
+	for i, line := range lines {
+		switch {
+		case line == "":
+			continue
+		case strings.HasPrefix(line, "--- ") && current == nil, strings.HasPrefix(line, "+++ ") && current == nil:
+			continue
+		case strings.HasPrefix(line, "@@"):
+			if current != nil {
+				offset += current.NewLines - current.OldLines
+			}
+			oldStart, oldLines, err := parseHunkHeader(line)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", i+1, err)
+			}
+			current = &Hunk{
+				OldStart: oldStart,
+				OldLines: oldLines,
+				NewStart: oldStart + offset,
+			}
+			if oldLines == 0 {
+				// pure insertions are anchored after the line they are inserted at
+				current.NewStart++
+			}
+			hunks = append(hunks, current)
+		case strings.HasPrefix(line, noNewlineMarker):
+			if current == nil || len(current.Lines) == 0 {
+				return nil, fmt.Errorf("line %d: unexpected %q", i+1, noNewlineMarker)
+			}
+			last := &current.Lines[len(current.Lines)-1]
+			last.Content = strings.TrimSuffix(last.Content, "\n")
+		default:
+			if current == nil {
+				return nil, fmt.Errorf("line %d: content outside of a hunk", i+1)
+			}
+			kind := LineKind(line[0])
+			switch kind {
+			case Context, Insert:
+				current.NewLines++
+			case Delete:
+			default:
+				return nil, fmt.Errorf("line %d: invalid hunk line %q", i+1, strings.TrimSuffix(line, "\n"))
+			}
+			current.Lines = append(current.Lines, Line{Kind: kind, Content: line[1:]})
+		}
+	}
+
+	return hunks, nil
+}
+
+// parseHunkHeader returns the original file range of a hunk header.
+func parseHunkHeader(header string) (int, int, error) {
+	fields := strings.Fields(header)
+	if len(fields) < 3 || !strings.HasPrefix(fields[1], "-") {
+		return 0, 0, fmt.Errorf("invalid hunk header %q", strings.TrimSpace(header))
+	}
+
+	start, count, found := strings.Cut(fields[1][1:], ",")
+	if !found {
+		count = "1"
+	}
+	s, err := strconv.Atoi(start)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid hunk header %q: %w", strings.TrimSpace(header), err)
+	}
+	c, err := strconv.Atoi(count)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid hunk header %q: %w", strings.TrimSpace(header), err)
+	}
+	return s, c, nil
+}
+
+// Apply applies hunks to the original contents of a file and returns the result. The hunks must all
+// come from the same patch, and be ordered as they were in the patch; any subset of them may be
+// applied. An error is returned if the original contents do not match the hunks.
+func Apply(original string, hunks []*Hunk) (string, error) {
+	originalLines := strings.SplitAfter(original, "\n")
+	if originalLines[len(originalLines)-1] == "" {
+		originalLines = originalLines[:len(originalLines)-1]
+	}
+
+	var b strings.Builder
+	next := 0 // index of the next original line that has not been written yet
+	for _, hunk := range hunks {
+		start := hunk.OldStart - 1
+		if hunk.OldLines == 0 {
+			// pure insertions happen after the line they reference
+			start = hunk.OldStart
+		}
+		if start < next || start > len(originalLines) {
+			return "", fmt.Errorf("hunk %s is out of order or out of range", hunk.Header())
+		}
+		for _, line := range originalLines[next:start] {
+			b.WriteString(line)
+		}
+		next = start
+
+		for _, line := range hunk.Lines {
+			switch line.Kind {
+			case Context, Delete:
+				if next >= len(originalLines) || originalLines[next] != line.Content {
+					return "", fmt.Errorf("hunk %s does not match the original file at line %d", hunk.Header(), next+1)
+				}
+				if line.Kind == Context {
+					b.WriteString(line.Content)
+				}
+				next++
+			case Insert:
+				b.WriteString(line.Content)
+			}
+		}
+	}
+
+	for _, line := range originalLines[next:] {
+		b.WriteString(line)
+	}
+	return b.String(), nil
+}
+
+// ParseEdited reads a hunk that a user edited from the lines of original, as written by String but without
+// the header. Lines starting with '#' are ignored. As with git add --patch, only the new side of the hunk
+// may change: added lines can be changed or removed, and removed lines can be kept by turning their '-'
+// into a ' '. An error is returned if the old side of the hunk no longer matches the original.
+func ParseEdited(original *Hunk, edited string) (*Hunk, error) {
+	hunk := &Hunk{OldStart: original.OldStart, NewStart: original.NewStart}
+	for i, line := range strings.SplitAfter(edited, "\n") {
+		switch {
+		case line == "", strings.HasPrefix(line, "#"):
+			continue
+		case strings.HasPrefix(line, noNewlineMarker):
+			if len(hunk.Lines) == 0 {
+				return nil, fmt.Errorf("line %d: unexpected %q", i+1, noNewlineMarker)
+			}
+			last := &hunk.Lines[len(hunk.Lines)-1]
+			last.Content = strings.TrimSuffix(last.Content, "\n")
+			continue
+		}
+
+		kind := LineKind(line[0])
+		switch kind {
+		case Context:
+			hunk.OldLines++
+			hunk.NewLines++
+		case Insert:
+			hunk.NewLines++
+		case Delete:
+			hunk.OldLines++
+		default:
+			return nil, fmt.Errorf("line %d: invalid hunk line %q", i+1, strings.TrimSuffix(line, "\n"))
+		}
+		hunk.Lines = append(hunk.Lines, Line{Kind: kind, Content: line[1:]})
+	}
+
+	if !slices.Equal(oldSide(original), oldSide(hunk)) {
+		return nil, errors.New("the original lines of the hunk were changed; only added lines can be edited")
+	}
+	return hunk, nil
+}
+
+// oldSide returns the lines of a hunk that are in the original file.
+func oldSide(h *Hunk) []string {
+	lines := []string{}
+	for _, line := range h.Lines {
+		if line.Kind != Insert {
+			lines = append(lines, line.Content)
+		}
+	}
+	return lines
+}
+
+// Generate creates a git compatible unified diff between the original and modified contents of a file.
+// The name of the file is used in the patch headers. If there are no changes, an empty string is returned.
+func Generate(name, original, modified string) string {
+	return godiffpatch.GeneratePatch(name, original, modified)
+}
@@ -0,0 +1,249 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+)
+
+const original = `package main
+
+import "fmt"
+
+func main() {
+	fmt.Println("one")
+	fmt.Println("two")
+	fmt.Println("three")
+	fmt.Println("four")
+	fmt.Println("five")
+	fmt.Println("six")
+	fmt.Println("seven")
+	fmt.Println("eight")
+	fmt.Println("nine")
+	fmt.Println("ten")
+}
+`
+
+func modify(t *testing.T, replacements ...string) string {
+	t.Helper()
+	modified := original
+	for i := 0; i < len(replacements); i += 2 {
+		if !strings.Contains(modified, replacements[i]) {
+			t.Fatalf("original does not contain %q", replacements[i])
+		}
+		modified = strings.Replace(modified, replacements[i], replacements[i+1], 1)
+	}
+	return modified
+}
+
+func TestParse(t *testing.T) {
+	modified := modify(t,
+		"import \"fmt\"\n", "import (\n\t\"fmt\"\n\t\"os\"\n)\n",
+		"\tfmt.Println(\"ten\")\n", "\tfmt.Println(\"ten\")\n\tos.Exit(0)\n",
+	)
+
+	hunks, err := Parse(Generate("main.go", original, modified))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hunks) != 2 {
+		t.Fatalf("expected 2 hunks, got %d", len(hunks))
+	}
+
+	modifiedLines := strings.Split(modified, "\n")
+	for _, hunk := range hunks {
+		// every line in the hunk that exists in the modified file must line up with NewStart
+		newLine := hunk.NewStart
+		for _, line := range hunk.Lines {
+			if line.Kind == Delete {
+				continue
+			}
+			if got := modifiedLines[newLine-1] + "\n"; got != line.Content {
+				t.Errorf("hunk %s: expected line %d to be %q, got %q", hunk.Header(), newLine, line.Content, got)
+			}
+			newLine++
+		}
+		if newLine != hunk.NewStart+hunk.NewLines {
+			t.Errorf("hunk %s: expected %d new lines, counted %d", hunk.Header(), hunk.NewLines, newLine-hunk.NewStart)
+		}
+	}
+
+	if !hunks[1].ContainsNewLine(19) {
+		t.Errorf("expected hunk %s to contain the added os.Exit on line 19", hunks[1].Header())
+	}
+	if hunks[0].ContainsNewLine(19) {
+		t.Errorf("expected hunk %s not to contain line 19", hunks[0].Header())
+	}
+}
+
+func TestParseEmpty(t *testing.T) {
+	hunks, err := Parse(Generate("main.go", original, original))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hunks) != 0 {
+		t.Errorf("expected no hunks, got %d", len(hunks))
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		patch string
+	}{
+		{name: "content outside hunk", patch: "--- a/main.go\n+++ b/main.go\n+hello\n"},
+		{name: "bad header", patch: "@@ -x,1 +1,1 @@\n hello\n"},
+		{name: "bad line kind", patch: "@@ -1,1 +1,1 @@\n*hello\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Parse(tt.patch); err == nil {
+				t.Error("expected an error, got nil")
+			}
+		})
+	}
+}
+
+func TestApply(t *testing.T) {
+	first := modify(t, "import \"fmt\"\n", "import (\n\t\"fmt\"\n\t\"os\"\n)\n")
+	both := modify(t,
+		"import \"fmt\"\n", "import (\n\t\"fmt\"\n\t\"os\"\n)\n",
+		"\tfmt.Println(\"ten\")\n", "\tfmt.Println(\"ten\")\n\tos.Exit(0)\n",
+	)
+	second := modify(t, "\tfmt.Println(\"ten\")\n", "\tfmt.Println(\"ten\")\n\tos.Exit(0)\n")
+
+	hunks, err := Parse(Generate("main.go", original, both))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		hunks []*Hunk
+		want  string
+	}{
+		{name: "all hunks", hunks: hunks, want: both},
+		{name: "no hunks", hunks: nil, want: original},
+		{name: "first hunk", hunks: hunks[:1], want: first},
+		{name: "second hunk", hunks: hunks[1:], want: second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Apply(original, tt.hunks)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("unexpected result:\n%s", Generate("main.go", tt.want, got))
+			}
+		})
+	}
+}
+
+func TestApplyNoTrailingNewline(t *testing.T) {
+	orig := "package main\n\nfunc main() {}"
+	modified := "package main\n\nfunc main() {\n}"
+
+	hunks, err := Parse(Generate("main.go", orig, modified))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := Apply(orig, hunks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != modified {
+		t.Errorf("expected %q, got %q", modified, got)
+	}
+}
+
+func TestApplyMismatch(t *testing.T) {
+	hunks, err := Parse(Generate("main.go", original, modify(t, "\"one\"", "\"uno\"")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Apply(modify(t, "\"one\"", "\"eins\""), hunks); err == nil {
+		t.Error("expected an error applying hunks to a different file, got nil")
+	}
+}
+
+func TestHunkString(t *testing.T) {
+	patch := Generate("main.go", original, modify(t, "\"one\"", "\"uno\""))
+	hunks, err := Parse(patch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hunks) != 1 {
+		t.Fatalf("expected 1 hunk, got %d", len(hunks))
+	}
+	want := patch[strings.Index(patch, "@@"):]
+	if got := hunks[0].String(); got != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, got)
+	}
+}
+
+func TestParseEdited(t *testing.T) {
+	modified := modify(t, "\tfmt.Println(\"five\")\n", "\tfmt.Println(\"five\")\n\tos.Exit(5)\n", "\tfmt.Println(\"six\")\n", "")
+	hunks, err := Parse(Generate("main.go", original, modified))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hunks) != 1 {
+		t.Fatalf("expected 1 hunk, got %d", len(hunks))
+	}
+	text := hunks[0].String()
+	text = "# edit the hunk\n" + text[strings.Index(text, "\n")+1:]
+
+	tests := []struct {
+		name    string
+		edited  string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:   "unchanged",
+			edited: text,
+			want:   modified,
+		},
+		{
+			name:   "changed added line",
+			edited: strings.Replace(text, "os.Exit(5)", "os.Exit(6)", 1),
+			want:   strings.Replace(modified, "os.Exit(5)", "os.Exit(6)", 1),
+		},
+		{
+			name:   "kept removed line",
+			edited: strings.Replace(text, "-\tfmt.Println(\"six\")", " \tfmt.Println(\"six\")", 1),
+			want:   modify(t, "\tfmt.Println(\"six\")\n", "\tfmt.Println(\"six\")\n\tos.Exit(5)\n"),
+		},
+		{
+			name:    "changed original line",
+			edited:  strings.Replace(text, "\"seven\"", "\"sieben\"", 1),
+			wantErr: true,
+		},
+		{
+			name:    "invalid line",
+			edited:  text + "not a hunk line\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hunk, err := ParseEdited(hunks[0], tt.edited)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("expected an error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := Apply(original, []*Hunk{hunk})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("unexpected result:\n%s", Generate("main.go", tt.want, got))
+			}
+		})
+	}
+}
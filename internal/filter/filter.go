@@ -0,0 +1,150 @@
+// Package filter decides which packages, files and functions of an application get instrumented, based on
+// include and exclude glob patterns provided by the user.
+//
+// Patterns are matched against three kinds of subjects:
+//   - packages, by import path, e.g. "github.com/acme/app/internal/testutil"
+//   - files, by their path relative to the application root, e.g. "internal/mocks/store_mock.go"
+//   - functions, by import path and name, e.g. "github.com/acme/app/handlers.Health" or, for methods,
+//     "github.com/acme/app/handlers.Server.Health", and also by package name and name, e.g.
+//     "handlers.Server.Health", and by name alone, e.g. "Server.Health"
+//
+// Subjects and patterns are split into segments on "/". A pattern matches a subject if it matches any
+// contiguous run of the subject's segments, so "vendor" matches every path that has a vendor directory in
+// it, "internal/testutil" matches that package and everything in it, "*_mock.go" matches mock files,
+// "handlers.Server.*" matches every method of handlers.Server, even if its package is not in a handlers
+// directory, and "Repo.Get" matches the Get method of every Repo type. Within a segment, the syntax of
+// path.Match is supported. A "**" segment matches any number of segments.
+package filter
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Filter holds the include and exclude patterns for an instrumentation run. The zero value and a nil
+// Filter include everything.
+type Filter struct {
+	include [][]string
+	exclude [][]string
+}
+
+// New creates a filter from include and exclude patterns. Empty patterns are ignored. An error is
+// returned if any pattern is malformed.
+//
+// If no include patterns are given, everything that is not excluded is included. Otherwise, a function is
+// only included if it, its file, or its package matches an include pattern. Exclude patterns always win.
+func New(include, exclude []string) (*Filter, error) {
+	f := &Filter{}
+	var err error
+	if f.include, err = compile(include); err != nil {
+		return nil, err
+	}
+	if f.exclude, err = compile(exclude); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func compile(patterns []string) ([][]string, error) {
+	compiled := [][]string{}
+	for _, pattern := range patterns {
+		pattern = strings.Trim(strings.TrimSpace(pattern), "/")
+		if pattern == "" {
+			continue
+		}
+
+		segments := strings.Split(pattern, "/")
+		for _, segment := range segments {
+			if _, err := path.Match(segment, ""); err != nil {
+				return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+			}
+		}
+		compiled = append(compiled, segments)
+	}
+	return compiled, nil
+}
+
+// Package returns false if the package with the given import path is excluded.
+func (f *Filter) Package(importPath string) bool {
+	return f == nil || !matchAny(f.exclude, importPath)
+}
+
+// File returns false if the file is excluded. The path must be relative to the application root.
+func (f *Filter) File(filePath string) bool {
+	return f == nil || !matchAny(f.exclude, filepathToSlash(filePath))
+}
+
+// Function returns true if a function should be instrumented. The importPath, packageName and filePath are the
+// import path and name of the package and the path of the file the function is declared in, relative to the
+// application root. Methods are named after their receiver type, e.g. "Server.Health".
+func (f *Filter) Function(importPath, packageName, filePath, name string) bool {
+	if f == nil {
+		return true
+	}
+
+	subjects := []string{importPath, filepathToSlash(filePath), FunctionName(importPath, name), packageName + "." + name, name}
+	for _, subject := range subjects {
+		if matchAny(f.exclude, subject) {
+			return false
+		}
+	}
+	if len(f.include) == 0 {
+		return true
+	}
+	for _, subject := range subjects {
+		if matchAny(f.include, subject) {
+			return true
+		}
+	}
+	return false
+}
+
+// FunctionName returns the full name of a function, by import path and name, which function patterns are
+// matched against along with its package name and its name alone.
+func FunctionName(importPath, name string) string {
+	return importPath + "." + name
+}
+
+func filepathToSlash(filePath string) string {
+	return strings.ReplaceAll(filePath, "\\", "/")
+}
+
+// matchAny returns true if any of the patterns matches a contiguous run of the subject's segments.
+func matchAny(patterns [][]string, subject string) bool {
+	if subject == "" {
+		return false
+	}
+
+	segments := strings.Split(strings.Trim(subject, "/"), "/")
+	for _, pattern := range patterns {
+		for start := range segments {
+			if matchPrefix(pattern, segments[start:]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matchPrefix returns true if the pattern matches the segments from the start of the list up to any point.
+func matchPrefix(pattern, segments []string) bool {
+	if len(pattern) == 0 {
+		return true
+	}
+
+	if pattern[0] == "**" {
+		for i := 0; i <= len(segments); i++ {
+			if matchPrefix(pattern[1:], segments[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if len(segments) == 0 {
+		return false
+	}
+	ok, err := path.Match(pattern[0], segments[0])
+	return err == nil && ok && matchPrefix(pattern[1:], segments[1:])
+}
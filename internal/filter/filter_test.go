@@ -0,0 +1,168 @@
+package filter
+
+import "testing"
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		wantErr  bool
+	}{
+		{name: "no patterns"},
+		{name: "valid patterns", patterns: []string{"vendor", "internal/**", "*_mock.go", "handlers.Server.*"}},
+		{name: "empty patterns are ignored", patterns: []string{"", " ", "/"}},
+		{name: "malformed pattern", patterns: []string{"internal/[testutil"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(nil, tt.patterns)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("New() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPackage(t *testing.T) {
+	tests := []struct {
+		name       string
+		exclude    []string
+		importPath string
+		want       bool
+	}{
+		{name: "no patterns", importPath: "github.com/acme/app/handlers", want: true},
+		{name: "directory name", exclude: []string{"testutil"}, importPath: "github.com/acme/app/internal/testutil", want: false},
+		{name: "nested package of excluded directory", exclude: []string{"internal/testutil"}, importPath: "github.com/acme/app/internal/testutil/fakes", want: false},
+		{name: "partial segment does not match", exclude: []string{"test"}, importPath: "github.com/acme/app/internal/testutil", want: true},
+		{name: "glob segment", exclude: []string{"legacy*"}, importPath: "github.com/acme/app/legacyapi", want: false},
+		{name: "double star", exclude: []string{"github.com/acme/**/mocks"}, importPath: "github.com/acme/app/store/mocks", want: false},
+		{name: "double star requires suffix", exclude: []string{"github.com/acme/**/mocks"}, importPath: "github.com/acme/app/store", want: true},
+		{name: "function patterns do not exclude packages", exclude: []string{"handlers.Server.*"}, importPath: "github.com/acme/app/handlers", want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := New(nil, tt.exclude)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := f.Package(tt.importPath); got != tt.want {
+				t.Errorf("Package(%q) = %v, want %v", tt.importPath, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFile(t *testing.T) {
+	tests := []struct {
+		name     string
+		exclude  []string
+		filePath string
+		want     bool
+	}{
+		{name: "no patterns", filePath: "main.go", want: true},
+		{name: "file glob", exclude: []string{"*_mock.go"}, filePath: "store/store_mock.go", want: false},
+		{name: "file glob does not match other files", exclude: []string{"*_mock.go"}, filePath: "store/store.go", want: true},
+		{name: "directory", exclude: []string{"vendor"}, filePath: "vendor/github.com/x/y.go", want: false},
+		{name: "windows separators", exclude: []string{"generated/**"}, filePath: `generated\api.go`, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := New(nil, tt.exclude)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := f.File(tt.filePath); got != tt.want {
+				t.Errorf("File(%q) = %v, want %v", tt.filePath, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFunction(t *testing.T) {
+	const (
+		importPath = "github.com/acme/app/handlers"
+		filePath   = "handlers/server.go"
+	)
+
+	tests := []struct {
+		name     string
+		include  []string
+		exclude  []string
+		function string
+		want     bool
+	}{
+		{name: "no patterns", function: "Health", want: true},
+		{name: "excluded method", exclude: []string{"handlers.Server.*"}, function: "Server.Health", want: false},
+		{name: "other receiver", exclude: []string{"handlers.Server.*"}, function: "Client.Health", want: true},
+		{name: "excluded function glob", exclude: []string{"*.Legacy*"}, function: "LegacyHealth", want: false},
+		{name: "excluded by package", exclude: []string{"handlers"}, function: "Health", want: false},
+		{name: "excluded by file", exclude: []string{"server.go"}, function: "Health", want: false},
+		{name: "included function", include: []string{"handlers.Health"}, function: "Health", want: true},
+		{name: "not included function", include: []string{"handlers.Health"}, function: "Ready", want: false},
+		{name: "included by package", include: []string{"github.com/acme/app/handlers"}, function: "Ready", want: true},
+		{name: "included by file", include: []string{"handlers/*.go"}, function: "Ready", want: true},
+		{name: "exclude wins over include", include: []string{"handlers"}, exclude: []string{"handlers.Ready"}, function: "Ready", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := New(tt.include, tt.exclude)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := f.Function(importPath, "handlers", filePath, tt.function); got != tt.want {
+				t.Errorf("Function(%q) = %v, want %v", tt.function, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFunction_Names(t *testing.T) {
+	// the package is named handlers, but is not in a handlers directory
+	const (
+		importPath  = "github.com/acme/app/internal/server"
+		packageName = "handlers"
+		filePath    = "internal/server/server.go"
+	)
+
+	tests := []struct {
+		name     string
+		include  []string
+		exclude  []string
+		function string
+		want     bool
+	}{
+		{name: "excluded method by type and name", exclude: []string{"Repo.Get"}, function: "Repo.Get", want: false},
+		{name: "other method of the type", exclude: []string{"Repo.Get"}, function: "Repo.Put", want: true},
+		{name: "excluded function by name", exclude: []string{"Handler"}, function: "Handler", want: false},
+		{name: "other function", exclude: []string{"Handler"}, function: "HandlerFunc", want: true},
+		{name: "excluded methods by package name", exclude: []string{"handlers.Server.*"}, function: "Server.Health", want: false},
+		{name: "excluded methods by directory", exclude: []string{"server.Server.*"}, function: "Server.Health", want: false},
+		{name: "other package name", exclude: []string{"api.Server.*"}, function: "Server.Health", want: true},
+		{name: "included methods by package name", include: []string{"handlers.Server.*"}, function: "Server.Health", want: true},
+		{name: "not included method", include: []string{"handlers.Server.*"}, function: "Client.Health", want: false},
+		{name: "included function by name", include: []string{"Handler"}, function: "Handler", want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := New(tt.include, tt.exclude)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := f.Function(importPath, packageName, filePath, tt.function); got != tt.want {
+				t.Errorf("Function(%q) = %v, want %v", tt.function, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNilFilter(t *testing.T) {
+	var f *Filter
+	if !f.Package("github.com/acme/app") || !f.File("main.go") || !f.Function("github.com/acme/app", "main", "main.go", "main") {
+		t.Error("expected a nil filter to include everything")
+	}
+}
@@ -0,0 +1,393 @@
+// Package modules resolves the Go modules that provide the packages imported by instrumentation without
+// network access, and computes the go.mod and go.sum edits that `go get` would otherwise make in place.
+//
+// Module versions are taken from a table of pinned versions when one exists, and otherwise from the newest
+// version available in the local module cache. Checksums for go.sum are computed from the module cache, which
+// the go command has already verified against the checksum database when the modules were downloaded.
+package modules
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+	"golang.org/x/mod/sumdb/dirhash"
+)
+
+// PinnedVersions are the versions of New Relic modules that the instrumentation generated by this tool is
+// known to work with. They are preferred over whatever version happens to be in the module cache.
+//
+// The integrations for gorilla, fasthttp, httprouter, fiber and go-redis (nrgorilla, nrfasthttp, nrhttprouter,
+// nrfiber, nrredis-v8 and nrredis-v9) are deliberately left out: their versions are released along with the go
+// agent, and no version of them has been validated against the code this tool generates, so they float to the
+// newest version in the module cache. The go.mod file of that version requires the go agent it was released with,
+// which upgrades the pinned agent if it is older. If none of their versions is in the module cache, AddImports
+// warns that they have to be added with `go get`.
+var PinnedVersions = map[string]string{
+	"github.com/newrelic/go-agent/v3":                        "v3.43.3",
+	"github.com/newrelic/go-agent/v3/integrations/nrecho-v4": "v1.1.5",
+	"github.com/newrelic/go-agent/v3/integrations/nrgin":     "v1.3.1",
+	"github.com/newrelic/go-agent/v3/integrations/nrpgx5":    "v1.3.3",
+	"github.com/newrelic/go-agent/v3/integrations/nrpq":      "v1.1.1",
+}
+
+// nestedModulePrefix is the prefix of the import paths of New Relic integrations. Every integration is a module
+// of its own, nested in the go agent module, so they must never be resolved to the go agent module.
+const nestedModulePrefix = "github.com/newrelic/go-agent/v3/integrations/"
+
+// Resolver finds the modules that provide import paths, using only the local module cache and a table of
+// pinned versions.
+type Resolver struct {
+	modCache string            // root of the module cache, e.g. $GOPATH/pkg/mod
+	pinned   map[string]string // module path to version
+}
+
+// NewResolver creates a resolver for the module cache used by the go command, with the default pinned versions.
+func NewResolver() (*Resolver, error) {
+	modCache := os.Getenv("GOMODCACHE")
+	if modCache == "" {
+		out, err := exec.Command("go", "env", "GOMODCACHE").Output()
+		if err != nil {
+			return nil, fmt.Errorf("failed to locate the module cache: %v", err)
+		}
+		modCache = strings.TrimSpace(string(out))
+	}
+
+	return NewResolverWithCache(modCache, PinnedVersions), nil
+}
+
+// NewResolverWithCache creates a resolver for the given module cache directory and pinned versions.
+func NewResolverWithCache(modCache string, pinned map[string]string) *Resolver {
+	return &Resolver{
+		modCache: modCache,
+		pinned:   pinned,
+	}
+}
+
+// downloadDir returns the directory in the module cache's download cache that contains the versions of a module.
+func (r *Resolver) downloadDir(modulePath string) (string, error) {
+	escaped, err := module.EscapePath(modulePath)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(r.modCache, "cache", "download", escaped, "@v"), nil
+}
+
+// versionFile returns the path to a file for a module version in the download cache, e.g. the .mod or .ziphash file.
+func (r *Resolver) versionFile(mod module.Version, ext string) (string, error) {
+	dir, err := r.downloadDir(mod.Path)
+	if err != nil {
+		return "", err
+	}
+	escaped, err := module.EscapeVersion(mod.Version)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, escaped+ext), nil
+}
+
+// cachedVersions returns the versions of a module that have a go.mod file in the module cache, sorted from
+// oldest to newest.
+func (r *Resolver) cachedVersions(modulePath string) []string {
+	dir, err := r.downloadDir(modulePath)
+	if err != nil {
+		return nil
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+
+	versions := []string{}
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), ".mod")
+		if !ok {
+			continue
+		}
+		version, err := module.UnescapeVersion(name)
+		if err != nil || !semver.IsValid(version) {
+			continue
+		}
+		versions = append(versions, version)
+	}
+	semver.Sort(versions)
+	return versions
+}
+
+// Module returns the module and version that provides an import path. The longest module path that either
+// has a pinned version or is present in the module cache wins, the same way the go command resolves imports.
+// New Relic integrations are never resolved to the go agent module that contains them.
+func (r *Resolver) Module(importPath string) (module.Version, error) {
+	for path := importPath; path != "." && path != "/"; path = pathParent(path) {
+		if version, ok := r.pinned[path]; ok {
+			return module.Version{Path: path, Version: version}, nil
+		}
+
+		versions := r.cachedVersions(path)
+		if len(versions) > 0 {
+			return module.Version{Path: path, Version: versions[len(versions)-1]}, nil
+		}
+
+		if strings.HasPrefix(path, nestedModulePrefix) && strings.Count(path[len(nestedModulePrefix):], "/") == 0 {
+			break
+		}
+	}
+
+	return module.Version{}, fmt.Errorf("no module providing %s was found in the module cache %s", importPath, r.modCache)
+}
+
+// pathParent returns the import path with its last element removed.
+func pathParent(path string) string {
+	i := strings.LastIndexByte(path, '/')
+	if i < 0 {
+		return "."
+	}
+	return path[:i]
+}
+
+// goModFile returns the contents of the go.mod file of a module version from the module cache.
+func (r *Resolver) goModFile(mod module.Version) ([]byte, error) {
+	path, err := r.versionFile(mod, ".mod")
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadFile(path)
+}
+
+// sums returns the go.sum lines for a module version. The go.mod checksum is computed from the cached go.mod
+// file, and the module checksum is read from the cached .ziphash file. A line is left out if the cache does
+// not contain what is needed to compute it.
+func (r *Resolver) sums(mod module.Version) []string {
+	lines := []string{}
+
+	if data, err := r.goModFile(mod); err == nil {
+		hash, err := dirhash.Hash1([]string{"go.mod"}, func(string) (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(data)), nil
+		})
+		if err == nil {
+			lines = append(lines, fmt.Sprintf("%s %s/go.mod %s", mod.Path, mod.Version, hash))
+		}
+	}
+
+	if path, err := r.versionFile(mod, ".ziphash"); err == nil {
+		if data, err := os.ReadFile(path); err == nil {
+			lines = append(lines, fmt.Sprintf("%s %s %s", mod.Path, mod.Version, strings.TrimSpace(string(data))))
+		}
+	}
+
+	return lines
+}
+
+// Edit is the result of adding modules to a main module.
+type Edit struct {
+	GoMod    []byte   // new contents of go.mod
+	GoSum    []byte   // new contents of go.sum
+	Warnings []string // anything that could not be resolved offline, and needs to be fixed by hand
+}
+
+// AddImports computes the go.mod and go.sum changes needed for a main module to build packages that use the
+// given import paths. Imports from the standard library, from the main module, and from modules that are already
+// required are left alone.
+//
+// The requirements listed in the go.mod file of every added module are added as indirect requirements, or
+// upgraded to the version the added module needs. Since go.mod files are pruned, those requirements are enough
+// to build the packages of the added module.
+func (r *Resolver) AddImports(goMod, goSum []byte, goModPath string, imports []string) (*Edit, error) {
+	f, err := modfile.Parse(goModPath, goMod, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// the requirements the go.mod file should end up with, in the order they were added
+	requirements := []*modfile.Require{}
+	required := map[string]*modfile.Require{}
+	for _, req := range f.Require {
+		requirement := &modfile.Require{Mod: req.Mod, Indirect: req.Indirect}
+		requirements = append(requirements, requirement)
+		required[req.Mod.Path] = requirement
+	}
+
+	changed := map[string]bool{}
+	require := func(mod module.Version, indirect bool) {
+		if f.Module != nil && mod.Path == f.Module.Mod.Path {
+			return
+		}
+		req, ok := required[mod.Path]
+		if !ok {
+			req = &modfile.Require{Mod: mod, Indirect: indirect}
+			requirements = append(requirements, req)
+			required[mod.Path] = req
+			changed[mod.Path] = true
+			return
+		}
+		if semver.Compare(req.Mod.Version, mod.Version) < 0 {
+			req.Mod.Version = mod.Version
+			changed[mod.Path] = true
+		}
+		if req.Indirect && !indirect {
+			req.Indirect = false
+			changed[mod.Path] = true
+		}
+	}
+
+	edit := &Edit{}
+	direct := []string{}
+	imports = slices.Clone(imports)
+	slices.Sort(imports)
+	for _, importPath := range imports {
+		if isStandardLibrary(importPath) || (f.Module != nil && isInModule(importPath, f.Module.Mod.Path)) {
+			continue
+		}
+		if slices.ContainsFunc(f.Require, func(req *modfile.Require) bool {
+			return isInModule(importPath, req.Mod.Path) && r.provides(req.Mod, importPath)
+		}) {
+			continue
+		}
+
+		mod, err := r.Module(importPath)
+		if err != nil {
+			edit.Warnings = append(edit.Warnings, fmt.Sprintf("%v; run \"go get %s\" to add it", err, importPath))
+			continue
+		}
+		require(mod, false)
+		if slices.Contains(direct, mod.Path) {
+			continue
+		}
+		direct = append(direct, mod.Path)
+
+		data, err := r.goModFile(mod)
+		if err != nil {
+			edit.Warnings = append(edit.Warnings, fmt.Sprintf("the requirements of %s are not in the module cache; run \"go mod tidy\" to add them", mod))
+			continue
+		}
+		depFile, err := modfile.ParseLax(mod.Path+"@"+mod.Version+"/go.mod", data, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		if depFile.Go != nil && (f.Go == nil || semver.Compare("v"+depFile.Go.Version, "v"+f.Go.Version) > 0) {
+			if err := f.AddGoStmt(depFile.Go.Version); err != nil {
+				return nil, err
+			}
+		}
+		for _, req := range depFile.Require {
+			require(req.Mod, true)
+		}
+	}
+
+	if len(changed) == 0 {
+		return &Edit{GoMod: goMod, GoSum: goSum, Warnings: edit.Warnings}, nil
+	}
+
+	// keep direct and indirect requirements in separate blocks, the same way the go command does
+	f.SetRequireSeparateIndirect(requirements)
+	f.Cleanup()
+	edit.GoMod, err = f.Format()
+	if err != nil {
+		return nil, err
+	}
+
+	sumLines := strings.Split(strings.TrimSpace(string(goSum)), "\n")
+	if len(sumLines) == 1 && sumLines[0] == "" {
+		sumLines = nil
+	}
+	for _, req := range requirements {
+		if !changed[req.Mod.Path] {
+			continue
+		}
+		for _, line := range r.sums(req.Mod) {
+			if !slices.Contains(sumLines, line) {
+				sumLines = append(sumLines, line)
+			}
+		}
+		// the go command needs both checksums of every module in the pruned module graph, indirect or not
+		if !hasSum(sumLines, req.Mod.Path+" "+req.Mod.Version+"/go.mod ") || !hasSum(sumLines, req.Mod.Path+" "+req.Mod.Version+" ") {
+			edit.Warnings = append(edit.Warnings, fmt.Sprintf("%s is not fully downloaded to the module cache; run \"go mod download %s\" to complete go.sum", req.Mod, req.Mod))
+		}
+	}
+	slices.SortFunc(sumLines, compareSumLines)
+	edit.GoSum = []byte(strings.Join(sumLines, "\n") + "\n")
+
+	return edit, nil
+}
+
+// hasSum returns true if one of the go.sum lines starts with prefix.
+func hasSum(sumLines []string, prefix string) bool {
+	return slices.ContainsFunc(sumLines, func(line string) bool {
+		return strings.HasPrefix(line, prefix)
+	})
+}
+
+// provides returns true if a required module contains the package at an import path. Nested modules, such as
+// the New Relic integrations inside the go agent repository, do not provide the packages of their parent.
+func (r *Resolver) provides(mod module.Version, importPath string) bool {
+	resolved, err := r.Module(importPath)
+	if err != nil {
+		// nothing better is known, so the existing requirement has to be trusted
+		return true
+	}
+	return resolved.Path == mod.Path
+}
+
+// isStandardLibrary returns true if the import path belongs to the standard library, which is the case
+// when its first element does not contain a dot.
+func isStandardLibrary(importPath string) bool {
+	first, _, _ := strings.Cut(importPath, "/")
+	return !strings.Contains(first, ".")
+}
+
+// isInModule returns true if the import path is in the module with the given path, or in a module nested in it.
+func isInModule(importPath, modulePath string) bool {
+	return importPath == modulePath || strings.HasPrefix(importPath, modulePath+"/")
+}
+
+// compareSumLines orders go.sum lines by module path and semantic version, the same way the go command does.
+func compareSumLines(a, b string) int {
+	aFields, bFields := strings.Fields(a), strings.Fields(b)
+	if len(aFields) < 2 || len(bFields) < 2 {
+		return strings.Compare(a, b)
+	}
+	if c := strings.Compare(aFields[0], bFields[0]); c != 0 {
+		return c
+	}
+	aVersion, aGoMod := strings.CutSuffix(aFields[1], "/go.mod")
+	bVersion, bGoMod := strings.CutSuffix(bFields[1], "/go.mod")
+	if c := semver.Compare(aVersion, bVersion); c != 0 {
+		return c
+	}
+	switch {
+	case aGoMod == bGoMod:
+		return 0
+	case aGoMod:
+		return 1
+	default:
+		return -1
+	}
+}
+
+// FindGoMod returns the path to the go.mod file of the module that contains dir.
+func FindGoMod(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for {
+		path := filepath.Join(dir, "go.mod")
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", fmt.Errorf("no go.mod file found for %s", dir)
+		}
+		dir = parent
+	}
+}
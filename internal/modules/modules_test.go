@@ -0,0 +1,322 @@
+package modules
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/mod/module"
+)
+
+// cachedModule describes a module version to write to a fake module cache.
+type cachedModule struct {
+	path    string
+	version string
+	goMod   string
+	zipHash string // left out of the cache if empty
+}
+
+func createModuleCache(t *testing.T, mods ...cachedModule) string {
+	t.Helper()
+	cache := t.TempDir()
+	for _, mod := range mods {
+		escaped, err := module.EscapePath(mod.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dir := filepath.Join(cache, "cache", "download", escaped, "@v")
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, mod.version+".mod"), []byte(mod.goMod), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if mod.zipHash != "" {
+			if err := os.WriteFile(filepath.Join(dir, mod.version+".ziphash"), []byte(mod.zipHash), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	return cache
+}
+
+var testCache = []cachedModule{
+	{
+		path:    "github.com/newrelic/go-agent/v3",
+		version: "v3.40.0",
+		goMod:   "module github.com/newrelic/go-agent/v3\n\ngo 1.21\n",
+		zipHash: "h1:agent40=",
+	},
+	{
+		path:    "github.com/newrelic/go-agent/v3",
+		version: "v3.42.0",
+		goMod:   "module github.com/newrelic/go-agent/v3\n\ngo 1.22\n\nrequire google.golang.org/grpc v1.60.0\n",
+		zipHash: "h1:agent42=",
+	},
+	{
+		path:    "github.com/newrelic/go-agent/v3/integrations/nrgin",
+		version: "v1.3.1",
+		goMod:   "module github.com/newrelic/go-agent/v3/integrations/nrgin\n\ngo 1.22\n\nrequire (\n\tgithub.com/gin-gonic/gin v1.9.1\n\tgithub.com/newrelic/go-agent/v3 v3.42.0\n)\n",
+		zipHash: "h1:nrgin=",
+	},
+	{
+		path:    "github.com/gin-gonic/gin",
+		version: "v1.9.1",
+		goMod:   "module github.com/gin-gonic/gin\n\ngo 1.20\n",
+		zipHash: "h1:gin=",
+	},
+	{
+		path:    "google.golang.org/grpc",
+		version: "v1.60.0",
+		goMod:   "module google.golang.org/grpc\n\ngo 1.19\n",
+	},
+}
+
+func TestModule(t *testing.T) {
+	cache := createModuleCache(t, testCache...)
+
+	tests := []struct {
+		name       string
+		pinned     map[string]string
+		importPath string
+		want       module.Version
+		wantErr    bool
+	}{
+		{
+			name:       "newest cached version",
+			importPath: "github.com/newrelic/go-agent/v3/newrelic",
+			want:       module.Version{Path: "github.com/newrelic/go-agent/v3", Version: "v3.42.0"},
+		},
+		{
+			name:       "pinned version wins",
+			pinned:     map[string]string{"github.com/newrelic/go-agent/v3": "v3.40.0"},
+			importPath: "github.com/newrelic/go-agent/v3/newrelic",
+			want:       module.Version{Path: "github.com/newrelic/go-agent/v3", Version: "v3.40.0"},
+		},
+		{
+			name:       "nested module",
+			importPath: "github.com/newrelic/go-agent/v3/integrations/nrgin",
+			want:       module.Version{Path: "github.com/newrelic/go-agent/v3/integrations/nrgin", Version: "v1.3.1"},
+		},
+		{
+			name:       "pinned module that is not cached",
+			pinned:     map[string]string{"github.com/newrelic/go-agent/v3/integrations/nrecho-v4": "v1.1.5"},
+			importPath: "github.com/newrelic/go-agent/v3/integrations/nrecho-v4",
+			want:       module.Version{Path: "github.com/newrelic/go-agent/v3/integrations/nrecho-v4", Version: "v1.1.5"},
+		},
+		{
+			name:       "integration is not resolved to the go agent",
+			importPath: "github.com/newrelic/go-agent/v3/integrations/nrgochi",
+			wantErr:    true,
+		},
+		{
+			name:       "unknown module",
+			importPath: "example.com/unknown/pkg",
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewResolverWithCache(cache, tt.pinned)
+			got, err := r.Module(tt.importPath)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected an error, got %s", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestAddImports(t *testing.T) {
+	cache := createModuleCache(t, testCache...)
+	r := NewResolverWithCache(cache, map[string]string{})
+
+	goMod := `module example.com/app
+
+go 1.21
+
+require github.com/gin-gonic/gin v1.9.0
+`
+	goSum := `github.com/gin-gonic/gin v1.9.0 h1:gin0=
+github.com/gin-gonic/gin v1.9.0/go.mod h1:gin0mod=
+`
+	edit, err := r.AddImports([]byte(goMod), []byte(goSum), "go.mod", []string{
+		"net/http",
+		"example.com/app/internal/handlers",
+		"github.com/newrelic/go-agent/v3/integrations/nrgin",
+		"github.com/newrelic/go-agent/v3/newrelic",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantGoMod := `module example.com/app
+
+go 1.22
+
+require (
+	github.com/gin-gonic/gin v1.9.1
+	github.com/newrelic/go-agent/v3 v3.42.0
+	github.com/newrelic/go-agent/v3/integrations/nrgin v1.3.1
+)
+
+require google.golang.org/grpc v1.60.0 // indirect
+`
+	if string(edit.GoMod) != wantGoMod {
+		t.Errorf("unexpected go.mod:\n%s\nwant:\n%s", edit.GoMod, wantGoMod)
+	}
+
+	sums := strings.Split(strings.TrimSpace(string(edit.GoSum)), "\n")
+	wantModules := []string{
+		"github.com/gin-gonic/gin v1.9.0 h1:gin0=",
+		"github.com/gin-gonic/gin v1.9.0/go.mod h1:gin0mod=",
+		"github.com/gin-gonic/gin v1.9.1 h1:gin=",
+		"github.com/gin-gonic/gin v1.9.1/go.mod ",
+		"github.com/newrelic/go-agent/v3 v3.42.0 h1:agent42=",
+		"github.com/newrelic/go-agent/v3 v3.42.0/go.mod ",
+		"github.com/newrelic/go-agent/v3/integrations/nrgin v1.3.1 h1:nrgin=",
+		"github.com/newrelic/go-agent/v3/integrations/nrgin v1.3.1/go.mod ",
+		"google.golang.org/grpc v1.60.0/go.mod ",
+	}
+	if len(sums) != len(wantModules) {
+		t.Fatalf("expected %d go.sum lines, got %d:\n%s", len(wantModules), len(sums), edit.GoSum)
+	}
+	for i, want := range wantModules {
+		if !strings.HasPrefix(sums[i], want) {
+			t.Errorf("expected go.sum line %d to start with %q, got %q", i+1, want, sums[i])
+		}
+	}
+
+	// grpc has no zip hash in the cache, so go.sum is incomplete even though it is only an indirect requirement
+	if len(edit.Warnings) != 1 || !strings.Contains(edit.Warnings[0], "go mod download google.golang.org/grpc@v1.60.0") {
+		t.Errorf("expected a warning to download grpc, got %v", edit.Warnings)
+	}
+}
+
+func TestAddImportsIndirectSums(t *testing.T) {
+	cache := createModuleCache(t, testCache...)
+	r := NewResolverWithCache(cache, map[string]string{})
+
+	// the zip hash of grpc is already in go.sum
+	goMod := "module example.com/app\n\ngo 1.22\n"
+	goSum := "google.golang.org/grpc v1.60.0 h1:grpc=\n"
+	edit, err := r.AddImports([]byte(goMod), []byte(goSum), "go.mod", []string{"github.com/newrelic/go-agent/v3/newrelic"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(edit.Warnings) != 0 {
+		t.Errorf("expected no warnings, got %v", edit.Warnings)
+	}
+
+	// the go.mod file of an indirect requirement is not in the cache
+	cache = createModuleCache(t, cachedModule{
+		path:    "github.com/newrelic/go-agent/v3",
+		version: "v3.42.0",
+		goMod:   "module github.com/newrelic/go-agent/v3\n\ngo 1.22\n\nrequire example.com/missing v1.0.0\n",
+		zipHash: "h1:agent42=",
+	})
+	r = NewResolverWithCache(cache, map[string]string{})
+	edit, err = r.AddImports([]byte(goMod), nil, "go.mod", []string{"github.com/newrelic/go-agent/v3/newrelic"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(edit.Warnings) != 1 || !strings.Contains(edit.Warnings[0], "go mod download example.com/missing@v1.0.0") {
+		t.Errorf("expected a warning to download the indirect requirement, got %v", edit.Warnings)
+	}
+}
+
+func TestAddImportsNoChanges(t *testing.T) {
+	cache := createModuleCache(t, testCache...)
+	r := NewResolverWithCache(cache, map[string]string{})
+
+	goMod := "module example.com/app\n\ngo 1.22\n\nrequire github.com/newrelic/go-agent/v3 v3.40.0\n"
+	edit, err := r.AddImports([]byte(goMod), nil, "go.mod", []string{"github.com/newrelic/go-agent/v3/newrelic", "net/http"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(edit.GoMod) != goMod {
+		t.Errorf("expected go.mod to be unchanged, got:\n%s", edit.GoMod)
+	}
+	if len(edit.GoSum) != 0 {
+		t.Errorf("expected go.sum to be unchanged, got:\n%s", edit.GoSum)
+	}
+}
+
+func TestAddImportsNotDownloaded(t *testing.T) {
+	cache := createModuleCache(t, testCache...)
+	r := NewResolverWithCache(cache, map[string]string{"github.com/newrelic/go-agent/v3": "v3.43.3"})
+
+	edit, err := r.AddImports([]byte("module example.com/app\n\ngo 1.22\n"), nil, "go.mod", []string{"github.com/newrelic/go-agent/v3/newrelic"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(edit.GoMod), "require github.com/newrelic/go-agent/v3 v3.43.3\n") {
+		t.Errorf("expected the pinned version to be required, got:\n%s", edit.GoMod)
+	}
+	if len(edit.Warnings) != 2 {
+		t.Errorf("expected warnings about the missing go.mod and module, got %v", edit.Warnings)
+	}
+}
+
+func TestAddImportsUnknownModule(t *testing.T) {
+	goMod := "module example.com/app\n\ngo 1.22\n"
+	r := NewResolverWithCache(t.TempDir(), map[string]string{})
+	edit, err := r.AddImports([]byte(goMod), nil, "go.mod", []string{"example.com/unknown"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(edit.GoMod) != goMod {
+		t.Errorf("expected go.mod to be unchanged, got:\n%s", edit.GoMod)
+	}
+	if len(edit.Warnings) != 1 || !strings.Contains(edit.Warnings[0], "go get example.com/unknown") {
+		t.Errorf("expected a warning to go get the unknown module, got %v", edit.Warnings)
+	}
+}
+
+func TestCompareSumLines(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{a: "a.com/x v1.9.0 h1:a=", b: "a.com/x v1.10.0 h1:b=", want: -1},
+		{a: "a.com/x v1.9.0/go.mod h1:a=", b: "a.com/x v1.9.0 h1:b=", want: 1},
+		{a: "a.com/x v1.9.0 h1:a=", b: "b.com/x v1.0.0 h1:b=", want: -1},
+		{a: "a.com/x v1.9.0 h1:a=", b: "a.com/x v1.9.0 h1:a=", want: 0},
+	}
+
+	for _, tt := range tests {
+		if got := compareSumLines(tt.a, tt.b); got != tt.want {
+			t.Errorf("compareSumLines(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestFindGoMod(t *testing.T) {
+	root := t.TempDir()
+	nested := filepath.Join(root, "cmd", "server")
+	if err := os.MkdirAll(nested, 0755); err != nil {
+		t.Fatal(err)
+	}
+	goMod := filepath.Join(root, "go.mod")
+	if err := os.WriteFile(goMod, []byte("module example.com/app\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := FindGoMod(nested)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != goMod {
+		t.Errorf("expected %q, got %q", goMod, got)
+	}
+}
@@ -0,0 +1,350 @@
+// report is a library that records everything the tool did to an application, and everything it could not do,
+// so that it can be written to a machine readable JSON report. Every entry has a stable diagnostic code that
+// tools consuming the report can rely on across releases.
+//
+// Like the console printer in the comment library, the report is a global that must be enabled at the start of
+// the program. When it is not enabled, recording an entry does nothing.
+package report
+
+import (
+	"encoding/json"
+	"go/ast"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/dave/dst"
+	"github.com/dave/dst/decorator"
+	"github.com/newrelic/go-easy-instrumentation/internal/util"
+)
+
+// Version is the version of the report format. It is only incremented when a change would break existing consumers.
+const Version = 1
+
+// CoreIntegration is the integration name of the instrumentation that is applied to every application.
+const CoreIntegration = "core"
+
+// Severity is the kind of an entry in the report.
+type Severity string
+
+const (
+	SeverityAction  Severity = "action"  // instrumentation was added to the application
+	SeverityWarning Severity = "warning" // something needs the attention of the user
+	SeveritySkipped Severity = "skipped" // a construct was intentionally left alone
+)
+
+// Kind is the kind of instrumentation added by an action.
+type Kind string
+
+const (
+	KindAgent       Kind = "agent"
+	KindTransaction Kind = "txn"
+	KindSegment     Kind = "segment"
+	KindExternal    Kind = "external"
+	KindMiddleware  Kind = "middleware"
+	KindNoticeError Kind = "noticeError"
+	KindDriverSwap  Kind = "driver-swap"
+	KindLogs        Kind = "logs"
+)
+
+// Code is a stable diagnostic code. Codes are never reused for a different meaning.
+type Code string
+
+// Action codes, one for each kind of instrumentation.
+const (
+	CodeAgentInitialized Code = "NR1001"
+	CodeTransaction      Code = "NR1002"
+	CodeSegment          Code = "NR1003"
+	CodeExternalSegment  Code = "NR1004"
+	CodeMiddleware       Code = "NR1005"
+	CodeNoticeError      Code = "NR1006"
+	CodeDriverSwap       Code = "NR1007"
+	CodeLogs             Code = "NR1008"
+)
+
+// Warning codes.
+const (
+	CodeUncheckedError           Code = "NR2001"
+	CodeUninstrumentableHttpCall Code = "NR2002"
+	CodeGoroutineInMain          Code = "NR2003"
+	CodeFunctionLiteralName      Code = "NR2004"
+	CodeContextTransaction       Code = "NR2005"
+	CodeCompileError             Code = "NR2006"
+	CodeModuleNotResolved        Code = "NR2007"
+	CodeSignaturePreserved       Code = "NR2008"
+	CodeRouterType               Code = "NR2009"
+	CodeStoredFunctionLiteral    Code = "NR2010"
+)
+
+// Skipped codes.
+const (
+	CodeAlreadyInstrumented Code = "NR3001"
+	CodeExcluded            Code = "NR3002"
+	CodeHunkDropped         Code = "NR3003"
+	CodeHunkRejected        Code = "NR3004"
+)
+
+// actionCodes maps each kind of action to its code.
+var actionCodes = map[Kind]Code{
+	KindAgent:       CodeAgentInitialized,
+	KindTransaction: CodeTransaction,
+	KindSegment:     CodeSegment,
+	KindExternal:    CodeExternalSegment,
+	KindMiddleware:  CodeMiddleware,
+	KindNoticeError: CodeNoticeError,
+	KindDriverSwap:  CodeDriverSwap,
+	KindLogs:        CodeLogs,
+}
+
+// Entry is a single action, warning or skipped construct.
+type Entry struct {
+	Code        Code     `json:"code"`
+	Severity    Severity `json:"severity"`
+	Kind        Kind     `json:"kind,omitempty"`
+	Integration string   `json:"integration,omitempty"`
+	File        string   `json:"file,omitempty"` // relative to the application root
+	Line        int      `json:"line,omitempty"`
+	Column      int      `json:"column,omitempty"`
+	Function    string   `json:"function,omitempty"` // enclosing function, e.g. "main" or "Server.Health"
+	Message     string   `json:"message"`
+}
+
+// Summary counts the entries in the report.
+type Summary struct {
+	Actions  int            `json:"actions"`
+	Warnings int            `json:"warnings"`
+	Skipped  int            `json:"skipped"`
+	Codes    map[Code]int   `json:"codes"`
+	Kinds    map[Kind]int   `json:"kinds"`
+	Files    map[string]int `json:"files"`
+}
+
+// Report is the JSON document written by Write.
+type Report struct {
+	Version     int     `json:"version"`
+	ToolVersion string  `json:"toolVersion"`
+	Application string  `json:"application"`
+	DiffFile    string  `json:"diffFile"`
+	Summary     Summary `json:"summary"`
+	Actions     []Entry `json:"actions"`
+	Warnings    []Entry `json:"warnings"`
+	Skipped     []Entry `json:"skipped"`
+}
+
+type recorder struct {
+	mu      sync.Mutex
+	appPath string // as given by the user, so that the report does not depend on the machine it was created on
+	appRoot string
+	entries []Entry
+}
+
+// initialize this with Enable if you want to write a report
+var rec *recorder
+
+// Enable starts recording entries for the application at applicationPath.
+func Enable(applicationPath string) {
+	appRoot, err := filepath.Abs(applicationPath)
+	if err != nil {
+		appRoot = applicationPath
+	}
+	rec = &recorder{appPath: applicationPath, appRoot: appRoot}
+}
+
+// Disable stops recording entries, and discards everything recorded so far.
+func Disable() {
+	rec = nil
+}
+
+// Enabled returns true if entries are being recorded.
+func Enabled() bool {
+	return rec != nil
+}
+
+// Action records instrumentation of the given kind that an integration added at node.
+func Action(pkg *decorator.Package, node dst.Node, integration string, kind Kind, message string) {
+	rec.add(pkg, node, Entry{Code: actionCodes[kind], Severity: SeverityAction, Kind: kind, Integration: integration, Message: message})
+}
+
+// Warning records an issue at node that needs the attention of the user.
+func Warning(pkg *decorator.Package, node dst.Node, integration string, code Code, message string) {
+	rec.add(pkg, node, Entry{Code: code, Severity: SeverityWarning, Integration: integration, Message: message})
+}
+
+// Skipped records a construct at node that was intentionally not instrumented.
+func Skipped(pkg *decorator.Package, node dst.Node, integration string, code Code, message string) {
+	rec.add(pkg, node, Entry{Code: code, Severity: SeveritySkipped, Integration: integration, Message: message})
+}
+
+// Add records an entry that is not tied to a node in the syntax tree, such as a compile error. The file of the
+// entry may be absolute, in which case it is made relative to the application root.
+func Add(entry Entry) {
+	if rec == nil {
+		return
+	}
+	entry.File = rec.relativePath(entry.File)
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	rec.entries = append(rec.entries, entry)
+}
+
+// Entries returns every entry recorded so far, in the order they were recorded.
+func Entries() []Entry {
+	if rec == nil {
+		return nil
+	}
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	return slices.Clone(rec.entries)
+}
+
+func (r *recorder) add(pkg *decorator.Package, node dst.Node, entry Entry) {
+	if r == nil {
+		return
+	}
+
+	if pos := util.Position(node, pkg); pos != nil && pos.IsValid() {
+		entry.File = r.relativePath(pos.Filename)
+		entry.Line = pos.Line
+		entry.Column = pos.Column
+		entry.Function = enclosingFunction(pkg, node)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries = append(r.entries, entry)
+}
+
+func (r *recorder) relativePath(path string) string {
+	if path == "" || !filepath.IsAbs(path) {
+		return filepath.ToSlash(path)
+	}
+	rel, err := filepath.Rel(r.appRoot, path)
+	if err != nil {
+		return filepath.ToSlash(path)
+	}
+	return filepath.ToSlash(rel)
+}
+
+// enclosingFunction returns the name of the function declaration that contains node, or an empty string if
+// there is none. Methods are named after their receiver type, e.g. "Server.Health".
+func enclosingFunction(pkg *decorator.Package, node dst.Node) string {
+	astNode := pkg.Decorator.Ast.Nodes[node]
+	if astNode == nil || pkg.Package == nil {
+		return ""
+	}
+
+	pos := astNode.Pos()
+	for _, file := range pkg.Package.Syntax {
+		if pos < file.Pos() || pos > file.End() {
+			continue
+		}
+		for _, decl := range file.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || pos < fn.Pos() || pos > fn.End() {
+				continue
+			}
+			return funcDeclName(fn)
+		}
+	}
+	return ""
+}
+
+func funcDeclName(fn *ast.FuncDecl) string {
+	if fn.Recv == nil || len(fn.Recv.List) == 0 {
+		return fn.Name.Name
+	}
+
+	recv := fn.Recv.List[0].Type
+	for {
+		switch t := recv.(type) {
+		case *ast.StarExpr:
+			recv = t.X
+		case *ast.IndexExpr:
+			recv = t.X
+		case *ast.IndexListExpr:
+			recv = t.X
+		case *ast.ParenExpr:
+			recv = t.X
+		case *ast.Ident:
+			return t.Name + "." + fn.Name.Name
+		default:
+			return fn.Name.Name
+		}
+	}
+}
+
+// Build creates the report from everything recorded so far. Entries are sorted by file and position, so
+// that the same application always produces the same report.
+func Build(toolVersion, diffFile string) *Report {
+	report := &Report{
+		Version:     Version,
+		ToolVersion: toolVersion,
+		DiffFile:    diffFile,
+		Summary: Summary{
+			Codes: map[Code]int{},
+			Kinds: map[Kind]int{},
+			Files: map[string]int{},
+		},
+		Actions:  []Entry{},
+		Warnings: []Entry{},
+		Skipped:  []Entry{},
+	}
+	if rec == nil {
+		return report
+	}
+
+	rec.mu.Lock()
+	entries := slices.Clone(rec.entries)
+	report.Application = rec.appPath
+	rec.mu.Unlock()
+
+	slices.SortStableFunc(entries, compareEntries)
+	entries = slices.Compact(entries)
+
+	for _, entry := range entries {
+		switch entry.Severity {
+		case SeverityAction:
+			report.Actions = append(report.Actions, entry)
+			report.Summary.Kinds[entry.Kind]++
+		case SeverityWarning:
+			report.Warnings = append(report.Warnings, entry)
+		case SeveritySkipped:
+			report.Skipped = append(report.Skipped, entry)
+		}
+		report.Summary.Codes[entry.Code]++
+		if entry.File != "" {
+			report.Summary.Files[entry.File]++
+		}
+	}
+	report.Summary.Actions = len(report.Actions)
+	report.Summary.Warnings = len(report.Warnings)
+	report.Summary.Skipped = len(report.Skipped)
+	return report
+}
+
+func compareEntries(a, b Entry) int {
+	if c := strings.Compare(a.File, b.File); c != 0 {
+		return c
+	}
+	if a.Line != b.Line {
+		return a.Line - b.Line
+	}
+	if a.Column != b.Column {
+		return a.Column - b.Column
+	}
+	if c := strings.Compare(string(a.Code), string(b.Code)); c != 0 {
+		return c
+	}
+	return strings.Compare(a.Message, b.Message)
+}
+
+// Write builds the report and writes it to path as indented JSON.
+func Write(path, toolVersion, diffFile string) error {
+	data, err := json.MarshalIndent(Build(toolVersion, diffFile), "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
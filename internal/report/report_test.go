@@ -0,0 +1,163 @@
+package report
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/dave/dst"
+	"github.com/dave/dst/decorator"
+	"golang.org/x/tools/go/packages"
+)
+
+const testApp = `package main
+
+type Server struct{}
+
+func (s *Server) Health() error {
+	return nil
+}
+
+func main() {
+	s := &Server{}
+	s.Health()
+}
+`
+
+// loadTestApp writes testApp to a temporary module and loads it.
+func loadTestApp(t *testing.T) (string, *decorator.Package) {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("Skipping report tests that load packages in short mode")
+	}
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/app\n\ngo 1.22\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "app.go"), []byte(testApp), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	pkgs, err := decorator.Load(&packages.Config{Dir: dir, Mode: packages.LoadSyntax}, "./...")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pkgs) != 1 {
+		t.Fatalf("expected 1 package, got %d", len(pkgs))
+	}
+	return dir, pkgs[0]
+}
+
+// findFuncDecl returns the declaration of the function or method with the given name.
+func findFuncDecl(t *testing.T, pkg *decorator.Package, name string) *dst.FuncDecl {
+	t.Helper()
+	for _, decl := range pkg.Syntax[0].Decls {
+		if fn, ok := decl.(*dst.FuncDecl); ok && fn.Name.Name == name {
+			return fn
+		}
+	}
+	t.Fatalf("function %s not found", name)
+	return nil
+}
+
+func TestDisabled(t *testing.T) {
+	Disable()
+	Action(nil, nil, CoreIntegration, KindAgent, "initialized the New Relic agent in main")
+	Add(Entry{Code: CodeCompileError, Severity: SeverityWarning, Message: "does not compile"})
+
+	if Enabled() {
+		t.Error("expected the report to be disabled")
+	}
+	got := Build("1.0.0", "app.diff")
+	if len(got.Actions) != 0 || len(got.Warnings) != 0 || len(got.Skipped) != 0 {
+		t.Errorf("expected nothing to be recorded, got %+v", got)
+	}
+}
+
+func TestRecord(t *testing.T) {
+	dir, pkg := loadTestApp(t)
+	Enable(dir)
+	t.Cleanup(Disable)
+
+	main := findFuncDecl(t, pkg, "main")
+	health := findFuncDecl(t, pkg, "Health")
+
+	// recorded out of order, and with a duplicate, to check that the report is stable
+	Warning(pkg, main.Body.List[1], CoreIntegration, CodeUncheckedError, "unchecked error can not be captured")
+	Action(pkg, main, CoreIntegration, KindAgent, "initialized the New Relic agent in main")
+	Action(pkg, health, CoreIntegration, KindSegment, "added a segment to Health")
+	Action(pkg, health, CoreIntegration, KindSegment, "added a segment to Health")
+	Skipped(pkg, health.Body.List[0], "nrgin", CodeAlreadyInstrumented, "already instrumented")
+	Add(Entry{Code: CodeHunkDropped, Severity: SeveritySkipped, File: filepath.Join(dir, "app.go"), Line: 11, Message: "does not compile"})
+
+	got := Build("1.0.0", "app.diff")
+
+	wantActions := []Entry{
+		{Code: CodeSegment, Severity: SeverityAction, Kind: KindSegment, Integration: CoreIntegration, File: "app.go", Line: 5, Column: 1, Function: "Server.Health", Message: "added a segment to Health"},
+		{Code: CodeAgentInitialized, Severity: SeverityAction, Kind: KindAgent, Integration: CoreIntegration, File: "app.go", Line: 9, Column: 1, Function: "main", Message: "initialized the New Relic agent in main"},
+	}
+	if !reflect.DeepEqual(got.Actions, wantActions) {
+		t.Errorf("expected actions %+v, got %+v", wantActions, got.Actions)
+	}
+
+	wantWarnings := []Entry{
+		{Code: CodeUncheckedError, Severity: SeverityWarning, Integration: CoreIntegration, File: "app.go", Line: 11, Column: 2, Function: "main", Message: "unchecked error can not be captured"},
+	}
+	if !reflect.DeepEqual(got.Warnings, wantWarnings) {
+		t.Errorf("expected warnings %+v, got %+v", wantWarnings, got.Warnings)
+	}
+
+	wantSkipped := []Entry{
+		{Code: CodeAlreadyInstrumented, Severity: SeveritySkipped, Integration: "nrgin", File: "app.go", Line: 6, Column: 2, Function: "Server.Health", Message: "already instrumented"},
+		{Code: CodeHunkDropped, Severity: SeveritySkipped, File: "app.go", Line: 11, Message: "does not compile"},
+	}
+	if !reflect.DeepEqual(got.Skipped, wantSkipped) {
+		t.Errorf("expected skipped %+v, got %+v", wantSkipped, got.Skipped)
+	}
+
+	wantSummary := Summary{
+		Actions:  2,
+		Warnings: 1,
+		Skipped:  2,
+		Codes:    map[Code]int{CodeSegment: 1, CodeAgentInitialized: 1, CodeUncheckedError: 1, CodeAlreadyInstrumented: 1, CodeHunkDropped: 1},
+		Kinds:    map[Kind]int{KindSegment: 1, KindAgent: 1},
+		Files:    map[string]int{"app.go": 5},
+	}
+	if !reflect.DeepEqual(got.Summary, wantSummary) {
+		t.Errorf("expected summary %+v, got %+v", wantSummary, got.Summary)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	dir, pkg := loadTestApp(t)
+	Enable(dir)
+	t.Cleanup(Disable)
+
+	Action(pkg, findFuncDecl(t, pkg, "main"), CoreIntegration, KindAgent, "initialized the New Relic agent in main")
+
+	path := filepath.Join(t.TempDir(), "report.json")
+	if err := Write(path, "1.0.0", "app.diff"); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got Report
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Version != Version || got.ToolVersion != "1.0.0" || got.DiffFile != "app.diff" {
+		t.Errorf("unexpected report header: %+v", got)
+	}
+	if len(got.Actions) != 1 || got.Actions[0].Code != CodeAgentInitialized || got.Actions[0].Function != "main" {
+		t.Errorf("expected the agent action to be written, got %+v", got.Actions)
+	}
+	if got.Warnings == nil || got.Skipped == nil {
+		t.Error("expected empty lists to be written as [] rather than null")
+	}
+}
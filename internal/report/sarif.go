@@ -0,0 +1,264 @@
+package report
+
+import (
+	"encoding/json"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	sarifVersion   = "2.1.0"
+	sarifSchema    = "https://json.schemastore.org/sarif-2.1.0.json"
+	sarifToolName  = "go-easy-instrumentation"
+	sarifToolURI   = "https://github.com/newrelic/go-easy-instrumentation"
+	sarifUriBaseId = "SRCROOT"
+)
+
+// Rule describes a warning code, so that code scanning tools can explain it to the user.
+type Rule struct {
+	ID          Code
+	Name        string
+	Description string
+	HelpURI     string
+	Level       string // SARIF level: "error", "warning" or "note"
+}
+
+// Rules lists every warning code in the order they are written to the SARIF log.
+var Rules = []Rule{
+	{
+		ID:          CodeUncheckedError,
+		Name:        "UncheckedError",
+		Description: "An error is not checked, so it can not be captured with NoticeError.",
+		HelpURI:     "https://docs.newrelic.com/docs/apm/agents/go-agent/api-guides/guide-using-go-agent-api/#errors",
+		Level:       "warning",
+	},
+	{
+		ID:          CodeUninstrumentableHttpCall,
+		Name:        "UninstrumentableHttpCall",
+		Description: "An outbound HTTP call can not be instrumented, so it will not be traced.",
+		HelpURI:     "https://docs.newrelic.com/docs/apm/agents/go-agent/configuration/distributed-tracing-go-agent/#make-http-requests",
+		Level:       "warning",
+	},
+	{
+		ID:          CodeGoroutineInMain,
+		Name:        "GoroutineInMain",
+		Description: "A goroutine started in main is not traced, such as when it runs a function that is also called elsewhere, and must be instrumented manually.",
+		HelpURI:     "https://docs.newrelic.com/docs/apm/agents/go-agent/instrumentation/instrument-go-transactions/#goroutines",
+		Level:       "note",
+	},
+	{
+		ID:          CodeFunctionLiteralName,
+		Name:        "FunctionLiteralName",
+		Description: "Segments for function literals are named \"function literal\"; declare a function to get a better name.",
+		HelpURI:     "https://docs.newrelic.com/docs/apm/agents/go-agent/instrumentation/instrument-go-segments/",
+		Level:       "note",
+	},
+	{
+		ID:          CodeContextTransaction,
+		Name:        "ContextTransaction",
+		Description: "A transaction was added to a context argument defensively, and may not be necessary.",
+		HelpURI:     "https://docs.newrelic.com/docs/apm/agents/go-agent/instrumentation/instrument-go-transactions/",
+		Level:       "note",
+	},
+	{
+		ID:          CodeCompileError,
+		Name:        "CompileError",
+		Description: "The instrumented application does not compile.",
+		HelpURI:     "https://github.com/newrelic/go-easy-instrumentation#cli-flags",
+		Level:       "error",
+	},
+	{
+		ID:          CodeModuleNotResolved,
+		Name:        "ModuleNotResolved",
+		Description: "A module required by the instrumentation could not be resolved from the local module cache, or its go.sum checksums could not be computed from it.",
+		HelpURI:     "https://github.com/newrelic/go-easy-instrumentation#cli-flags",
+		Level:       "warning",
+	},
+	{
+		ID:          CodeSignaturePreserved,
+		Name:        "SignaturePreserved",
+		Description: "A function was not traced, since a transaction can not be passed to it without changing its signature.",
+		HelpURI:     "https://docs.newrelic.com/docs/apm/agents/go-agent/instrumentation/instrument-go-transactions/",
+		Level:       "warning",
+	},
+	{
+		ID:          CodeRouterType,
+		Name:        "RouterType",
+		Description: "A router was not instrumented, since the instrumented router has a different type than the one it is used as.",
+		HelpURI:     "https://pkg.go.dev/github.com/newrelic/go-agent/v3/integrations/nrhttprouter",
+		Level:       "warning",
+	},
+	{
+		ID:          CodeStoredFunctionLiteral,
+		Name:        "StoredFunctionLiteral",
+		Description: "A function literal stored in a struct field, a map or a slice uses the transaction of the function it is declared in, which may have ended when it is called.",
+		HelpURI:     "https://docs.newrelic.com/docs/apm/agents/go-agent/instrumentation/instrument-go-transactions/",
+		Level:       "warning",
+	},
+}
+
+// The subset of the SARIF 2.1.0 format that is written by WriteSARIF.
+type (
+	sarifLog struct {
+		Schema  string     `json:"$schema"`
+		Version string     `json:"version"`
+		Runs    []sarifRun `json:"runs"`
+	}
+
+	sarifRun struct {
+		Tool               sarifTool                        `json:"tool"`
+		OriginalUriBaseIds map[string]sarifArtifactLocation `json:"originalUriBaseIds,omitempty"`
+		Results            []sarifResult                    `json:"results"`
+	}
+
+	sarifTool struct {
+		Driver sarifDriver `json:"driver"`
+	}
+
+	sarifDriver struct {
+		Name           string      `json:"name"`
+		Version        string      `json:"version"`
+		InformationURI string      `json:"informationUri"`
+		Rules          []sarifRule `json:"rules"`
+	}
+
+	sarifRule struct {
+		ID                   string             `json:"id"`
+		Name                 string             `json:"name"`
+		ShortDescription     sarifMessage       `json:"shortDescription"`
+		HelpURI              string             `json:"helpUri"`
+		DefaultConfiguration sarifConfiguration `json:"defaultConfiguration"`
+	}
+
+	sarifConfiguration struct {
+		Level string `json:"level"`
+	}
+
+	sarifMessage struct {
+		Text string `json:"text"`
+	}
+
+	sarifResult struct {
+		RuleID    string          `json:"ruleId"`
+		RuleIndex int             `json:"ruleIndex"`
+		Level     string          `json:"level"`
+		Message   sarifMessage    `json:"message"`
+		Locations []sarifLocation `json:"locations,omitempty"`
+	}
+
+	sarifLocation struct {
+		PhysicalLocation sarifPhysicalLocation  `json:"physicalLocation"`
+		LogicalLocations []sarifLogicalLocation `json:"logicalLocations,omitempty"`
+	}
+
+	sarifPhysicalLocation struct {
+		ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+		Region           *sarifRegion          `json:"region,omitempty"`
+	}
+
+	sarifArtifactLocation struct {
+		URI       string `json:"uri"`
+		UriBaseId string `json:"uriBaseId,omitempty"`
+	}
+
+	sarifRegion struct {
+		StartLine   int `json:"startLine"`
+		StartColumn int `json:"startColumn,omitempty"`
+	}
+
+	sarifLogicalLocation struct {
+		FullyQualifiedName string `json:"fullyQualifiedName"`
+		Kind               string `json:"kind"`
+	}
+)
+
+// buildSARIF creates a SARIF 2.1.0 log with a result for every warning recorded so far. File locations are
+// relative to the application root, which is given as the SRCROOT base URI.
+func buildSARIF(toolVersion string) sarifLog {
+	run := sarifRun{
+		Tool: sarifTool{
+			Driver: sarifDriver{
+				Name:           sarifToolName,
+				Version:        toolVersion,
+				InformationURI: sarifToolURI,
+				Rules:          []sarifRule{},
+			},
+		},
+		Results: []sarifResult{},
+	}
+
+	ruleIndex := map[Code]int{}
+	for i, rule := range Rules {
+		ruleIndex[rule.ID] = i
+		run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{
+			ID:                   string(rule.ID),
+			Name:                 rule.Name,
+			ShortDescription:     sarifMessage{Text: rule.Description},
+			HelpURI:              rule.HelpURI,
+			DefaultConfiguration: sarifConfiguration{Level: rule.Level},
+		})
+	}
+
+	if rec != nil {
+		run.OriginalUriBaseIds = map[string]sarifArtifactLocation{
+			sarifUriBaseId: {URI: directoryURI(rec.appRoot)},
+		}
+	}
+
+	for _, entry := range Build(toolVersion, "").Warnings {
+		index, ok := ruleIndex[entry.Code]
+		if !ok {
+			continue
+		}
+		result := sarifResult{
+			RuleID:    string(entry.Code),
+			RuleIndex: index,
+			Level:     Rules[index].Level,
+			Message:   sarifMessage{Text: entry.Message},
+		}
+		if entry.File != "" {
+			location := sarifLocation{
+				PhysicalLocation: sarifPhysicalLocation{
+					ArtifactLocation: sarifArtifactLocation{URI: entry.File, UriBaseId: sarifUriBaseId},
+				},
+			}
+			if entry.Line > 0 {
+				location.PhysicalLocation.Region = &sarifRegion{StartLine: entry.Line, StartColumn: entry.Column}
+			}
+			if entry.Function != "" {
+				location.LogicalLocations = []sarifLogicalLocation{{FullyQualifiedName: entry.Function, Kind: "function"}}
+			}
+			result.Locations = []sarifLocation{location}
+		}
+		run.Results = append(run.Results, result)
+	}
+
+	return sarifLog{
+		Schema:  sarifSchema,
+		Version: sarifVersion,
+		Runs:    []sarifRun{run},
+	}
+}
+
+// directoryURI returns the file URI of a directory, with the trailing slash that SARIF requires for base URIs.
+func directoryURI(dir string) string {
+	path := filepath.ToSlash(dir)
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path // windows drive letters
+	}
+	if !strings.HasSuffix(path, "/") {
+		path += "/"
+	}
+	return (&url.URL{Scheme: "file", Path: path}).String()
+}
+
+// WriteSARIF builds the SARIF log and writes it to path as indented JSON.
+func WriteSARIF(path, toolVersion string) error {
+	data, err := json.MarshalIndent(buildSARIF(toolVersion), "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
@@ -0,0 +1,106 @@
+package report
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBuildSARIF(t *testing.T) {
+	dir, pkg := loadTestApp(t)
+	Enable(dir)
+	t.Cleanup(Disable)
+
+	main := findFuncDecl(t, pkg, "main")
+	Action(pkg, main, CoreIntegration, KindAgent, "initialized the New Relic agent in main")
+	Warning(pkg, main.Body.List[1], CoreIntegration, CodeUncheckedError, "unchecked error can not be captured")
+	Add(Entry{Code: CodeModuleNotResolved, Severity: SeverityWarning, Message: "module not found"})
+
+	log := buildSARIF("1.0.0")
+	if log.Version != "2.1.0" || len(log.Runs) != 1 {
+		t.Fatalf("expected a single SARIF 2.1.0 run, got %+v", log)
+	}
+	run := log.Runs[0]
+	if len(run.Tool.Driver.Rules) != len(Rules) {
+		t.Errorf("expected %d rules, got %d", len(Rules), len(run.Tool.Driver.Rules))
+	}
+	if got := run.OriginalUriBaseIds[sarifUriBaseId].URI; got != directoryURI(dir) {
+		t.Errorf("expected base URI %q, got %q", directoryURI(dir), got)
+	}
+
+	// actions are not diagnostics, and are left out of the log
+	if len(run.Results) != 2 {
+		t.Fatalf("expected 2 results, got %+v", run.Results)
+	}
+
+	result := run.Results[1]
+	if result.RuleID != string(CodeUncheckedError) || run.Tool.Driver.Rules[result.RuleIndex].ID != result.RuleID || result.Level != "warning" {
+		t.Errorf("unexpected result %+v", result)
+	}
+	location := result.Locations[0]
+	if location.PhysicalLocation.ArtifactLocation.URI != "app.go" || location.PhysicalLocation.ArtifactLocation.UriBaseId != sarifUriBaseId {
+		t.Errorf("unexpected artifact location %+v", location.PhysicalLocation.ArtifactLocation)
+	}
+	if region := location.PhysicalLocation.Region; region == nil || region.StartLine != 11 || region.StartColumn != 2 {
+		t.Errorf("unexpected region %+v", region)
+	}
+	if len(location.LogicalLocations) != 1 || location.LogicalLocations[0].FullyQualifiedName != "main" {
+		t.Errorf("unexpected logical locations %+v", location.LogicalLocations)
+	}
+
+	if run.Results[0].RuleID != string(CodeModuleNotResolved) || run.Results[0].Locations != nil {
+		t.Errorf("expected a result without a location for the module warning, got %+v", run.Results[0])
+	}
+}
+
+func TestRulesHaveHelp(t *testing.T) {
+	seen := map[Code]bool{}
+	for _, rule := range Rules {
+		if seen[rule.ID] {
+			t.Errorf("duplicate rule %s", rule.ID)
+		}
+		seen[rule.ID] = true
+		if rule.Name == "" || rule.Description == "" || rule.HelpURI == "" {
+			t.Errorf("rule %s is missing a name, description or help URI", rule.ID)
+		}
+		switch rule.Level {
+		case "error", "warning", "note":
+		default:
+			t.Errorf("rule %s has invalid level %q", rule.ID, rule.Level)
+		}
+	}
+}
+
+func TestDirectoryURI(t *testing.T) {
+	tests := map[string]string{
+		"/home/user/app":  "file:///home/user/app/",
+		"/home/user/app/": "file:///home/user/app/",
+		"C:/src/my app":   "file:///C:/src/my%20app/",
+	}
+	for dir, want := range tests {
+		if got := directoryURI(dir); got != want {
+			t.Errorf("directoryURI(%q) = %q, want %q", dir, got, want)
+		}
+	}
+}
+
+func TestWriteSARIF(t *testing.T) {
+	Disable()
+	path := filepath.Join(t.TempDir(), "results.sarif")
+	if err := WriteSARIF(path, "1.0.0"); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]any
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got["version"] != "2.1.0" || got["$schema"] != sarifSchema {
+		t.Errorf("unexpected SARIF header: %v", got)
+	}
+}
@@ -4,8 +4,11 @@
 package sqlhelpers
 
 import (
+	"go/token"
+
 	"github.com/dave/dst"
 	"github.com/dave/dst/dstutil"
+	"github.com/newrelic/go-easy-instrumentation/internal/codegen"
 )
 
 // SQLImportPath is the standard library import path for database/sql.
@@ -135,6 +138,86 @@ func ReplaceSQLMethodWithContext(stmt dst.Stmt, ctxName string) {
 	call.Args = append([]dst.Expr{&dst.Ident{Name: ctxName}}, call.Args...)
 }
 
+// RevertSQLMethodWithContext undoes ReplaceSQLMethodWithContext, rewriting a context-aware SQL
+// execution call that is passed ctxName as its first argument back to the method without a context:
+//
+//	row := db.QueryRowContext(ctx, ...)  ->  row := db.QueryRow(...)
+//
+// Returns false, and leaves stmt unchanged, if stmt is not such a call.
+func RevertSQLMethodWithContext(stmt dst.Stmt, ctxName string) bool {
+	assignStmt, ok := stmt.(*dst.AssignStmt)
+	if !ok || len(assignStmt.Rhs) != 1 {
+		return false
+	}
+
+	call, ok := assignStmt.Rhs[0].(*dst.CallExpr)
+	if !ok || len(call.Args) == 0 {
+		return false
+	}
+
+	selExpr, ok := call.Fun.(*dst.SelectorExpr)
+	if !ok {
+		return false
+	}
+
+	ctxArg, ok := call.Args[0].(*dst.Ident)
+	if !ok || ctxArg.Name != ctxName {
+		return false
+	}
+
+	for _, method := range []string{"QueryRow", "Query", "Exec"} {
+		if contextMethodName(method) == selExpr.Sel.Name {
+			selExpr.Sel.Name = method
+			call.Args = call.Args[1:]
+			return true
+		}
+	}
+	return false
+}
+
+// contextWithTransaction returns the name of the context if stmt creates a context for a transaction:
+//
+//	ctx := newrelic.NewContext(context.Background(), nrTxn)
+func contextWithTransaction(stmt dst.Stmt) string {
+	assign, ok := stmt.(*dst.AssignStmt)
+	if !ok || assign.Tok != token.DEFINE || len(assign.Lhs) != 1 || len(assign.Rhs) != 1 {
+		return ""
+	}
+	ctxIdent, ok := assign.Lhs[0].(*dst.Ident)
+	if !ok {
+		return ""
+	}
+	call, ok := assign.Rhs[0].(*dst.CallExpr)
+	if !ok || len(call.Args) != 2 {
+		return ""
+	}
+	if fun, ok := call.Fun.(*dst.Ident); !ok || fun.Name != "NewContext" || fun.Path != codegen.NewRelicAgentImportPath {
+		return ""
+	}
+	background, ok := call.Args[0].(*dst.CallExpr)
+	if !ok {
+		return ""
+	}
+	if fun, ok := background.Fun.(*dst.Ident); !ok || fun.Name != "Background" || fun.Path != "context" {
+		return ""
+	}
+	return ctxIdent.Name
+}
+
+// RevertContextWithTransaction finds the first context created for a transaction in stmts that
+// is passed to the SQL execution call right after it, and reverts that call with
+// RevertSQLMethodWithContext. It returns the statement that created the context, which the
+// caller should remove, or nil if there is none.
+func RevertContextWithTransaction(stmts []dst.Stmt) dst.Stmt {
+	for i := 0; i+1 < len(stmts); i++ {
+		ctxName := contextWithTransaction(stmts[i])
+		if ctxName != "" && RevertSQLMethodWithContext(stmts[i+1], ctxName) {
+			return stmts[i]
+		}
+	}
+	return nil
+}
+
 // FindLastUsageOfExecutionResult scans stmts after startIndex for the last statement that
 // references varName, and returns that index. Returns startIndex if varName is never used after
 // startIndex, or if varName is empty / the blank identifier (in which case there is no handle
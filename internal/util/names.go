@@ -0,0 +1,40 @@
+package util
+
+import (
+	"fmt"
+
+	"github.com/dave/dst"
+	"github.com/dave/dst/decorator"
+)
+
+// UniqueName returns name, followed by the lowest number from 2 that makes it unique if an identifier in block
+// already has that name, or if a parameter of the function whose body is block has. It is used to name the
+// variables that instrumentation declares in a block without redeclaring a variable of the same scope.
+func UniqueName(pkg *decorator.Package, block *dst.BlockStmt, name string) string {
+	used := map[string]bool{}
+	dst.Inspect(block, func(n dst.Node) bool {
+		if ident, ok := n.(*dst.Ident); ok && ident.Path == "" {
+			used[ident.Name] = true
+		}
+		return true
+	})
+	unique := name
+	for i := 2; used[unique] || declaredInScope(pkg, block, unique); i++ {
+		unique = fmt.Sprintf("%s%d", name, i)
+	}
+	return unique
+}
+
+// declaredInScope returns true if name is declared in the scope of a block, which includes the parameters and
+// results of the function whose body it is.
+func declaredInScope(pkg *decorator.Package, block *dst.BlockStmt, name string) bool {
+	if pkg == nil || pkg.Decorator == nil || pkg.Types == nil {
+		return false
+	}
+	astNode := pkg.Decorator.Ast.Nodes[block]
+	if astNode == nil {
+		return false
+	}
+	scope := pkg.Types.Scope().Innermost(astNode.Pos())
+	return scope != nil && scope.Lookup(name) != nil
+}
@@ -0,0 +1,21 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/dave/dst"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUniqueName(t *testing.T) {
+	block := &dst.BlockStmt{
+		List: []dst.Stmt{
+			&dst.ExprStmt{X: dst.NewIdent("client")},
+			&dst.ExprStmt{X: dst.NewIdent("client2")},
+			&dst.ExprStmt{X: &dst.Ident{Name: "server", Path: "github.com/valyala/fasthttp"}},
+		},
+	}
+	assert.Equal(t, "client3", UniqueName(nil, block, "client"))
+	assert.Equal(t, "server", UniqueName(nil, block, "server"), "qualified identifiers do not name variables")
+	assert.Equal(t, "handler", UniqueName(nil, block, "handler"))
+}
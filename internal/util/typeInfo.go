@@ -69,13 +69,66 @@ func FunctionName(call *dst.CallExpr) string {
 	if call == nil {
 		return ""
 	}
-	switch v := call.Fun.(type) {
+	if ident := FunctionIdent(call.Fun); ident != nil {
+		return ident.Name
+	}
+	return ""
+}
+
+// FunctionExpr returns the expression of the function or method invoked by a call without the parentheses and
+// type arguments around it, such as Map in `Map[int, string](...)`.
+func FunctionExpr(fun dst.Expr) dst.Expr {
+	for {
+		switch f := fun.(type) {
+		case *dst.ParenExpr:
+			fun = f.X
+		case *dst.IndexExpr:
+			fun = f.X
+		case *dst.IndexListExpr:
+			fun = f.X
+		default:
+			return fun
+		}
+	}
+}
+
+// FunctionIdent returns the identifier of the function or method invoked by a call, such as Get in
+// `repo.Get[int](...)`, or nil if the function is not named, such as when a function literal is called.
+func FunctionIdent(fun dst.Expr) *dst.Ident {
+	switch v := FunctionExpr(fun).(type) {
 	case *dst.Ident:
-		return v.Name
+		return v
 	case *dst.SelectorExpr:
-		return v.Sel.Name
+		return v.Sel
 	}
-	return ""
+	return nil
+}
+
+// CalledFunction returns the function or method invoked by a call expression as it is declared, rather than as it
+// is instantiated with type arguments, according to go types info. If it is not known, nil is returned.
+func CalledFunction(call *dst.CallExpr, pkg *decorator.Package) *types.Func {
+	if call == nil || pkg == nil || pkg.TypesInfo == nil {
+		return nil
+	}
+	ident := FunctionIdent(call.Fun)
+	if ident == nil {
+		return nil
+	}
+
+	var astIdent *ast.Ident
+	switch v := pkg.Decorator.Ast.Nodes[ident].(type) {
+	case *ast.SelectorExpr:
+		astIdent = v.Sel
+	case *ast.Ident:
+		astIdent = v
+	default:
+		return nil
+	}
+	fn, ok := pkg.TypesInfo.Uses[astIdent].(*types.Func)
+	if !ok {
+		return nil
+	}
+	return fn.Origin()
 }
 
 // Position returns the position of the node in the file
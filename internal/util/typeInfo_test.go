@@ -3,6 +3,8 @@ package util
 import (
 	"go/types"
 	"testing"
+
+	"github.com/dave/dst"
 )
 
 func TestIsNamedError(t *testing.T) {
@@ -146,3 +148,60 @@ func TestCheckUnderlyingType(t *testing.T) {
 		})
 	}
 }
+
+func TestFunctionName(t *testing.T) {
+	tests := []struct {
+		name string
+		call *dst.CallExpr
+		want string
+	}{
+		{
+			name: "function",
+			call: &dst.CallExpr{Fun: dst.NewIdent("Map")},
+			want: "Map",
+		},
+		{
+			name: "method",
+			call: &dst.CallExpr{Fun: &dst.SelectorExpr{X: dst.NewIdent("repo"), Sel: dst.NewIdent("Find")}},
+			want: "Find",
+		},
+		{
+			name: "instantiated generic function",
+			call: &dst.CallExpr{Fun: &dst.IndexExpr{X: dst.NewIdent("Map"), Index: dst.NewIdent("int")}},
+			want: "Map",
+		},
+		{
+			name: "instantiated generic function with several type arguments",
+			call: &dst.CallExpr{Fun: &dst.IndexListExpr{
+				X:       &dst.SelectorExpr{X: dst.NewIdent("slices"), Sel: dst.NewIdent("Map")},
+				Indices: []dst.Expr{dst.NewIdent("int"), dst.NewIdent("string")},
+			}},
+			want: "Map",
+		},
+		{
+			name: "function literal",
+			call: &dst.CallExpr{Fun: &dst.FuncLit{}},
+			want: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FunctionName(tt.call); got != tt.want {
+				t.Errorf("FunctionName() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFunctionIdent(t *testing.T) {
+	get := dst.NewIdent("Get")
+	if got := FunctionIdent(&dst.ParenExpr{X: &dst.IndexExpr{
+		X:     &dst.SelectorExpr{X: dst.NewIdent("repo"), Sel: get},
+		Index: dst.NewIdent("int"),
+	}}); got != get {
+		t.Errorf("FunctionIdent() = %v, want the identifier of the method", got)
+	}
+	if got := FunctionIdent(&dst.FuncLit{}); got != nil {
+		t.Errorf("FunctionIdent() = %v, want nil for a function literal", got)
+	}
+}
@@ -0,0 +1,119 @@
+package parser
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/dave/dst"
+	"github.com/newrelic/go-easy-instrumentation/internal/config"
+	"github.com/newrelic/go-easy-instrumentation/internal/report"
+	"github.com/newrelic/go-easy-instrumentation/internal/util"
+)
+
+// Binary is a main package of the application, which is built into a binary of its own.
+type Binary struct {
+	Dir     string // the directory of the main package, relative to the application root
+	AppName string // the application name the binary reports; empty if it is taken from NEW_RELIC_APP_NAME
+	Skipped bool   // the binary was opted out of instrumentation
+}
+
+// SetBinaries sets how the main packages of the application are instrumented, by directory relative to the
+// application root. When an application has more than one main package, each binary reports an application name
+// of its own, so that they can be told apart in New Relic, see Binaries.
+func (m *InstrumentationManager) SetBinaries(binaries map[string]config.Binary) {
+	m.binaries = binaries
+}
+
+// Binaries returns the main packages of the application, including those with cached results, sorted by
+// directory. A binary reports the application name it is configured with. Otherwise, when the application has
+// more than one main package, it is named after its directory, prefixed with the application name if there is
+// one, e.g. "checkout-api" for cmd/api; a single binary reports the application name.
+func (m *InstrumentationManager) Binaries() []Binary {
+	dirs := m.binaryDirs()
+	binaries := make([]Binary, 0, len(dirs))
+	for _, dir := range dirs {
+		binaries = append(binaries, Binary{
+			Dir:     dir,
+			AppName: m.binaryAppName(dir, dirs),
+			Skipped: m.binaries[dir].Skip,
+		})
+	}
+	return binaries
+}
+
+// binaryDirs returns the directories of the main packages of the application, sorted. Main packages are found
+// when the package calls are traced.
+func (m *InstrumentationManager) binaryDirs() []string {
+	dirs := []string{}
+	for _, state := range m.packages {
+		if state.main && !util.IsTestPackage(state.pkg) {
+			dirs = append(dirs, m.relativeFilePath(state.pkg.Dir))
+		}
+	}
+	for _, result := range m.cached {
+		if result.Main {
+			dirs = append(dirs, result.Dir)
+		}
+	}
+	slices.Sort(dirs)
+	return slices.Compact(dirs)
+}
+
+// binaryAppName returns the application name of the binary built from the main package in dir, out of the
+// binaries built from the main packages in dirs.
+func (m *InstrumentationManager) binaryAppName(dir string, dirs []string) string {
+	if name := m.binaries[dir].AppName; name != "" {
+		return name
+	}
+	if len(dirs) < 2 {
+		return m.appName
+	}
+
+	name := m.binaryName(dir)
+	for _, other := range dirs {
+		// binaries in directories with the same name are told apart by their whole path
+		if dir != "." && other != dir && m.binaryName(other) == name {
+			name = strings.ReplaceAll(dir, "/", "-")
+			break
+		}
+	}
+	if m.appName != "" {
+		return m.appName + "-" + name
+	}
+	return name
+}
+
+// binaryName returns the name of the directory of a main package, or of the application if it is at its root.
+func (m *InstrumentationManager) binaryName(dir string) string {
+	if dir != "." {
+		return path.Base(dir)
+	}
+	absAppPath, err := filepath.Abs(m.userAppPath)
+	if err != nil {
+		return filepath.Base(m.userAppPath)
+	}
+	return filepath.Base(absAppPath)
+}
+
+// skipBinary returns true if a package is a main package that was opted out of instrumentation, and reports that
+// its main function is left unchanged.
+func (m *InstrumentationManager) skipBinary(state *packageState) bool {
+	if !state.main {
+		return false
+	}
+	dir := m.relativeFilePath(state.pkg.Dir)
+	if !m.binaries[dir].Skip {
+		return false
+	}
+	for _, file := range state.pkg.Syntax {
+		for _, decl := range file.Decls {
+			if fn, ok := decl.(*dst.FuncDecl); ok && fn.Name.Name == "main" {
+				report.Skipped(state.pkg, fn, report.CoreIntegration, report.CodeExcluded, fmt.Sprintf("binary %s was opted out of instrumentation", dir))
+			}
+		}
+	}
+	return true
+}
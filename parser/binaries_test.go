@@ -0,0 +1,112 @@
+package parser
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/newrelic/go-easy-instrumentation/internal/cache"
+	"github.com/newrelic/go-easy-instrumentation/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+// binariesTestManager returns a manager for an application in a directory named checkout, with a main package
+// in each of the given directories.
+func binariesTestManager(t *testing.T, appName string, dirs ...string) *InstrumentationManager {
+	manager := walkTestManager(len(dirs) + 1)
+	manager.appName = appName
+	manager.userAppPath = filepath.Join(t.TempDir(), "checkout")
+	for i, pkgID := range manager.getSortedPackages() {
+		state := manager.packages[pkgID]
+		if i == len(dirs) {
+			state.pkg.Dir = filepath.Join(manager.userAppPath, "internal")
+			continue
+		}
+		state.pkg.Dir = filepath.Join(manager.userAppPath, filepath.FromSlash(dirs[i]))
+		state.main = true
+	}
+	return manager
+}
+
+func TestBinaries(t *testing.T) {
+	tests := []struct {
+		name     string
+		appName  string
+		dirs     []string
+		binaries map[string]config.Binary
+		want     []Binary
+	}{
+		{
+			name:    "a single binary reports the application name",
+			appName: "checkout",
+			dirs:    []string{"cmd/api"},
+			want:    []Binary{{Dir: "cmd/api", AppName: "checkout"}},
+		},
+		{
+			name: "binaries are named after their directories",
+			dirs: []string{"cmd/api", "cmd/worker", "."},
+			want: []Binary{
+				{Dir: ".", AppName: "checkout"},
+				{Dir: "cmd/api", AppName: "api"},
+				{Dir: "cmd/worker", AppName: "worker"},
+			},
+		},
+		{
+			name:    "binaries are prefixed with the application name",
+			appName: "shop",
+			dirs:    []string{"cmd/api", "cmd/worker"},
+			want: []Binary{
+				{Dir: "cmd/api", AppName: "shop-api"},
+				{Dir: "cmd/worker", AppName: "shop-worker"},
+			},
+		},
+		{
+			name: "binaries in directories with the same name are named after their paths",
+			dirs: []string{"cmd/api", "tools/api", "cmd/worker"},
+			want: []Binary{
+				{Dir: "cmd/api", AppName: "cmd-api"},
+				{Dir: "cmd/worker", AppName: "worker"},
+				{Dir: "tools/api", AppName: "tools-api"},
+			},
+		},
+		{
+			name:    "binaries are configured by directory",
+			appName: "shop",
+			dirs:    []string{"cmd/api", "cmd/worker", "cmd/migrate"},
+			binaries: map[string]config.Binary{
+				"cmd/api":     {AppName: "storefront"},
+				"cmd/migrate": {Skip: true},
+				"cmd/missing": {AppName: "missing"},
+			},
+			want: []Binary{
+				{Dir: "cmd/api", AppName: "storefront"},
+				{Dir: "cmd/migrate", AppName: "shop-migrate", Skipped: true},
+				{Dir: "cmd/worker", AppName: "shop-worker"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manager := binariesTestManager(t, tt.appName, tt.dirs...)
+			manager.SetBinaries(tt.binaries)
+			assert.Equal(t, tt.want, manager.Binaries())
+		})
+	}
+}
+
+func TestBinaries_AppName(t *testing.T) {
+	manager := binariesTestManager(t, "", "cmd/api", "cmd/migrate")
+	manager.SetBinaries(map[string]config.Binary{"cmd/migrate": {Skip: true}})
+	manager.SetCachedResults(map[string]*cache.Result{
+		"example.com/checkout/cmd/worker": {Dir: "cmd/worker", Main: true},
+	})
+	assert.Len(t, manager.Binaries(), 3, "cached main packages are binaries too")
+
+	manager.setPackage("pkg0")
+	assert.Equal(t, "api", manager.AppName(), "the binary built from the current package is named")
+	assert.False(t, manager.skipBinary(manager.packages["pkg0"]))
+	assert.True(t, manager.skipBinary(manager.packages["pkg1"]))
+
+	manager.setPackage("pkg2")
+	assert.Equal(t, "", manager.AppName(), "packages other than main packages report the application name")
+	assert.False(t, manager.skipBinary(manager.packages["pkg2"]))
+}
@@ -0,0 +1,120 @@
+package parser
+
+import (
+	"maps"
+	"path/filepath"
+	"slices"
+
+	"github.com/dave/dst"
+	"github.com/newrelic/go-easy-instrumentation/internal/cache"
+	"github.com/newrelic/go-easy-instrumentation/internal/util"
+)
+
+// SetCachedResults sets the results of the packages of the application that were instrumented by an earlier run and
+// have not changed since, by load path. These packages are not loaded: their results are combined with those of
+// the packages that are, as if the whole application had been instrumented.
+func (m *InstrumentationManager) SetCachedResults(results map[string]*cache.Result) {
+	m.cached = results
+}
+
+// cachedPaths returns the load paths of the packages with cached results, sorted.
+func (m *InstrumentationManager) cachedPaths() []string {
+	return slices.Sorted(maps.Keys(m.cached))
+}
+
+// Results returns the results of instrumenting each of the packages that were loaded, by load path, so that they
+// can be cached. It must be called once the application is instrumented and its unit tests are resolved, before
+// the changes are reviewed.
+func (m *InstrumentationManager) Results() (map[string]*cache.Result, error) {
+	if err := m.restoreChanges(); err != nil {
+		return nil, err
+	}
+
+	results := map[string]*cache.Result{}
+	for _, pkgID := range m.getSortedPackages() {
+		state := m.packages[pkgID]
+		path := cache.LoadPath(state.pkg.Package)
+		result, ok := results[path]
+		if !ok {
+			result = &cache.Result{}
+			results[path] = result
+		}
+		if util.IsTestPackage(state.pkg) {
+			continue
+		}
+
+		result.Dir = m.relativeFilePath(state.pkg.Dir)
+		result.ImportsAdded = slices.Sorted(maps.Keys(state.importsAdded))
+		result.Main = state.main
+		result.Facts = state.facts
+		for _, file := range state.pkg.Syntax {
+			for _, decl := range file.Decls {
+				fn, ok := decl.(*dst.FuncDecl)
+				if !ok {
+					continue
+				}
+				if m.instrumented[fn] {
+					result.AlreadyInstrumented++
+				}
+				if name := m.preserved[fn]; name != "" {
+					result.Preserved = append(result.Preserved, name)
+				}
+			}
+		}
+		slices.Sort(result.Preserved)
+	}
+
+	for _, change := range m.changes {
+		state, ok := m.packages[change.pkgID]
+		if !ok || change.original == change.modified {
+			continue
+		}
+		result := results[cache.LoadPath(state.pkg.Package)]
+		result.Files = append(result.Files, cache.File{
+			Package:  change.pkgID,
+			Path:     filepath.ToSlash(change.diffName),
+			Modified: change.modified,
+		})
+	}
+	return results, nil
+}
+
+// addedImports are the imports added to the packages in a directory.
+type addedImports struct {
+	dir     string
+	imports []string
+}
+
+// addedImports returns the imports added to each package of the application other than tests, including those with
+// cached results, in the order of their package IDs, followed by the cached packages in the order of their load paths.
+func (m *InstrumentationManager) addedImports() ([]addedImports, error) {
+	added := []addedImports{}
+	for _, pkgID := range m.getSortedPackages() {
+		state := m.packages[pkgID]
+		if util.IsTestPackage(state.pkg) || len(state.importsAdded) == 0 {
+			continue
+		}
+		added = append(added, addedImports{
+			dir:     state.pkg.Dir,
+			imports: slices.Sorted(maps.Keys(state.importsAdded)),
+		})
+	}
+
+	if len(m.cached) == 0 {
+		return added, nil
+	}
+	absAppPath, err := filepath.Abs(m.userAppPath)
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range m.cachedPaths() {
+		result := m.cached[path]
+		if len(result.ImportsAdded) > 0 {
+			added = append(added, addedImports{
+				dir:     filepath.Join(absAppPath, filepath.FromSlash(result.Dir)),
+				imports: result.ImportsAdded,
+			})
+		}
+	}
+	return added, nil
+}
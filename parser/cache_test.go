@@ -0,0 +1,49 @@
+package parser
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/dave/dst"
+	"github.com/dave/dst/decorator"
+	"github.com/newrelic/go-easy-instrumentation/internal/cache"
+	"github.com/newrelic/go-easy-instrumentation/internal/codegen"
+	"github.com/newrelic/go-easy-instrumentation/parser/facts"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCachedResults(t *testing.T) {
+	manager := walkTestManager(3)
+	manager.userAppPath = t.TempDir()
+	manager.instrumented = map[*dst.FuncDecl]bool{}
+	manager.preserved = map[*dst.FuncDecl]string{}
+	manager.packages["pkg1"].importsAdded[codegen.NewRelicAgentImportPath] = true
+	manager.SetCachedResults(map[string]*cache.Result{
+		"example.com/app": {
+			Dir:                 ".",
+			Main:                true,
+			Facts:               []facts.Entry{{Name: "handler", Fact: facts.GrpcServerType}},
+			ImportsAdded:        []string{codegen.NewRelicAgentImportPath},
+			Preserved:           []string{"Server.Health"},
+			AlreadyInstrumented: 2,
+		},
+		"example.com/app/lib": {Dir: "lib"},
+	})
+
+	// the main function and facts of cached packages are known without loading them
+	discover := func(pkg *decorator.Package, node dst.Node) (facts.Entry, bool) {
+		return facts.Entry{}, false
+	}
+	assert.NoError(t, tracePackageFunctionCalls(manager, discover))
+	assert.Equal(t, facts.GrpcServerType, manager.facts.GetFact("handler"))
+
+	assert.Equal(t, 2, manager.AlreadyInstrumented())
+	assert.Equal(t, []string{"Server.Health"}, manager.PreservedSignatures())
+
+	added, err := manager.addedImports()
+	assert.NoError(t, err)
+	assert.Equal(t, []addedImports{
+		{dir: "", imports: []string{codegen.NewRelicAgentImportPath}},
+		{dir: filepath.Clean(manager.userAppPath), imports: []string{codegen.NewRelicAgentImportPath}},
+	}, added)
+}
@@ -0,0 +1,101 @@
+package parser
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"slices"
+	"strings"
+
+	"github.com/dave/dst"
+	"github.com/newrelic/go-easy-instrumentation/internal/config"
+	"golang.org/x/tools/go/callgraph"
+	"golang.org/x/tools/go/callgraph/cha"
+	"golang.org/x/tools/go/callgraph/vta"
+	"golang.org/x/tools/go/ssa/ssautil"
+)
+
+// SetCallGraph sets the call graph algorithm that calls to interface methods are resolved with, so that tracing
+// is passed to the implementations of the method declared in the application: config.CallGraphCHA, which finds
+// every implementation, or config.CallGraphVTA, which only finds those whose values can reach the call. By
+// default, tracing is only passed to functions and methods that are called directly.
+func (m *InstrumentationManager) SetCallGraph(algorithm string) {
+	m.callGraph = algorithm
+}
+
+// buildCallGraph builds the call graph of the application, and records the methods declared in the application
+// that each call to an interface method may invoke.
+func (m *InstrumentationManager) buildCallGraph() error {
+	m.implementations = map[token.Pos][]*types.Func{}
+	if m.callGraph == "" {
+		return nil
+	}
+
+	prog := m.ssaProgram()
+	var graph *callgraph.Graph
+	switch m.callGraph {
+	case config.CallGraphCHA:
+		graph = cha.CallGraph(prog)
+	case config.CallGraphVTA:
+		graph = vta.CallGraph(ssautil.AllFunctions(prog), cha.CallGraph(prog))
+	default:
+		return fmt.Errorf("unknown call graph algorithm %q", m.callGraph)
+	}
+
+	for fn, node := range graph.Nodes {
+		if fn == nil || fn.Pkg == nil || m.packages[fn.Pkg.Pkg.Path()] == nil {
+			continue
+		}
+		for _, edge := range node.Out {
+			if edge.Site == nil || !edge.Site.Common().IsInvoke() {
+				continue
+			}
+			// the wrappers of methods with a value receiver, which are called through pointers, have the object of the method
+			method, ok := edge.Callee.Func.Object().(*types.Func)
+			if !ok || method.Pkg() == nil || m.packages[method.Pkg().Path()] == nil {
+				continue
+			}
+			pos := edge.Site.Pos()
+			if !slices.ContainsFunc(m.implementations[pos], func(f *types.Func) bool { return f.Origin() == method.Origin() }) {
+				m.implementations[pos] = append(m.implementations[pos], method)
+			}
+		}
+	}
+	for _, methods := range m.implementations {
+		slices.SortFunc(methods, func(a, b *types.Func) int {
+			return strings.Compare(a.Origin().FullName(), b.Origin().FullName())
+		})
+	}
+	return nil
+}
+
+// findImplementations returns the invocations of the methods declared in the application that a call to an
+// interface method may invoke, according to the call graph. Nothing is returned if no call graph was built.
+func (m *InstrumentationManager) findImplementations(call *dst.CallExpr) []*invocationInfo {
+	pkg := m.getDecoratorPackage()
+	if len(m.implementations) == 0 || pkg == nil {
+		return nil
+	}
+	astCall, ok := pkg.Decorator.Ast.Nodes[call].(*ast.CallExpr)
+	if !ok {
+		return nil
+	}
+
+	invocations := []*invocationInfo{}
+	for _, method := range m.implementations[astCall.Lparen] {
+		path := method.Pkg().Path()
+		key := method.Origin().FullName()
+		if state, ok := m.packages[path]; ok && state.tracedFuncs[key] != nil {
+			invocations = append(invocations, &invocationInfo{
+				functionName:   method.Name(),
+				key:            key,
+				packageName:    path,
+				call:           call,
+				decl:           state.tracedFuncs[key].body,
+				implementation: true,
+			})
+		}
+	}
+	return invocations
+}
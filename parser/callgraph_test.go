@@ -0,0 +1,104 @@
+package parser
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/dave/dst"
+	"github.com/dave/dst/decorator"
+	"github.com/newrelic/go-easy-instrumentation/internal/config"
+	"github.com/newrelic/go-easy-instrumentation/parser/tracestate"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTraceFunction_CallGraph(t *testing.T) {
+	code := `package main
+
+import "context"
+
+type store interface {
+	Get(ctx context.Context, key string) string
+}
+
+type memoryStore struct{}
+
+func (memoryStore) Get(ctx context.Context, key string) string {
+	return key
+}
+
+type diskStore struct{}
+
+func (*diskStore) Get(ctx context.Context, key string) string {
+	return key
+}
+
+func lookup(s store, key string) string {
+	return s.Get(context.Background(), key)
+}
+
+func main() {
+	lookup(memoryStore{}, "a")
+	lookup(&diskStore{}, "b")
+}
+`
+	tests := []struct {
+		name      string
+		callGraph string
+		expect    []string
+		notExpect []string
+	}{
+		{
+			name:      "interface calls are not traced by default",
+			callGraph: "",
+			notExpect: []string{`StartSegment("memoryStore.Get")`, `StartSegment("diskStore.Get")`},
+		},
+		{
+			name:      "implementations found with class hierarchy analysis",
+			callGraph: config.CallGraphCHA,
+			expect:    []string{`StartSegment("memoryStore.Get")`, `StartSegment("diskStore.Get")`},
+		},
+		{
+			name:      "implementations found with variable type analysis",
+			callGraph: config.CallGraphVTA,
+			expect:    []string{`StartSegment("memoryStore.Get")`, `StartSegment("diskStore.Get")`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer PanicRecovery(t)
+			uuid, _ := Pseudo_uuid()
+			testDir := fmt.Sprintf("tmp_%s", uuid)
+			defer CleanTestApp(t, testDir)
+
+			manager := TestInstrumentationManager(t, code, testDir)
+			manager.SetCallGraph(tt.callGraph)
+			pkg := manager.getDecoratorPackage()
+
+			var mainFunc *dst.FuncDecl
+			for _, decl := range pkg.Syntax[0].Decls {
+				if funcDecl, ok := decl.(*dst.FuncDecl); ok {
+					manager.createFunctionDeclaration(funcDecl)
+					if funcDecl.Name.Name == "main" {
+						mainFunc = funcDecl
+					}
+				}
+			}
+			if err := manager.buildCallGraph(); err != nil {
+				t.Fatal(err)
+			}
+			TraceFunction(manager, mainFunc, tracestate.Main("app"))
+
+			buf := bytes.NewBuffer([]byte{})
+			if err := decorator.NewRestorerWithImports(testDir, createTestResolver(testDir)).Fprint(buf, pkg.Syntax[0]); err != nil {
+				t.Fatal(err)
+			}
+			for _, expect := range tt.expect {
+				assert.Contains(t, buf.String(), expect)
+			}
+			for _, notExpect := range tt.notExpect {
+				assert.NotContains(t, buf.String(), notExpect)
+			}
+		})
+	}
+}
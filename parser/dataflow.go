@@ -0,0 +1,325 @@
+package parser
+
+import (
+	"go/token"
+	"go/types"
+	"slices"
+
+	"github.com/dave/dst"
+	"github.com/newrelic/go-easy-instrumentation/internal/codegen"
+	"github.com/newrelic/go-easy-instrumentation/internal/util"
+	"golang.org/x/tools/go/packages"
+	"golang.org/x/tools/go/ssa"
+	"golang.org/x/tools/go/ssa/ssautil"
+)
+
+// transactionType is the type of the transactions of the go agent.
+const transactionType = "*" + codegen.NewRelicAgentImportPath + ".Transaction"
+
+// contextFunctions are the functions that derive a context from the context passed as their first argument, which
+// carries the same transaction as it.
+var contextFunctions = []string{
+	"context.WithValue",
+	"context.WithCancel",
+	"context.WithCancelCause",
+	"context.WithDeadline",
+	"context.WithDeadlineCause",
+	"context.WithTimeout",
+	"context.WithTimeoutCause",
+	"context.WithoutCancel",
+}
+
+// ssaProgram returns the SSA form of the packages of the application, other than tests, building it the first
+// time it is needed. It is built with debug information, so that its values can be traced back to the variables
+// of the source code.
+func (m *InstrumentationManager) ssaProgram() *ssa.Program {
+	if m.program != nil {
+		return m.program
+	}
+	pkgs := []*packages.Package{}
+	for _, pkgID := range m.getSortedPackages() {
+		if state := m.packages[pkgID]; !util.IsTestPackage(state.pkg) {
+			pkgs = append(pkgs, state.pkg.Package)
+		}
+	}
+	m.program, _ = ssautil.Packages(pkgs, ssa.InstantiateGenerics|ssa.GlobalDebug)
+	m.program.Build()
+	return m.program
+}
+
+// transactionFlow tracks the values of the SSA form of the application that hold a transaction, or a context that
+// carries one, as they flow through variables, struct fields, function calls, return values and closures.
+type transactionFlow struct {
+	holds   map[ssa.Value]bool             // values that hold a transaction, and addresses that store one
+	fields  map[*types.Var]bool            // struct fields that store a transaction
+	results map[ssa.Value]map[int]bool     // the results of calls that return several values that hold one, by index
+	returns map[*ssa.Function]map[int]bool // the results of functions that return one, by index
+}
+
+// trackTransactions finds the transactions that already exist in the application, wherever they come from, and
+// records the variables, parameters and struct fields that hold them, or a context that carries them, in the
+// transaction cache. The functions that hold a transaction are in its scope: calls to them do not start a new
+// transaction, and those that get it from a parameter are traced with it rather than left unchanged. Functions
+// that get a transaction of their own are marked as traced.
+func (m *InstrumentationManager) trackTransactions() {
+	m.transactionScope = map[string]bool{}
+	m.transactionReceivers = map[string]bool{}
+
+	funcs := []*ssa.Function{}
+	for fn := range ssautil.AllFunctions(m.ssaProgram()) {
+		if fn.Pkg != nil && m.packages[fn.Pkg.Pkg.Path()] != nil && len(fn.Blocks) > 0 {
+			funcs = append(funcs, fn)
+		}
+	}
+	flow := &transactionFlow{
+		holds:   map[ssa.Value]bool{},
+		fields:  map[*types.Var]bool{},
+		results: map[ssa.Value]map[int]bool{},
+		returns: map[*ssa.Function]map[int]bool{},
+	}
+	for changed := true; changed; {
+		changed = false
+		for _, fn := range funcs {
+			for _, block := range fn.Blocks {
+				for _, instr := range block.Instrs {
+					changed = flow.step(instr) || changed
+				}
+			}
+		}
+	}
+
+	for _, fn := range funcs {
+		// function literals are in the scope of the transactions they hold, but the functions they are declared in
+		// are not, since they may run at any time
+		declared, ok := fn.Object().(*types.Func)
+		key := ""
+		if ok && fn.Parent() == nil {
+			key = declared.Origin().FullName()
+		}
+
+		holds := false
+		for _, param := range fn.Params {
+			if flow.hold(param) {
+				m.transactionCache.AddObject(param.Object())
+				holds = true
+				if key != "" {
+					m.transactionReceivers[key] = true
+				}
+			}
+		}
+		for _, block := range fn.Blocks {
+			for _, instr := range block.Instrs {
+				switch v := instr.(type) {
+				case *ssa.DebugRef:
+					if obj, ok := v.Object().(*types.Var); ok && flow.hold(v.X) {
+						m.transactionCache.AddObject(obj)
+					}
+				case ssa.Value:
+					holds = holds || flow.hold(v)
+				}
+			}
+		}
+		if holds && key != "" {
+			m.transactionScope[key] = true
+		}
+	}
+	for field := range flow.fields {
+		m.transactionCache.AddObject(field)
+	}
+
+	// functions that get a transaction of their own, rather than from a parameter, are already traced
+	for key := range m.transactionScope {
+		if m.transactionReceivers[key] {
+			continue
+		}
+		for _, state := range m.packages {
+			if traced, ok := state.tracedFuncs[key]; ok && traced.body.Name.Name != "main" {
+				traced.traced = true
+			}
+		}
+	}
+}
+
+// step propagates the transactions held by the operands of an instruction to the values it defines, and returns
+// true if any new value was found to hold one.
+func (f *transactionFlow) step(instr ssa.Instruction) bool {
+	switch v := instr.(type) {
+	case *ssa.Store:
+		if f.hold(v.Val) {
+			return f.mark(v.Addr)
+		}
+	case *ssa.UnOp:
+		if v.Op == token.MUL && f.hold(v.X) {
+			return f.mark(v)
+		}
+	case *ssa.Phi:
+		if slices.ContainsFunc(v.Edges, f.hold) {
+			return f.mark(v)
+		}
+	case *ssa.ChangeType:
+		if f.hold(v.X) {
+			return f.mark(v)
+		}
+	case *ssa.MakeInterface:
+		if f.hold(v.X) {
+			return f.mark(v)
+		}
+	case *ssa.ChangeInterface:
+		if f.hold(v.X) {
+			return f.mark(v)
+		}
+	case *ssa.TypeAssert:
+		if f.hold(v.X) && v.CommaOk {
+			return f.markResult(v, 0)
+		}
+		if f.hold(v.X) {
+			return f.mark(v)
+		}
+	case *ssa.Field:
+		if field := structField(v.X.Type(), v.Field); field != nil && f.fields[field] {
+			return f.mark(v)
+		}
+	case *ssa.FieldAddr:
+		if field := structField(v.X.Type(), v.Field); field != nil && f.fields[field] {
+			return f.mark(v)
+		}
+	case *ssa.Extract:
+		if f.results[v.Tuple][v.Index] {
+			return f.mark(v)
+		}
+	case *ssa.MakeClosure:
+		changed := false
+		if fn, ok := v.Fn.(*ssa.Function); ok {
+			for i, binding := range v.Bindings {
+				if i < len(fn.FreeVars) && f.hold(binding) {
+					changed = f.mark(fn.FreeVars[i]) || changed
+				}
+			}
+		}
+		return changed
+	case *ssa.Return:
+		changed := false
+		for i, result := range v.Results {
+			if f.hold(result) && !f.returns[v.Parent()][i] {
+				if f.returns[v.Parent()] == nil {
+					f.returns[v.Parent()] = map[int]bool{}
+				}
+				f.returns[v.Parent()][i] = true
+				changed = true
+			}
+		}
+		return changed
+	case ssa.CallInstruction:
+		return f.call(v)
+	}
+	return false
+}
+
+// call propagates the transactions passed to a function to its parameters, and those it returns to the results
+// of the call. Contexts made with newrelic.NewContext, or derived from a context that carries a transaction, carry
+// one as well.
+func (f *transactionFlow) call(instr ssa.CallInstruction) bool {
+	common := instr.Common()
+	callee := common.StaticCallee()
+	if callee == nil {
+		return false
+	}
+	result := instr.Value()
+	changed := false
+
+	name := ""
+	if fn, ok := callee.Object().(*types.Func); ok && fn.Pkg() != nil {
+		name = fn.Pkg().Path() + "." + fn.Name()
+	}
+	switch {
+	case result == nil:
+	case name == codegen.NewRelicAgentImportPath+".NewContext":
+		changed = f.mark(result)
+	case slices.Contains(contextFunctions, name) && len(common.Args) > 0 && f.hold(common.Args[0]):
+		if _, ok := result.Type().(*types.Tuple); ok {
+			changed = f.markResult(result, 0)
+		} else {
+			changed = f.mark(result)
+		}
+	}
+
+	if len(callee.Blocks) == 0 {
+		return changed
+	}
+	for i, arg := range common.Args {
+		if i < len(callee.Params) && f.hold(arg) {
+			changed = f.mark(callee.Params[i]) || changed
+		}
+	}
+	if result != nil {
+		for i := range f.returns[callee] {
+			if _, ok := result.Type().(*types.Tuple); ok {
+				changed = f.markResult(result, i) || changed
+			} else {
+				changed = f.mark(result) || changed
+			}
+		}
+	}
+	return changed
+}
+
+// hold returns true if a value holds a transaction or a context that carries one, or is the address of a
+// variable or struct field that stores one.
+func (f *transactionFlow) hold(v ssa.Value) bool {
+	return v != nil && (f.holds[v] || v.Type().String() == transactionType)
+}
+
+// mark records that a value holds a transaction, and the struct field it is the address of, if any. Returns true
+// if it was not known to hold one yet.
+func (f *transactionFlow) mark(v ssa.Value) bool {
+	if f.holds[v] {
+		return false
+	}
+	f.holds[v] = true
+	if addr, ok := v.(*ssa.FieldAddr); ok {
+		if field := structField(addr.X.Type(), addr.Field); field != nil {
+			f.fields[field] = true
+		}
+	}
+	return true
+}
+
+// markResult records that the result at an index of a value that returns several values holds a transaction.
+func (f *transactionFlow) markResult(v ssa.Value, index int) bool {
+	if f.results[v][index] {
+		return false
+	}
+	if f.results[v] == nil {
+		f.results[v] = map[int]bool{}
+	}
+	f.results[v][index] = true
+	return true
+}
+
+// structField returns the field at an index of a struct, or of the struct that a pointer points to.
+func structField(t types.Type, index int) *types.Var {
+	if ptr, ok := t.Underlying().(*types.Pointer); ok {
+		t = ptr.Elem()
+	}
+	st, ok := t.Underlying().(*types.Struct)
+	if !ok || index >= st.NumFields() {
+		return nil
+	}
+	return st.Field(index)
+}
+
+// isTransaction returns true if an identifier refers to a variable, parameter or struct field that holds an existing
+// transaction, or a context that carries one.
+func (m *InstrumentationManager) isTransaction(ident *dst.Ident) bool {
+	return m.transactionCache.CheckTransactionExists(ident) || m.transactionCache.CheckObjectExists(objectOf(m.getDecoratorPackage(), ident))
+}
+
+// inTransactionScope returns true if a function is called with an existing transaction, or gets one of its own.
+func (m *InstrumentationManager) inTransactionScope(inv *invocationInfo) bool {
+	return m.transactionCache.IsFunctionInTransactionScope(inv.functionName) || m.transactionScope[inv.key]
+}
+
+// receivesTransaction returns true if an existing transaction is passed to a function in one of its parameters.
+func (m *InstrumentationManager) receivesTransaction(inv *invocationInfo) bool {
+	return m.transactionReceivers[inv.key]
+}
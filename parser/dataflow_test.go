@@ -0,0 +1,106 @@
+package parser
+
+import (
+	"fmt"
+	"go/types"
+	"testing"
+
+	"github.com/dave/dst"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrackTransactions(t *testing.T) {
+	code := `package main
+
+import (
+	"context"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+type key struct{}
+
+type worker struct {
+	txn *newrelic.Transaction
+	ctx context.Context
+}
+
+func start(app *newrelic.Application) *newrelic.Transaction {
+	return app.StartTransaction("start")
+}
+
+func fromField(w *worker) {
+	process(w.ctx)
+}
+
+func process(ctx context.Context) {
+	work()
+}
+
+func closure(txn *newrelic.Transaction) {
+	ctx := newrelic.NewContext(context.Background(), txn)
+	func() {
+		process(ctx)
+	}()
+}
+
+func withValue(parent context.Context) {
+	ctx := context.WithValue(parent, key{}, "value")
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	process(ctx)
+}
+
+func work() {}
+
+func main() {
+	app, _ := newrelic.NewApplication()
+	txn := start(app)
+	w := &worker{txn: txn, ctx: newrelic.NewContext(context.Background(), txn)}
+	fromField(w)
+	withValue(w.ctx)
+	work()
+}
+`
+	defer PanicRecovery(t)
+	uuid, _ := Pseudo_uuid()
+	testDir := fmt.Sprintf("tmp_%s", uuid)
+	defer CleanTestApp(t, testDir)
+
+	manager := TestInstrumentationManager(t, code, testDir)
+	pkg := manager.getDecoratorPackage()
+
+	decls := map[string]*dst.FuncDecl{}
+	for _, decl := range pkg.Syntax[0].Decls {
+		if funcDecl, ok := decl.(*dst.FuncDecl); ok {
+			manager.createFunctionDeclaration(funcDecl)
+			decls[funcDecl.Name.Name] = funcDecl
+		}
+	}
+	manager.trackTransactions()
+
+	key := func(name string) string {
+		return pkg.PkgPath + "." + name
+	}
+	for _, name := range []string{"start", "fromField", "process", "closure", "withValue", "main"} {
+		assert.True(t, manager.transactionScope[key(name)], "%s should be in the scope of a transaction", name)
+	}
+	assert.False(t, manager.transactionScope[key("work")], "work does not hold a transaction")
+
+	// the transaction reaches process through a struct field, a closure, and a chain of derived contexts
+	assert.True(t, manager.transactionReceivers[key("process")])
+	assert.True(t, manager.transactionReceivers[key("withValue")])
+	assert.False(t, manager.transactionReceivers[key("start")])
+
+	// functions that get a transaction of their own are already traced
+	assert.True(t, manager.packages[manager.currentPackage].tracedFuncs[key("start")].traced)
+	assert.False(t, manager.packages[manager.currentPackage].tracedFuncs[key("process")].traced)
+
+	// the parameters and variables that hold the transaction are recorded
+	processCtx := objectOf(pkg, decls["process"].Type.Params.List[0].Names[0])
+	assert.True(t, manager.transactionCache.CheckObjectExists(processCtx))
+	mainTxn, ok := objectOf(pkg, decls["main"].Body.List[1].(*dst.AssignStmt).Lhs[0].(*dst.Ident)).(*types.Var)
+	if assert.True(t, ok) {
+		assert.True(t, manager.transactionCache.CheckObjectExists(mainTxn))
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/dave/dst/dstutil"
 	"github.com/newrelic/go-easy-instrumentation/internal/codegen"
 	"github.com/newrelic/go-easy-instrumentation/internal/comment"
+	"github.com/newrelic/go-easy-instrumentation/internal/report"
 	"github.com/newrelic/go-easy-instrumentation/internal/util"
 	"github.com/newrelic/go-easy-instrumentation/parser/tracestate"
 )
@@ -115,6 +116,7 @@ func NoticeError(manager *InstrumentationManager, stmt dst.Stmt, c *dstutil.Curs
 				}
 
 				comment.Debug(pkg, stmt, "Capturing error return value for NoticeError")
+				report.Action(pkg, stmt, report.CoreIntegration, report.KindNoticeError, "captured the error returned by "+util.WriteExpr(call.Fun, pkg))
 
 				// add an empty line beore the return statement for readability
 				nodeVal.Decorations().Before = dst.EmptyLine
@@ -135,6 +137,7 @@ func NoticeError(manager *InstrumentationManager, stmt dst.Stmt, c *dstutil.Curs
 			if cachedExpr != nil && util.AssertExpressionEqual(result, cachedExpr) {
 				manager.ErrorCache().Clear()
 				comment.Debug(pkg, stmt, "Injecting error nil check with NoticeError before return")
+				report.Action(pkg, stmt, report.CoreIntegration, report.KindNoticeError, fmt.Sprintf("added NoticeError for %s before return", util.WriteExpr(cachedExpr, pkg)))
 				capture := codegen.IfErrorNotNilNoticeError(cachedExpr, tracing.TransactionVariable())
 				capture.Decs.Before = dst.EmptyLine
 				c.InsertBefore(capture)
@@ -153,6 +156,7 @@ func NoticeError(manager *InstrumentationManager, stmt dst.Stmt, c *dstutil.Curs
 					stmtBlock = nodeVal.Body.List[0]
 				}
 				comment.Debug(pkg, stmt, "Injecting NoticeError into error handling block")
+				report.Action(pkg, stmt, report.CoreIntegration, report.KindNoticeError, fmt.Sprintf("added NoticeError for %s to error handling block", util.WriteExpr(errExpr, pkg)))
 				nodeVal.Body.List = append([]dst.Stmt{codegen.NoticeError(errExpr, tracing.TransactionVariable(), stmtBlock)}, nodeVal.Body.List...)
 				manager.ErrorCache().Clear()
 				return true
@@ -178,6 +182,7 @@ func NoticeError(manager *InstrumentationManager, stmt dst.Stmt, c *dstutil.Curs
 		if cachedErrExpr != nil {
 			stmt := manager.ErrorCache().GetStatement()
 			comment.Warn(pkg, stmt, stmt, fmt.Sprintf("Unchecked Error \"%s\", please consult New Relic documentation on error capture", util.WriteExpr(cachedErrExpr, pkg)))
+			report.Warning(pkg, stmt, report.CoreIntegration, report.CodeUncheckedError, fmt.Sprintf("unchecked error %q can not be captured", util.WriteExpr(cachedErrExpr, pkg)))
 			manager.ErrorCache().Clear()
 		}
 
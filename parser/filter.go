@@ -0,0 +1,73 @@
+package parser
+
+import (
+	"path/filepath"
+
+	"github.com/dave/dst"
+	"github.com/newrelic/go-easy-instrumentation/internal/filter"
+)
+
+// SetFilter sets the include and exclude patterns that decide which packages, files and functions get instrumented.
+// Excluded code is still loaded and type checked, but is never traced or modified.
+func (m *InstrumentationManager) SetFilter(f *filter.Filter) {
+	m.filter = f
+}
+
+// relativeFilePath returns the path of a file relative to the root of the user's application.
+// If the path can not be made relative, it is returned unchanged.
+func (m *InstrumentationManager) relativeFilePath(path string) string {
+	absAppPath, err := filepath.Abs(m.userAppPath)
+	if err != nil {
+		return path
+	}
+	rel, err := filepath.Rel(absAppPath, path)
+	if err != nil {
+		return path
+	}
+	return filepath.ToSlash(rel)
+}
+
+// includeFile returns false if the file, or the package it belongs to, is excluded from instrumentation.
+func (m *InstrumentationManager) includeFile(state *packageState, file *dst.File) bool {
+	if m.filter == nil {
+		return true
+	}
+	return m.filter.Package(state.pkg.PkgPath) && m.filter.File(m.relativeFilePath(state.pkg.Decorator.Filenames[file]))
+}
+
+// includeFunction returns true if the function declared in the given file should be instrumented.
+func (m *InstrumentationManager) includeFunction(state *packageState, file *dst.File, decl *dst.FuncDecl) bool {
+	if m.filter == nil {
+		return true
+	}
+	return m.filter.Function(state.pkg.PkgPath, state.pkg.Name, m.relativeFilePath(state.pkg.Decorator.Filenames[file]), functionName(decl))
+}
+
+// functionName returns the name that function patterns are matched against: the name of a function, or
+// the receiver type and name of a method, e.g. "Server.Health".
+func functionName(decl *dst.FuncDecl) string {
+	if decl.Recv == nil || len(decl.Recv.List) == 0 {
+		return decl.Name.Name
+	}
+
+	recv := decl.Recv.List[0].Type
+	for {
+		switch t := recv.(type) {
+		case *dst.StarExpr:
+			recv = t.X
+			continue
+		case *dst.IndexExpr:
+			recv = t.X
+			continue
+		case *dst.IndexListExpr:
+			recv = t.X
+			continue
+		case *dst.ParenExpr:
+			recv = t.X
+			continue
+		case *dst.Ident:
+			return t.Name + "." + decl.Name.Name
+		}
+		return decl.Name.Name
+	}
+}
@@ -0,0 +1,151 @@
+package parser
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/dave/dst"
+	"github.com/dave/dst/decorator"
+	"github.com/dave/dst/dstutil"
+	"github.com/newrelic/go-easy-instrumentation/internal/filter"
+)
+
+const filterTestApp = `package main
+
+type Server struct{}
+
+func (s *Server) Health() {}
+
+func Legacy() {}
+
+func handler() {}
+
+func main() {
+	s := &Server{}
+	s.Health()
+	Legacy()
+	handler()
+}
+`
+
+func filterTestManager(t *testing.T, include, exclude []string) *InstrumentationManager {
+	id, err := Pseudo_uuid()
+	if err != nil {
+		t.Fatal(err)
+	}
+	testDir := fmt.Sprintf("tmp_%s", id)
+	t.Cleanup(func() { CleanTestApp(t, testDir) })
+
+	manager := TestInstrumentationManager(t, filterTestApp, testDir)
+	f, err := filter.New(include, exclude)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manager.SetFilter(f)
+	return manager
+}
+
+func TestFilteredInstrumentation(t *testing.T) {
+	tests := []struct {
+		name    string
+		include []string
+		exclude []string
+		want    []string
+	}{
+		{
+			name: "no patterns",
+			want: []string{"Health", "Legacy", "handler", "main"},
+		},
+		{
+			name:    "excluded function and method",
+			exclude: []string{"*.Legacy", "*.Server.*"},
+			want:    []string{"handler", "main"},
+		},
+		{
+			name:    "included function",
+			include: []string{"*.main"},
+			want:    []string{"main"},
+		},
+		{
+			name:    "excluded file",
+			exclude: []string{"app.go"},
+			want:    []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manager := filterTestManager(t, tt.include, tt.exclude)
+			if err := tracePackageFunctionCalls(manager); err != nil {
+				t.Fatal(err)
+			}
+
+			traced := []string{}
+			for _, fn := range manager.packages[manager.currentPackage].tracedFuncs {
+				traced = append(traced, fn.body.Name.Name)
+			}
+			slices.Sort(traced)
+			if !slices.Equal(traced, tt.want) {
+				t.Errorf("expected traced functions %v, got %v", tt.want, traced)
+			}
+
+			instrumented := []string{}
+			err := instrumentPackages(manager, func(manager *InstrumentationManager, c *dstutil.Cursor) {
+				if fn, ok := c.Node().(*dst.FuncDecl); ok {
+					instrumented = append(instrumented, fn.Name.Name)
+				}
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			slices.Sort(instrumented)
+			if !slices.Equal(instrumented, tt.want) {
+				t.Errorf("expected instrumented functions %v, got %v", tt.want, instrumented)
+			}
+		})
+	}
+}
+
+func TestFilteredWriteDiff(t *testing.T) {
+	manager := filterTestManager(t, nil, []string{"app.go"})
+	main := manager.getDecoratorPackage().Syntax[0].Decls[len(manager.getDecoratorPackage().Syntax[0].Decls)-1].(*dst.FuncDecl)
+	main.Body.List = append(main.Body.List, &dst.ExprStmt{X: &dst.CallExpr{Fun: dst.NewIdent("handler")}})
+
+	if err := manager.restoreChanges(); err != nil {
+		t.Fatal(err)
+	}
+	if len(manager.changes) != 0 {
+		t.Errorf("expected excluded files to be left out of the diff, got %d changes", len(manager.changes))
+	}
+}
+
+func TestFunctionName(t *testing.T) {
+	code := `package main
+
+type Server struct{}
+type List[T any] struct{}
+type Pair[K, V any] struct{}
+
+func main() {}
+func (s Server) Value() {}
+func (s *Server) Pointer() {}
+func (l *List[T]) Generic() {}
+func (p Pair[K, V]) Generics() {}
+`
+	file, err := decorator.Parse(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"main", "Server.Value", "Server.Pointer", "List.Generic", "Pair.Generics"}
+	got := []string{}
+	for _, decl := range file.Decls {
+		if fn, ok := decl.(*dst.FuncDecl); ok {
+			got = append(got, functionName(fn))
+		}
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
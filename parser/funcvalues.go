@@ -0,0 +1,146 @@
+package parser
+
+import (
+	"go/types"
+	"slices"
+
+	"github.com/dave/dst"
+	"github.com/dave/dst/decorator"
+	"github.com/dave/dst/dstutil"
+	"github.com/newrelic/go-easy-instrumentation/internal/util"
+)
+
+// asyncCallbacks are the functions and methods that run the function passed to them in a new goroutine.
+var asyncCallbacks = []string{
+	"(*sync.WaitGroup).Go",
+	"(*golang.org/x/sync/errgroup.Group).Go",
+	"(*golang.org/x/sync/errgroup.Group).TryGo",
+}
+
+// requestTypes are the types of the parameters of request handlers, which get their transaction from the request
+// they serve rather than from the function they are declared in.
+var requestTypes = []string{
+	"*net/http.Request",
+	"*github.com/gin-gonic/gin.Context",
+	"github.com/labstack/echo.Context",
+	"github.com/labstack/echo/v4.Context",
+	"*github.com/valyala/fasthttp.RequestCtx",
+	"*github.com/gofiber/fiber/v2.Ctx",
+}
+
+// recordFunctionValues records the functions and methods declared in the application that are used as values
+// rather than called, such as callbacks passed to a function, handlers stored in a struct field or a map, and method
+// values like s.handle. Their signatures must match the function types they are used as, so tracing can only be
+// passed to them in a context or transaction parameter they already have. Tests are never instrumented, so the
+// functions they use as values are not recorded.
+func (m *InstrumentationManager) recordFunctionValues() {
+	m.functionValues = map[string]bool{}
+	for _, state := range m.packages {
+		if util.IsTestPackage(state.pkg) {
+			continue
+		}
+		for _, file := range state.pkg.Syntax {
+			for _, fn := range functionValues(state.pkg, file, true) {
+				m.functionValues[fn.Origin().FullName()] = true
+			}
+		}
+	}
+}
+
+// functionValues returns the functions and methods that the identifiers in a node refer to as values rather than
+// calling them. The statements in nested blocks are only searched if nested is true.
+func functionValues(pkg *decorator.Package, node dst.Node, nested bool) []*types.Func {
+	funcs := []*types.Func{}
+	called := map[*dst.Ident]bool{}
+	dst.Inspect(node, func(n dst.Node) bool {
+		switch v := n.(type) {
+		case *dst.BlockStmt:
+			return nested || v == node
+		case *dst.FuncDecl:
+			called[v.Name] = true
+		case *dst.CallExpr:
+			if ident := util.FunctionIdent(v.Fun); ident != nil {
+				called[ident] = true
+			}
+		case *dst.Ident:
+			if called[v] {
+				return true
+			}
+			if fn, ok := objectOf(pkg, v).(*types.Func); ok && fn.Pkg() != nil && !slices.Contains(funcs, fn) {
+				funcs = append(funcs, fn)
+			}
+		}
+		return true
+	})
+	return funcs
+}
+
+// findFunctionValueInfo returns the functions and methods declared in the application that a statement uses as
+// values, such as `retry(ctx, s.fetch)`. These can be traced, since the transaction is passed to them in the
+// context they are called with, but only if they already take one.
+func (m *InstrumentationManager) findFunctionValueInfo(stmt dst.Stmt) []*invocationInfo {
+	invInfo := []*invocationInfo{}
+	for _, fn := range functionValues(m.getDecoratorPackage(), stmt, false) {
+		path := fn.Pkg().Path()
+		key := fn.Origin().FullName()
+		if state, ok := m.packages[path]; ok && state.tracedFuncs[key] != nil {
+			invInfo = append(invInfo, &invocationInfo{
+				functionName: fn.Name(),
+				key:          key,
+				packageName:  path,
+				decl:         state.tracedFuncs[key].body,
+				value:        true,
+			})
+		}
+	}
+	return invInfo
+}
+
+// isFunctionLiteralValue returns true if the function literal at the cursor is used as a value, such as a callback
+// passed to a function or a handler stored in a struct field or a map. Literals that are called where they are
+// declared or returned, and request handlers, which get their transaction from the request, are not. A callback
+// usually runs before the function it is passed to returns, but a stored literal may run once the transaction of
+// the function it is declared in has ended; see isStoredFunctionLiteral.
+func (m *InstrumentationManager) isFunctionLiteralValue(c *dstutil.Cursor, lit *dst.FuncLit) bool {
+	if c.Name() == "Fun" {
+		return false
+	}
+	if _, ok := c.Parent().(*dst.ReturnStmt); ok {
+		return false
+	}
+	pkg := m.getDecoratorPackage()
+	for _, param := range lit.Type.Params.List {
+		if t := typeOf(pkg, param.Type); t != nil && slices.Contains(requestTypes, t.String()) {
+			return false
+		}
+	}
+	return true
+}
+
+// isStoredFunctionLiteral returns true if the function literal at the cursor is an element of a composite literal,
+// such as a handler stored in a struct field, a map or a slice. It can be called after the function it is declared
+// in returns, when the transaction it captures has already ended.
+func isStoredFunctionLiteral(c *dstutil.Cursor) bool {
+	switch c.Parent().(type) {
+	case *dst.CompositeLit:
+		return c.Name() == "Elts"
+	case *dst.KeyValueExpr:
+		return c.Name() == "Value"
+	}
+	return false
+}
+
+// runsInGoroutine returns true if the function literal at the cursor is passed to a function that runs it in a
+// new goroutine, such as errgroup.Group.Go.
+func (m *InstrumentationManager) runsInGoroutine(c *dstutil.Cursor) bool {
+	call, ok := c.Parent().(*dst.CallExpr)
+	if !ok || c.Name() != "Args" {
+		return false
+	}
+	return slices.Contains(asyncCallbacks, functionKey(m.getDecoratorPackage(), call.Fun))
+}
+
+// isFunctionValue returns true if a function is used as a value anywhere in the application.
+func (m *InstrumentationManager) isFunctionValue(inv *invocationInfo) bool {
+	return inv.value || m.functionValues[inv.key]
+}
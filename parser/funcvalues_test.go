@@ -0,0 +1,161 @@
+package parser
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dave/dst"
+	"github.com/dave/dst/decorator"
+	"github.com/newrelic/go-easy-instrumentation/parser/tracestate"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/tools/go/packages"
+)
+
+func TestTraceFunction_FunctionValues(t *testing.T) {
+	code := `package main
+
+import (
+	"context"
+	"net/http"
+	"sync"
+)
+
+type server struct {
+	handlers map[string]func() error
+}
+
+func retry(f func() error) error {
+	return f()
+}
+
+func work() error {
+	return nil
+}
+
+func (s *server) fetch(ctx context.Context) error {
+	return nil
+}
+
+func (s *server) handle() error {
+	return nil
+}
+
+func withContext(ctx context.Context, f func(context.Context) error) error {
+	return f(ctx)
+}
+
+func process(s *server) {
+	retry(func() error {
+		return work()
+	})
+	var wg sync.WaitGroup
+	wg.Go(func() {
+		work()
+	})
+	wg.Wait()
+	s.handlers = map[string]func() error{
+		"work": func() error {
+			return work()
+		},
+	}
+	withContext(context.Background(), s.fetch)
+	retry(s.handle)
+	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {})
+}
+
+func main() {
+	process(&server{})
+}
+`
+	defer PanicRecovery(t)
+	uuid, _ := Pseudo_uuid()
+	testDir := fmt.Sprintf("tmp_%s", uuid)
+	defer CleanTestApp(t, testDir)
+
+	manager := TestInstrumentationManager(t, code, testDir)
+	pkg := manager.getDecoratorPackage()
+
+	var mainFunc *dst.FuncDecl
+	for _, decl := range pkg.Syntax[0].Decls {
+		if funcDecl, ok := decl.(*dst.FuncDecl); ok {
+			manager.createFunctionDeclaration(funcDecl)
+			if funcDecl.Name.Name == "main" {
+				mainFunc = funcDecl
+			}
+		}
+	}
+	manager.recordFunctionValues()
+	TraceFunction(manager, mainFunc, tracestate.Main("app"))
+
+	buf := bytes.NewBuffer([]byte{})
+	if err := decorator.NewRestorerWithImports(testDir, createTestResolver(testDir)).Fprint(buf, pkg.Syntax[0]); err != nil {
+		t.Fatal(err)
+	}
+	got := buf.String()
+
+	for _, expect := range []string{
+		// literals passed as arguments and stored in maps capture the transaction
+		`defer nrTxn.StartSegment("function literal").End()`,
+		"return work(nrTxn)",
+		// stored literals may run once the transaction has ended
+		"// NR WARN: this function literal uses the transaction of the function it is declared in, which may have ended when it is called",
+		// literals run in a new goroutine copy it first
+		"nrTxn := nrTxn.NewGoroutine()",
+		`defer nrTxn.StartSegment("async function literal").End()`,
+		// method values that take a context are traced, and keep their signatures
+		"func (s *server) fetch(ctx context.Context) error {",
+		`defer nrTxn.StartSegment("fetch").End()`,
+		"// NR WARN: handle was not traced, since a transaction can not be passed to it without changing its signature",
+		"func (s *server) handle() error {",
+		// request handlers get their transaction from the request
+		`http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {})`,
+	} {
+		assert.Contains(t, got, expect)
+	}
+}
+
+// testManagerWithTests creates a manager for an application whose package has a test file, loaded along with its
+// test variants.
+func testManagerWithTests(t *testing.T, code, testCode string) *InstrumentationManager {
+	uuid, _ := Pseudo_uuid()
+	testDir := fmt.Sprintf("tmp_%s", uuid)
+	t.Cleanup(func() { CleanTestApp(t, testDir) })
+	if _, err := CreateTestApp(t, testDir, "app.go", code); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(testDir, "app_test.go"), []byte(testCode), 0644); err != nil {
+		t.Fatal(err)
+	}
+	pkgs, err := decorator.Load(&packages.Config{Dir: testDir, Mode: packages.LoadSyntax, Tests: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewInstrumentationManager(pkgs, "", "NewRelicAgent", filepath.Join(testDir, "new-relic-instrumentation.diff"), testDir)
+}
+
+func TestRecordFunctionValues_TestFiles(t *testing.T) {
+	code := `package main
+
+func handle() {}
+
+func main() {
+	handle()
+}
+`
+	testCode := `package main
+
+func run(f func()) {
+	f()
+}
+
+func runHandle() {
+	run(handle)
+}
+`
+	manager := testManagerWithTests(t, code, testCode)
+	manager.recordFunctionValues()
+	assert.Empty(t, manager.functionValues, "a function used as a value only in tests can be traced")
+}
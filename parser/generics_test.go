@@ -0,0 +1,91 @@
+package parser
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/dave/dst"
+	"github.com/dave/dst/decorator"
+	"github.com/newrelic/go-easy-instrumentation/parser/tracestate"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTraceFunction_Generics(t *testing.T) {
+	code := `package main
+
+import "context"
+
+type repo[T any] struct {
+	items []T
+}
+
+func (r *repo[T]) find(ctx context.Context, match func(T) bool) (T, bool) {
+	var zero T
+	for _, item := range r.items {
+		if match(item) {
+			return item, true
+		}
+	}
+	return zero, false
+}
+
+func mapItems[T, U any](items []T, f func(T) U) []U {
+	out := make([]U, 0, len(items))
+	for _, item := range items {
+		out = append(out, f(item))
+	}
+	return out
+}
+
+func first[T any](v T) T {
+	return v
+}
+
+func main() {
+	r := &repo[string]{items: []string{"a", "b"}}
+	r.find(context.Background(), func(s string) bool { return s == "b" })
+	mapItems[string, int](r.items, func(s string) int { return len(s) })
+	first[context.Context](context.Background())
+}
+`
+	defer PanicRecovery(t)
+	uuid, _ := Pseudo_uuid()
+	testDir := fmt.Sprintf("tmp_%s", uuid)
+	defer CleanTestApp(t, testDir)
+
+	manager := TestInstrumentationManager(t, code, testDir)
+	pkg := manager.getDecoratorPackage()
+
+	var mainFunc *dst.FuncDecl
+	for _, decl := range pkg.Syntax[0].Decls {
+		if funcDecl, ok := decl.(*dst.FuncDecl); ok {
+			manager.createFunctionDeclaration(funcDecl)
+			if funcDecl.Name.Name == "main" {
+				mainFunc = funcDecl
+			}
+		}
+	}
+	TraceFunction(manager, mainFunc, tracestate.Main("app"))
+
+	buf := bytes.NewBuffer([]byte{})
+	if err := decorator.NewRestorerWithImports(testDir, createTestResolver(testDir)).Fprint(buf, pkg.Syntax[0]); err != nil {
+		t.Fatal(err)
+	}
+	got := buf.String()
+
+	for _, expect := range []string{
+		// methods of generic types are traced through the context they take
+		`r.find(newrelic.NewContext(context.Background(), nrTxn), func(s string) bool { return s == "b" })`,
+		`defer nrTxn.StartSegment("find").End()`,
+		// instantiated generic functions get a transaction parameter
+		"func mapItems[T, U any](items []T, f func(T) U, nrTxn *newrelic.Transaction) []U {",
+		"mapItems[string, int](r.items, func(s string) int { return len(s) }, nrTxn)",
+		`defer nrTxn.StartSegment("mapItems").End()`,
+		// a context can not be passed for a type parameter, even if it is instantiated with one
+		"func first[T any](v T, nrTxn *newrelic.Transaction) T {",
+		"first[context.Context](context.Background(), nrTxn)",
+	} {
+		assert.Contains(t, got, expect)
+	}
+}
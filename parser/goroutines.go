@@ -0,0 +1,278 @@
+package parser
+
+import (
+	"go/token"
+	"go/types"
+	"slices"
+
+	"github.com/dave/dst"
+	"github.com/dave/dst/dstutil"
+	"github.com/newrelic/go-easy-instrumentation/internal/report"
+	"github.com/newrelic/go-easy-instrumentation/internal/util"
+	"github.com/newrelic/go-easy-instrumentation/parser/tracestate"
+)
+
+// joinTypes are the types of the values that a goroutine can be waited for with, other than channels.
+var joinTypes = []string{
+	"sync.WaitGroup",
+	"golang.org/x/sync/errgroup.Group",
+}
+
+// recordDirectCalls records the functions and methods declared in the application that are called other than
+// to start a goroutine. A function that is only started in goroutines from main can own the transaction of the
+// goroutine, and end it when it returns. Calls made by tests do not count, since tests are never instrumented.
+func (m *InstrumentationManager) recordDirectCalls() {
+	m.directCalls = map[string]bool{}
+	for _, state := range m.packages {
+		if util.IsTestPackage(state.pkg) {
+			continue
+		}
+		for _, file := range state.pkg.Syntax {
+			goCalls := map[*dst.CallExpr]bool{}
+			dst.Inspect(file, func(n dst.Node) bool {
+				switch v := n.(type) {
+				case *dst.GoStmt:
+					goCalls[v.Call] = true
+				case *dst.CallExpr:
+					if key := functionKey(state.pkg, v.Fun); key != "" && !goCalls[v] {
+						m.directCalls[key] = true
+					}
+				}
+				return true
+			})
+		}
+	}
+}
+
+// wrapJoinedGoroutines wraps the goroutines started in the main function that main waits for, with a
+// sync.WaitGroup, an errgroup.Group, or by receiving from a channel, in a transaction that spans from the statement
+// that starts them to the statement that joins them. The goroutines are passed a copy of the transaction made with
+// NewGoroutine when they are traced.
+func (m *InstrumentationManager) wrapJoinedGoroutines(decl *dst.FuncDecl, tracing *tracestate.State) {
+	m.wrapJoinedGoroutinesInBlock(decl.Body, decl.Body, tracing)
+}
+
+func (m *InstrumentationManager) wrapJoinedGoroutinesInBlock(body, block *dst.BlockStmt, tracing *tracestate.State) {
+	for i := 0; i < len(block.List); i++ {
+		if m.WasInstrumented(block.List[i]) {
+			continue
+		}
+
+		joined := false
+		for _, goStmt := range goStatements(block.List[i]) {
+			if !m.isTraceableGoroutine(goStmt, tracing) {
+				continue
+			}
+			join := m.findJoin(goStmt, block.List[i+1:])
+			if join < 0 {
+				continue
+			}
+			last := i + 1 + join
+			name := goroutineName(goStmt)
+			if tracing.WrapGoroutinesWithTransaction(block, i, last, name, block == body) {
+				report.Action(m.getDecoratorPackage(), goStmt, report.CoreIntegration, report.KindTransaction, "started transaction "+name+" for goroutines joined in main")
+				// continue after the statement that ends the transaction
+				i = last + 2
+				joined = true
+				break
+			}
+		}
+
+		// the goroutines may be joined in a block nested in the statement
+		if !joined {
+			for _, nested := range nestedBlocks(block.List[i]) {
+				m.wrapJoinedGoroutinesInBlock(body, nested, tracing)
+			}
+		}
+	}
+}
+
+// goStatements returns the go statements in a statement, other than those in function literals.
+func goStatements(stmt dst.Stmt) []*dst.GoStmt {
+	goStmts := []*dst.GoStmt{}
+	dst.Inspect(stmt, func(n dst.Node) bool {
+		switch v := n.(type) {
+		case *dst.FuncLit:
+			return false
+		case *dst.GoStmt:
+			goStmts = append(goStmts, v)
+			return false
+		}
+		return true
+	})
+	return goStmts
+}
+
+// nestedBlocks returns the outermost blocks nested in a statement, other than the bodies of function literals.
+func nestedBlocks(stmt dst.Stmt) []*dst.BlockStmt {
+	blocks := []*dst.BlockStmt{}
+	dst.Inspect(stmt, func(n dst.Node) bool {
+		switch v := n.(type) {
+		case *dst.FuncLit:
+			return false
+		case *dst.BlockStmt:
+			blocks = append(blocks, v)
+			return false
+		}
+		return true
+	})
+	return blocks
+}
+
+// isTraceableGoroutine returns true if a goroutine runs a function declared in the application, or a function
+// literal that calls one.
+func (m *InstrumentationManager) isTraceableGoroutine(goStmt *dst.GoStmt, tracing *tracestate.State) bool {
+	if lit, ok := goStmt.Call.Fun.(*dst.FuncLit); ok {
+		return m.callsApplication(lit)
+	}
+	for _, inv := range m.findInvocationInfo(goStmt.Call, tracing) {
+		if inv.call == goStmt.Call {
+			return true
+		}
+	}
+	return false
+}
+
+// callsApplication returns true if a node calls a function or method declared in the application.
+func (m *InstrumentationManager) callsApplication(node dst.Node) bool {
+	pkg := m.getDecoratorPackage()
+	found := false
+	dst.Inspect(node, func(n dst.Node) bool {
+		if call, ok := n.(*dst.CallExpr); ok {
+			if ident := util.FunctionIdent(call.Fun); ident != nil {
+				if fn, ok := objectOf(pkg, ident).(*types.Func); ok && fn.Pkg() != nil {
+					state, ok := m.packages[fn.Pkg().Path()]
+					found = ok && state.tracedFuncs[fn.Origin().FullName()] != nil
+				}
+			}
+		}
+		return !found
+	})
+	return found
+}
+
+// goroutineName returns the name of the transaction started for a goroutine: the name of the function it runs.
+func goroutineName(goStmt *dst.GoStmt) string {
+	if ident := util.FunctionIdent(goStmt.Call.Fun); ident != nil {
+		return ident.Name
+	}
+	return "function literal"
+}
+
+// findJoin returns the index of the first statement that waits for a goroutine to finish, or -1 if none of them
+// does. A statement waits for a goroutine if it calls Wait on a sync.WaitGroup or errgroup.Group that the
+// goroutine uses, or receives from a channel that the goroutine uses.
+func (m *InstrumentationManager) findJoin(goStmt *dst.GoStmt, stmts []dst.Stmt) int {
+	pkg := m.getDecoratorPackage()
+	objects := map[types.Object]bool{}
+	dst.Inspect(goStmt, func(n dst.Node) bool {
+		if ident, ok := n.(*dst.Ident); ok {
+			if v, ok := objectOf(pkg, ident).(*types.Var); ok && isJoinType(v.Type()) {
+				objects[v] = true
+			}
+		}
+		return true
+	})
+	if len(objects) == 0 {
+		return -1
+	}
+
+	joins := func(expr dst.Expr) bool {
+		ident, ok := expr.(*dst.Ident)
+		return ok && objects[objectOf(pkg, ident)]
+	}
+	for i, stmt := range stmts {
+		found := false
+		dst.Inspect(stmt, func(n dst.Node) bool {
+			switch v := n.(type) {
+			case *dst.FuncLit:
+				return false
+			case *dst.CallExpr:
+				sel, ok := v.Fun.(*dst.SelectorExpr)
+				found = found || ok && sel.Sel.Name == "Wait" && joins(sel.X)
+			case *dst.UnaryExpr:
+				found = found || v.Op == token.ARROW && joins(v.X)
+			case *dst.RangeStmt:
+				found = found || joins(v.X)
+			}
+			return !found
+		})
+		if found {
+			return i
+		}
+	}
+	return -1
+}
+
+// isJoinType returns true if a goroutine can be waited for with a value of this type.
+func isJoinType(t types.Type) bool {
+	if ptr, ok := t.(*types.Pointer); ok {
+		t = ptr.Elem()
+	}
+	if _, ok := t.Underlying().(*types.Chan); ok {
+		return true
+	}
+	named, ok := t.(*types.Named)
+	if !ok || named.Obj().Pkg() == nil {
+		return false
+	}
+	return slices.Contains(joinTypes, named.Obj().Pkg().Path()+"."+named.Obj().Name())
+}
+
+// noBackgroundTransaction is the reason a goroutine is not traced when no transaction can be started for it, since
+// the agent is not available in main.
+const noBackgroundTransaction = "no transaction can be started for it in main"
+
+// traceBackgroundGoroutine traces a goroutine started in the main function that main never waits for, such as a
+// long-lived worker, as a background transaction: the transaction is started in main, passed to the goroutine, and
+// ended when the function it runs returns. A function declared in the application can only own the transaction if
+// it is never called other than to start a goroutine, and a function literal is only traced if it calls the
+// application. If the goroutine can not be traced this way, the reason is returned, so that it can be reported.
+// Otherwise, the result is an empty string.
+func traceBackgroundGoroutine(manager *InstrumentationManager, c *dstutil.Cursor, goStmt *dst.GoStmt, tracing *tracestate.State, topLevel bool) string {
+	pkg := manager.getDecoratorPackage()
+	if lit, ok := goStmt.Call.Fun.(*dst.FuncLit); ok {
+		// a goroutine that does not call the application, such as a server or a signal handler, is left as it is
+		if !manager.callsApplication(lit) {
+			return ""
+		}
+		if !tracing.StartBackgroundTransaction(c, "function literal", topLevel) {
+			return noBackgroundTransaction
+		}
+		report.Action(pkg, goStmt, report.CoreIntegration, report.KindTransaction, "started background transaction function literal")
+		childState, tracingImport := tracing.AddToCall(pkg, goStmt.Call, false)
+		manager.addImport(tracingImport)
+		childState.EndTransaction()
+		newLit, _ := TraceFunction(manager, lit, childState)
+		goStmt.Call.Fun = newLit.(*dst.FuncLit)
+		return ""
+	}
+
+	rootPkg := manager.currentPackage
+	for _, inv := range manager.findInvocationInfo(goStmt.Call, tracing) {
+		if inv.call != goStmt.Call {
+			continue
+		}
+		switch {
+		case manager.directCalls[inv.key]:
+			return "the function it runs is also called elsewhere"
+		case manager.isFunctionValue(inv):
+			return "the function it runs is also used as a value"
+		case !manager.shouldInstrumentFunction(inv):
+			return "the function it runs is already traced"
+		case !manager.canTrace(inv):
+			return "a transaction can not be passed to the function it runs without changing its signature"
+		}
+		if !tracing.StartBackgroundTransaction(c, inv.functionName, topLevel) {
+			return noBackgroundTransaction
+		}
+		report.Action(pkg, goStmt, report.CoreIntegration, report.KindTransaction, "started background transaction "+inv.functionName)
+		childState, tracingImport := tracing.AddToCall(pkg, goStmt.Call, false)
+		manager.addImport(tracingImport)
+		childState.EndTransaction()
+		manager.setPackage(inv.packageName)
+		TraceFunction(manager, inv.decl, childState)
+		manager.setPackage(rootPkg)
+	}
+	return ""
+}
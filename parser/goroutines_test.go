@@ -0,0 +1,171 @@
+package parser
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/dave/dst"
+	"github.com/dave/dst/decorator"
+	"github.com/newrelic/go-easy-instrumentation/parser/tracestate"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTraceFunction_GoroutinesInMain(t *testing.T) {
+	code := `package main
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+)
+
+func fetch(id int) error {
+	return nil
+}
+
+func worker(jobs chan int) {
+	for id := range jobs {
+		fetch(id)
+	}
+}
+
+func main() {
+	jobs := make(chan int)
+	go worker(jobs)
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt)
+	go func() {
+		<-quit
+		os.Exit(0)
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			fetch(id)
+		}(i)
+	}
+	wg.Wait()
+}
+`
+	got := traceMain(t, code)
+
+	for _, expect := range []string{
+		// goroutines that are never joined get a background transaction that they end
+		`nrTxn := app.StartTransaction("worker")`,
+		"go worker(jobs, nrTxn)",
+		"func worker(jobs chan int, nrTxn *newrelic.Transaction) {",
+		"defer nrTxn.End()",
+		// goroutines joined with a wait group run in a transaction that ends once they are done
+		`nrTxn = app.StartTransaction("function literal")`,
+		"go func(id int, nrTxn *newrelic.Transaction) {",
+		`defer nrTxn.StartSegment("async function literal").End()`,
+		"}(i, nrTxn.NewGoroutine())",
+		"wg.Wait()\n\tnrTxn.End()",
+		// goroutines that do not call the application are left as they are
+		"go func() {\n\t\t<-quit",
+	} {
+		assert.Contains(t, got, expect)
+	}
+	assert.NotContains(t, got, "NR INFO")
+}
+
+// traceMain traces the main function of code with an application named app, and returns the resulting code.
+func traceMain(t *testing.T, code string) string {
+	defer PanicRecovery(t)
+	uuid, _ := Pseudo_uuid()
+	testDir := fmt.Sprintf("tmp_%s", uuid)
+	defer CleanTestApp(t, testDir)
+
+	manager := TestInstrumentationManager(t, code, testDir)
+	pkg := manager.getDecoratorPackage()
+
+	var mainFunc *dst.FuncDecl
+	for _, decl := range pkg.Syntax[0].Decls {
+		if funcDecl, ok := decl.(*dst.FuncDecl); ok {
+			manager.createFunctionDeclaration(funcDecl)
+			if funcDecl.Name.Name == "main" {
+				mainFunc = funcDecl
+			}
+		}
+	}
+	manager.recordFunctionValues()
+	manager.recordDirectCalls()
+	TraceFunction(manager, mainFunc, tracestate.Main("app"))
+
+	buf := bytes.NewBuffer([]byte{})
+	if err := decorator.NewRestorerWithImports(testDir, createTestResolver(testDir)).Fprint(buf, pkg.Syntax[0]); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestTraceFunction_GoroutinesInMainNotTraced(t *testing.T) {
+	tests := []struct {
+		name   string
+		code   string
+		reason string
+	}{
+		{
+			name: "function also called elsewhere",
+			code: `package main
+
+func worker() {}
+
+func main() {
+	go worker()
+	worker()
+}
+`,
+			reason: "the function it runs is also called elsewhere",
+		},
+		{
+			name: "function also used as a value",
+			code: `package main
+
+func worker() {}
+
+func run(fn func()) {
+	fn()
+}
+
+func main() {
+	go worker()
+	run(worker)
+}
+`,
+			reason: "the function it runs is also used as a value",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := traceMain(t, tt.code)
+			assert.Contains(t, got, fmt.Sprintf("can not trace this goroutine, since %s; please instrument manually.", tt.reason))
+			assert.Contains(t, got, "\tgo worker()\n")
+		})
+	}
+}
+
+func TestRecordDirectCalls_TestFiles(t *testing.T) {
+	code := `package main
+
+func worker() {}
+
+func main() {
+	go worker()
+}
+`
+	testCode := `package main
+
+func runWorker() {
+	worker()
+}
+`
+	manager := testManagerWithTests(t, code, testCode)
+	manager.recordDirectCalls()
+	assert.Empty(t, manager.directCalls, "a goroutine that runs a function called only by tests can be traced")
+}
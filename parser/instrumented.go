@@ -0,0 +1,172 @@
+package parser
+
+import (
+	"go/types"
+	"slices"
+
+	"github.com/dave/dst"
+	"github.com/dave/dst/decorator"
+	"github.com/dave/dst/dstutil"
+	"github.com/newrelic/go-easy-instrumentation/internal/report"
+	"github.com/newrelic/go-easy-instrumentation/internal/util"
+)
+
+// SetUpdate sets whether the functions that are already instrumented are updated. By default, a function
+// that already contains New Relic instrumentation is left unchanged, so that running the tool again on an
+// instrumented application does not change it. When updating, the statements of these functions that are
+// not instrumented yet, which is the code added to them since they were instrumented, get instrumentation too.
+func (m *InstrumentationManager) SetUpdate(update bool) {
+	m.update = update
+}
+
+// AlreadyInstrumented returns the number of functions that already contained New Relic instrumentation
+// when the application was scanned, including those in packages with cached results.
+func (m *InstrumentationManager) AlreadyInstrumented() int {
+	count := len(m.instrumented)
+	for _, result := range m.cached {
+		count += result.AlreadyInstrumented
+	}
+	return count
+}
+
+// WasInstrumented returns true if a statement or function literal already contained New Relic instrumentation
+// when the application was scanned, and must be left as it is. Integrations that add instrumentation without
+// being called for every node should check this first.
+func (m *InstrumentationManager) WasInstrumented(node dst.Node) bool {
+	return m.instrumentedNodes[node]
+}
+
+// isInstrumented returns true if a node contains New Relic instrumentation: it refers to a package, function
+// or type of the go agent or of one of its integrations, or to a variable holding a value from the agent.
+// Every integration instruments code with the agent, so this recognizes the instrumentation added by any of
+// them, whether it was added by this tool or by hand.
+func isInstrumented(pkg *decorator.Package, node dst.Node) bool {
+	return inspectInstrumentation(pkg, node, agentObjects(pkg, node), true)
+}
+
+// isInstrumentedNode returns true if a simple statement, one that does not contain other statements, or a
+// function literal contains New Relic instrumentation. The header of an if, switch or loop statement counts
+// as a simple statement, such as a condition that calls a function with a transaction. The bodies of the
+// function literals in a statement are not part of it, since they are instrumented on their own. The agents
+// are the variables of the function that hold values from the agent, as returned by agentObjects.
+func isInstrumentedNode(pkg *decorator.Package, node dst.Node, agents map[types.Object]bool) bool {
+	switch n := node.(type) {
+	case *dst.AssignStmt, *dst.ExprStmt, *dst.DeferStmt, *dst.GoStmt, *dst.ReturnStmt, *dst.DeclStmt, *dst.SendStmt, *dst.IncDecStmt:
+		return inspectInstrumentation(pkg, node, agents, false)
+	case *dst.IfStmt:
+		return isInstrumentedHeader(pkg, agents, n.Init, n.Cond)
+	case *dst.SwitchStmt:
+		return isInstrumentedHeader(pkg, agents, n.Init, n.Tag)
+	case *dst.ForStmt:
+		return isInstrumentedHeader(pkg, agents, n.Init, n.Cond, n.Post)
+	case *dst.RangeStmt:
+		return isInstrumentedHeader(pkg, agents, n.Key, n.Value, n.X)
+	case *dst.FuncLit:
+		return inspectInstrumentation(pkg, node, agents, true)
+	}
+	return false
+}
+
+func isInstrumentedHeader(pkg *decorator.Package, agents map[types.Object]bool, nodes ...dst.Node) bool {
+	for _, node := range nodes {
+		if node != nil && inspectInstrumentation(pkg, node, agents, false) {
+			return true
+		}
+	}
+	return false
+}
+
+func inspectInstrumentation(pkg *decorator.Package, node dst.Node, agents map[types.Object]bool, funcLits bool) bool {
+	found := false
+	dst.Inspect(node, func(n dst.Node) bool {
+		if found || n == nil {
+			return false
+		}
+		if lit, ok := n.(*dst.FuncLit); ok && !funcLits {
+			// the parameters of a function literal still show whether it is instrumented
+			found = inspectInstrumentation(pkg, lit.Type, agents, false)
+			return false
+		}
+		if ident, ok := n.(*dst.Ident); ok && agents[objectOf(pkg, ident)] {
+			// the type of a variable is not known when the agent module could not be loaded
+			found = true
+		} else {
+			found = usesAgent(pkg, n)
+		}
+		return !found
+	})
+	return found
+}
+
+// isWrapped returns true if the statement at index i was instrumented by adding a statement after it: the
+// next statement is instrumented and uses a variable that the statement assigns, such as middleware added to
+// a router right after it is created.
+func isWrapped(pkg *decorator.Package, stmts []dst.Stmt, i int, agents map[types.Object]bool) bool {
+	assign, ok := stmts[i].(*dst.AssignStmt)
+	if !ok || i+1 >= len(stmts) || !isInstrumentedNode(pkg, stmts[i+1], agents) {
+		return false
+	}
+	assigned := []string{}
+	for _, lhs := range assign.Lhs {
+		if ident, ok := lhs.(*dst.Ident); ok && ident.Name != "_" {
+			assigned = append(assigned, ident.Name)
+		}
+	}
+	used := false
+	dst.Inspect(stmts[i+1], func(n dst.Node) bool {
+		if ident, ok := n.(*dst.Ident); ok && ident.Path == "" && slices.Contains(assigned, ident.Name) {
+			used = true
+		}
+		return !used
+	})
+	return used
+}
+
+// detectInstrumentation finds the code of the application that already contains instrumentation, before any
+// is added. The functions that contain instrumentation are marked as traced, so that they do not get another
+// transaction parameter or segment, and the statements and function literals that do are left as they are.
+// This covers the instrumentation of every integration, whether it was added by an earlier run or by hand.
+func (m *InstrumentationManager) detectInstrumentation() {
+	m.instrumented = map[*dst.FuncDecl]bool{}
+	m.instrumentedNodes = map[dst.Node]bool{}
+	for _, pkgID := range m.getSortedPackages() {
+		state := m.packages[pkgID]
+		if util.IsTestPackage(state.pkg) {
+			continue
+		}
+		m.setPackage(pkgID)
+		for _, file := range state.pkg.Syntax {
+			if util.IsGenerated(state.pkg.Decorator, file) || !m.includeFile(state, file) {
+				continue
+			}
+			for _, decl := range file.Decls {
+				fn, ok := decl.(*dst.FuncDecl)
+				if !ok || !m.includeFunction(state, file, fn) || !isInstrumented(state.pkg, fn) {
+					continue
+				}
+				m.instrumented[fn] = true
+				m.createFunctionDeclaration(fn)
+				m.updateFunctionDeclaration(fn)
+				report.Skipped(state.pkg, fn, report.CoreIntegration, report.CodeAlreadyInstrumented, "function is already instrumented")
+
+				agents := agentObjects(state.pkg, fn)
+				dstutil.Apply(fn.Body, func(c *dstutil.Cursor) bool {
+					node := c.Node()
+					if isInstrumentedNode(state.pkg, node, agents) {
+						m.instrumentedNodes[node] = true
+					} else if block, ok := c.Parent().(*dst.BlockStmt); ok && c.Index() >= 0 && isWrapped(state.pkg, block.List, c.Index(), agents) {
+						m.instrumentedNodes[node] = true
+					}
+					return true
+				}, nil)
+			}
+		}
+	}
+}
+
+// skipFunction returns true if a function is left unchanged because it is already instrumented. The main
+// function is never skipped, since it starts the transactions of the functions it calls; only its statements
+// that are already instrumented are left unchanged. The diagnostic functions still run on skipped functions.
+func (m *InstrumentationManager) skipFunction(fn *dst.FuncDecl) bool {
+	return m.instrumented[fn] && !m.update && fn.Name.Name != "main"
+}
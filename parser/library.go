@@ -0,0 +1,58 @@
+package parser
+
+import (
+	"github.com/dave/dst"
+	"github.com/dave/dst/decorator"
+	"github.com/newrelic/go-easy-instrumentation/parser/tracestate"
+)
+
+// SetLibrary sets whether the application is instrumented as a library, which has no main function of its own and
+// is used by other applications. A library never initializes the agent: its entry points take the transactions of
+// the applications that call them from the context or request they are passed instead, see LibraryEntryPoint. Since the
+// exported functions of a library are called by other modules, their signatures are always preserved.
+func (m *InstrumentationManager) SetLibrary(library bool) {
+	m.library = library
+}
+
+// Library returns true if the application is instrumented as a library.
+func (m *InstrumentationManager) Library() bool {
+	return m.library
+}
+
+// LibraryEntryPoint returns the trace state of a function declared in the current package if it is an entry point of
+// a library: a function or method exported from a package other than main, which takes a context.Context or an
+// *http.Request that the applications calling it may have added a transaction to. A context parameter is preferred
+// over the context of a request. Returns false if the function is not an entry point, or its parameter is not named.
+func (m *InstrumentationManager) LibraryEntryPoint(decl *dst.FuncDecl) (*tracestate.State, bool) {
+	pkg := m.getDecoratorPackage()
+	if pkg == nil || pkg.Name == "main" || !decl.Name.IsExported() || decl.Body == nil {
+		return nil, false
+	}
+	if ctx := parameterOfType(pkg, decl, "context.Context"); ctx != "" {
+		return tracestate.EntryPoint(ctx), true
+	}
+	if req := parameterOfType(pkg, decl, "*net/http.Request"); req != "" {
+		return tracestate.RequestEntryPoint(req), true
+	}
+	return nil, false
+}
+
+// parameterOfType returns the name of the first named parameter of a function declaration whose type is typeName.
+func parameterOfType(pkg *decorator.Package, decl *dst.FuncDecl, typeName string) string {
+	for _, param := range decl.Type.Params.List {
+		if t := typeOf(pkg, param.Type); t == nil || t.String() != typeName {
+			continue
+		}
+		for _, name := range param.Names {
+			if name.Name != "_" {
+				return name.Name
+			}
+		}
+	}
+	return ""
+}
+
+// preservesSignatures returns true if the signatures of exported functions and interface methods must not change.
+func (m *InstrumentationManager) preservesSignatures() bool {
+	return m.preserveSignatures || m.library
+}
@@ -0,0 +1,65 @@
+package parser
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/dave/dst"
+	"github.com/newrelic/go-easy-instrumentation/parser/tracestate"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTracePackageFunctionCalls_Library(t *testing.T) {
+	manager := walkTestManager(3)
+	err := tracePackageFunctionCalls(manager)
+	assert.ErrorContains(t, err, "cannot find a main method")
+
+	manager = walkTestManager(3)
+	manager.SetLibrary(true)
+	assert.NoError(t, tracePackageFunctionCalls(manager), "a library does not need a main function")
+	assert.True(t, manager.Library())
+	assert.True(t, manager.preservesSignatures(), "the signatures of a library are always preserved")
+}
+
+func TestLibraryEntryPoint(t *testing.T) {
+	code := `package handlers
+
+import (
+	"context"
+	"net/http"
+)
+
+func Get(ctx context.Context, r *http.Request) {}
+
+func Serve(w http.ResponseWriter, r *http.Request) {}
+
+func Ignore(_ http.ResponseWriter, _ *http.Request) {}
+
+func serve(w http.ResponseWriter, r *http.Request) {}
+`
+	defer PanicRecovery(t)
+	uuid, _ := Pseudo_uuid()
+	testDir := fmt.Sprintf("tmp_%s", uuid)
+	defer CleanTestApp(t, testDir)
+
+	manager := TestInstrumentationManager(t, code, testDir)
+	decls := map[string]*dst.FuncDecl{}
+	for _, decl := range manager.getDecoratorPackage().Syntax[0].Decls {
+		if funcDecl, ok := decl.(*dst.FuncDecl); ok {
+			decls[funcDecl.Name.Name] = funcDecl
+		}
+	}
+
+	tracing, ok := manager.LibraryEntryPoint(decls["Get"])
+	if assert.True(t, ok, "a function that takes a context is an entry point") {
+		assert.Equal(t, tracestate.EntryPoint("ctx"), tracing, "the context parameter is preferred over the request")
+	}
+	tracing, ok = manager.LibraryEntryPoint(decls["Serve"])
+	if assert.True(t, ok, "an HTTP handler is an entry point") {
+		assert.Equal(t, tracestate.RequestEntryPoint("r"), tracing)
+	}
+	_, ok = manager.LibraryEntryPoint(decls["Ignore"])
+	assert.False(t, ok, "a handler whose request is not named is not an entry point")
+	_, ok = manager.LibraryEntryPoint(decls["serve"])
+	assert.False(t, ok, "an unexported handler is not an entry point")
+}
@@ -2,24 +2,37 @@ package parser
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
+	"go/token"
+	"go/types"
 	"log"
+	"maps"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"slices"
+	"strings"
 
 	"github.com/dave/dst"
 	"github.com/dave/dst/decorator"
+	"github.com/dave/dst/decorator/resolver"
 	"github.com/dave/dst/decorator/resolver/gopackages"
+	"github.com/dave/dst/decorator/resolver/guess"
 	"github.com/dave/dst/dstutil"
+	"github.com/newrelic/go-easy-instrumentation/internal/cache"
 	"github.com/newrelic/go-easy-instrumentation/internal/codegen"
+	"github.com/newrelic/go-easy-instrumentation/internal/config"
+	"github.com/newrelic/go-easy-instrumentation/internal/diff"
+	"github.com/newrelic/go-easy-instrumentation/internal/filter"
+	"github.com/newrelic/go-easy-instrumentation/internal/modules"
+	"github.com/newrelic/go-easy-instrumentation/internal/report"
 	"github.com/newrelic/go-easy-instrumentation/internal/util"
 	"github.com/newrelic/go-easy-instrumentation/parser/errorcache"
 	"github.com/newrelic/go-easy-instrumentation/parser/facts"
 	"github.com/newrelic/go-easy-instrumentation/parser/tracestate"
 	"github.com/newrelic/go-easy-instrumentation/parser/transactioncache"
-	godiffpatch "github.com/sourcegraph/go-diff-patch"
+	"golang.org/x/tools/go/ssa"
 )
 
 // tracedFunction contains relevant information about a function within the current package, and
@@ -33,31 +46,54 @@ type tracedFunctionDecl struct {
 
 type tracingFunctions struct {
 	stateless          []StatelessTracingFunction
+	diagnostic         []DiagnosticFunction
 	stateful           []StatefulTracingFunction
 	dependency         []FactDiscoveryFunction
 	preinstrumentation []PreInstrumentationTracingFunction
+	uninstrument       []UninstrumentFunction
 }
 
 // InstrumentationManager maintains state relevant to tracing across all files, packages and functions.
 type InstrumentationManager struct {
-	appName           string
-	agentVariableName string
-	userAppPath       string // path to the user's application as provided by the user
-	diffFile          string
-	currentPackage    string
-	tracingFunctions  tracingFunctions
-	facts             facts.Keeper
-	packages          map[string]*packageState          // stores stateful information on packages by ID
-	errorCache        errorcache.ErrorCache             // stores error handling status for functions
-	transactionCache  transactioncache.TransactionCache // stores transaction status for functions
-	setupFunc         *dst.FuncDecl
+	appName              string
+	agentVariableName    string
+	txnVariableName      string // name of the transaction variables created by instrumentation
+	userAppPath          string // path to the user's application as provided by the user
+	diffFile             string
+	currentPackage       string
+	tracingFunctions     tracingFunctions
+	facts                facts.Keeper
+	packages             map[string]*packageState          // stores stateful information on packages by ID
+	errorCache           errorcache.ErrorCache             // stores error handling status for functions
+	transactionCache     transactioncache.TransactionCache // stores transaction status for functions
+	setupFunc            *dst.FuncDecl
+	changes              []*fileChange  // restored contents of every file, cached once instrumentation is complete
+	filter               *filter.Filter // decides which code gets instrumented; nil includes everything
+	agentConfig          []config.AgentConfigOption
+	update               bool                        // also instrument the code added to functions that are already instrumented
+	instrumented         map[*dst.FuncDecl]bool      // functions that were already instrumented before this run
+	instrumentedNodes    map[dst.Node]bool           // statements and function literals that were already instrumented before this run
+	preserveSignatures   bool                        // never change the signatures of exported functions and interface methods
+	preserved            map[*dst.FuncDecl]string    // functions checked for preserved signatures, with the names of those that were not traced
+	library              bool                        // the application is a library without a main function, which never initializes the agent
+	binaries             map[string]config.Binary    // how each main package is instrumented, by directory relative to the application root
+	callGraph            string                      // the call graph algorithm that calls to interface methods are resolved with, if any
+	implementations      map[token.Pos][]*types.Func // the methods of the application that each call to an interface method may invoke
+	functionValues       map[string]bool             // the functions and methods of the application that are used as values, by key
+	directCalls          map[string]bool             // the functions and methods of the application that are called other than to start a goroutine, by key
+	program              *ssa.Program                // the SSA form of the application, built the first time it is needed
+	transactionScope     map[string]bool             // the functions and methods of the application that hold an existing transaction, by key
+	transactionReceivers map[string]bool             // the functions and methods of the application that an existing transaction is passed to, by key
+	cached               map[string]*cache.Result    // the results of the packages that were not loaded, since they were cached by an earlier run, by load path
 }
 
 // PackageManager contains state relevant to tracing within a single package.
 type packageState struct {
 	pkg          *decorator.Package             // the package being instrumented
-	tracedFuncs  map[string]*tracedFunctionDecl // maintains state of tracing for functions within the package
+	tracedFuncs  map[string]*tracedFunctionDecl // maintains state of tracing for functions within the package, by functionDeclKey
 	importsAdded map[string]bool                // tracks imports added to the package
+	facts        []facts.Entry                  // the facts discovered in the package
+	main         bool                           // the package declares a main function
 }
 
 // NewInstrumentationManager initializes an InstrumentationManager cache for a given package.
@@ -67,15 +103,18 @@ func NewInstrumentationManager(pkgs []*decorator.Package, appName, agentVariable
 		diffFile:          diffFile,
 		appName:           appName,
 		agentVariableName: agentVariableName,
+		txnVariableName:   codegen.DefaultTransactionVariable,
 		packages:          map[string]*packageState{},
 		facts:             facts.NewKeeper(),
 		errorCache:        errorcache.ErrorCache{},
 		transactionCache:  *transactioncache.NewTransactionCache(),
 		tracingFunctions: tracingFunctions{
 			stateless:          []StatelessTracingFunction{},
+			diagnostic:         []DiagnosticFunction{},
 			stateful:           []StatefulTracingFunction{},
 			dependency:         []FactDiscoveryFunction{},
 			preinstrumentation: []PreInstrumentationTracingFunction{},
+			uninstrument:       []UninstrumentFunction{},
 		},
 	}
 
@@ -105,11 +144,21 @@ func (m *InstrumentationManager) LoadStatelessTracingFunctions(functions ...Stat
 	m.tracingFunctions.stateless = append(m.tracingFunctions.stateless, functions...)
 }
 
+// LoadDiagnosticFunctions registers diagnostic functions (exported for cmd)
+func (m *InstrumentationManager) LoadDiagnosticFunctions(functions ...DiagnosticFunction) {
+	m.tracingFunctions.diagnostic = append(m.tracingFunctions.diagnostic, functions...)
+}
+
 // LoadDependencyScans registers fact discovery functions (exported for cmd)
 func (m *InstrumentationManager) LoadDependencyScans(scans ...FactDiscoveryFunction) {
 	m.tracingFunctions.dependency = append(m.tracingFunctions.dependency, scans...)
 }
 
+// LoadUninstrumentFunctions registers functions that remove instrumentation (exported for cmd)
+func (m *InstrumentationManager) LoadUninstrumentFunctions(functions ...UninstrumentFunction) {
+	m.tracingFunctions.uninstrument = append(m.tracingFunctions.uninstrument, functions...)
+}
+
 func (m *InstrumentationManager) CreateDiffFile() error {
 	f, err := os.Create(m.diffFile)
 	f.Close()
@@ -149,6 +198,16 @@ func (m *InstrumentationManager) SetAgentVariableName(name string) {
 	m.agentVariableName = name
 }
 
+// TransactionVariableName returns the name of the transaction variables created by instrumentation (exported for integrations)
+func (m *InstrumentationManager) TransactionVariableName() string {
+	return m.txnVariableName
+}
+
+// SetTransactionVariableName sets the name of the transaction variables created by instrumentation (exported for integrations)
+func (m *InstrumentationManager) SetTransactionVariableName(name string) {
+	m.txnVariableName = name
+}
+
 // GetDecoratorPackage returns the decorator package for the current package (exported for integrations)
 func (m *InstrumentationManager) GetDecoratorPackage() *decorator.Package {
 	return m.getDecoratorPackage()
@@ -159,9 +218,24 @@ func (m *InstrumentationManager) Facts() *facts.Keeper {
 	return &m.facts
 }
 
-// AppName returns the application name (exported for integrations)
+// AppName returns the application name that the binary built from the current package reports, see Binaries
+// (exported for integrations)
 func (m *InstrumentationManager) AppName() string {
-	return m.appName
+	state, ok := m.packages[m.currentPackage]
+	if !ok || !state.main {
+		return m.appName
+	}
+	return m.binaryAppName(m.relativeFilePath(state.pkg.Dir), m.binaryDirs())
+}
+
+// AgentConfigOptions returns the config options that the agent is initialized with (exported for integrations)
+func (m *InstrumentationManager) AgentConfigOptions() []config.AgentConfigOption {
+	return m.agentConfig
+}
+
+// SetAgentConfigOptions sets the config options that the agent is initialized with (exported for integrations)
+func (m *InstrumentationManager) SetAgentConfigOptions(options []config.AgentConfigOption) {
+	m.agentConfig = options
 }
 
 // SetupFunc returns the setup function declaration (exported for integrations)
@@ -215,6 +289,30 @@ func (m *InstrumentationManager) getPackageName() string {
 	return m.currentPackage
 }
 
+// functionDeclKey returns the key that a function declaration is stored under in its package: the full name of
+// the function or method it declares, such as "example.com/app.work" or "(*example.com/app.Store).Get", so that
+// methods with the same name on different receivers are kept apart. A declaration without type information is
+// stored under the name that function patterns are matched against, such as "Store.Get".
+func functionDeclKey(pkg *decorator.Package, decl *dst.FuncDecl) string {
+	if fn, ok := objectOf(pkg, decl.Name).(*types.Func); ok {
+		return fn.Origin().FullName()
+	}
+	return functionName(decl)
+}
+
+// calledFunctionKey returns the key of the function declaration that the function or method called by an
+// identifier is stored under, resolving methods through the type of their receiver. An identifier without type
+// information is matched by its name, and an empty string is returned if it does not refer to a function or method.
+func calledFunctionKey(pkg *decorator.Package, fun *dst.Ident) string {
+	switch obj := objectOf(pkg, fun).(type) {
+	case *types.Func:
+		return obj.Origin().FullName()
+	case nil:
+		return fun.Name
+	}
+	return ""
+}
+
 // createFunctionDeclaration creates a tracking object for a function declaration that can be used
 // to find tracing locations. This is for initializing and set up only.
 func (m *InstrumentationManager) createFunctionDeclaration(decl *dst.FuncDecl) {
@@ -223,19 +321,20 @@ func (m *InstrumentationManager) createFunctionDeclaration(decl *dst.FuncDecl) {
 		return
 	}
 
-	_, ok = state.tracedFuncs[decl.Name.Name]
+	key := functionDeclKey(state.pkg, decl)
+	_, ok = state.tracedFuncs[key]
 	if !ok {
-		state.tracedFuncs[decl.Name.Name] = &tracedFunctionDecl{
+		state.tracedFuncs[key] = &tracedFunctionDecl{
 			body: decl,
 		}
 	}
 }
 
-// UpdateFunctionDeclaration replaces the declaration stored for the given function name, and marks it as traced.
+// UpdateFunctionDeclaration replaces the declaration stored for the given function, and marks it as traced.
 func (m *InstrumentationManager) updateFunctionDeclaration(decl *dst.FuncDecl) {
 	state, ok := m.packages[m.currentPackage]
 	if ok {
-		t, ok := state.tracedFuncs[decl.Name.Name]
+		t, ok := state.tracedFuncs[functionDeclKey(state.pkg, decl)]
 		if ok {
 			t.body = decl
 			t.traced = true
@@ -244,10 +343,13 @@ func (m *InstrumentationManager) updateFunctionDeclaration(decl *dst.FuncDecl) {
 }
 
 type invocationInfo struct {
-	functionName string
-	packageName  string
-	call         *dst.CallExpr
-	decl         *dst.FuncDecl
+	functionName   string
+	key            string // the key the declaration of the function is stored under
+	packageName    string
+	call           *dst.CallExpr
+	decl           *dst.FuncDecl
+	implementation bool // the function is an implementation of the interface method that is called
+	value          bool // the function is used as a value rather than called, and call is nil
 }
 
 func resolvePath(identPath, currentPackage, forTest string) string {
@@ -262,21 +364,22 @@ func resolvePath(identPath, currentPackage, forTest string) string {
 	return currentPackage
 }
 
-func (m *InstrumentationManager) isDefinedInPackage(functionName, packageName string) bool {
+// isDefinedInPackage returns true if a function with the given key is declared in the package.
+func (m *InstrumentationManager) isDefinedInPackage(key, packageName string) bool {
 	state, ok := m.packages[packageName]
 	if ok {
-		_, ok = state.tracedFuncs[functionName]
+		_, ok = state.tracedFuncs[key]
 		return ok
 	}
 
 	return false
 }
 
-// IsFunctionTraced checks if a function has been marked as already traced in the current package
-func (m *InstrumentationManager) IsFunctionTraced(functionName string) bool {
+// IsFunctionTraced checks if a function declared in the current package has been marked as already traced
+func (m *InstrumentationManager) IsFunctionTraced(decl *dst.FuncDecl) bool {
 	state, ok := m.packages[m.currentPackage]
 	if ok {
-		tracedFunc, ok := state.tracedFuncs[functionName]
+		tracedFunc, ok := state.tracedFuncs[functionDeclKey(state.pkg, decl)]
 		return ok && tracedFunc.traced
 	}
 	return false
@@ -284,25 +387,26 @@ func (m *InstrumentationManager) IsFunctionTraced(functionName string) bool {
 
 // getInvocationInfoFromCall returns a collection of data about a function call if it was defined
 // in the scope of this application. If the expression passed does not contain a valid function
-// call, this method will return nil.
-//
-// TODO: support function literals
+// call, this method will return nil. Function literals are traced where they are declared, see
+// isFunctionLiteralValue.
 //
 // NOTE: unlike getInvocationInfo, this method does not recursively search for invocations.
 func (m *InstrumentationManager) getInvocationInfoFromCall(call *dst.CallExpr, forTest string) *invocationInfo {
-	functionCallIdent, ok := call.Fun.(*dst.Ident)
+	functionCallIdent, ok := util.FunctionExpr(call.Fun).(*dst.Ident)
 	if !ok {
 		return nil
 	}
 
 	path := resolvePath(functionCallIdent.Path, m.getPackageName(), forTest)
+	key := calledFunctionKey(m.getDecoratorPackage(), functionCallIdent)
 	pkg, ok := m.packages[path]
-	if ok && pkg.tracedFuncs[functionCallIdent.Name] != nil {
+	if ok && pkg.tracedFuncs[key] != nil {
 		return &invocationInfo{
 			functionName: functionCallIdent.Name,
+			key:          key,
 			packageName:  path,
 			call:         call,
-			decl:         pkg.tracedFuncs[functionCallIdent.Name].body,
+			decl:         pkg.tracedFuncs[key].body,
 		}
 	}
 
@@ -343,34 +447,41 @@ func (m *InstrumentationManager) findInvocationInfo(node dst.Node, state *traces
 					call:         call,
 				})
 			}
-			switch fun := call.Fun.(type) {
+			// generic functions are called with the type arguments they are instantiated with, like `Map[int](...)`
+			switch fun := util.FunctionExpr(call.Fun).(type) {
 			case *dst.Ident:
 				path := resolvePath(fun.Path, m.getPackageName(), "")
+				key := calledFunctionKey(m.getDecoratorPackage(), fun)
 				pkg, ok := m.packages[path]
-				if ok && pkg.tracedFuncs[fun.Name] != nil {
+				if ok && pkg.tracedFuncs[key] != nil {
 					invInfo = append(invInfo, &invocationInfo{
 						functionName: fun.Name,
+						key:          key,
 						packageName:  path,
 						call:         call,
-						decl:         pkg.tracedFuncs[fun.Name].body,
+						decl:         pkg.tracedFuncs[key].body,
 					})
 				}
 			case *dst.SelectorExpr:
-				// Handle selector expressions like `f().g().x()`
+				// Handle selector expressions like `f().g().x()`, and methods, which are resolved through the type of their receiver
 				pkgName := util.PackagePath(fun.Sel, m.getDecoratorPackage())
 				path := resolvePath(pkgName, m.getPackageName(), "")
+				key := calledFunctionKey(m.getDecoratorPackage(), fun.Sel)
 				pkg, ok := m.packages[path]
-				functionName := fun.Sel.Name
 
 				// Check if the function is defined in a package of this application.
 				// If true, tracing can be passed into it.
-				if ok && pkg.tracedFuncs[functionName] != nil {
+				if ok && pkg.tracedFuncs[key] != nil {
 					invInfo = append(invInfo, &invocationInfo{
-						functionName: functionName,
+						functionName: fun.Sel.Name,
+						key:          key,
 						packageName:  path,
 						call:         call,
-						decl:         pkg.tracedFuncs[functionName].body,
+						decl:         pkg.tracedFuncs[key].body,
 					})
+				} else {
+					// calls to interface methods are passed to the implementations found in the call graph
+					invInfo = append(invInfo, m.findImplementations(call)...)
 				}
 			}
 			return true
@@ -389,7 +500,7 @@ func (m *InstrumentationManager) shouldInstrumentFunction(inv *invocationInfo) b
 
 	state, ok := m.packages[inv.packageName]
 	if ok {
-		v, ok := state.tracedFuncs[inv.functionName]
+		v, ok := state.tracedFuncs[inv.key]
 		if ok {
 			return !v.traced
 		}
@@ -409,76 +520,188 @@ func (m *InstrumentationManager) getSortedPackages() []string {
 	return keys
 }
 
-// WriteDiff writes out the changes made to a file to the diff file for this package.
-// onProgress is a callback function that is invoked before writing each file diff.
-// This allows the caller (e.g., the CLI UI) to receive granular progress updates
-// containing the name of the file currently being processed.
-func (m *InstrumentationManager) WriteDiff(onProgress func(string)) error {
-	pkgs := m.getSortedPackages()
-	for _, pkg := range pkgs {
-		state := m.packages[pkg]
-		r := decorator.NewRestorerWithImports(state.pkg.Dir, gopackages.New(state.pkg.Dir))
+// knownPackageNames maps import paths to package names that can not be guessed from the import path.
+var knownPackageNames = map[string]string{
+	"github.com/newrelic/go-agent/v3/integrations/nrecho-v4":  "nrecho",
+	"github.com/newrelic/go-agent/v3/integrations/nrecho-v3":  "nrecho",
+	"github.com/newrelic/go-agent/v3/integrations/nrredis-v8": "nrredis",
+	"github.com/newrelic/go-agent/v3/integrations/nrredis-v9": "nrredis",
+}
+
+// importResolver resolves the names of imported packages with the first resolver that succeeds.
+type importResolver []resolver.RestorerResolver
+
+// newImportResolver creates a resolver that looks up the names of imported packages with the go command, and
+// falls back to guessing them from the import path. Guessing is needed for packages from modules that are not
+// downloaded yet, such as the modules added to the diff in offline mode.
+func newImportResolver(dir string) importResolver {
+	return importResolver{
+		gopackages.New(dir),
+		guess.WithMap(knownPackageNames),
+	}
+}
+
+func (r importResolver) ResolvePackage(path string) (string, error) {
+	var err error
+	for _, res := range r {
+		var name string
+		name, err = res.ResolvePackage(path)
+		if err == nil && name != "" {
+			return name, nil
+		}
+	}
+	return "", err
+}
+
+// fileChange holds the original and instrumented contents of a file in the user's application.
+type fileChange struct {
+	pkgID    string // the package the file was restored from
+	path     string // absolute path to the file
+	diffName string // what this file will be named in the diff file
+	original string
+	modified string
+	created  bool // the file does not exist yet, and is created by the diff
+}
+
+// patch returns the unified diff for this file, or an empty string if it was not changed.
+func (fc *fileChange) patch() string {
+	patch := diff.Generate(fc.diffName, fc.original, fc.modified)
+	if fc.created {
+		patch = strings.Replace(patch, "--- a/"+fc.diffName+"\n", "--- /dev/null\n", 1)
+	}
+	return patch
+}
+
+// restoreChanges restores the modified syntax trees of every package back into code, and caches the
+// results in the manager. Generated files are never altered, and are not included in the changes.
+// Neither are excluded files, so changes made to them by later steps, such as resolving unit tests, are dropped.
+// Once restored, later steps should use the cached changes rather than restoring the trees again.
+func (m *InstrumentationManager) restoreChanges() error {
+	if m.changes != nil {
+		return nil
+	}
+
+	absAppPath, err := filepath.Abs(m.userAppPath)
+	if err != nil {
+		return err
+	}
+
+	// the changes to the files of cached packages are kept in the order of their package IDs, as if they were loaded
+	cachedFiles := map[string][]cache.File{}
+	for _, path := range m.cachedPaths() {
+		for _, file := range m.cached[path].Files {
+			cachedFiles[file.Package] = append(cachedFiles[file.Package], file)
+		}
+	}
+	pkgIDs := slices.Sorted(maps.Keys(cachedFiles))
+	for _, pkgID := range m.getSortedPackages() {
+		if _, ok := cachedFiles[pkgID]; !ok {
+			pkgIDs = append(pkgIDs, pkgID)
+		}
+	}
+	slices.Sort(pkgIDs)
+
+	changes := []*fileChange{}
+	for _, pkg := range pkgIDs {
+		for _, file := range cachedFiles[pkg] {
+			diffFileName := filepath.FromSlash(file.Path)
+			path := filepath.Join(absAppPath, diffFileName)
+			originalFile, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			changes = append(changes, &fileChange{
+				pkgID:    pkg,
+				path:     path,
+				diffName: diffFileName,
+				original: string(originalFile),
+				modified: file.Modified,
+			})
+		}
+
+		state, ok := m.packages[pkg]
+		if !ok {
+			continue
+		}
+		r := decorator.NewRestorerWithImports(state.pkg.Dir, newImportResolver(state.pkg.Dir))
 
 		for _, file := range state.pkg.Syntax {
 			if util.IsGenerated(state.pkg.Decorator, file) { // never alter generated files, and do not include them in the diff
 				continue
 			}
+			if !m.includeFile(state, file) {
+				continue
+			}
 			path := state.pkg.Decorator.Filenames[file]
 			originalFile, err := os.ReadFile(path)
 			if err != nil {
 				return err
 			}
 
-			// what this file will be named in the diff file
-			var diffFileName string
-
-			absAppPath, err := filepath.Abs(m.userAppPath)
+			diffFileName, err := filepath.Rel(absAppPath, path)
 			if err != nil {
 				return err
 			}
-			diffFileName, err = filepath.Rel(absAppPath, path)
-			if err != nil {
-				return err
-			}
-
-			if onProgress != nil {
-				onProgress(fmt.Sprintf("Writing diff for %s", diffFileName))
-			}
 
 			modifiedFile := bytes.NewBuffer([]byte{})
 			if err := r.Fprint(modifiedFile, file); err != nil {
 				return err
 			}
 
-			f, err := os.OpenFile(m.diffFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-			if err != nil {
-				f.Close()
-				return err
-			}
+			changes = append(changes, &fileChange{
+				pkgID:    pkg,
+				path:     path,
+				diffName: diffFileName,
+				original: string(originalFile),
+				modified: modifiedFile.String(),
+			})
+		}
+	}
 
-			defer f.Close()
+	m.changes = changes
+	return nil
+}
 
-			patch := godiffpatch.GeneratePatch(diffFileName, string(originalFile), modifiedFile.String())
-			if _, err := f.WriteString(patch); err != nil {
-				return err
-			}
+// WriteDiff writes out the changes made to a file to the diff file for this package.
+// onProgress is a callback function that is invoked before writing each file diff.
+// This allows the caller (e.g., the CLI UI) to receive granular progress updates
+// containing the name of the file currently being processed.
+func (m *InstrumentationManager) WriteDiff(onProgress func(string)) error {
+	if err := m.restoreChanges(); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(m.diffFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	for _, change := range m.changes {
+		if onProgress != nil {
+			onProgress(fmt.Sprintf("Writing diff for %s", change.diffName))
+		}
+
+		if _, err := f.WriteString(change.patch()); err != nil {
+			return err
 		}
 	}
 	return nil
 }
 
 func (m *InstrumentationManager) AddRequiredModules() error {
-	for _, state := range m.packages {
-		if util.IsTestPackage(state.pkg) {
-			continue
-		}
+	added, err := m.addedImports()
+	if err != nil {
+		return err
+	}
+	for _, pkg := range added {
 		wd, err := os.Getwd()
 		if err != nil {
 			return fmt.Errorf("failed to get working directory: %v", err)
 		}
 
 		// change to the directory of the package being traced so that go get adds dependencies to the right place
-		if state.pkg.Dir != "" {
+		if pkg.dir != "" {
 			defer func() {
 				err := os.Chdir(wd)
 				if err != nil {
@@ -486,13 +709,13 @@ func (m *InstrumentationManager) AddRequiredModules() error {
 				}
 			}()
 
-			err = os.Chdir(state.pkg.Dir)
+			err = os.Chdir(pkg.dir)
 			if err != nil {
 				return err
 			}
 		}
 
-		for module := range state.importsAdded {
+		for _, module := range pkg.imports {
 			if module == "" {
 				continue
 			}
@@ -508,14 +731,107 @@ func (m *InstrumentationManager) AddRequiredModules() error {
 	return nil
 }
 
-func (m *InstrumentationManager) TracePackageCalls() error {
-	return tracePackageFunctionCalls(m, m.tracingFunctions.dependency...)
+// AddRequiredModulesToDiff is an offline alternative to AddRequiredModules. Rather than running go get, it
+// resolves the modules that provide every import added during instrumentation from the local module cache or
+// a table of pinned versions, and adds the resulting go.mod and go.sum changes to the diff. The user's files are
+// never modified, and no network access is needed.
+//
+// Anything that can not be resolved offline is returned as a warning, so that the user can fix it by hand.
+func (m *InstrumentationManager) AddRequiredModulesToDiff(resolver *modules.Resolver) ([]string, error) {
+	if err := m.restoreChanges(); err != nil {
+		return nil, err
+	}
 
+	// group the imports added by the module they were added to
+	importsByGoMod := map[string][]string{}
+	added, err := m.addedImports()
+	if err != nil {
+		return nil, err
+	}
+	for _, pkg := range added {
+		goModPath, err := modules.FindGoMod(pkg.dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, module := range pkg.imports {
+			if module != "" && !slices.Contains(importsByGoMod[goModPath], module) {
+				importsByGoMod[goModPath] = append(importsByGoMod[goModPath], module)
+			}
+		}
+	}
+
+	absAppPath, err := filepath.Abs(m.userAppPath)
+	if err != nil {
+		return nil, err
+	}
+
+	warnings := []string{}
+	goModPaths := slices.Sorted(maps.Keys(importsByGoMod))
+	for _, goModPath := range goModPaths {
+		// git apply rejects paths outside of the directory it is run in
+		if rel, err := filepath.Rel(absAppPath, goModPath); err != nil || strings.HasPrefix(rel, "..") {
+			warnings = append(warnings, fmt.Sprintf("%s is outside of %s, so its changes can not be added to the diff; run \"go get %s\" in %s instead",
+				goModPath, m.userAppPath, strings.Join(importsByGoMod[goModPath], " "), filepath.Dir(goModPath)))
+			continue
+		}
+
+		goMod, err := os.ReadFile(goModPath)
+		if err != nil {
+			return nil, err
+		}
+		goSumPath := filepath.Join(filepath.Dir(goModPath), "go.sum")
+		goSum, err := os.ReadFile(goSumPath)
+		goSumExists := err == nil
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+
+		edit, err := resolver.AddImports(goMod, goSum, goModPath, importsByGoMod[goModPath])
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve modules for %s: %w", goModPath, err)
+		}
+		warnings = append(warnings, edit.Warnings...)
+
+		for _, file := range []struct {
+			path     string
+			original []byte
+			modified []byte
+			created  bool
+		}{
+			{goModPath, goMod, edit.GoMod, false},
+			{goSumPath, goSum, edit.GoSum, !goSumExists},
+		} {
+			diffFileName, err := filepath.Rel(absAppPath, file.path)
+			if err != nil {
+				return nil, err
+			}
+			m.changes = append(m.changes, &fileChange{
+				path:     file.path,
+				diffName: diffFileName,
+				original: string(file.original),
+				modified: string(file.modified),
+				created:  file.created,
+			})
+		}
+	}
+
+	return warnings, nil
+}
+
+func (m *InstrumentationManager) TracePackageCalls() error {
+	if err := tracePackageFunctionCalls(m, m.tracingFunctions.dependency...); err != nil {
+		return err
+	}
+	m.recordFunctionValues()
+	m.recordDirectCalls()
+	return m.buildCallGraph()
 }
 
 // ScanApplication scans the existing Go application without adding instrumentation to the source code.
 // This will not generate any changes to the actual source code, just the abstract syntax tree generated from it.
 func (m *InstrumentationManager) ScanApplication() error {
+	m.detectInstrumentation()
+	m.trackTransactions()
 
 	tracingFunctions := m.tracingFunctions.preinstrumentation
 
@@ -533,20 +849,23 @@ func (m *InstrumentationManager) InstrumentApplication() error {
 }
 
 func errorNoMain(path string) error {
-	return fmt.Errorf("cannot find a main method in %s; instrumenting applications without a main method is not supported", path)
+	return fmt.Errorf("cannot find a main method in %s; instrumenting applications without a main method is only supported in library mode", path)
 }
 
-// traceFunctionCalls discovers and sets up tracing for all function calls in the current package
+// traceFunctionCalls discovers and sets up tracing for all function calls in the application. The packages are
+// walked concurrently, and the facts discovered in them are added in the order of their package IDs. Every fact
+// that can not be added is reported in the error returned.
 func tracePackageFunctionCalls(manager *InstrumentationManager, factDiscoveryFunctions ...FactDiscoveryFunction) error {
 	hasMain := false
 	var errReturn error
 
-	for packageName, pkg := range manager.packages {
-		if util.IsTestPackage(pkg.pkg) {
-			continue
-		}
-		manager.setPackage(packageName)
-
+	type discovery struct {
+		entries []facts.Entry
+		hasMain bool
+	}
+	discoveries := make([]discovery, len(manager.packages))
+	walks := manager.walkPackages(func(i int, walk *InstrumentationManager) {
+		pkg := walk.packages[walk.currentPackage]
 		for _, file := range pkg.pkg.Syntax {
 			pos := util.Position(file, pkg.pkg)
 			if pos != nil && util.IsGenerated(pkg.pkg.Decorator, file) {
@@ -555,21 +874,22 @@ func tracePackageFunctionCalls(manager *InstrumentationManager, factDiscoveryFun
 
 			for _, decl := range file.Decls {
 				if fn, isFn := decl.(*dst.FuncDecl); isFn {
-					manager.createFunctionDeclaration(fn)
+					// excluded functions are never traced, but facts are still discovered from them
+					if walk.includeFunction(pkg, file, fn) {
+						walk.createFunctionDeclaration(fn)
+					} else {
+						report.Skipped(pkg.pkg, fn, report.CoreIntegration, report.CodeExcluded, "excluded by include or exclude patterns")
+					}
 					if fn.Name.Name == "main" {
-						hasMain = true
+						discoveries[i].hasMain = true
 					}
 				}
 				if len(factDiscoveryFunctions) > 0 {
 					dst.Inspect(decl, func(n dst.Node) bool {
 						for _, scan := range factDiscoveryFunctions {
-							entry, ok := scan(manager.getDecoratorPackage(), n)
+							entry, ok := scan(pkg.pkg, n)
 							if ok {
-								err := manager.facts.AddFact(entry)
-								if err != nil {
-									errReturn = fmt.Errorf("error adding fact entry %s: %v", entry, err)
-									return false
-								}
+								discoveries[i].entries = append(discoveries[i].entries, entry)
 							}
 						}
 						return true
@@ -577,9 +897,31 @@ func tracePackageFunctionCalls(manager *InstrumentationManager, factDiscoveryFun
 				}
 			}
 		}
+	})
+
+	// the facts of cached packages are known without discovering them again
+	for _, path := range manager.cachedPaths() {
+		hasMain = hasMain || manager.cached[path].Main
+		for _, entry := range manager.cached[path].Facts {
+			err := manager.facts.AddFact(entry)
+			if err != nil {
+				errReturn = errors.Join(errReturn, fmt.Errorf("error adding fact entry %s: %v", entry, err))
+			}
+		}
+	}
+	for i, walk := range walks {
+		state := manager.packages[walk.currentPackage]
+		state.facts, state.main = discoveries[i].entries, discoveries[i].hasMain
+		hasMain = hasMain || state.main
+		for _, entry := range state.facts {
+			err := manager.facts.AddFact(entry)
+			if err != nil {
+				errReturn = errors.Join(errReturn, fmt.Errorf("error adding fact entry %s: %v", entry, err))
+			}
+		}
 	}
 
-	if !hasMain {
+	if !hasMain && !manager.library {
 		noMain := errorNoMain(manager.userAppPath)
 		if errReturn != nil {
 			return fmt.Errorf("%w; %w", errReturn, noMain)
@@ -589,66 +931,94 @@ func tracePackageFunctionCalls(manager *InstrumentationManager, factDiscoveryFun
 	return errReturn
 }
 
-// apply instrumentation to the package
+// apply instrumentation to the package. Tracing a function can change the functions it calls in other packages,
+// so the packages are instrumented one at a time, in the order of their package IDs.
 func instrumentPackages(manager *InstrumentationManager, instrumentationFunctions ...StatelessTracingFunction) error {
 	if instrumentationFunctions == nil {
 		return fmt.Errorf("error instrumenting packages: instrumentation functions are nil")
 	}
-	for pkgName, pkgState := range manager.packages {
-		if util.IsTestPackage(pkgState.pkg) {
+	for _, pkgName := range manager.getSortedPackages() {
+		pkgState := manager.packages[pkgName]
+		if util.IsTestPackage(pkgState.pkg) || manager.skipBinary(pkgState) {
 			continue
 		}
 		manager.setPackage(pkgName)
 		for _, file := range pkgState.pkg.Syntax {
+			if !manager.includeFile(pkgState, file) {
+				continue
+			}
 			for _, decl := range file.Decls {
-				if fn, isFn := decl.(*dst.FuncDecl); isFn {
+				fn, isFn := decl.(*dst.FuncDecl)
+				if !isFn || !manager.includeFunction(pkgState, file, fn) {
+					continue
+				}
+				if manager.skipFunction(fn) {
+					// functions that are already instrumented are not changed, but what can not be instrumented
+					// in them is still reported
 					dstutil.Apply(fn, nil, func(c *dstutil.Cursor) bool {
-						for _, instFunc := range instrumentationFunctions {
-							instFunc(manager, c)
+						for _, diagnose := range manager.tracingFunctions.diagnostic {
+							diagnose(manager, c)
 						}
 						return true
 					})
+					continue
 				}
+				dstutil.Apply(fn, func(c *dstutil.Cursor) bool {
+					// statements and function literals that are already instrumented are left as they are
+					return !manager.WasInstrumented(c.Node())
+				}, func(c *dstutil.Cursor) bool {
+					// when updating a function that is already instrumented, only its statements are instrumented
+					if c.Node() == fn && manager.instrumented[fn] && fn.Name.Name != "main" {
+						return true
+					}
+					for _, instFunc := range instrumentationFunctions {
+						instFunc(manager, c)
+					}
+					for _, diagnose := range manager.tracingFunctions.diagnostic {
+						diagnose(manager, c)
+					}
+					return true
+				})
 			}
 		}
 	}
 	return nil
 }
 
-// Does not apply instrumentation to the package, only scans it.
+// Does not apply instrumentation to the package, only scans it. The packages are scanned concurrently, and what
+// was found in them is merged into the manager in the order of their package IDs.
 func scanPackages(manager *InstrumentationManager, instrumentationFunctions ...PreInstrumentationTracingFunction) error {
 	if instrumentationFunctions == nil {
 		return fmt.Errorf("error scanning packages: instrumentation functions are nil")
 	}
-	for pkgName, pkgState := range manager.packages {
-		if util.IsTestPackage(pkgState.pkg) {
-			continue
-		}
-		manager.setPackage(pkgName)
-		for _, file := range pkgState.pkg.Syntax {
+	walks := manager.walkPackages(func(_ int, walk *InstrumentationManager) {
+		for _, file := range walk.packages[walk.currentPackage].pkg.Syntax {
 			for _, decl := range file.Decls {
 				if fn, isFn := decl.(*dst.FuncDecl); isFn {
 					dstutil.Apply(fn, nil, func(c *dstutil.Cursor) bool {
 						for _, instFunc := range instrumentationFunctions {
-							instFunc(manager, c)
+							instFunc(walk, c)
 						}
 						return true
 					})
 				}
 			}
 		}
-	}
+	})
+	manager.mergeWalks(walks)
 	return nil
 }
 
 func (m *InstrumentationManager) ResolveUnitTests() error {
-	for _, pkgState := range m.packages {
+	for _, pkgID := range m.getSortedPackages() {
+		pkgState := m.packages[pkgID]
 		// vet that this is a package created to test another package
 		pkg := pkgState.pkg
 		// NOTE: do not switch this to util.IsTestPackage(), it will not work
 		if pkg.ForTest == "" {
 			continue
 		}
+		m.setPackage(pkgID)
 
 		for _, file := range pkg.Syntax {
 			if util.IsGenerated(pkg.Decorator, file) {
@@ -662,7 +1032,7 @@ func (m *InstrumentationManager) ResolveUnitTests() error {
 
 				// pointers to decls from the package being tested are coppied in test packages
 				// and will modify the original decl if incorrectly modified by this function
-				if m.isDefinedInPackage(fn.Name.Name, pkg.ForTest) {
+				if m.isDefinedInPackage(functionDeclKey(pkg, fn), pkg.ForTest) {
 					continue
 				}
 
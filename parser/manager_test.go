@@ -1,13 +1,18 @@
 package parser
 
 import (
+	"fmt"
+	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/dave/dst"
 	"github.com/dave/dst/decorator"
 	"github.com/dave/dst/dstutil"
 	"github.com/newrelic/go-easy-instrumentation/internal/codegen"
+	"github.com/newrelic/go-easy-instrumentation/internal/modules"
 	"github.com/newrelic/go-easy-instrumentation/parser/facts"
 	"github.com/newrelic/go-easy-instrumentation/parser/tracestate"
 	"github.com/stretchr/testify/assert"
@@ -302,7 +307,7 @@ func TestGetPackageFunctionInvocation(t *testing.T) {
 				currentPackage: "foo",
 			},
 			args: args{node: &dst.CallExpr{Fun: &dst.Ident{Name: "bar", Path: "foo"}}},
-			want: []*invocationInfo{{packageName: "foo", functionName: "bar", call: &dst.CallExpr{Fun: &dst.Ident{Name: "bar", Path: "foo"}}, decl: testFuncDecl}},
+			want: []*invocationInfo{{packageName: "foo", functionName: "bar", key: "bar", call: &dst.CallExpr{Fun: &dst.Ident{Name: "bar", Path: "foo"}}, decl: testFuncDecl}},
 		},
 		{
 			name: "empty_path_passes",
@@ -311,7 +316,7 @@ func TestGetPackageFunctionInvocation(t *testing.T) {
 				currentPackage: "foo",
 			},
 			args: args{node: &dst.CallExpr{Fun: &dst.Ident{Name: "bar"}}},
-			want: []*invocationInfo{{packageName: "foo", functionName: "bar", call: &dst.CallExpr{Fun: &dst.Ident{Name: "bar"}}, decl: testFuncDecl}},
+			want: []*invocationInfo{{packageName: "foo", functionName: "bar", key: "bar", call: &dst.CallExpr{Fun: &dst.Ident{Name: "bar"}}, decl: testFuncDecl}},
 		},
 		{
 			name: "finds_call_in_complex_node",
@@ -320,7 +325,7 @@ func TestGetPackageFunctionInvocation(t *testing.T) {
 				currentPackage: "foo",
 			},
 			args: args{node: &dst.ExprStmt{X: &dst.CallExpr{Fun: &dst.Ident{Name: "Sprintf", Path: "fmt"}, Args: []dst.Expr{&dst.CallExpr{Fun: &dst.Ident{Name: "bar"}}}}}},
-			want: []*invocationInfo{{packageName: "foo", functionName: "bar", call: &dst.CallExpr{Fun: &dst.Ident{Name: "bar"}}, decl: testFuncDecl}},
+			want: []*invocationInfo{{packageName: "foo", functionName: "bar", key: "bar", call: &dst.CallExpr{Fun: &dst.Ident{Name: "bar"}}, decl: testFuncDecl}},
 		},
 		{
 			name: "ignore_functions_not_in_package",
@@ -368,12 +373,13 @@ func TestGetPackageFunctionInvocation(t *testing.T) {
 				{
 					packageName:  "foo",
 					functionName: "bar",
+					key:          "bar",
 					call: &dst.CallExpr{Fun: &dst.SelectorExpr{
 						X:   &dst.CallExpr{Fun: &dst.Ident{Name: "bax"}},
 						Sel: &dst.Ident{Name: "bar", Path: "foo"},
 					}},
 					decl: testFuncDecl},
-				{packageName: "foo", functionName: "bax", call: &dst.CallExpr{Fun: &dst.Ident{Name: "bax"}}, decl: testFuncDecl},
+				{packageName: "foo", functionName: "bax", key: "bax", call: &dst.CallExpr{Fun: &dst.Ident{Name: "bax"}}, decl: testFuncDecl},
 			},
 		},
 		// Nested Methods: bax(bar()) should return an invocation for bax and bar
@@ -401,6 +407,7 @@ func TestGetPackageFunctionInvocation(t *testing.T) {
 				{
 					packageName:  "foo",
 					functionName: "bax",
+					key:          "bax",
 					call: &dst.CallExpr{
 						Fun: &dst.Ident{
 							Name: "bax", Path: "foo",
@@ -412,7 +419,7 @@ func TestGetPackageFunctionInvocation(t *testing.T) {
 						},
 					},
 					decl: testFuncDecl},
-				{packageName: "foo", functionName: "bar", call: &dst.CallExpr{Fun: &dst.Ident{Name: "bar", Path: "foo"}}, decl: testFuncDecl},
+				{packageName: "foo", functionName: "bar", key: "bar", call: &dst.CallExpr{Fun: &dst.Ident{Name: "bar", Path: "foo"}}, decl: testFuncDecl},
 			},
 		},
 	}
@@ -433,6 +440,51 @@ func TestGetPackageFunctionInvocation(t *testing.T) {
 	}
 }
 
+func TestGetPackageFunctionInvocation_Methods(t *testing.T) {
+	defer PanicRecovery(t)
+	uuid, _ := Pseudo_uuid()
+	testDir := fmt.Sprintf("tmp_%s", uuid)
+	defer CleanTestApp(t, testDir)
+
+	manager := TestInstrumentationManager(t, `package main
+
+type users struct{}
+
+func (users) Get() string { return "user" }
+
+type orders struct{}
+
+func (*orders) Get() string { return "order" }
+
+func get() string { return "" }
+
+func main() {
+	var g func() string = get
+	println((&orders{}).Get(), g())
+}
+`, testDir)
+	pkg := manager.getDecoratorPackage()
+	decls := map[string]*dst.FuncDecl{}
+	for _, decl := range pkg.Syntax[0].Decls {
+		if fn, ok := decl.(*dst.FuncDecl); ok {
+			manager.createFunctionDeclaration(fn)
+			decls[functionName(fn)] = fn
+		}
+	}
+	if len(manager.packages[manager.currentPackage].tracedFuncs) != len(decls) {
+		t.Fatalf("expected every function to be stored separately, got %+v", manager.packages[manager.currentPackage].tracedFuncs)
+	}
+
+	mainBody := decls["main"].Body.List
+	got := manager.findInvocationInfo(mainBody[len(mainBody)-1], tracestate.FunctionBody(codegen.DefaultTransactionVariable))
+	if len(got) != 1 {
+		t.Fatalf("expected only the call to orders.Get to be found, got %+v", got)
+	}
+	assert.Equal(t, decls["orders.Get"], got[0].decl)
+	assert.Equal(t, "Get", got[0].functionName)
+	assert.True(t, strings.HasSuffix(got[0].key, ".orders).Get"), got[0].key)
+}
+
 func TestShouldInstrumentFunction(t *testing.T) {
 	type fields struct {
 		userAppPath       string
@@ -457,7 +509,7 @@ func TestShouldInstrumentFunction(t *testing.T) {
 				packages:       map[string]*packageState{"foo": {tracedFuncs: map[string]*tracedFunctionDecl{"bar": {}}}},
 				currentPackage: "foo",
 			},
-			args: args{inv: &invocationInfo{packageName: "foo", functionName: "bar"}},
+			args: args{inv: &invocationInfo{packageName: "foo", functionName: "bar", key: "bar"}},
 			want: true,
 		},
 		{
@@ -475,7 +527,7 @@ func TestShouldInstrumentFunction(t *testing.T) {
 				packages:       map[string]*packageState{"foo": {tracedFuncs: map[string]*tracedFunctionDecl{"bar": {traced: true}}}},
 				currentPackage: "foo",
 			},
-			args: args{inv: &invocationInfo{packageName: "foo", functionName: "bar"}},
+			args: args{inv: &invocationInfo{packageName: "foo", functionName: "bar", key: "bar"}},
 			want: false,
 		},
 		{
@@ -484,7 +536,7 @@ func TestShouldInstrumentFunction(t *testing.T) {
 				packages:       map[string]*packageState{},
 				currentPackage: "foo",
 			},
-			args: args{inv: &invocationInfo{packageName: "foo", functionName: "bar"}},
+			args: args{inv: &invocationInfo{packageName: "foo", functionName: "bar", key: "bar"}},
 			want: false,
 		},
 	}
@@ -537,7 +589,7 @@ func TestGetInvocationInfoFromCall(t *testing.T) {
 				currentPackage: "foo",
 			},
 			args: args{call: &dst.CallExpr{Fun: &dst.Ident{Name: "bar", Path: "foo"}}, forTest: ""},
-			want: &invocationInfo{packageName: "foo", functionName: "bar", call: &dst.CallExpr{Fun: &dst.Ident{Name: "bar", Path: "foo"}}, decl: testFuncDecl},
+			want: &invocationInfo{packageName: "foo", functionName: "bar", key: "bar", call: &dst.CallExpr{Fun: &dst.Ident{Name: "bar", Path: "foo"}}, decl: testFuncDecl},
 		},
 		{
 			name: "ignore_functions_not_in_package",
@@ -555,7 +607,7 @@ func TestGetInvocationInfoFromCall(t *testing.T) {
 				currentPackage: "foo",
 			},
 			args: args{call: &dst.CallExpr{Fun: &dst.Ident{Name: "bar"}}, forTest: "foo"},
-			want: &invocationInfo{packageName: "foo", functionName: "bar", call: &dst.CallExpr{Fun: &dst.Ident{Name: "bar"}}, decl: testFuncDecl},
+			want: &invocationInfo{packageName: "foo", functionName: "bar", key: "bar", call: &dst.CallExpr{Fun: &dst.Ident{Name: "bar"}}, decl: testFuncDecl},
 		},
 	}
 	for _, tt := range tests {
@@ -924,6 +976,15 @@ func TestLoadTracingFunctions(t *testing.T) {
 				assert.Equal(t, 2, len(m.tracingFunctions.stateless))
 			},
 		},
+		{
+			name: "loadDiagnosticFunctions_adds_functions",
+			testFunc: func(m *InstrumentationManager) {
+				m.LoadDiagnosticFunctions(mockStateless)
+			},
+			verify: func(t *testing.T, m *InstrumentationManager) {
+				assert.Equal(t, 1, len(m.tracingFunctions.diagnostic))
+			},
+		},
 		{
 			name: "loadStatefulTracingFunctions_adds_functions",
 			testFunc: func(m *InstrumentationManager) {
@@ -961,6 +1022,28 @@ func TestLoadTracingFunctions(t *testing.T) {
 	}
 }
 
+func TestInstrumentPackages_Diagnostics(t *testing.T) {
+	manager := walkTestManager(2)
+	skipped := manager.packages["pkg0"].pkg.Syntax[0].Decls[0].(*dst.FuncDecl)
+	traced := manager.packages["pkg1"].pkg.Syntax[0].Decls[0].(*dst.FuncDecl)
+	manager.instrumented = map[*dst.FuncDecl]bool{skipped: true}
+
+	instrumented := map[dst.Node]bool{}
+	diagnosed := map[dst.Node]bool{}
+	manager.LoadDiagnosticFunctions(func(m *InstrumentationManager, c *dstutil.Cursor) {
+		diagnosed[c.Node()] = true
+	})
+	err := instrumentPackages(manager, func(m *InstrumentationManager, c *dstutil.Cursor) {
+		instrumented[c.Node()] = true
+	})
+	assert.NoError(t, err)
+
+	assert.False(t, instrumented[skipped.Body], "a function that is already instrumented is not changed")
+	assert.True(t, diagnosed[skipped.Body], "a function that is already instrumented is still diagnosed")
+	assert.True(t, instrumented[traced.Body])
+	assert.True(t, diagnosed[traced.Body])
+}
+
 func TestErrorNoMain(t *testing.T) {
 	type args struct {
 		path string
@@ -1194,3 +1277,64 @@ func TestInstrumentApplication(t *testing.T) {
 		})
 	}
 }
+
+func TestAddRequiredModulesToDiff(t *testing.T) {
+	defer PanicRecovery(t)
+	id, err := Pseudo_uuid()
+	if err != nil {
+		t.Fatal(err)
+	}
+	testDir := fmt.Sprintf("tmp_%s", id)
+	defer CleanTestApp(t, testDir)
+
+	manager := TestInstrumentationManager(t, "package main\n\nfunc main() {}\n", testDir)
+	manager.AddImport(codegen.NewRelicAgentImportPath)
+	manager.AddImport("net/http")
+
+	// a module cache containing only the go agent
+	cache := t.TempDir()
+	versionDir := filepath.Join(cache, "cache", "download", "github.com", "newrelic", "go-agent", "v3", "@v")
+	if err := os.MkdirAll(versionDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(versionDir, "v3.43.3.mod"), []byte("module github.com/newrelic/go-agent/v3\n\ngo 1.22\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(versionDir, "v3.43.3.ziphash"), []byte("h1:agent=\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	warnings, err := manager.AddRequiredModulesToDiff(modules.NewResolverWithCache(cache, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(warnings) != 0 {
+		t.Errorf("expected no warnings, got %v", warnings)
+	}
+
+	patches := map[string]string{}
+	for _, change := range manager.changes {
+		patches[change.diffName] = change.patch()
+	}
+	if !strings.Contains(patches["go.mod"], "+require github.com/newrelic/go-agent/v3 v3.43.3\n") {
+		t.Errorf("expected go.mod patch to require the go agent, got:\n%s", patches["go.mod"])
+	}
+	if !strings.HasPrefix(patches["go.sum"], "--- /dev/null\n+++ b/go.sum\n") {
+		t.Errorf("expected go.sum patch to create the file, got:\n%s", patches["go.sum"])
+	}
+	if !strings.Contains(patches["go.sum"], "+github.com/newrelic/go-agent/v3 v3.43.3 h1:agent=\n") {
+		t.Errorf("expected go.sum patch to contain the go agent checksum, got:\n%s", patches["go.sum"])
+	}
+
+	// the module files on disk must not be modified
+	goMod, err := os.ReadFile(filepath.Join(testDir, "go.mod"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(goMod), "go-agent") {
+		t.Errorf("expected go.mod on disk to be unchanged, got:\n%s", goMod)
+	}
+	if _, err := os.Stat(filepath.Join(testDir, "go.sum")); err == nil {
+		t.Error("expected go.sum not to be created on disk")
+	}
+}
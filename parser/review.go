@@ -0,0 +1,65 @@
+package parser
+
+import (
+	"fmt"
+
+	"github.com/newrelic/go-easy-instrumentation/internal/diff"
+)
+
+// FileHunks is the change proposed for a single file, split into hunks so that each can be reviewed on its own.
+type FileHunks struct {
+	File  string // name of the file as it appears in the diff
+	Hunks []*diff.Hunk
+}
+
+// ProposedChanges returns the hunks of every file that will be changed by the diff, in the order they are written.
+func (m *InstrumentationManager) ProposedChanges() ([]FileHunks, error) {
+	if err := m.restoreChanges(); err != nil {
+		return nil, err
+	}
+
+	files := []FileHunks{}
+	for _, change := range m.changes {
+		if change.original == change.modified {
+			continue
+		}
+		hunks, err := diff.Parse(change.patch())
+		if err != nil {
+			return nil, fmt.Errorf("parsing changes to %s: %w", change.diffName, err)
+		}
+		files = append(files, FileHunks{File: change.diffName, Hunks: hunks})
+	}
+	return files, nil
+}
+
+// KeepHunks replaces the proposed change for each of the given files with only the given hunks, which must
+// come from ProposedChanges, and may have been edited with diff.ParseEdited. A file without hunks is left
+// unchanged, and is not written to the diff. Files that are not given keep all of their changes.
+func (m *InstrumentationManager) KeepHunks(files []FileHunks) error {
+	if err := m.restoreChanges(); err != nil {
+		return err
+	}
+
+	kept := map[string][]*diff.Hunk{}
+	for _, file := range files {
+		kept[file.File] = file.Hunks
+	}
+
+	for _, change := range m.changes {
+		hunks, ok := kept[change.diffName]
+		if !ok {
+			continue
+		}
+		modified, err := diff.Apply(change.original, hunks)
+		if err != nil {
+			return fmt.Errorf("applying reviewed changes to %s: %w", change.diffName, err)
+		}
+		change.modified = modified
+		delete(kept, change.diffName)
+	}
+
+	for file := range kept {
+		return fmt.Errorf("no changes were proposed for %s", file)
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/dave/dst"
+	"github.com/newrelic/go-easy-instrumentation/internal/diff"
+)
+
+const reviewTestApp = `package main
+
+import "fmt"
+
+func first() {
+	fmt.Println("first")
+}
+
+// spacer line 1
+// spacer line 2
+// spacer line 3
+// spacer line 4
+// spacer line 5
+// spacer line 6
+// spacer line 7
+// spacer line 8
+// spacer line 9
+// spacer line 10
+
+func last() {
+	fmt.Println("last")
+}
+
+func main() {
+	first()
+	last()
+}
+`
+
+// reviewTestManager creates a manager for the review test app, with a change to first and to last that
+// are far enough apart to be in separate hunks.
+func reviewTestManager(t *testing.T) *InstrumentationManager {
+	id, err := Pseudo_uuid()
+	if err != nil {
+		t.Fatal(err)
+	}
+	testDir := fmt.Sprintf("tmp_%s", id)
+	t.Cleanup(func() { CleanTestApp(t, testDir) })
+
+	manager := TestInstrumentationManager(t, reviewTestApp, testDir)
+	for _, decl := range manager.getDecoratorPackage().Syntax[0].Decls {
+		fn, ok := decl.(*dst.FuncDecl)
+		if !ok || (fn.Name.Name != "first" && fn.Name.Name != "last") {
+			continue
+		}
+		fn.Body.List = append(fn.Body.List, &dst.ExprStmt{X: &dst.CallExpr{
+			Fun:  &dst.Ident{Name: "Println", Path: "fmt"},
+			Args: []dst.Expr{&dst.BasicLit{Value: fmt.Sprintf("%q", "instrumented "+fn.Name.Name)}},
+		}})
+	}
+	return manager
+}
+
+func TestProposedChanges(t *testing.T) {
+	defer PanicRecovery(t)
+	manager := reviewTestManager(t)
+
+	files, err := manager.ProposedChanges()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || files[0].File != "app.go" {
+		t.Fatalf("expected changes to app.go, got %+v", files)
+	}
+	if len(files[0].Hunks) != 2 {
+		t.Fatalf("expected 2 hunks, got %d", len(files[0].Hunks))
+	}
+}
+
+func TestKeepHunks(t *testing.T) {
+	defer PanicRecovery(t)
+	manager := reviewTestManager(t)
+
+	files, err := manager.ProposedChanges()
+	if err != nil {
+		t.Fatal(err)
+	}
+	hunks := files[0].Hunks
+
+	// keep the first hunk as it is, and edit the second
+	text := hunks[1].String()
+	edited, err := diff.ParseEdited(hunks[1], strings.Replace(text[strings.Index(text, "\n")+1:], "instrumented last", "edited last", 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.KeepHunks([]FileHunks{{File: "app.go", Hunks: []*diff.Hunk{hunks[0], edited}}}); err != nil {
+		t.Fatal(err)
+	}
+
+	modified := manager.changes[0].modified
+	if !strings.Contains(modified, `"instrumented first"`) || !strings.Contains(modified, `"edited last"`) || strings.Contains(modified, `"instrumented last"`) {
+		t.Errorf("unexpected reviewed changes:\n%s", manager.changes[0].patch())
+	}
+
+	// rejecting every hunk leaves the file out of the diff
+	if err := manager.KeepHunks([]FileHunks{{File: "app.go"}}); err != nil {
+		t.Fatal(err)
+	}
+	if patch := manager.changes[0].patch(); patch != "" {
+		t.Errorf("expected no changes, got:\n%s", patch)
+	}
+
+	if err := manager.KeepHunks([]FileHunks{{File: "missing.go"}}); err == nil {
+		t.Error("expected an error for a file without proposed changes, got nil")
+	}
+}
@@ -0,0 +1,143 @@
+package parser
+
+import (
+	"fmt"
+	"go/types"
+	"slices"
+
+	"github.com/dave/dst"
+	"github.com/dave/dst/decorator"
+	"github.com/newrelic/go-easy-instrumentation/internal/comment"
+	"github.com/newrelic/go-easy-instrumentation/internal/filter"
+	"github.com/newrelic/go-easy-instrumentation/internal/report"
+)
+
+// SetPreserveSignatures sets whether the signatures of exported functions and methods, and of methods that
+// implement an interface, are preserved. By default, a transaction parameter is added to the functions that
+// tracing is passed to when they do not take a context or transaction already, which breaks the code of other
+// modules that call them. When signatures are preserved, these functions are not traced unless the transaction
+// can be passed to them in a parameter they already have, and each of them is reported with a warning.
+func (m *InstrumentationManager) SetPreserveSignatures(preserve bool) {
+	m.preserveSignatures = preserve
+}
+
+// PreservedSignatures returns the names of the functions that were not traced because their signatures are
+// preserved, in the form `<import path>.Func` or `<import path>.Type.Method`, sorted.
+func (m *InstrumentationManager) PreservedSignatures() []string {
+	names := []string{}
+	for _, name := range m.preserved {
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	for _, result := range m.cached {
+		names = append(names, result.Preserved...)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// canTrace returns true if tracing can be passed to the function that is invoked. When signatures are preserved,
+// or the function is an implementation of an interface method or is used as a value, a function whose signature
+// must not change can only be traced if it already takes a context or a transaction; otherwise, it is left
+// untraced, and a warning is added to its declaration the first time it is called.
+func (m *InstrumentationManager) canTrace(inv *invocationInfo) bool {
+	if inv == nil || inv.decl == nil || !m.preservesSignatures() && !inv.implementation && !m.isFunctionValue(inv) {
+		return true
+	}
+	state, ok := m.packages[inv.packageName]
+	if !ok {
+		return true
+	}
+	if m.preserved == nil {
+		m.preserved = map[*dst.FuncDecl]string{}
+	}
+	name, checked := m.preserved[inv.decl]
+	if !checked {
+		name = ""
+		if !hasTracingParameter(state.pkg, inv.decl) && (inv.implementation || m.isFunctionValue(inv) || m.mustPreserveSignature(state.pkg, inv.decl)) {
+			name = filter.FunctionName(state.pkg.PkgPath, functionName(inv.decl))
+			comment.Warn(state.pkg, inv.decl, inv.decl, fmt.Sprintf("%s was not traced, since a transaction can not be passed to it without changing its signature", inv.decl.Name.Name), "add a context.Context parameter to it to trace it, or instrument it manually")
+			report.Warning(state.pkg, inv.decl, report.CoreIntegration, report.CodeSignaturePreserved, fmt.Sprintf("%s was not traced to preserve its signature", name))
+		}
+		m.preserved[inv.decl] = name
+	}
+	return name == ""
+}
+
+// mustPreserveSignature returns true if changing the signature of a function could break code that uses it: it
+// is exported from a package other than main, which can be used by other modules, or it is a method that
+// implements an interface.
+func (m *InstrumentationManager) mustPreserveSignature(pkg *decorator.Package, decl *dst.FuncDecl) bool {
+	if decl.Name.IsExported() && pkg.Name != "main" {
+		return true
+	}
+	return decl.Recv != nil && m.implementsInterface(pkg, decl)
+}
+
+// implementsInterface returns true if the receiver of a method implements an interface that has a method with the
+// same name, out of the interfaces declared in the packages of the application and the packages they import.
+func (m *InstrumentationManager) implementsInterface(pkg *decorator.Package, decl *dst.FuncDecl) bool {
+	fn, ok := objectOf(pkg, decl.Name).(*types.Func)
+	if !ok {
+		return false
+	}
+	recv := fn.Type().(*types.Signature).Recv()
+	if recv == nil {
+		return false
+	}
+	recvType := recv.Type()
+	if ptr, ok := recvType.(*types.Pointer); ok {
+		recvType = ptr.Elem()
+	}
+
+	scopes := map[*types.Package]bool{}
+	for _, state := range m.packages {
+		if state.pkg.Types == nil {
+			continue
+		}
+		scopes[state.pkg.Types] = true
+		for _, imported := range state.pkg.Types.Imports() {
+			scopes[imported] = true
+		}
+	}
+	for scope := range scopes {
+		for _, name := range scope.Scope().Names() {
+			typeName, ok := scope.Scope().Lookup(name).(*types.TypeName)
+			if !ok {
+				continue
+			}
+			iface, ok := typeName.Type().Underlying().(*types.Interface)
+			if !ok || !hasMethod(iface, decl.Name.Name) {
+				continue
+			}
+			if types.Implements(recvType, iface) || types.Implements(types.NewPointer(recvType), iface) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func hasMethod(iface *types.Interface, name string) bool {
+	for i := 0; i < iface.NumMethods(); i++ {
+		if iface.Method(i).Name() == name {
+			return true
+		}
+	}
+	return false
+}
+
+// hasTracingParameter returns true if a function already takes a transaction or a context, so that tracing can
+// be passed to it without changing its signature.
+func hasTracingParameter(pkg *decorator.Package, decl *dst.FuncDecl) bool {
+	for _, field := range decl.Type.Params.List {
+		if isTransactionParameter(field) {
+			return true
+		}
+		if t := typeOf(pkg, field.Type); t != nil && t.String() == "context.Context" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,111 @@
+package parser
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/dave/dst"
+	"github.com/dave/dst/decorator"
+	"github.com/newrelic/go-easy-instrumentation/parser/tracestate"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTraceFunction_PreserveSignatures(t *testing.T) {
+	code := `package main
+
+import "context"
+
+type greeter interface {
+	Greet()
+}
+
+type english struct{}
+
+func (english) Greet() {
+	println("hello")
+}
+
+func work() {
+	println("work")
+}
+
+func withContext(ctx context.Context) {
+	println("context")
+}
+
+func main() {
+	var g greeter = english{}
+	english{}.Greet()
+	work()
+	withContext(context.Background())
+	_ = g
+}
+`
+	tests := []struct {
+		name      string
+		preserve  bool
+		expect    []string
+		preserved []string
+	}{
+		{
+			name:     "signatures are changed by default",
+			preserve: false,
+			expect: []string{
+				"func (english) Greet(nrTxn *newrelic.Transaction) {",
+				"func work(nrTxn *newrelic.Transaction) {",
+				"func withContext(ctx context.Context) {",
+			},
+		},
+		{
+			name:     "interface methods keep their signatures",
+			preserve: true,
+			expect: []string{
+				"// NR WARN: Greet was not traced, since a transaction can not be passed to it without changing its signature",
+				"func (english) Greet() {",
+				"\tenglish{}.Greet()\n",
+				"func work(nrTxn *newrelic.Transaction) {",
+				"func withContext(ctx context.Context) {",
+			},
+			preserved: []string{"english.Greet"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer PanicRecovery(t)
+			uuid, _ := Pseudo_uuid()
+			testDir := fmt.Sprintf("tmp_%s", uuid)
+			defer CleanTestApp(t, testDir)
+
+			manager := TestInstrumentationManager(t, code, testDir)
+			manager.SetPreserveSignatures(tt.preserve)
+			pkg := manager.getDecoratorPackage()
+
+			var mainFunc *dst.FuncDecl
+			for _, decl := range pkg.Syntax[0].Decls {
+				if funcDecl, ok := decl.(*dst.FuncDecl); ok {
+					manager.createFunctionDeclaration(funcDecl)
+					if funcDecl.Name.Name == "main" {
+						mainFunc = funcDecl
+					}
+				}
+			}
+			TraceFunction(manager, mainFunc, tracestate.Main("app"))
+
+			buf := bytes.NewBuffer([]byte{})
+			if err := decorator.NewRestorerWithImports(testDir, createTestResolver(testDir)).Fprint(buf, pkg.Syntax[0]); err != nil {
+				t.Fatal(err)
+			}
+			for _, expect := range tt.expect {
+				assert.Contains(t, buf.String(), expect)
+			}
+
+			preserved := manager.PreservedSignatures()
+			assert.Len(t, preserved, len(tt.preserved))
+			for i, name := range tt.preserved {
+				assert.True(t, strings.HasSuffix(preserved[i], "."+name), "expected %s to be preserved, got %s", name, preserved[i])
+			}
+		})
+	}
+}
@@ -16,6 +16,7 @@ import (
 	"github.com/dave/dst/decorator/resolver"
 	"github.com/dave/dst/decorator/resolver/guess"
 	"github.com/dave/dst/dstutil"
+	"github.com/newrelic/go-easy-instrumentation/internal/diff"
 	"github.com/newrelic/go-easy-instrumentation/parser/tracestate"
 	"golang.org/x/tools/go/packages"
 )
@@ -80,32 +81,7 @@ func Pseudo_uuid() (string, error) {
 // createTestResolver creates a resolver that handles hyphenated import paths
 // like nrecho-v4 by using a predefined map for known integrations.
 func createTestResolver(dir string) resolver.RestorerResolver {
-	// Pre-populate known aliases for hyphenated paths that guess.New() can't handle
-	knownAliases := map[string]string{
-		"github.com/newrelic/go-agent/v3/integrations/nrecho-v4": "nrecho",
-		"github.com/newrelic/go-agent/v3/integrations/nrecho-v3": "nrecho",
-	}
-
-	return &combinedResolver{
-		known: guess.WithMap(knownAliases),
-		guess: guess.New(),
-	}
-}
-
-// combinedResolver tries known aliases first, then falls back to guess.
-type combinedResolver struct {
-	known resolver.RestorerResolver
-	guess resolver.RestorerResolver
-}
-
-func (r *combinedResolver) ResolvePackage(path string) (string, error) {
-	// Try known aliases first (handles hyphenated paths)
-	alias, err := r.known.ResolvePackage(path)
-	if err == nil && alias != "" {
-		return alias, nil
-	}
-	// Fall back to guess for everything else
-	return r.guess.ResolvePackage(path)
+	return guess.WithMap(knownPackageNames)
 }
 
 func TestInstrumentationManager(t *testing.T, code, testAppDir string) *InstrumentationManager {
@@ -183,6 +159,15 @@ func RunStatefulTracingFunction(t *testing.T, code string, stmtFunc StatefulTrac
 
 // RunStatelessTracingFunction runs a stateless tracing function against test code.
 func RunStatelessTracingFunction(t *testing.T, code string, tracingFunc StatelessTracingFunction, statefulTracingFuncs ...StatefulTracingFunction) string {
+	return runTracingFunction(t, code, false, tracingFunc, statefulTracingFuncs...)
+}
+
+// RunLibraryTracingFunction runs a stateless tracing function against test code that is instrumented as a library.
+func RunLibraryTracingFunction(t *testing.T, code string, tracingFunc StatelessTracingFunction, statefulTracingFuncs ...StatefulTracingFunction) string {
+	return runTracingFunction(t, code, true, tracingFunc, statefulTracingFuncs...)
+}
+
+func runTracingFunction(t *testing.T, code string, library bool, tracingFunc StatelessTracingFunction, statefulTracingFuncs ...StatefulTracingFunction) string {
 	id, err := Pseudo_uuid()
 	if err != nil {
 		t.Fatal(err)
@@ -197,6 +182,7 @@ func RunStatelessTracingFunction(t *testing.T, code string, tracingFunc Stateles
 		t.Fatalf("Package was nil: %+v", manager.packages)
 	}
 
+	manager.SetLibrary(library)
 	manager.tracingFunctions.stateful = append(manager.tracingFunctions.stateful, statefulTracingFuncs...)
 	manager.tracingFunctions.stateless = append(manager.tracingFunctions.stateless, tracingFunc)
 	err = manager.TracePackageCalls()
@@ -235,3 +221,57 @@ func UnitTest(t *testing.T, code string) []*decorator.Package {
 
 	return pkgs
 }
+
+// RunUninstrumentFunctions removes instrumentation from test code with the given uninstrument functions. It
+// returns the resulting code, and the warnings for the instrumentation that could not be removed.
+func RunUninstrumentFunctions(t *testing.T, code string, functions ...UninstrumentFunction) (string, []string) {
+	id, err := Pseudo_uuid()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testDir := fmt.Sprintf("tmp_%s", id)
+	defer CleanTestApp(t, testDir)
+
+	manager := TestInstrumentationManager(t, code, testDir)
+	pkg := manager.getDecoratorPackage()
+	if pkg == nil {
+		t.Fatalf("Package was nil: %+v", manager.packages)
+	}
+	manager.LoadUninstrumentFunctions(functions...)
+	warnings, err := manager.RemoveInstrumentation()
+	if err != nil {
+		t.Fatalf("Failed to remove instrumentation: %v", err)
+	}
+	restorer := decorator.NewRestorerWithImports(testDir, createTestResolver(testDir))
+
+	buf := bytes.NewBuffer([]byte{})
+	err = restorer.Fprint(buf, pkg.Syntax[0])
+	if err != nil {
+		t.Fatalf("Failed to restore the file: %v", err)
+	}
+
+	return buf.String(), warnings
+}
+
+// InstrumentedExample returns the main.go of an example application, and the code that instrumenting it produces,
+// which is rebuilt by applying the expect.ref diff of the example to it.
+func InstrumentedExample(t *testing.T, exampleDir string) (string, string) {
+	original, err := os.ReadFile(filepath.Join(exampleDir, "main.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	patch, err := os.ReadFile(filepath.Join(exampleDir, "expect.ref"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hunks, err := diff.Parse(string(patch))
+	if err != nil {
+		t.Fatalf("Failed to parse %s: %v", filepath.Join(exampleDir, "expect.ref"), err)
+	}
+	instrumented, err := diff.Apply(string(original), hunks)
+	if err != nil {
+		t.Fatalf("Failed to apply %s: %v", filepath.Join(exampleDir, "expect.ref"), err)
+	}
+	return string(original), instrumented
+}
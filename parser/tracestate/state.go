@@ -4,6 +4,7 @@ package tracestate
 
 import (
 	"fmt"
+	"go/token"
 
 	"github.com/dave/dst"
 	"github.com/dave/dst/decorator"
@@ -21,16 +22,22 @@ import (
 // When creating a state, a TraceObject should be passed. This object identifies the way the transaction is being passed
 // to the function, and takes care of how to correctly handle it.
 type State struct {
-	main             bool                    // main indicates that the current state is for a main function.
-	txnUsed          bool                    // txnUsed indicates that the transaction variable has been used in the current scope.
-	definedTxn       bool                    // definedTxn indicates that a transaction has been defined from an agent application in the current scope.
-	async            bool                    // async indicates that the current function is an async function.
-	needsSegment     bool                    // needsSegment indicates that a segment should be created for the current function.
-	addTracingParam  bool                    // addTracingParam indicates that a tracing parameter should be added to the current function.
-	agentVariable    string                  // agentVariable is the name of the agent variable in the main function.
-	txnVariable      string                  // txnVariable is the name of the transaction variable in the current scope.
-	object           traceobject.TraceObject // object is the object that contains the transaction, along with helper functions for how to utilize it.
-	funcLitVariables map[string]*dst.FuncLit // funcLitVariables is a map of function literals that have been created in the current scope.
+	main             bool                         // main indicates that the current state is for a main function.
+	txnUsed          bool                         // txnUsed indicates that the transaction variable has been used in the current scope.
+	definedTxn       bool                         // definedTxn indicates that a transaction has been defined from an agent application in the current scope.
+	async            bool                         // async indicates that the current function is an async function.
+	needsSegment     bool                         // needsSegment indicates that a segment should be created for the current function.
+	addTracingParam  bool                         // addTracingParam indicates that a tracing parameter should be added to the current function.
+	newGoroutine     bool                         // newGoroutine indicates that the transaction captured by the current function literal must be copied for the goroutine it runs in.
+	endsTransaction  bool                         // endsTransaction indicates that the current function ends the transaction passed to it, which was started for the goroutine it runs in.
+	agentVariable    string                       // agentVariable is the name of the agent variable in the main function.
+	segmentName      string                       // segmentName is the name of the segment created for the current function, if it is not named after it.
+	txnVariable      string                       // txnVariable is the name of the transaction variable in the current scope.
+	txnName          string                       // txnName is the name given to the transaction variables created by instrumentation, see SetTransactionName.
+	object           traceobject.TraceObject      // object is the object that contains the transaction, along with helper functions for how to utilize it.
+	funcLitVariables map[string]*dst.FuncLit      // funcLitVariables is a map of function literals that have been created in the current scope.
+	spans            map[dst.Stmt]transactionSpan // spans maps the statement that starts each transaction wrapping goroutines in main to the span it starts.
+	spanEnd          dst.Stmt                     // spanEnd is the statement that ends the transaction span the current statement is in, if any.
 }
 
 // Main creates a new State object for tracing a main function.
@@ -69,6 +76,40 @@ func FunctionBody(transactionVariable string, obj ...traceobject.TraceObject) *S
 	}
 }
 
+// EntryPoint creates a trace state for an entry point of a library, which is called by other applications. The
+// transaction of the application that calls it, if any, is taken from the context parameter named contextVariable,
+// and a segment is added to the function.
+func EntryPoint(contextVariable string) *State {
+	return &State{
+		object:           traceobject.NewContext(contextVariable),
+		needsSegment:     true,
+		funcLitVariables: make(map[string]*dst.FuncLit),
+	}
+}
+
+// RequestEntryPoint creates a trace state for an entry point of a library that handles HTTP requests. The
+// transaction of the application that serves the request, if any, is taken from the context of the *http.Request
+// parameter named requestVariable, and a segment is added to the function.
+func RequestEntryPoint(requestVariable string) *State {
+	return &State{
+		object:           traceobject.NewRequestContext(requestVariable),
+		needsSegment:     true,
+		funcLitVariables: make(map[string]*dst.FuncLit),
+	}
+}
+
+// FunctionValue creates a trace state for a function declaration that is used as a value rather than called, such
+// as a method value passed as a callback. The transaction is passed to it in the context or transaction parameter
+// it already has by the code that calls it, so it must have one.
+func FunctionValue() *State {
+	return &State{
+		object:           traceobject.NewTransaction(),
+		needsSegment:     true,
+		addTracingParam:  true,
+		funcLitVariables: make(map[string]*dst.FuncLit),
+	}
+}
+
 // Placeholder creates a placeholder state for testing.
 func Placeholder() *State {
 	return &State{
@@ -81,6 +122,7 @@ func Placeholder() *State {
 func (tc *State) functionCall(obj traceobject.TraceObject) *State {
 	return &State{
 		txnVariable:      tc.txnVariable,
+		txnName:          tc.txnName,
 		object:           obj,
 		main:             false,
 		needsSegment:     true,
@@ -94,6 +136,7 @@ func (tc *State) functionCall(obj traceobject.TraceObject) *State {
 func (tc *State) goroutine(obj traceobject.TraceObject) *State {
 	return &State{
 		txnVariable:      tc.txnVariable,
+		txnName:          tc.txnName,
 		object:           obj,
 		needsSegment:     true,
 		addTracingParam:  true,
@@ -102,6 +145,23 @@ func (tc *State) goroutine(obj traceobject.TraceObject) *State {
 	}
 }
 
+// SetTransactionName sets the name given to the transaction variables that instrumentation creates in the current
+// scope and in the functions traced from it. The transaction variable of a main function always has this name.
+func (tc *State) SetTransactionName(name string) {
+	tc.txnName = name
+	if tc.main {
+		tc.txnVariable = name
+	}
+}
+
+// transactionName returns the name given to the transaction variables created by instrumentation.
+func (tc *State) transactionName() string {
+	if tc.txnName == "" {
+		return codegen.DefaultTransactionVariable
+	}
+	return tc.txnName
+}
+
 // CreateSegment creates a segment for the current function if needed.
 // Calling this will add a defer statement to the function declaration that will create a segment as the first
 // statement in the function.
@@ -114,6 +174,9 @@ func (tc *State) CreateSegment(node dst.Node) (string, bool) {
 	switch decl := node.(type) {
 	case *dst.FuncDecl:
 		name := decl.Name.Name
+		if tc.segmentName != "" {
+			name = tc.segmentName
+		}
 		if tc.async {
 			name = fmt.Sprintf("async %s", name)
 		}
@@ -134,14 +197,34 @@ func (tc *State) CreateSegment(node dst.Node) (string, bool) {
 	return "", false
 }
 
+// NameSegment sets the name of the segment created for the current function, instead of the name of the function,
+// such as the receiver type and name of a method that implements the interface method that was called.
+func (tc *State) NameSegment(name string) {
+	tc.segmentName = name
+}
+
+// Clone returns a copy of a trace state that has not traced a function yet, so that a call that may invoke
+// several functions, such as a call to an interface method, can trace each of them with its own state.
+// Cloning a nil state returns nil.
+func (tc *State) Clone() *State {
+	if tc == nil {
+		return nil
+	}
+	clone := *tc
+	clone.funcLitVariables = make(map[string]*dst.FuncLit)
+	return &clone
+}
+
 // WrapWithTransaction creates a transaction in the line before the current cursor position if all of these contidions are met:
 //  1. The agent variable is in scope
 //  2. The cursor is in a function body
 //  3. We are in the main method
+//  4. The statement is not in a transaction span that wraps goroutines, see WrapGoroutinesWithTransaction
 //
 // The transaction created will always be assigned to a variable with the default transaction variable name.
-func (tc *State) WrapWithTransaction(c *dstutil.Cursor, functionName, transactionVariable string) {
-	if tc.main && tc.agentVariable != "" && c.Index() >= 0 {
+// Returns true if a transaction was created.
+func (tc *State) WrapWithTransaction(c *dstutil.Cursor, functionName, transactionVariable string) bool {
+	if tc.main && tc.agentVariable != "" && c.Index() >= 0 && tc.spanEnd == nil {
 		tc.txnVariable = transactionVariable
 		start := codegen.StartTransaction(tc.agentVariable, tc.txnVariable, functionName, tc.definedTxn)
 		tc.definedTxn = true
@@ -149,7 +232,125 @@ func (tc *State) WrapWithTransaction(c *dstutil.Cursor, functionName, transactio
 		codegen.WrapStatements(start, c.Node().(dst.Stmt), end)
 		c.InsertBefore(start)
 		c.InsertAfter(end)
+		return true
+	}
+	return false
+}
+
+// WrapGoroutinesWithTransaction starts a transaction before the statement at index first of a block in the main
+// function, and ends it after the statement at index last, so that the goroutines started by the statements in
+// between and joined by the last one, such as with sync.WaitGroup.Wait, run as part of it. The statements in this
+// span pass its transaction to the functions they call instead of starting their own. If topLevel is false, the
+// block is nested in the body of main, and the transaction variable is declared in it.
+// Returns true if the transaction was added.
+func (tc *State) WrapGoroutinesWithTransaction(block *dst.BlockStmt, first, last int, functionName string, topLevel bool) bool {
+	if !tc.main || tc.agentVariable == "" || first < 0 || last < first || last >= len(block.List) {
+		return false
+	}
+	// whether the transaction variable is defined by the start is decided once it is reached, see EnterStatement
+	start := codegen.StartTransaction(tc.agentVariable, tc.transactionName(), functionName, false)
+	end := codegen.EndTransaction(tc.transactionName())
+	moveDecorations(start, end, block.List[first], block.List[last])
+
+	list := make([]dst.Stmt, 0, len(block.List)+2)
+	list = append(list, block.List[:first]...)
+	list = append(list, start)
+	list = append(list, block.List[first:last+1]...)
+	list = append(list, end)
+	list = append(list, block.List[last+1:]...)
+	block.List = list
+
+	if tc.spans == nil {
+		tc.spans = make(map[dst.Stmt]transactionSpan)
+	}
+	tc.spans[start] = transactionSpan{end: end, topLevel: topLevel}
+	return true
+}
+
+// StartBackgroundTransaction starts a transaction in the line before the current cursor position, which must be a
+// goroutine started in the main function that is never joined, such as a long-lived worker. The transaction is passed
+// to the goroutine, which ends it when it returns. If topLevel is false, the statement is nested in the body of main,
+// and the transaction variable is declared in its block.
+// Returns true if a transaction was created.
+func (tc *State) StartBackgroundTransaction(c *dstutil.Cursor, functionName string, topLevel bool) bool {
+	if !tc.main || tc.agentVariable == "" || c.Index() < 0 {
+		return false
+	}
+	start := codegen.StartTransaction(tc.agentVariable, tc.transactionName(), functionName, topLevel && tc.definedTxn)
+	if topLevel {
+		tc.definedTxn = true
+	}
+	stmt := c.Node().(dst.Stmt)
+	moveDecorations(start, nil, stmt, nil)
+	c.InsertBefore(start)
+	return true
+}
+
+// moveDecorations moves the spacing and comments before the first statement to the statement inserted before it,
+// and those after the last statement to the statement inserted after it, if any.
+func moveDecorations(before, after, first, last dst.Stmt) {
+	beforeDecs, firstDecs := before.Decorations(), first.Decorations()
+	beforeDecs.Before = firstDecs.Before
+	beforeDecs.Start = firstDecs.Start
+	firstDecs.Before = dst.None
+	firstDecs.Start = nil
+
+	if after != nil {
+		afterDecs, lastDecs := after.Decorations(), last.Decorations()
+		afterDecs.After = lastDecs.After
+		afterDecs.End = lastDecs.End
+		lastDecs.After = dst.None
+		lastDecs.End = nil
+	}
+}
+
+// transactionSpan is a transaction that wraps goroutines started in main until they are joined.
+type transactionSpan struct {
+	end      dst.Stmt // end is the statement that ends the transaction.
+	topLevel bool     // topLevel is true if the transaction is started in the body of main rather than a nested block.
+}
+
+// EnterStatement is called before a statement of the current function is traced, and ExitStatement once the
+// statement and the statements nested in it are, so that the state knows whether they are in a transaction span.
+// Spans are created before the function is traced, so the start of a span in the body of main only assigns the
+// transaction variable, rather than defining it, if a transaction was started before it.
+func (tc *State) EnterStatement(stmt dst.Stmt) {
+	span, ok := tc.spans[stmt]
+	if !ok || tc.spanEnd != nil {
+		return
+	}
+	if span.topLevel {
+		if tc.definedTxn {
+			stmt.(*dst.AssignStmt).Tok = token.ASSIGN
+		}
+		tc.definedTxn = true
 	}
+	tc.spanEnd = span.end
+}
+
+// ExitStatement is called once a statement and the statements nested in it are traced, see EnterStatement.
+func (tc *State) ExitStatement(stmt dst.Stmt) {
+	if tc.spanEnd != nil && stmt == tc.spanEnd {
+		tc.spanEnd = nil
+	}
+}
+
+// InTransactionSpan returns true if the current statement is in a transaction span that wraps goroutines.
+func (tc *State) InTransactionSpan() bool {
+	return tc.spanEnd != nil
+}
+
+// EndTransaction records that the current function ends the transaction passed to it when it returns, rather than
+// adding a segment to it, since the transaction was started for the goroutine the function runs in.
+func (tc *State) EndTransaction() {
+	tc.endsTransaction = true
+	tc.needsSegment = false
+}
+
+// DefineTransaction records that the transaction variable is already defined in the current scope, so that
+// the transactions created later are assigned to it rather than declaring it again.
+func (tc *State) DefineTransaction() {
+	tc.definedTxn = true
 }
 
 // IsMain returns true if the current state is for a main function.
@@ -160,7 +361,7 @@ func (tc *State) IsMain() bool {
 // TransactionVariable returns the name of the transaction variable.
 func (tc *State) TransactionVariable() dst.Expr {
 	if tc.main || tc.txnVariable == "" {
-		tc.txnVariable = codegen.DefaultTransactionVariable
+		tc.txnVariable = tc.transactionName()
 	}
 
 	tc.txnUsed = true
@@ -207,6 +408,35 @@ func (tc *State) FuncLiteralDeclaration(pkg *decorator.Package, lit *dst.FuncLit
 	return tc.functionCall(tc.object)
 }
 
+// FuncLiteralValue creates a trace state for a function literal that is used as a value rather than called in
+// the current scope, such as a callback passed to a function, or a handler stored in a struct field or a map. Its
+// signature is set by the code that calls it, so no parameter is added to it; it captures the transaction of the
+// current scope instead, so it should only run while that transaction is in progress. If the literal runs in
+// another goroutine, async should be true, and the literal makes a copy of the transaction with NewGoroutine before
+// using it.
+func (tc *State) FuncLiteralValue(async bool) *State {
+	if !async {
+		return &State{
+			txnVariable:      tc.txnVariable,
+			txnName:          tc.txnName,
+			object:           tc.object,
+			needsSegment:     true,
+			funcLitVariables: make(map[string]*dst.FuncLit),
+		}
+	}
+
+	tc.TransactionVariable() // the transaction of the current scope must be in scope for the literal to capture it
+	return &State{
+		txnVariable:      tc.txnVariable,
+		txnName:          tc.txnName,
+		object:           traceobject.NewTransaction(),
+		needsSegment:     true,
+		async:            true,
+		newGoroutine:     true,
+		funcLitVariables: make(map[string]*dst.FuncLit),
+	}
+}
+
 // NoticeFuncLiteralAssignment is called when a function literal is assigned to a variable.
 func (tc *State) NoticeFuncLiteralAssignment(pkg *decorator.Package, variable dst.Expr, lit *dst.FuncLit) {
 	variableString := util.WriteExpr(variable, pkg)
@@ -244,11 +474,11 @@ func (tc *State) AddParameterToDeclaration(pkg *decorator.Package, node dst.Node
 	if tc.addTracingParam {
 		switch decl := node.(type) {
 		case *dst.FuncDecl:
-			obj, goGet := tc.object.AddToFuncDecl(pkg, decl)
+			obj, goGet := tc.object.AddToFuncDecl(pkg, decl, tc.transactionName())
 			tc.object = obj
 			return goGet, true
 		case *dst.FuncLit:
-			obj, goGet := tc.object.AddToFuncLit(pkg, decl)
+			obj, goGet := tc.object.AddToFuncLit(pkg, decl, tc.transactionName())
 			tc.object = obj
 			return goGet, true
 		}
@@ -264,24 +494,48 @@ func (tc *State) AddParameterToDeclaration(pkg *decorator.Package, node dst.Node
 //
 // In some cases, this may require a library to be installed, and it will return the import path for that library.
 func (tc *State) AssignTransactionVariable(node dst.Node) string {
+	// a function that ends its transaction always uses it
+	if tc.endsTransaction {
+		tc.txnUsed = true
+	}
 	// we dont need to assign this if nothing ever invoked the transaction
 	if !tc.txnUsed {
 		return ""
 	}
 
-	stmt, imp := tc.object.AssignTransactionVariable(codegen.DefaultTransactionVariable)
-	if stmt != nil {
-		tc.txnVariable = codegen.DefaultTransactionVariable
+	var stmt dst.Stmt
+	var imp string
+	if tc.newGoroutine {
+		stmt = codegen.TxnNewGoroutineAssignment(tc.txnVariable)
+	} else {
+		stmt, imp = tc.object.AssignTransactionVariable(tc.transactionName())
+	}
+	if stmt != nil && !tc.newGoroutine {
+		tc.txnVariable = tc.transactionName()
+	}
 
+	// the transaction is ended once it is assigned, in place of the segment
+	if tc.endsTransaction {
+		tc.TransactionVariable()
+		end := codegen.DeferEndTransaction(tc.txnVariable)
+		switch decl := node.(type) {
+		case *dst.FuncDecl:
+			codegen.PrependStatementToFunctionDecl(decl, end)
+		case *dst.FuncLit:
+			codegen.PrependStatementToFunctionLit(decl, end)
+		}
+	}
+
+	if stmt != nil {
 		// check that a segment was added, so we can fix the formatting
 		switch decl := node.(type) {
 		case *dst.FuncDecl:
-			if tc.needsSegment && len(decl.Body.List) > 0 {
+			if (tc.needsSegment || tc.endsTransaction) && len(decl.Body.List) > 0 {
 				codegen.CreateStatementBlock(false, stmt, decl.Body.List[0])
 			}
 			codegen.PrependStatementToFunctionDecl(decl, stmt)
 		case *dst.FuncLit:
-			if tc.needsSegment && len(decl.Body.List) > 0 {
+			if (tc.needsSegment || tc.endsTransaction) && len(decl.Body.List) > 0 {
 				codegen.CreateStatementBlock(false, stmt, decl.Body.List[0])
 			}
 			codegen.PrependStatementToFunctionLit(decl, stmt)
@@ -635,3 +635,172 @@ func TestState_AssignTransactionVariable(t *testing.T) {
 		})
 	}
 }
+
+func TestState_FuncLiteralValue(t *testing.T) {
+	t.Run("synchronous literal captures the transaction", func(t *testing.T) {
+		parent := FunctionBody(codegen.DefaultTransactionVariable, traceobject.NewContext("ctx"))
+		lit := &dst.FuncLit{Type: &dst.FuncType{Params: &dst.FieldList{}}, Body: &dst.BlockStmt{}}
+
+		state := parent.FuncLiteralValue(false)
+		state.CreateSegment(lit)
+		state.AssignTransactionVariable(lit)
+
+		assert.False(t, parent.txnUsed, "the parent transaction should not be used when the literal captures the context")
+		assert.Len(t, lit.Type.Params.List, 0, "no parameter should be added to the literal")
+		assert.Len(t, lit.Body.List, 2, "expected the transaction to be taken from the captured context before the segment")
+	})
+
+	t.Run("async literal copies the captured transaction", func(t *testing.T) {
+		parent := FunctionBody(codegen.DefaultTransactionVariable, traceobject.NewTransaction())
+		lit := &dst.FuncLit{Type: &dst.FuncType{Params: &dst.FieldList{}}, Body: &dst.BlockStmt{}}
+
+		state := parent.FuncLiteralValue(true)
+		state.CreateSegment(lit)
+		state.AssignTransactionVariable(lit)
+
+		assert.True(t, parent.txnUsed, "the parent transaction should be used by the literal")
+		assert.Len(t, lit.Type.Params.List, 0, "no parameter should be added to the literal")
+		if assert.Len(t, lit.Body.List, 2) {
+			assign, ok := lit.Body.List[0].(*dst.AssignStmt)
+			if assert.True(t, ok, "expected the transaction to be copied before the segment") {
+				assert.Equal(t, codegen.TxnNewGoroutine(dst.NewIdent(codegen.DefaultTransactionVariable)), assign.Rhs[0])
+			}
+		}
+	})
+}
+
+func TestState_WrapGoroutinesWithTransaction(t *testing.T) {
+	stmts := func() []dst.Stmt {
+		return []dst.Stmt{
+			&dst.ExprStmt{X: dst.NewIdent("before")},
+			&dst.GoStmt{Call: &dst.CallExpr{Fun: dst.NewIdent("work")}},
+			&dst.ExprStmt{X: &dst.CallExpr{Fun: &dst.SelectorExpr{X: dst.NewIdent("wg"), Sel: dst.NewIdent("Wait")}}},
+		}
+	}
+
+	t.Run("wraps the goroutines in a transaction", func(t *testing.T) {
+		state := Main("app")
+		block := &dst.BlockStmt{List: stmts()}
+		start := block.List[1]
+		end := block.List[2]
+
+		assert.True(t, state.WrapGoroutinesWithTransaction(block, 1, 2, "work", true))
+		if assert.Len(t, block.List, 5) {
+			assert.Equal(t, codegen.StartTransaction("app", codegen.DefaultTransactionVariable, "work", false), block.List[1])
+			assert.Equal(t, start, block.List[2])
+			assert.Equal(t, end, block.List[3])
+			assert.Equal(t, codegen.EndTransaction(codegen.DefaultTransactionVariable), block.List[4])
+		}
+
+		state.EnterStatement(block.List[1])
+		assert.True(t, state.definedTxn, "the transaction variable should be defined by the start of the span")
+		assert.True(t, state.InTransactionSpan(), "statements after the start of the transaction should be in its span")
+		state.ExitStatement(block.List[1])
+		state.EnterStatement(block.List[4])
+		state.ExitStatement(block.List[4])
+		assert.False(t, state.InTransactionSpan(), "statements after the end of the transaction should not be in its span")
+	})
+
+	t.Run("assigns the transaction when it is already defined", func(t *testing.T) {
+		state := Main("app")
+		state.DefineTransaction()
+		block := &dst.BlockStmt{List: stmts()}
+
+		assert.True(t, state.WrapGoroutinesWithTransaction(block, 1, 2, "work", true))
+		state.EnterStatement(block.List[1])
+		assert.Equal(t, codegen.StartTransaction("app", codegen.DefaultTransactionVariable, "work", true), block.List[1])
+	})
+
+	t.Run("only wraps goroutines in main", func(t *testing.T) {
+		state := FunctionBody(codegen.DefaultTransactionVariable)
+		block := &dst.BlockStmt{List: stmts()}
+
+		assert.False(t, state.WrapGoroutinesWithTransaction(block, 1, 2, "work", true))
+		assert.Len(t, block.List, 3)
+	})
+}
+
+func TestState_EndTransaction(t *testing.T) {
+	state := FunctionBody(codegen.DefaultTransactionVariable)
+	state.EndTransaction()
+	decl := &dst.FuncDecl{
+		Name: dst.NewIdent("work"),
+		Type: &dst.FuncType{Params: &dst.FieldList{}},
+		Body: &dst.BlockStmt{List: []dst.Stmt{&dst.ExprStmt{X: dst.NewIdent("work")}}},
+	}
+
+	state.CreateSegment(decl)
+	state.AssignTransactionVariable(decl)
+
+	if assert.Len(t, decl.Body.List, 2, "expected the transaction to be ended instead of starting a segment") {
+		assert.Equal(t, codegen.DeferEndTransaction(codegen.DefaultTransactionVariable), decl.Body.List[0])
+	}
+}
+
+func TestState_SetTransactionName(t *testing.T) {
+	state := Main("app")
+	state.SetTransactionName("txn")
+	assert.Equal(t, dst.NewIdent("txn"), state.TransactionVariable(), "the transaction variable of main should be renamed")
+
+	block := &dst.BlockStmt{List: []dst.Stmt{&dst.GoStmt{Call: &dst.CallExpr{Fun: dst.NewIdent("work")}}}}
+	assert.True(t, state.WrapGoroutinesWithTransaction(block, 0, 0, "work", true))
+	if assert.Len(t, block.List, 3) {
+		assert.Equal(t, codegen.StartTransaction("app", "txn", "work", false), block.List[0])
+		assert.Equal(t, codegen.EndTransaction("txn"), block.List[2])
+	}
+
+	// the name is passed on to the functions traced from main
+	decl := &dst.FuncDecl{
+		Name: dst.NewIdent("work"),
+		Type: &dst.FuncType{Params: &dst.FieldList{}},
+		Body: &dst.BlockStmt{},
+	}
+	_, ok := state.functionCall(traceobject.NewTransaction()).AddParameterToDeclaration(nil, decl)
+	if assert.True(t, ok) {
+		assert.Equal(t, []*dst.Field{codegen.NewTransactionParameter("txn")}, decl.Type.Params.List)
+	}
+}
+
+func TestState_EntryPoint(t *testing.T) {
+	state := EntryPoint("ctx")
+	decl := &dst.FuncDecl{
+		Name: dst.NewIdent("Get"),
+		Type: &dst.FuncType{Params: &dst.FieldList{}},
+		Body: &dst.BlockStmt{List: []dst.Stmt{&dst.ExprStmt{X: dst.NewIdent("work")}}},
+	}
+
+	_, ok := state.AddParameterToDeclaration(nil, decl)
+	assert.False(t, ok, "no parameter should be added to an entry point")
+	_, ok = state.CreateSegment(decl)
+	assert.True(t, ok, "expected a segment to be added to the entry point")
+	state.AssignTransactionVariable(decl)
+
+	if assert.Len(t, decl.Body.List, 3) {
+		assign, ok := decl.Body.List[0].(*dst.AssignStmt)
+		if assert.True(t, ok, "expected the transaction to be taken from the context before the segment") {
+			assert.Equal(t, codegen.TxnFromContext(codegen.DefaultTransactionVariable, dst.NewIdent("ctx")).Rhs, assign.Rhs)
+		}
+		assert.IsType(t, &dst.DeferStmt{}, decl.Body.List[1], "expected a segment to be started")
+	}
+}
+
+func TestState_RequestEntryPoint(t *testing.T) {
+	state := RequestEntryPoint("r")
+	decl := &dst.FuncDecl{
+		Name: dst.NewIdent("Serve"),
+		Type: &dst.FuncType{Params: &dst.FieldList{}},
+		Body: &dst.BlockStmt{List: []dst.Stmt{&dst.ExprStmt{X: dst.NewIdent("work")}}},
+	}
+
+	_, ok := state.CreateSegment(decl)
+	assert.True(t, ok, "expected a segment to be added to the entry point")
+	state.AssignTransactionVariable(decl)
+
+	if assert.Len(t, decl.Body.List, 3) {
+		assign, ok := decl.Body.List[0].(*dst.AssignStmt)
+		if assert.True(t, ok, "expected the transaction to be taken from the context of the request before the segment") {
+			assert.Equal(t, codegen.TxnFromContext(codegen.DefaultTransactionVariable, codegen.HttpRequestContext("r")).Rhs, assign.Rhs)
+		}
+		assert.IsType(t, &dst.DeferStmt{}, decl.Body.List[1], "expected a segment to be started")
+	}
+}
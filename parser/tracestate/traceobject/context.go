@@ -2,11 +2,13 @@ package traceobject
 
 import (
 	"fmt"
+	"go/types"
 
 	"github.com/dave/dst"
 	"github.com/dave/dst/decorator"
 	"github.com/newrelic/go-easy-instrumentation/internal/codegen"
 	"github.com/newrelic/go-easy-instrumentation/internal/comment"
+	"github.com/newrelic/go-easy-instrumentation/internal/report"
 	"github.com/newrelic/go-easy-instrumentation/internal/util"
 )
 
@@ -18,6 +20,7 @@ const (
 // Note: this will not work for structured objects that contain a context.Context object.
 type Context struct {
 	contextParameterName string // the name of the context parameter for a function declaration
+	requestParameterName string // the name of the *http.Request parameter whose context is used instead, if any
 }
 
 func NewContext(name ...string) *Context {
@@ -27,36 +30,86 @@ func NewContext(name ...string) *Context {
 	return &Context{}
 }
 
+// NewRequestContext creates a trace object for the context of the *http.Request parameter named requestName.
+func NewRequestContext(requestName string) *Context {
+	return &Context{requestParameterName: requestName}
+}
+
+// contextExpression returns the expression of the context that the transaction is known to be in.
+//
+//	ctx
+//	r.Context()
+func (ctx *Context) contextExpression() dst.Expr {
+	if ctx.requestParameterName != "" {
+		return codegen.HttpRequestContext(ctx.requestParameterName)
+	}
+	return dst.NewIdent(ctx.contextParameterName)
+}
+
+// isKnownContext returns true if arg is the context that the transaction is known to be in.
+func (ctx *Context) isKnownContext(arg dst.Expr) bool {
+	if ctx.requestParameterName != "" {
+		return util.AssertExpressionEqual(arg, ctx.contextExpression())
+	}
+	ident, ok := arg.(*dst.Ident)
+	return ok && ident.Name == ctx.contextParameterName
+}
+
+// isContextParameter returns true if the argument at index i of a call is passed for a context.Context parameter
+// of the function it calls. The parameters of a generic function are checked as they are declared, since a
+// context with a transaction can not be passed for a parameter whose type is a type parameter, even when the
+// function is instantiated with context.Context. If the function is not known, the argument is assumed to be.
+func isContextParameter(pkg *decorator.Package, call *dst.CallExpr, i int) bool {
+	fn := util.CalledFunction(call, pkg)
+	if fn == nil {
+		return true
+	}
+	sig := fn.Type().(*types.Signature)
+	params := sig.Params()
+	if params.Len() == 0 {
+		return false
+	}
+	if i >= params.Len()-1 && sig.Variadic() {
+		if slice, ok := params.At(params.Len() - 1).Type().(*types.Slice); ok && !call.Ellipsis {
+			return slice.Elem().String() == contextType
+		}
+	}
+	if i >= params.Len() {
+		return false
+	}
+	return params.At(i).Type().String() == contextType
+}
+
 func (ctx *Context) AddToCall(pkg *decorator.Package, call *dst.CallExpr, transactionVariableName string, async bool) AddToCallReturn {
 	for i, arg := range call.Args {
 		typ := util.TypeOf(arg, pkg)
-		if typ != nil && typ.String() == contextType {
-			ident, ok := arg.(*dst.Ident)
-			if ok {
-				// the context we know has a transaction is being passed to the function call
-				if ident.Name == ctx.contextParameterName {
-					if async {
-						call.Args[i] = codegen.WrapContextExpression(arg, transactionVariableName, async)
-						return AddToCallReturn{
-							TraceObject: NewContext(),
-							Import:      codegen.NewRelicAgentImportPath,
-							NeedsTx:     true,
-						}
-					}
+		if typ != nil && typ.String() == contextType && isContextParameter(pkg, call, i) {
+			// the context we know has a transaction is being passed to the function call
+			if ctx.isKnownContext(arg) {
+				if async {
+					call.Args[i] = codegen.WrapContextExpression(arg, transactionVariableName, async)
 					return AddToCallReturn{
 						TraceObject: NewContext(),
-						Import:      "",
-						NeedsTx:     false,
+						Import:      codegen.NewRelicAgentImportPath,
+						NeedsTx:     true,
 					}
 				}
+				return AddToCallReturn{
+					TraceObject: NewContext(),
+					Import:      "",
+					NeedsTx:     false,
+				}
+			}
 
+			if _, ok := arg.(*dst.Ident); ok {
 				// if the context variable being passed is different from the one we know has a transaction
 				// pass a transaction to it defensively.
 				argumentString := util.WriteExpr(arg, pkg)
 				comment.Info(pkg, call, call,
 					fmt.Sprintf("a transaction was added to to the context argument %s to ensure a transaction is passed to the function call", argumentString),
-					fmt.Sprintf("This may not be necessary, and can be safely removed if this context %s is a child of %s", argumentString, util.WriteExpr(dst.NewIdent(ctx.contextParameterName), pkg)),
+					fmt.Sprintf("This may not be necessary, and can be safely removed if this context %s is a child of %s", argumentString, util.WriteExpr(ctx.contextExpression(), pkg)),
 				)
+				report.Warning(pkg, call, report.CoreIntegration, report.CodeContextTransaction, fmt.Sprintf("a transaction was added to the context argument %s defensively", argumentString))
 
 				call.Args[i] = codegen.WrapContextExpression(arg, transactionVariableName, async)
 				return AddToCallReturn{
@@ -80,27 +133,27 @@ func (ctx *Context) AddToCall(pkg *decorator.Package, call *dst.CallExpr, transa
 	return transactionReturn()
 }
 
-func (ctx *Context) AddToFuncDecl(pkg *decorator.Package, decl *dst.FuncDecl) (TraceObject, string) {
+func (ctx *Context) AddToFuncDecl(pkg *decorator.Package, decl *dst.FuncDecl, transactionVariableName string) (TraceObject, string) {
 	obj, goGet := getTracingParameter(pkg, decl.Type.Params.List)
 	if obj != nil {
 		return obj, goGet
 	}
 
 	// append a transaction if we dont find a context parameter
-	decl.Type.Params.List = append(decl.Type.Params.List, codegen.NewTransactionParameter(codegen.DefaultTransactionVariable))
+	decl.Type.Params.List = append(decl.Type.Params.List, codegen.NewTransactionParameter(transactionVariableName))
 	return NewTransaction(), codegen.NewRelicAgentImportPath
 }
 
-func (ctx *Context) AddToFuncLit(pkg *decorator.Package, lit *dst.FuncLit) (TraceObject, string) {
+func (ctx *Context) AddToFuncLit(pkg *decorator.Package, lit *dst.FuncLit, transactionVariableName string) (TraceObject, string) {
 	obj, goGet := getTracingParameter(pkg, lit.Type.Params.List)
 	if obj != nil {
 		return obj, goGet
 	}
 
-	lit.Type.Params.List = append(lit.Type.Params.List, codegen.NewTransactionParameter(codegen.DefaultTransactionVariable))
+	lit.Type.Params.List = append(lit.Type.Params.List, codegen.NewTransactionParameter(transactionVariableName))
 	return NewTransaction(), codegen.NewRelicAgentImportPath
 }
 
 func (ctx *Context) AssignTransactionVariable(variableName string) (dst.Stmt, string) {
-	return codegen.TxnFromContext(variableName, dst.NewIdent(ctx.contextParameterName)), ""
+	return codegen.TxnFromContext(variableName, ctx.contextExpression()), ""
 }
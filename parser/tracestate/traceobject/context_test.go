@@ -314,7 +314,7 @@ func TestContext_AddToFuncDecl(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			ctx := NewContext()
-			gotTO, gotImport := ctx.AddToFuncDecl(tt.args.pkg, tt.args.decl)
+			gotTO, gotImport := ctx.AddToFuncDecl(tt.args.pkg, tt.args.decl, codegen.DefaultTransactionVariable)
 			assert.Equal(t, tt.wantTO, gotTO, "AddToFuncDecl() TraceObject incorrect")
 			assert.Equal(t, tt.wantImport, gotImport, "AddToFuncDecl() Import incorrect")
 			assert.Equal(t, tt.wantParams, tt.args.decl.Type.Params.List, "AddToFuncDecl() did not add the correct trace object to the function declaration")
@@ -412,10 +412,84 @@ func TestContext_AddToFuncLit(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			ctx := NewContext()
-			gotTO, gotImport := ctx.AddToFuncLit(tt.args.pkg, tt.args.decl)
+			gotTO, gotImport := ctx.AddToFuncLit(tt.args.pkg, tt.args.decl, codegen.DefaultTransactionVariable)
 			assert.Equal(t, tt.wantTO, gotTO, "AddToFuncDecl() TraceObject incorrect")
 			assert.Equal(t, tt.wantImport, gotImport, "AddToFuncDecl() Import incorrect")
 			assert.Equal(t, tt.wantParams, tt.args.decl.Type.Params.List, "AddToFuncDecl() did not add the correct trace object to the function declaration")
 		})
 	}
 }
+
+func TestIsContextParameter(t *testing.T) {
+	app := types.NewPackage("example.com/app", "app")
+	contextType := types.NewNamed(types.NewTypeName(0, types.NewPackage("context", "context"), "Context", nil), types.NewInterfaceType(nil, nil), nil)
+	typeParam := types.NewTypeParam(types.NewTypeName(0, app, "T", nil), types.NewInterfaceType(nil, nil))
+
+	functions := map[string]*types.Func{
+		"fetch": types.NewFunc(0, app, "fetch", types.NewSignatureType(nil, nil, nil, types.NewTuple(
+			types.NewVar(0, app, "ctx", contextType),
+		), nil, false)),
+		"apply": types.NewFunc(0, app, "apply", types.NewSignatureType(nil, nil, []*types.TypeParam{typeParam}, types.NewTuple(
+			types.NewVar(0, app, "v", typeParam),
+		), nil, false)),
+		"fetchAll": types.NewFunc(0, app, "fetchAll", types.NewSignatureType(nil, nil, nil, types.NewTuple(
+			types.NewVar(0, app, "ctxs", types.NewSlice(contextType)),
+		), nil, true)),
+	}
+	nodes := map[dst.Node]ast.Node{}
+	uses := map[*ast.Ident]types.Object{}
+	idents := map[string]*dst.Ident{}
+	for name, fn := range functions {
+		ident := dst.NewIdent(name)
+		astIdent := ast.NewIdent(name)
+		nodes[ident] = astIdent
+		uses[astIdent] = fn
+		idents[name] = ident
+	}
+	pkg := &decorator.Package{
+		Decorator: &decorator.Decorator{
+			Map: decorator.Map{
+				Ast: decorator.AstMap{
+					Nodes: nodes,
+				},
+			},
+		},
+		Package: &packages.Package{
+			TypesInfo: &types.Info{
+				Uses: uses,
+			},
+		},
+	}
+
+	tests := []struct {
+		name string
+		call *dst.CallExpr
+		want bool
+	}{
+		{
+			name: "context parameter",
+			call: &dst.CallExpr{Fun: idents["fetch"], Args: []dst.Expr{dst.NewIdent("ctx")}},
+			want: true,
+		},
+		{
+			name: "type parameter instantiated with a context",
+			call: &dst.CallExpr{Fun: &dst.IndexExpr{X: idents["apply"], Index: contextParameterType()}, Args: []dst.Expr{dst.NewIdent("ctx")}},
+			want: false,
+		},
+		{
+			name: "variadic context parameter",
+			call: &dst.CallExpr{Fun: idents["fetchAll"], Args: []dst.Expr{dst.NewIdent("ctx"), dst.NewIdent("ctx")}},
+			want: true,
+		},
+		{
+			name: "unknown function",
+			call: &dst.CallExpr{Fun: dst.NewIdent("unknown"), Args: []dst.Expr{dst.NewIdent("ctx")}},
+			want: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, isContextParameter(pkg, tt.call, len(tt.call.Args)-1))
+		})
+	}
+}
@@ -52,7 +52,7 @@ func (txn *Transaction) AddToCall(pkg *decorator.Package, call *dst.CallExpr, tr
 		}
 
 		// if the call already contains a context, inject a transaction into it rather than adding an argument
-		if typ != nil && typ.String() == contextType {
+		if typ != nil && typ.String() == contextType && isContextParameter(pkg, call, i) {
 			call.Args[i] = codegen.WrapContextExpression(arg, transactionVariable, async)
 			return AddToCallReturn{
 				TraceObject: NewContext(),
@@ -91,24 +91,24 @@ func getTracingParameter(pkg *decorator.Package, params []*dst.Field) (TraceObje
 }
 
 // AddToFuncDecl adds the transaction as a parameter to the function declaration
-func (txn *Transaction) AddToFuncDecl(pkg *decorator.Package, decl *dst.FuncDecl) (TraceObject, string) {
+func (txn *Transaction) AddToFuncDecl(pkg *decorator.Package, decl *dst.FuncDecl, transactionVariableName string) (TraceObject, string) {
 	obj, goGet := getTracingParameter(pkg, decl.Type.Params.List)
 	if obj != nil {
 		return obj, goGet
 	}
 
-	decl.Type.Params.List = append(decl.Type.Params.List, codegen.NewTransactionParameter(codegen.DefaultTransactionVariable))
+	decl.Type.Params.List = append(decl.Type.Params.List, codegen.NewTransactionParameter(transactionVariableName))
 	return txn, codegen.NewRelicAgentImportPath
 }
 
 // AddToFuncLit adds the transaction as a parameter to the function literal
-func (txn *Transaction) AddToFuncLit(pkg *decorator.Package, lit *dst.FuncLit) (TraceObject, string) {
+func (txn *Transaction) AddToFuncLit(pkg *decorator.Package, lit *dst.FuncLit, transactionVariableName string) (TraceObject, string) {
 	obj, goGet := getTracingParameter(pkg, lit.Type.Params.List)
 	if obj != nil {
 		return obj, goGet
 	}
 
-	lit.Type.Params.List = append(lit.Type.Params.List, codegen.NewTransactionParameter(codegen.DefaultTransactionVariable))
+	lit.Type.Params.List = append(lit.Type.Params.List, codegen.NewTransactionParameter(transactionVariableName))
 	return txn, codegen.NewRelicAgentImportPath
 }
 
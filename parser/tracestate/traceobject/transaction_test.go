@@ -225,7 +225,7 @@ func TestTransaction_AddToFuncDecl(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			txn := NewTransaction()
-			gotTO, gotImport := txn.AddToFuncDecl(tt.args.pkg, tt.args.decl)
+			gotTO, gotImport := txn.AddToFuncDecl(tt.args.pkg, tt.args.decl, codegen.DefaultTransactionVariable)
 			assert.Equal(t, tt.wantTO, gotTO, "AddToFuncDecl() TraceObject incorrect")
 			assert.Equal(t, tt.wantImport, gotImport, "AddToFuncDecl() Import incorrect")
 			assert.Equal(t, tt.wantParams, tt.args.decl.Type.Params.List, "AddToFuncDecl() did not add the correct trace object to the function declaration")
@@ -323,7 +323,7 @@ func TestTransaction_AddToFuncLit(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			txn := NewTransaction()
-			gotTO, gotImport := txn.AddToFuncLit(tt.args.pkg, tt.args.decl)
+			gotTO, gotImport := txn.AddToFuncLit(tt.args.pkg, tt.args.decl, codegen.DefaultTransactionVariable)
 			assert.Equal(t, tt.wantTO, gotTO, "AddToFuncDecl() TraceObject incorrect")
 			assert.Equal(t, tt.wantImport, gotImport, "AddToFuncDecl() Import incorrect")
 			assert.Equal(t, tt.wantParams, tt.args.decl.Type.Params.List, "AddToFuncDecl() did not add the correct trace object to the function declaration")
@@ -32,16 +32,22 @@ type TraceObject interface {
 	//
 	// Make sure that the package passed is from the same package that the function is defined in.
 	//
+	// If no trace object can be found in the parameters of the function, a transaction parameter named
+	// transactionVariableName is added.
+	//
 	// If an import needs to be added to support the changes made, it will be returned as a string.
-	AddToFuncDecl(pkg *decorator.Package, decl *dst.FuncDecl) (TraceObject, string)
+	AddToFuncDecl(pkg *decorator.Package, decl *dst.FuncDecl, transactionVariableName string) (TraceObject, string)
 
 	// AddToFuncLit adds a trace object to a function literal definition as a parameter, so that
 	// trace objects can be passed in calls to this function literal.
 	//
 	// Make sure that the package passed is from the same package that the function literal is defined in.
 	//
+	// If no trace object can be found in the parameters of the function literal, a transaction parameter named
+	// transactionVariableName is added.
+	//
 	// If an import needs to be added to support the changes made, it will be returned as a string.
-	AddToFuncLit(pkg *decorator.Package, lit *dst.FuncLit) (TraceObject, string)
+	AddToFuncLit(pkg *decorator.Package, lit *dst.FuncLit, transactionVariableName string) (TraceObject, string)
 
 	// AssignTransactionVariable fetches the transaction from the trace object and assigns it to a variable
 	//
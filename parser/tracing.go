@@ -7,9 +7,9 @@ import (
 
 	"github.com/dave/dst"
 	"github.com/dave/dst/dstutil"
-	"github.com/newrelic/go-easy-instrumentation/internal/codegen"
 	"github.com/newrelic/go-easy-instrumentation/internal/comment"
 	"github.com/newrelic/go-easy-instrumentation/internal/common"
+	"github.com/newrelic/go-easy-instrumentation/internal/report"
 	"github.com/newrelic/go-easy-instrumentation/parser/tracestate"
 )
 
@@ -26,6 +26,8 @@ func TraceFunction(manager *InstrumentationManager, node dst.Node, tracing *trac
 		panic(fmt.Sprintf("TraceFunction only accepts *dst.FuncDecl or *dst.FuncLit, got %s", nodeType))
 	}
 
+	tracing.SetTransactionName(manager.TransactionVariableName())
+
 	var funcType *dst.FuncType
 	var funcBody *dst.BlockStmt
 
@@ -67,13 +69,20 @@ func TraceFunction(manager *InstrumentationManager, node dst.Node, tracing *trac
 			return true
 		}
 
-		ident, ok := selExpr.X.(*dst.Ident)
-		if !ok {
+		// the transaction may be held in a variable or in a struct field, like `s.txn.StartSegment(...)`
+		var ident *dst.Ident
+		switch x := selExpr.X.(type) {
+		case *dst.Ident:
+			ident = x
+		case *dst.SelectorExpr:
+			ident = x.Sel
+		default:
 			return true
 		}
 
-		if manager.transactionCache.CheckTransactionExists(ident) {
+		if manager.isTransaction(ident) {
 			comment.Debug(manager.getDecoratorPackage(), node, fmt.Sprintf("Found existing instrumentation for function: %s", ident.Name))
+			report.Skipped(manager.getDecoratorPackage(), node, report.CoreIntegration, report.CodeAlreadyInstrumented, "function already starts a segment")
 			hasSegment = true
 			return false // Stop further traversal
 		}
@@ -86,18 +95,37 @@ func TraceFunction(manager *InstrumentationManager, node dst.Node, tracing *trac
 		segmentImport, ok := tracing.CreateSegment(node)
 		if ok {
 			manager.addImport(segmentImport)
+			report.Action(manager.getDecoratorPackage(), node, report.CoreIntegration, report.KindSegment, "added a segment to "+segmentName(node))
 			TopLevelFunctionChanged = true
 		}
 	}
 
+	// goroutines that main waits for are wrapped in a transaction before it is traced
+	if isFuncDecl && tracing.IsMain() {
+		manager.wrapJoinedGoroutines(decl, tracing)
+	}
+
 	outputNode := dstutil.Apply(node, func(c *dstutil.Cursor) bool {
 		n := c.Node()
+		// statements and function literals that are already instrumented are left as they are
+		if n != node && manager.WasInstrumented(n) {
+			return false
+		}
+		if stmt, ok := n.(dst.Stmt); ok {
+			tracing.EnterStatement(stmt)
+		}
 		switch v := n.(type) {
 		case *dst.BlockStmt, *dst.ForStmt:
 			return true
 		case *dst.GoStmt:
-			if tracing.IsMain() {
-				comment.Info(manager.getDecoratorPackage(), v, v, fmt.Sprintf("%s doesn't support tracing goroutines in a main method; please instrument manually.", common.ApplicationName), "https://docs.newrelic.com/docs/apm/agents/go-agent/instrumentation/instrument-go-transactions/#goroutines")
+			// goroutines in main that are not joined in a transaction span are traced as background transactions
+			if tracing.IsMain() && !tracing.InTransactionSpan() {
+				if reason := traceBackgroundGoroutine(manager, c, v, tracing, c.Parent() == funcBody); reason != "" {
+					comment.Info(manager.getDecoratorPackage(), v, v, fmt.Sprintf("%s can not trace this goroutine, since %s; please instrument manually.", common.ApplicationName, reason), "https://docs.newrelic.com/docs/apm/agents/go-agent/instrumentation/instrument-go-transactions/#goroutines")
+					report.Warning(manager.getDecoratorPackage(), v, report.CoreIntegration, report.CodeGoroutineInMain, fmt.Sprintf("goroutine started in main is not traced, since %s; please instrument it manually", reason))
+				} else {
+					TopLevelFunctionChanged = true
+				}
 				return false
 			}
 			switch fun := v.Call.Fun.(type) {
@@ -123,9 +151,19 @@ func TraceFunction(manager *InstrumentationManager, node dst.Node, tracing *trac
 			default:
 				rootPkg := manager.currentPackage
 				tracableInvocations := manager.findInvocationInfo(v.Call, tracing)
+				var callState *tracestate.State // the call may invoke several implementations of an interface method
 				for _, invInfo := range tracableInvocations {
-					childState, tracingImport := tracing.AddToCall(manager.getDecoratorPackage(), v.Call, true)
-					manager.addImport(tracingImport)
+					if !manager.canTrace(invInfo) {
+						continue
+					}
+					childState := callState.Clone()
+					if callState == nil {
+						var tracingImport string
+						childState, tracingImport = tracing.AddToCall(manager.getDecoratorPackage(), v.Call, true)
+						manager.addImport(tracingImport)
+						callState = childState.Clone()
+					}
+					nameImplementationSegment(childState, invInfo)
 					c.Replace(v)
 					TopLevelFunctionChanged = true
 
@@ -138,6 +176,24 @@ func TraceFunction(manager *InstrumentationManager, node dst.Node, tracing *trac
 				}
 			}
 
+		case *dst.FuncLit:
+			// function literals used as values, such as callbacks and handlers stored in a struct or a map, capture
+			// the transaction of this function, since a parameter can not be added to them
+			if v == node || tracing.IsMain() || !manager.isFunctionLiteralValue(c, v) {
+				return true
+			}
+			if isStoredFunctionLiteral(c) {
+				var commentNode dst.Node = v
+				if kv, ok := c.Parent().(*dst.KeyValueExpr); ok {
+					commentNode = kv
+				}
+				comment.Warn(manager.getDecoratorPackage(), commentNode, v, "this function literal uses the transaction of the function it is declared in, which may have ended when it is called; please verify that it is called while the transaction is in progress, or instrument it manually.")
+				report.Warning(manager.getDecoratorPackage(), v, report.CoreIntegration, report.CodeStoredFunctionLiteral, "stored function literal uses the transaction of the function it is declared in, which may have ended when it is called")
+			}
+			TraceFunction(manager, v, tracing.FuncLiteralValue(manager.runsInGoroutine(c)))
+			TopLevelFunctionChanged = true
+			return false
+
 		case dst.Stmt:
 			downstreamFunctionTraced := false
 			assign, ok := v.(*dst.AssignStmt)
@@ -159,23 +215,46 @@ func TraceFunction(manager *InstrumentationManager, node dst.Node, tracing *trac
 
 			rootPkg := manager.currentPackage
 			tracableInvocations := manager.findInvocationInfo(v, tracing)
-			transactionCreatedForStatement := false // prevent multiple transactions from being created for the same statement
+			transactionCreatedForStatement := false             // prevent multiple transactions from being created for the same statement
+			callStates := map[*dst.CallExpr]*tracestate.State{} // a call may invoke several implementations of an interface method
 
 			// inv info will be nil if the function is not declared in this application
 			for _, invInfo := range tracableInvocations {
 				// If the current function is the function that declares the NR App, we do not want to propagate tracing to it
-				// Additionally, if the function is already being traced by an existing transaction we can skip it
-				if manager.setupFunc == invInfo.decl || manager.transactionCache.IsFunctionInTransactionScope(invInfo.functionName) {
+				if manager.setupFunc == invInfo.decl {
+					continue
+				}
+				// If the function is already in the scope of an existing transaction, it does not get a new one. If the
+				// existing transaction is passed to it, it is traced with that transaction instead.
+				if manager.inTransactionScope(invInfo) {
+					if manager.receivesTransaction(invInfo) && manager.shouldInstrumentFunction(invInfo) && manager.canTrace(invInfo) {
+						manager.setPackage(invInfo.packageName)
+						TraceFunction(manager, invInfo.decl, tracestate.FunctionValue())
+						manager.setPackage(rootPkg)
+						TopLevelFunctionChanged = true
+					}
+					continue
+				}
+				// If the function can not be traced without changing its signature, it is left as it is
+				if !manager.canTrace(invInfo) {
 					continue
 				}
 
 				if !transactionCreatedForStatement {
 					// Check if the functionName is already present within transactions
-					tracing.WrapWithTransaction(c, invInfo.functionName, codegen.DefaultTransactionVariable)
+					if tracing.WrapWithTransaction(c, invInfo.functionName, manager.TransactionVariableName()) {
+						report.Action(manager.getDecoratorPackage(), v, report.CoreIntegration, report.KindTransaction, "started transaction "+invInfo.functionName)
+					}
 					transactionCreatedForStatement = true
 				}
-				childState, tracingImport := tracing.AddToCall(manager.getDecoratorPackage(), invInfo.call, false)
-				manager.addImport(tracingImport)
+				childState := callStates[invInfo.call].Clone()
+				if callStates[invInfo.call] == nil {
+					var tracingImport string
+					childState, tracingImport = tracing.AddToCall(manager.getDecoratorPackage(), invInfo.call, false)
+					manager.addImport(tracingImport)
+					callStates[invInfo.call] = childState.Clone()
+				}
+				nameImplementationSegment(childState, invInfo)
 				TopLevelFunctionChanged = true
 				// If not present, wrap the function with a transaction
 				if manager.shouldInstrumentFunction(invInfo) {
@@ -186,6 +265,16 @@ func TraceFunction(manager *InstrumentationManager, node dst.Node, tracing *trac
 				}
 			}
 
+			// functions used as values are traced if the transaction is passed to them in a parameter they already have
+			for _, invInfo := range manager.findFunctionValueInfo(v) {
+				if !manager.canTrace(invInfo) || !manager.shouldInstrumentFunction(invInfo) {
+					continue
+				}
+				manager.setPackage(invInfo.packageName)
+				TraceFunction(manager, invInfo.decl, tracestate.FunctionValue())
+				manager.setPackage(rootPkg)
+			}
+
 			ok = NoticeError(manager, v, c, tracing, downstreamFunctionTraced)
 			if ok {
 				TopLevelFunctionChanged = true
@@ -199,7 +288,12 @@ func TraceFunction(manager *InstrumentationManager, node dst.Node, tracing *trac
 			}
 		}
 		return true
-	}, nil)
+	}, func(c *dstutil.Cursor) bool {
+		if stmt, ok := c.Node().(dst.Stmt); ok {
+			tracing.ExitStatement(stmt)
+		}
+		return true
+	})
 
 	// Add an assignment for txn Variable if needed
 	assignmentImport := tracing.AssignTransactionVariable(node)
@@ -209,6 +303,7 @@ func TraceFunction(manager *InstrumentationManager, node dst.Node, tracing *trac
 	if manager.errorCache.GetExpression() != nil {
 		stmt := manager.errorCache.GetStatement()
 		comment.Warn(manager.getDecoratorPackage(), stmt, stmt, "Unchecked Error, please consult New Relic documentation on error capture", "https://docs.newrelic.com/docs/apm/agents/go-agent/api-guides/guide-using-go-agent-api/#errors")
+		report.Warning(manager.getDecoratorPackage(), stmt, report.CoreIntegration, report.CodeUncheckedError, "unchecked error can not be captured")
 		manager.errorCache.Clear()
 	}
 
@@ -220,6 +315,23 @@ func TraceFunction(manager *InstrumentationManager, node dst.Node, tracing *trac
 	return outputNode, TopLevelFunctionChanged
 }
 
+// nameImplementationSegment names the segment of a method that is traced as an implementation of the interface
+// method that was called after its receiver type, such as "Store.Get", since the method name alone does not tell
+// the implementations apart.
+func nameImplementationSegment(state *tracestate.State, inv *invocationInfo) {
+	if inv.implementation {
+		state.NameSegment(functionName(inv.decl))
+	}
+}
+
+// segmentName returns the name of the function a segment was added to, for the report.
+func segmentName(node dst.Node) string {
+	if decl, ok := node.(*dst.FuncDecl); ok {
+		return decl.Name.Name
+	}
+	return "function literal"
+}
+
 // hasTransactionParameter checks if a function has a transaction parameter
 // by examining the function's parameter list for any parameter names that exist
 // in the transaction cache.
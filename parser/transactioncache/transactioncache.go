@@ -2,6 +2,7 @@ package transactioncache
 
 import (
 	"fmt"
+	"go/types"
 
 	"github.com/dave/dst"
 )
@@ -26,15 +27,20 @@ type TransactionData struct {
 //
 //   - Functions: A map that stores already seen functions alongside their declarations. This is useful
 //     for tracking transactions that span multiple function calls.
+//
+//   - Objects: A set of the variables, parameters and struct fields that hold a transaction, or a context
+//     that carries one, wherever it flows from
 type TransactionCache struct {
 	Transactions map[*dst.Ident]*TransactionData
 	Functions    map[string]*dst.FuncDecl
+	Objects      map[types.Object]bool
 }
 
 func NewTransactionCache() *TransactionCache {
 	return &TransactionCache{
 		Transactions: make(map[*dst.Ident]*TransactionData),
 		Functions:    make(map[string]*dst.FuncDecl),
+		Objects:      make(map[types.Object]bool),
 	}
 }
 
@@ -238,6 +244,22 @@ func (tc *TransactionCache) CheckTransactionExists(transaction *dst.Ident) bool
 	return ok
 }
 
+// AddObject records that a variable, parameter or struct field holds a transaction, or a context that
+// carries one. Returns true on success.
+func (tc *TransactionCache) AddObject(obj types.Object) bool {
+	if tc == nil || tc.Objects == nil || obj == nil {
+		return false
+	}
+	tc.Objects[obj] = true
+	return true
+}
+
+// CheckObjectExists returns true if a variable, parameter or struct field is recorded in the cache as
+// holding a transaction, otherwise false.
+func (tc *TransactionCache) CheckObjectExists(obj types.Object) bool {
+	return obj != nil && tc.Objects[obj]
+}
+
 // Print outputs Debug printing of cache
 func (tc *TransactionCache) Print() {
 	for txnKey, txnData := range tc.Transactions {
@@ -1,6 +1,7 @@
 package transactioncache
 
 import (
+	"go/types"
 	"testing"
 
 	"github.com/dave/dst"
@@ -14,8 +15,10 @@ func TestNewTransactionCache(t *testing.T) {
 	assert.NotNil(t, tc)
 	assert.NotNil(t, tc.Transactions)
 	assert.NotNil(t, tc.Functions)
+	assert.NotNil(t, tc.Objects)
 	assert.Equal(t, 0, len(tc.Transactions))
 	assert.Equal(t, 0, len(tc.Functions))
+	assert.Equal(t, 0, len(tc.Objects))
 }
 
 // TestNewTxnData tests transaction data constructor
@@ -558,6 +561,23 @@ func TestTransactionCache_CheckTransactionExists(t *testing.T) {
 	}
 }
 
+// TestTransactionCache_CheckObjectExists tests tracking the variables that hold a transaction
+func TestTransactionCache_CheckObjectExists(t *testing.T) {
+	txn := types.NewVar(0, nil, "txn", types.Typ[types.Int])
+	ctx := types.NewVar(0, nil, "ctx", types.Typ[types.Int])
+
+	tc := NewTransactionCache()
+	assert.True(t, tc.AddObject(txn))
+	assert.False(t, tc.AddObject(nil))
+
+	assert.True(t, tc.CheckObjectExists(txn))
+	assert.False(t, tc.CheckObjectExists(ctx))
+	assert.False(t, tc.CheckObjectExists(nil))
+
+	var nilCache *TransactionCache
+	assert.False(t, nilCache.AddObject(txn))
+}
+
 // TestTransactionCache_ExtractNames tests the ExtractNames helper function
 func TestTransactionCache_ExtractNames(t *testing.T) {
 	t.Run("extracts_transaction_and_expression_names", func(t *testing.T) {
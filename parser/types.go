@@ -10,7 +10,9 @@
 //     primarily, it is looking for user defined function declarations. These declarations are objects in the tree, and
 //     we can uniquely identify them by package name, and function name. Additional key information is discovered with
 //     `FactDiscoveryFunctions` and cached in an object called the `FactStore`, which can be used for recognizing key
-//     information that is not available in the scope of a single package or function call.
+//     information that is not available in the scope of a single package or function call. Packages are walked
+//     concurrently, and what is found in them is merged in the order of their package paths, so that the result
+//     does not depend on the order the walks finish in.
 //
 //  3. Once we have gathered all our facts and impelentation data, we have all the information we need to instrument
 //     an application. The tool will walk through the entire syntax tree for each package again, making this the second
@@ -62,8 +64,17 @@ type StatefulTracingFunction func(manager *InstrumentationManager, stmt dst.Stmt
 // These functions are invoked on every node in the DST tree.
 type StatelessTracingFunction func(manager *InstrumentationManager, c *dstutil.Cursor)
 
+// DiagnosticFunction reports code that can not be instrumented, with a report warning and a comment, without
+// changing the code. They are executed after the stateless tracing functions on every node in the DST tree of every
+// function declared in an application, including the functions that are already instrumented and are otherwise
+// left unchanged, so that their diagnostics are not lost.
+type DiagnosticFunction func(manager *InstrumentationManager, c *dstutil.Cursor)
+
 // PreInstrumentationTracingFunction defines a function that is executed before any instrumentation is applied to a code block.
 // These functions are executed on every node in the DST tree of every function declared in an application.
+// Packages are scanned concurrently, so the manager passed to these functions is the context of the walk through a
+// single package: they may only change the state of that package, and what they record in the caches of the manager
+// is merged once every package has been scanned.
 type PreInstrumentationTracingFunction func(manager *InstrumentationManager, c *dstutil.Cursor)
 
 // FactDiscoveryFunction identify a "Fact" about a code pattern, which can be referenced later to identify
@@ -74,5 +85,14 @@ type PreInstrumentationTracingFunction func(manager *InstrumentationManager, c *
 //
 // These functions are best used when a piece of information must be known about the application
 // in order for some tracing functions to work, and we can not determine that information from the
-// scope those functions have access to.
+// scope those functions have access to. Packages are scanned for facts concurrently, so these functions must
+// not change the package they are passed.
 type FactDiscoveryFunction func(pkg *decorator.Package, node dst.Node) (facts.Entry, bool)
+
+// UninstrumentFunction removes New Relic instrumentation from a section of code. These functions are executed on
+// every node in the DST tree of every file in an application, before the children of that node are visited, so
+// that they can recognize a pattern of instrumentation as a whole before any part of it is removed.
+//
+// Once a function has changed a node, the remaining functions are not run on it. If the node was modified, it
+// should return true, otherwise false.
+type UninstrumentFunction func(manager *InstrumentationManager, c *dstutil.Cursor) bool
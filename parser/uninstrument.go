@@ -0,0 +1,948 @@
+package parser
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/dave/dst"
+	"github.com/dave/dst/decorator"
+	"github.com/dave/dst/dstutil"
+	"github.com/newrelic/go-easy-instrumentation/internal/codegen"
+	"github.com/newrelic/go-easy-instrumentation/internal/comment"
+	"github.com/newrelic/go-easy-instrumentation/internal/common"
+	"github.com/newrelic/go-easy-instrumentation/internal/util"
+)
+
+// agentImportPrefix is the start of the import path of the go agent, and of every one of its integrations.
+const agentImportPrefix = "github.com/newrelic/go-agent/v3/"
+
+// generatedHeader starts the comment above the variables that codegen.CaptureErrorReturnCallExpression assigns
+// the values returned by a call to.
+var generatedHeader = fmt.Sprintf("// generated by %s; ", common.ApplicationName)
+
+// uninstrumentedFile is a file that instrumentation is removed from, and the package it was loaded in.
+type uninstrumentedFile struct {
+	pkgID string
+	state *packageState
+	file  *dst.File
+}
+
+// uninstrumentedFiles returns every file that instrumentation can be removed from. A file that belongs to more
+// than one package, such as the files of a package and of its test variant, is only returned for the first of
+// them, so that each file is changed once.
+func (m *InstrumentationManager) uninstrumentedFiles() []uninstrumentedFile {
+	seen := map[string]bool{}
+	files := []uninstrumentedFile{}
+	for _, pkgID := range m.getSortedPackages() {
+		state := m.packages[pkgID]
+		for _, file := range state.pkg.Syntax {
+			path := state.pkg.Decorator.Filenames[file]
+			if seen[path] || util.IsGenerated(state.pkg.Decorator, file) || !m.includeFile(state, file) {
+				continue
+			}
+			seen[path] = true
+			files = append(files, uninstrumentedFile{pkgID: pkgID, state: state, file: file})
+		}
+	}
+	return files
+}
+
+// RemoveInstrumentation removes New Relic instrumentation from the syntax trees of every package, so that the diff
+// restores the code of the application to how it was before it was instrumented. The loaded uninstrument functions
+// remove the instrumentation they recognize, and then the transaction parameters added to functions, and any
+// variables holding New Relic values that are no longer needed, are removed.
+//
+// It returns a warning for every statement that still uses the agent afterwards, which must be changed by hand.
+func (m *InstrumentationManager) RemoveInstrumentation() ([]string, error) {
+	if len(m.tracingFunctions.uninstrument) == 0 {
+		return nil, fmt.Errorf("error removing instrumentation: uninstrument functions are nil")
+	}
+
+	files := m.uninstrumentedFiles()
+	for _, f := range files {
+		m.setPackage(f.pkgID)
+		for _, decl := range f.file.Decls {
+			if fn, ok := decl.(*dst.FuncDecl); ok && !m.includeFunction(f.state, f.file, fn) {
+				continue
+			}
+			dstutil.Apply(decl, func(c *dstutil.Cursor) bool {
+				if c.Node() == nil {
+					return true
+				}
+				comment.Remove(c.Node())
+				for _, uninstrument := range m.tracingFunctions.uninstrument {
+					if uninstrument(m, c) {
+						break
+					}
+				}
+				return true
+			}, nil)
+		}
+	}
+
+	// variables are removed both before and after the transaction parameters, since a parameter can be kept by a
+	// variable it is used to create, and a variable by a parameter it is passed to
+	m.removeUnusedVariables(files)
+	m.removeTransactionParameters(files)
+	m.removeUnusedVariables(files)
+
+	return m.remainingInstrumentation(files), nil
+}
+
+// UninstrumentFunctions
+//////////////////////////////////////////////
+
+// RemoveSegments removes segments that are started and ended in a single statement, such as
+// `defer txn.StartSegment("name").End()`.
+func RemoveSegments(manager *InstrumentationManager, c *dstutil.Cursor) bool {
+	var call *dst.CallExpr
+	switch stmt := c.Node().(type) {
+	case *dst.DeferStmt:
+		call = stmt.Call
+	case *dst.ExprStmt:
+		call, _ = stmt.X.(*dst.CallExpr)
+	}
+	if call == nil || c.Index() < 0 {
+		return false
+	}
+
+	end, ok := call.Fun.(*dst.SelectorExpr)
+	if !ok || end.Sel.Name != "End" {
+		return false
+	}
+	start, ok := end.X.(*dst.CallExpr)
+	if !ok {
+		return false
+	}
+
+	switch fun := start.Fun.(type) {
+	case *dst.SelectorExpr:
+		if fun.Sel.Name != "StartSegment" || !mayBeAgentValue(manager.GetDecoratorPackage(), fun.X) {
+			return false
+		}
+	case *dst.Ident:
+		if fun.Name != "StartSegment" || fun.Path != codegen.NewRelicAgentImportPath {
+			return false
+		}
+	default:
+		return false
+	}
+
+	DeleteStatement(c)
+	return true
+}
+
+// RemoveNoticeErrors removes calls to NoticeError, and the if statements that only check an error in order to
+// notice it. Return values that were assigned to variables so that the error could be noticed before it was
+// returned are returned directly again.
+func RemoveNoticeErrors(manager *InstrumentationManager, c *dstutil.Cursor) bool {
+	pkg := manager.GetDecoratorPackage()
+	switch node := c.Node().(type) {
+	case *dst.BlockStmt:
+		return restoreReturnValues(pkg, &node.List)
+	case *dst.CaseClause:
+		return restoreReturnValues(pkg, &node.Body)
+	case *dst.CommClause:
+		return restoreReturnValues(pkg, &node.Body)
+	case *dst.IfStmt:
+		if c.Index() < 0 || !isNoticeErrorCheck(pkg, node) {
+			return false
+		}
+		DeleteStatement(c)
+		return true
+	case *dst.ExprStmt:
+		if c.Index() < 0 || !isNoticeError(pkg, node) {
+			return false
+		}
+		DeleteStatement(c)
+		return true
+	}
+	return false
+}
+
+// RemoveTransactionContexts replaces contexts that a transaction was added to with `newrelic.NewContext` by the
+// context the transaction was added to.
+func RemoveTransactionContexts(manager *InstrumentationManager, c *dstutil.Cursor) bool {
+	call, ok := c.Node().(*dst.CallExpr)
+	if !ok || len(call.Args) != 2 {
+		return false
+	}
+	ident, ok := call.Fun.(*dst.Ident)
+	if !ok || ident.Name != "NewContext" || ident.Path != codegen.NewRelicAgentImportPath {
+		return false
+	}
+
+	c.Replace(call.Args[0])
+	return true
+}
+
+// RemoveMiddleware removes a statement that adds the middleware of an integration to a router, such as
+// `router.Use(nrgin.Middleware(NewRelicAgent))`. If other middleware is added by the same call, only the
+// integration's middleware is removed from it. The importPath is the import path of the integration.
+func RemoveMiddleware(c *dstutil.Cursor, importPath string) bool {
+	stmt, ok := c.Node().(*dst.ExprStmt)
+	if !ok || c.Index() < 0 {
+		return false
+	}
+	use, ok := stmt.X.(*dst.CallExpr)
+	if !ok {
+		return false
+	}
+	sel, ok := use.Fun.(*dst.SelectorExpr)
+	if !ok || sel.Sel.Name != "Use" {
+		return false
+	}
+
+	isMiddleware := func(arg dst.Expr) bool {
+		call, ok := arg.(*dst.CallExpr)
+		if !ok {
+			return false
+		}
+		ident, ok := call.Fun.(*dst.Ident)
+		return ok && ident.Name == "Middleware" && ident.Path == importPath
+	}
+	if !slices.ContainsFunc(use.Args, isMiddleware) {
+		return false
+	}
+
+	use.Args = slices.DeleteFunc(use.Args, isMiddleware)
+	if len(use.Args) == 0 {
+		DeleteStatement(c)
+	}
+	return true
+}
+
+// Helpers for removing instrumentation
+//////////////////////////////////////////////
+
+// DeleteStatement deletes the statement at the cursor, which must be in a list of statements. The comments above
+// it are moved to the statement after it, which also keeps the spacing above the deleted statement if it was the
+// first statement in the list. If it was the last statement, the one before it takes the spacing below it.
+func DeleteStatement(c *dstutil.Cursor) {
+	var list []dst.Stmt
+	switch parent := c.Parent().(type) {
+	case *dst.BlockStmt:
+		list = parent.List
+	case *dst.CaseClause:
+		list = parent.Body
+	case *dst.CommClause:
+		list = parent.Body
+	}
+
+	if i := c.Index(); i >= 0 && i+1 < len(list) {
+		moveDecorations(c.Node().(dst.Stmt), list[i+1], i == 0)
+	} else if i > 0 && i < len(list) {
+		list[i-1].Decorations().After = list[i].Decorations().After
+	}
+	c.Delete()
+}
+
+// DeleteStatements returns the list of statements without the ones that remove returns true for. Their comments
+// and spacing are kept in the same way as DeleteStatement does.
+func DeleteStatements(list []dst.Stmt, remove func(dst.Stmt) bool) []dst.Stmt {
+	kept := []dst.Stmt{}
+	deleted := []dst.Stmt{}
+	for _, stmt := range list {
+		if remove(stmt) {
+			deleted = append(deleted, stmt)
+			continue
+		}
+		for i := len(deleted) - 1; i >= 0; i-- {
+			moveDecorations(deleted[i], stmt, len(kept) == 0)
+		}
+		deleted = deleted[:0]
+		kept = append(kept, stmt)
+	}
+	if len(kept) > 0 && len(deleted) > 0 {
+		kept[len(kept)-1].Decorations().After = deleted[len(deleted)-1].Decorations().After
+	}
+	return kept
+}
+
+// moveDecorations moves the comments above a statement that is being deleted to the statement after it. If the
+// statement after it is separated from it by an empty line, the empty line is kept between the comments and it.
+func moveDecorations(deleted, next dst.Stmt, first bool) {
+	from, to := deleted.Decorations(), next.Decorations()
+	if len(from.Start) > 0 && to.Before == dst.EmptyLine {
+		to.Start = append(append(append(dst.Decorations{}, from.Start...), "\n"), to.Start...)
+		to.Before = from.Before
+		return
+	}
+	if len(from.Start) > 0 {
+		to.Start = append(append(dst.Decorations{}, from.Start...), to.Start...)
+	}
+	if first || from.Before > to.Before {
+		to.Before = from.Before
+	}
+}
+
+// isAgentType returns true if t is a type declared by the go agent or one of its integrations, or a pointer to one.
+func isAgentType(t types.Type) bool {
+	if ptr, ok := t.(*types.Pointer); ok {
+		t = ptr.Elem()
+	}
+	named, ok := t.(*types.Named)
+	return ok && named.Obj().Pkg() != nil && strings.HasPrefix(named.Obj().Pkg().Path()+"/", agentImportPrefix)
+}
+
+// typeOf returns the type of an expression, or nil if it is not known, such as when the agent module is not
+// downloaded, or the expression was not loaded from the application.
+func typeOf(pkg *decorator.Package, expr dst.Expr) types.Type {
+	if pkg == nil || pkg.TypesInfo == nil {
+		return nil
+	}
+	t := util.TypeOf(expr, pkg)
+	if t == nil || t == types.Typ[types.Invalid] {
+		return nil
+	}
+	return t
+}
+
+// isAgentValue returns true if the expression holds a value of a type from the go agent. If the type is not
+// known, calls to functions of the agent are assumed to return one.
+func isAgentValue(pkg *decorator.Package, expr dst.Expr) bool {
+	if t := typeOf(pkg, expr); t != nil {
+		return isAgentType(t)
+	}
+	call, ok := expr.(*dst.CallExpr)
+	if !ok {
+		return false
+	}
+	ident, ok := call.Fun.(*dst.Ident)
+	return ok && strings.HasPrefix(ident.Path, agentImportPrefix)
+}
+
+// mayBeAgentValue returns true unless the expression is known to hold a value that is not from the go agent. It
+// is used to check the receiver of methods that are only declared by the agent, such as NoticeError.
+func mayBeAgentValue(pkg *decorator.Package, expr dst.Expr) bool {
+	if t := typeOf(pkg, expr); t != nil {
+		return isAgentType(t)
+	}
+	return true
+}
+
+// isNoticeError returns true if the statement is a call to NoticeError, such as `txn.NoticeError(err)`.
+func isNoticeError(pkg *decorator.Package, stmt dst.Stmt) bool {
+	exprStmt, ok := stmt.(*dst.ExprStmt)
+	if !ok {
+		return false
+	}
+	call, ok := exprStmt.X.(*dst.CallExpr)
+	if !ok {
+		return false
+	}
+	sel, ok := call.Fun.(*dst.SelectorExpr)
+	return ok && sel.Sel.Name == "NoticeError" && mayBeAgentValue(pkg, sel.X)
+}
+
+// isNoticeErrorCheck returns true if the statement is an if statement that does nothing but notice errors.
+func isNoticeErrorCheck(pkg *decorator.Package, stmt dst.Stmt) bool {
+	ifStmt, ok := stmt.(*dst.IfStmt)
+	if !ok || ifStmt.Init != nil || ifStmt.Else != nil || len(ifStmt.Body.List) == 0 {
+		return false
+	}
+	for _, s := range ifStmt.Body.List {
+		if !isNoticeError(pkg, s) {
+			return false
+		}
+	}
+	return true
+}
+
+// restoreReturnValues undoes codegen.CaptureErrorReturnCallExpression in a list of statements: the variables
+// that a call was assigned to so that its error could be noticed are replaced by the call in the return statement
+// that follows them.
+func restoreReturnValues(pkg *decorator.Package, list *[]dst.Stmt) bool {
+	changed := false
+	for i := 0; i < len(*list); i++ {
+		assign, ok := (*list)[i].(*dst.AssignStmt)
+		if !ok || assign.Tok != token.DEFINE || len(assign.Rhs) != 1 || !slices.ContainsFunc(assign.Decs.Start, isGeneratedHeader) {
+			continue
+		}
+
+		next := i + 1
+		if next < len(*list) && isNoticeErrorCheck(pkg, (*list)[next]) {
+			next++
+		}
+		if next >= len(*list) {
+			continue
+		}
+		ret, ok := (*list)[next].(*dst.ReturnStmt)
+		if !ok {
+			continue
+		}
+		start := returnedVariables(ret, assign.Lhs)
+		if start < 0 {
+			continue
+		}
+
+		// the spacing above the return statement was replaced by an empty line when the call was captured
+		ret.Results = slices.Replace(ret.Results, start, start+len(assign.Lhs), assign.Rhs[0])
+		ret.Decs.Before = dst.NewLine
+		if i > 0 {
+			(*list)[i-1].Decorations().After = dst.NewLine
+		}
+		ret.Decs.Start = append(slices.DeleteFunc(slices.Clone(assign.Decs.Start), isGeneratedHeader), ret.Decs.Start...)
+		*list = slices.Delete(*list, i, next)
+		changed = true
+	}
+	return changed
+}
+
+func isGeneratedHeader(line string) bool {
+	return strings.HasPrefix(line, generatedHeader)
+}
+
+// returnedVariables returns the index of the first result of a return statement that returns the given variables,
+// in order, or -1 if it does not return them.
+func returnedVariables(ret *dst.ReturnStmt, variables []dst.Expr) int {
+	for start := 0; start+len(variables) <= len(ret.Results); start++ {
+		match := true
+		for i, variable := range variables {
+			want, ok := variable.(*dst.Ident)
+			got, ok2 := ret.Results[start+i].(*dst.Ident)
+			if !ok || !ok2 || want.Name != got.Name {
+				match = false
+				break
+			}
+		}
+		if match {
+			return start
+		}
+	}
+	return -1
+}
+
+// objectOf returns the object that an identifier declares or refers to, or nil if it is not known.
+func objectOf(pkg *decorator.Package, ident *dst.Ident) types.Object {
+	if pkg == nil || pkg.TypesInfo == nil {
+		return nil
+	}
+	var astIdent *ast.Ident
+	switch n := pkg.Decorator.Ast.Nodes[ident].(type) {
+	case *ast.Ident:
+		astIdent = n
+	case *ast.SelectorExpr:
+		astIdent = n.Sel
+	default:
+		return nil
+	}
+	if obj := pkg.TypesInfo.Defs[astIdent]; obj != nil {
+		return obj
+	}
+	return pkg.TypesInfo.Uses[astIdent]
+}
+
+// rootObject returns the object of the variable that a chain of selectors and calls starts from, such as txn in
+// `txn.StartSegment("name").End`.
+func rootObject(pkg *decorator.Package, expr dst.Expr) types.Object {
+	for {
+		switch e := expr.(type) {
+		case *dst.SelectorExpr:
+			expr = e.X
+		case *dst.CallExpr:
+			expr = e.Fun
+		case *dst.ParenExpr:
+			expr = e.X
+		case *dst.Ident:
+			return objectOf(pkg, e)
+		default:
+			return nil
+		}
+	}
+}
+
+// isRemovableUse returns true if a statement does nothing but call a method of, or set a field of, the value
+// of a variable, or assign it another value from the agent, so that it can be removed along with the variable.
+func isRemovableUse(pkg *decorator.Package, stmt dst.Stmt, obj types.Object, agents map[types.Object]bool) bool {
+	var call *dst.CallExpr
+	switch s := stmt.(type) {
+	case *dst.ExprStmt:
+		call, _ = s.X.(*dst.CallExpr)
+	case *dst.DeferStmt:
+		call = s.Call
+	case *dst.AssignStmt:
+		if s.Tok != token.ASSIGN || len(s.Lhs) != 1 || len(s.Rhs) != 1 {
+			return false
+		}
+		switch lhs := s.Lhs[0].(type) {
+		case *dst.SelectorExpr:
+			return rootObject(pkg, lhs) == obj
+		case *dst.Ident:
+			return objectOf(pkg, lhs) == obj && (isAgentValue(pkg, s.Rhs[0]) || agents[rootObject(pkg, s.Rhs[0])])
+		}
+		return false
+	}
+	if call == nil {
+		return false
+	}
+	sel, ok := call.Fun.(*dst.SelectorExpr)
+	return ok && rootObject(pkg, sel.X) == obj
+}
+
+// usesOf finds the statements under root that do nothing but use the value of a variable, and counts every
+// other use of it, not including the identifier that declares it. The agents are the variables that are known to
+// hold values from the agent, as returned by agentObjects.
+func usesOf(pkg *decorator.Package, root dst.Node, obj types.Object, declaration *dst.Ident, agents map[types.Object]bool) (map[dst.Stmt]bool, int) {
+	removable := map[dst.Stmt]bool{}
+	others := 0
+	dstutil.Apply(root, func(c *dstutil.Cursor) bool {
+		switch node := c.Node().(type) {
+		case dst.Stmt:
+			if c.Index() >= 0 && isRemovableUse(pkg, node, obj, agents) {
+				removable[node] = true
+				return false
+			}
+		case *dst.Ident:
+			if node != declaration && objectOf(pkg, node) == obj {
+				others++
+			}
+		}
+		return true
+	}, nil)
+	return removable, others
+}
+
+// deleteStatements deletes the given statements wherever they are under root, along with the if statements that
+// are left with an empty body by doing so.
+func deleteStatements(root dst.Node, remove map[dst.Stmt]bool) {
+	emptied := map[*dst.BlockStmt]bool{}
+	markEmptied := func(c *dstutil.Cursor) {
+		if block, ok := c.Parent().(*dst.BlockStmt); ok && len(block.List) == 1 {
+			emptied[block] = true
+		}
+	}
+
+	dstutil.Apply(root, func(c *dstutil.Cursor) bool {
+		stmt, ok := c.Node().(dst.Stmt)
+		if !ok || !remove[stmt] || c.Index() < 0 {
+			return true
+		}
+		markEmptied(c)
+		DeleteStatement(c)
+		return false
+	}, func(c *dstutil.Cursor) bool {
+		ifStmt, ok := c.Node().(*dst.IfStmt)
+		if ok && emptied[ifStmt.Body] && ifStmt.Init == nil && ifStmt.Else == nil && !hasCall(ifStmt.Cond) && c.Index() >= 0 {
+			markEmptied(c)
+			DeleteStatement(c)
+		}
+		return true
+	})
+}
+
+// hasCall returns true if an expression calls a function, and so may have side effects.
+func hasCall(expr dst.Expr) bool {
+	found := false
+	dst.Inspect(expr, func(n dst.Node) bool {
+		if _, ok := n.(*dst.CallExpr); ok {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+// removeUnusedVariables removes the unused New Relic variables of every function.
+func (m *InstrumentationManager) removeUnusedVariables(files []uninstrumentedFile) {
+	for _, f := range files {
+		for _, decl := range f.file.Decls {
+			if fn, ok := decl.(*dst.FuncDecl); ok && fn.Body != nil && m.includeFunction(f.state, f.file, fn) {
+				removeUnusedAgentValues(f.state.pkg, fn.Body)
+			}
+		}
+	}
+}
+
+// removeUnusedAgentValues removes the variables in a function body that hold New Relic values, such as
+// transactions and segments, and are not used by anything other than statements that call their methods, along
+// with those statements. This repeats until there is nothing left to remove, since removing a variable can leave
+// the ones it was created from unused.
+func removeUnusedAgentValues(pkg *decorator.Package, body *dst.BlockStmt) {
+	for removeUnusedAgentValue(pkg, body) {
+	}
+}
+
+func removeUnusedAgentValue(pkg *decorator.Package, body *dst.BlockStmt) bool {
+	agents := agentObjects(pkg, body)
+	removed := false
+	dstutil.Apply(body, func(c *dstutil.Cursor) bool {
+		if removed {
+			return false
+		}
+		assign, ok := c.Node().(*dst.AssignStmt)
+		if !ok || c.Index() < 0 || assign.Tok != token.DEFINE {
+			return true
+		}
+		variables := agentVariables(pkg, assign, agents)
+		if len(variables) == 0 {
+			return true
+		}
+
+		remove := map[dst.Stmt]bool{assign: true}
+		for ident, obj := range variables {
+			uses, others := usesOf(pkg, body, obj, ident, agents)
+			if others > 0 {
+				return true
+			}
+			maps.Copy(remove, uses)
+		}
+		deleteStatements(body, remove)
+		removed = true
+		return false
+	}, nil)
+	return removed
+}
+
+// agentObjects returns the variables declared under root that hold values from the go agent. When the type of a
+// variable is not known, because the agent module could not be loaded, it is assumed to hold a value from the
+// agent if it is declared as a parameter of an agent type, or assigned the result of a call to a function of the
+// agent, or of a method of another variable that holds one.
+func agentObjects(pkg *decorator.Package, root dst.Node) map[types.Object]bool {
+	agents := map[types.Object]bool{}
+	add := func(ident *dst.Ident, unknown func() bool) {
+		obj := objectOf(pkg, ident)
+		if obj == nil || ident.Name == "_" {
+			return
+		}
+		if t := obj.Type(); t != nil && t != types.Typ[types.Invalid] {
+			agents[obj] = isAgentType(t)
+		} else {
+			agents[obj] = unknown()
+		}
+	}
+
+	dst.Inspect(root, func(n dst.Node) bool {
+		switch node := n.(type) {
+		case *dst.Field:
+			for _, name := range node.Names {
+				add(name, func() bool {
+					ident, ok := node.Type.(*dst.Ident)
+					if star, isStar := node.Type.(*dst.StarExpr); isStar {
+						ident, ok = star.X.(*dst.Ident)
+					}
+					return ok && strings.HasPrefix(ident.Path, agentImportPrefix)
+				})
+			}
+		case *dst.AssignStmt:
+			if node.Tok != token.DEFINE {
+				return true
+			}
+			for _, lhs := range node.Lhs {
+				if ident, ok := lhs.(*dst.Ident); ok {
+					add(ident, func() bool {
+						return len(node.Rhs) == 1 && (isAgentValue(pkg, node.Rhs[0]) || agents[rootObject(pkg, node.Rhs[0])])
+					})
+				}
+			}
+		}
+		return true
+	})
+	return agents
+}
+
+// agentVariables returns the variables declared by an assignment if every one of them holds a value from the go
+// agent, such as `txn := app.StartTransaction("name")` or `app, _ := newrelic.NewApplication()`, and nil otherwise.
+func agentVariables(pkg *decorator.Package, assign *dst.AssignStmt, agents map[types.Object]bool) map[*dst.Ident]types.Object {
+	variables := map[*dst.Ident]types.Object{}
+	for _, lhs := range assign.Lhs {
+		ident, ok := lhs.(*dst.Ident)
+		if !ok {
+			return nil
+		}
+		if ident.Name == "_" {
+			continue
+		}
+		obj := objectOf(pkg, ident)
+		if obj == nil || !agents[obj] {
+			return nil
+		}
+		variables[ident] = obj
+	}
+	return variables
+}
+
+// transactionParameter is a transaction parameter of a function declared in the application.
+type transactionParameter struct {
+	pkg   *decorator.Package
+	decl  *dst.FuncDecl
+	field *dst.Field
+	index int // position of the parameter in the signature of the function
+	obj   types.Object
+}
+
+// isTransactionParameter returns true if the field is a single parameter of type *newrelic.Transaction.
+func isTransactionParameter(field *dst.Field) bool {
+	star, ok := field.Type.(*dst.StarExpr)
+	if !ok || len(field.Names) != 1 {
+		return false
+	}
+	ident, ok := star.X.(*dst.Ident)
+	return ok && ident.Name == "Transaction" && ident.Path == codegen.NewRelicAgentImportPath
+}
+
+// functionKey returns the full name of the function a call or declaration refers to, such as
+// "example.com/app.work" or "(*example.com/app.Server).Handle", which is the same in every package it is used in.
+func functionKey(pkg *decorator.Package, fun dst.Expr) string {
+	for {
+		switch f := fun.(type) {
+		case *dst.ParenExpr:
+			fun = f.X
+			continue
+		case *dst.IndexExpr:
+			fun = f.X
+			continue
+		case *dst.IndexListExpr:
+			fun = f.X
+			continue
+		case *dst.SelectorExpr:
+			fun = f.Sel
+			continue
+		case *dst.Ident:
+			if fn, ok := objectOf(pkg, f).(*types.Func); ok {
+				return fn.Origin().FullName()
+			}
+		}
+		return ""
+	}
+}
+
+// removeTransactionParameters removes the transaction parameters of the functions declared in the application,
+// and the arguments passed for them. A parameter is kept if it is still used by its function once the statements
+// that only call its methods are removed, other than to be passed on to a parameter that is removed.
+func (m *InstrumentationManager) removeTransactionParameters(files []uninstrumentedFile) {
+	params := map[string][]*transactionParameter{}
+	for _, f := range files {
+		for _, decl := range f.file.Decls {
+			fn, ok := decl.(*dst.FuncDecl)
+			if !ok || fn.Body == nil || !m.includeFunction(f.state, f.file, fn) {
+				continue
+			}
+			key := functionKey(f.state.pkg, fn.Name)
+			if key == "" {
+				continue
+			}
+			index := 0
+			for _, field := range fn.Type.Params.List {
+				if isTransactionParameter(field) {
+					if obj := objectOf(f.state.pkg, field.Names[0]); obj != nil {
+						params[key] = append(params[key], &transactionParameter{pkg: f.state.pkg, decl: fn, field: field, index: index, obj: obj})
+					}
+				}
+				index += max(len(field.Names), 1)
+			}
+		}
+	}
+
+	// statements that only use a parameter are removed first, so that they do not keep it
+	for _, list := range params {
+		for _, param := range list {
+			uses, _ := usesOf(param.pkg, param.decl.Body, param.obj, nil, agentObjects(param.pkg, param.decl))
+			deleteStatements(param.decl.Body, uses)
+		}
+	}
+
+	isRemoved := func(pkg *decorator.Package, call *dst.CallExpr, arg int) bool {
+		for _, param := range params[functionKey(pkg, call.Fun)] {
+			if param.index == arg {
+				return true
+			}
+		}
+		return false
+	}
+
+	// parameters that are still used can not be removed, and neither can the arguments passed to them, which may
+	// be the only remaining use of a parameter of the calling function
+	for changed := true; changed; {
+		changed = false
+		for key, list := range params {
+			for i, param := range list {
+				if m.parameterUsed(param, isRemoved) {
+					params[key] = slices.Delete(list, i, i+1)
+					if len(params[key]) == 0 {
+						delete(params, key)
+					}
+					changed = true
+					break
+				}
+			}
+		}
+	}
+
+	for _, list := range params {
+		for _, param := range list {
+			param.decl.Type.Params.List = slices.DeleteFunc(param.decl.Type.Params.List, func(field *dst.Field) bool { return field == param.field })
+		}
+	}
+
+	for _, f := range files {
+		for _, decl := range f.file.Decls {
+			dstutil.Apply(decl, func(c *dstutil.Cursor) bool {
+				call, ok := c.Node().(*dst.CallExpr)
+				if !ok || call.Ellipsis {
+					return true
+				}
+				if lit, ok := call.Fun.(*dst.FuncLit); ok {
+					removeLiteralTransactionParameters(f.state.pkg, lit, call)
+					return true
+				}
+				for i := len(call.Args) - 1; i >= 0; i-- {
+					if isRemoved(f.state.pkg, call, i) {
+						call.Args = slices.Delete(call.Args, i, i+1)
+					}
+				}
+				return true
+			}, nil)
+		}
+	}
+}
+
+// parameterUsed returns true if a transaction parameter is used by its function for anything other than to be
+// passed to another parameter that is removed.
+func (m *InstrumentationManager) parameterUsed(param *transactionParameter, isRemoved func(*decorator.Package, *dst.CallExpr, int) bool) bool {
+	used := false
+	dst.Inspect(param.decl.Body, func(n dst.Node) bool {
+		if used {
+			return false
+		}
+		switch node := n.(type) {
+		case *dst.CallExpr:
+			for i, arg := range node.Args {
+				if rootObject(param.pkg, arg) == param.obj && !isRemoved(param.pkg, node, i) {
+					used = true
+				}
+			}
+		case *dst.Ident:
+			if objectOf(param.pkg, node) == param.obj {
+				used = !m.passedToRemovedParameter(param, node, isRemoved)
+			}
+		}
+		return !used
+	})
+	return used
+}
+
+// passedToRemovedParameter returns true if the identifier is an argument, or the start of an argument, of a call
+// that passes it to a parameter that is removed.
+func (m *InstrumentationManager) passedToRemovedParameter(param *transactionParameter, ident *dst.Ident, isRemoved func(*decorator.Package, *dst.CallExpr, int) bool) bool {
+	passed := false
+	dst.Inspect(param.decl.Body, func(n dst.Node) bool {
+		call, ok := n.(*dst.CallExpr)
+		if !ok || passed {
+			return !passed
+		}
+		for i, arg := range call.Args {
+			if isRemoved(param.pkg, call, i) && contains(arg, ident) {
+				passed = true
+			}
+		}
+		return !passed
+	})
+	return passed
+}
+
+// contains returns true if the node is found under root.
+func contains(root, node dst.Node) bool {
+	found := false
+	dst.Inspect(root, func(n dst.Node) bool {
+		if n == node {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+// removeLiteralTransactionParameters removes the transaction parameters of a function literal that is called
+// where it is declared, such as `go func(txn *newrelic.Transaction) { ... }(txn.NewGoroutine())`, along with the
+// arguments passed for them.
+func removeLiteralTransactionParameters(pkg *decorator.Package, lit *dst.FuncLit, call *dst.CallExpr) {
+	index := 0
+	fields := slices.Clone(lit.Type.Params.List)
+	for _, field := range fields {
+		if isTransactionParameter(field) && index < len(call.Args) {
+			obj := objectOf(pkg, field.Names[0])
+			if obj == nil {
+				index++
+				continue
+			}
+			agents := agentObjects(pkg, lit)
+			uses, _ := usesOf(pkg, lit.Body, obj, nil, agents)
+			deleteStatements(lit.Body, uses)
+			if _, others := usesOf(pkg, lit.Body, obj, nil, agents); others == 0 {
+				lit.Type.Params.List = slices.DeleteFunc(lit.Type.Params.List, func(f *dst.Field) bool { return f == field })
+				call.Args = slices.Delete(call.Args, index, index+1)
+				continue
+			}
+		}
+		index += max(len(field.Names), 1)
+	}
+}
+
+// remainingInstrumentation adds a warning comment to every statement that still uses the go agent once the
+// instrumentation has been removed, and returns a message for each of them.
+func (m *InstrumentationManager) remainingInstrumentation(files []uninstrumentedFile) []string {
+	const message = "New Relic instrumentation could not be removed automatically, and must be removed by hand"
+
+	warnings := []string{}
+	for _, f := range files {
+		pkg := f.state.pkg
+		for _, decl := range f.file.Decls {
+			marked := []dst.Node{}
+			scopes := []dst.Node{decl}
+			dstutil.Apply(decl, func(c *dstutil.Cursor) bool {
+				node := c.Node()
+				if _, ok := node.(dst.Stmt); ok {
+					scopes = append(scopes, node)
+				}
+				if !usesAgent(pkg, node) {
+					return true
+				}
+				if scope := scopes[len(scopes)-1]; !slices.Contains(marked, scope) {
+					marked = append(marked, scope)
+				}
+				if _, ok := node.(dst.Stmt); ok {
+					scopes = scopes[:len(scopes)-1]
+				}
+				return false
+			}, func(c *dstutil.Cursor) bool {
+				if _, ok := c.Node().(dst.Stmt); ok {
+					scopes = scopes[:len(scopes)-1]
+				}
+				return true
+			})
+
+			for _, node := range marked {
+				comment.Warn(pkg, node, node, message)
+				location := m.relativeFilePath(pkg.Decorator.Filenames[f.file])
+				if pos := util.Position(node, pkg); pos != nil {
+					location = fmt.Sprintf("%s:%d", location, pos.Line)
+				}
+				warnings = append(warnings, fmt.Sprintf("%s: %s", location, message))
+			}
+		}
+	}
+	return warnings
+}
+
+// usesAgent returns true if a node refers to the go agent: a package, function or type of the agent, a blank
+// import of one of its integrations, or a variable holding a value from the agent.
+func usesAgent(pkg *decorator.Package, node dst.Node) bool {
+	switch n := node.(type) {
+	case *dst.ImportSpec:
+		return n.Name != nil && strings.HasPrefix(strings.Trim(n.Path.Value, `"`)+"/", agentImportPrefix)
+	case *dst.Ident:
+		if strings.HasPrefix(n.Path+"/", agentImportPrefix) {
+			return true
+		}
+		if obj, ok := objectOf(pkg, n).(*types.Var); ok {
+			return isAgentType(obj.Type())
+		}
+	}
+	return false
+}
@@ -0,0 +1,215 @@
+package parser
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/dave/dst"
+	"github.com/dave/dst/decorator"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRemoveInstrumentation(t *testing.T) {
+	tests := []struct {
+		name     string
+		code     string
+		expect   string
+		warnings int
+	}{
+		{
+			name: "segments, errors and transaction parameters",
+			code: `package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+func work(n int, nrTxn *newrelic.Transaction) error {
+	defer nrTxn.StartSegment("work").End()
+	if n > 3 {
+		return errors.New("too big")
+	}
+	fmt.Println("working", n)
+	return nil
+}
+
+func main() {
+	NewRelicAgent, err := newrelic.NewApplication(newrelic.ConfigFromEnvironment())
+	if err != nil {
+		panic(err)
+	}
+
+	nrTxn := NewRelicAgent.StartTransaction("work")
+	err = work(1, nrTxn)
+	if err != nil {
+		nrTxn.NoticeError(err)
+		fmt.Println(err)
+	}
+	nrTxn.End()
+
+	nrTxn = NewRelicAgent.StartTransaction("again")
+	fmt.Println("again")
+	nrTxn.End()
+}
+`,
+			expect: `package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+func work(n int) error {
+	if n > 3 {
+		return errors.New("too big")
+	}
+	fmt.Println("working", n)
+	return nil
+}
+
+func main() {
+	// NR WARN: New Relic instrumentation could not be removed automatically, and must be removed by hand
+	NewRelicAgent, err := newrelic.NewApplication(newrelic.ConfigFromEnvironment())
+	if err != nil {
+		panic(err)
+	}
+
+	err = work(1)
+	if err != nil {
+		fmt.Println(err)
+	}
+
+	fmt.Println("again")
+}
+`,
+			// the application is created by the agent integration, which is not loaded
+			warnings: 1,
+		},
+		{
+			name: "captured return values",
+			code: `package main
+
+import (
+	"errors"
+
+	"github.com/newrelic/go-agent/v3/newrelic"
+)
+
+func main() {}
+
+func fetch() error {
+	return errors.New("failed")
+}
+
+func handler(txn *newrelic.Transaction) (int, error) {
+	// generated by go-easy-instrumentation; the following variables were created to capture the error returned by fetch
+	fetchErr := fetch()
+	if fetchErr != nil {
+		txn.NoticeError(fetchErr)
+	}
+	return 1, fetchErr
+}
+`,
+			expect: `package main
+
+import "errors"
+
+func main() {}
+
+func fetch() error {
+	return errors.New("failed")
+}
+
+func handler() (int, error) {
+	return 1, fetch()
+}
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer PanicRecovery(t)
+			got, warnings := RunUninstrumentFunctions(t, tt.code, RemoveSegments, RemoveNoticeErrors, RemoveTransactionContexts)
+			assert.Equal(t, tt.expect, got)
+			assert.Len(t, warnings, tt.warnings)
+		})
+	}
+}
+
+func TestRemoveInstrumentation_NoFunctions(t *testing.T) {
+	manager := &InstrumentationManager{}
+	if _, err := manager.RemoveInstrumentation(); err == nil {
+		t.Error("expected an error when no uninstrument functions are loaded")
+	}
+}
+
+func TestDeleteStatements(t *testing.T) {
+	pkgs := UnitTest(t, `package main
+
+func main() {
+	println(1)
+
+	// about two
+	println(2)
+	println(3)
+	println(4)
+}
+`)
+	fn := pkgs[0].Syntax[0].Decls[0].(*dst.FuncDecl)
+	fn.Body.List = DeleteStatements(fn.Body.List, func(stmt dst.Stmt) bool {
+		call := stmt.(*dst.ExprStmt).X.(*dst.CallExpr)
+		lit := call.Args[0].(*dst.BasicLit)
+		return lit.Value == "2" || lit.Value == "4"
+	})
+
+	buf := bytes.NewBuffer([]byte{})
+	if err := decorator.Fprint(buf, pkgs[0].Syntax[0]); err != nil {
+		t.Fatal(err)
+	}
+	expect := `package main
+
+func main() {
+	println(1)
+
+	// about two
+	println(3)
+}
+`
+	assert.Equal(t, expect, buf.String())
+}
+
+func TestDeleteStatements_EmptyLineAfterComments(t *testing.T) {
+	pkgs := UnitTest(t, `package main
+
+func main() {
+	// about one
+	println(1)
+
+	println(2)
+}
+`)
+	fn := pkgs[0].Syntax[0].Decls[0].(*dst.FuncDecl)
+	fn.Body.List = DeleteStatements(fn.Body.List, func(stmt dst.Stmt) bool {
+		call := stmt.(*dst.ExprStmt).X.(*dst.CallExpr)
+		return call.Args[0].(*dst.BasicLit).Value == "1"
+	})
+
+	buf := bytes.NewBuffer([]byte{})
+	if err := decorator.Fprint(buf, pkgs[0].Syntax[0]); err != nil {
+		t.Fatal(err)
+	}
+	expect := `package main
+
+func main() {
+	// about one
+
+	println(2)
+}
+`
+	assert.Equal(t, expect, buf.String())
+}
@@ -0,0 +1,260 @@
+package parser
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/newrelic/go-easy-instrumentation/internal/diff"
+	"github.com/newrelic/go-easy-instrumentation/internal/util"
+	"golang.org/x/tools/go/packages"
+)
+
+// verifyLoadMode is the minimum amount of information needed to type check the instrumented application.
+const verifyLoadMode = packages.LoadSyntax | packages.NeedForTest
+
+// CompileError is an error reported by the type checker for the instrumented application.
+type CompileError struct {
+	File    string // name of the file as it appears in the diff
+	Line    int
+	Column  int
+	Message string
+	Hunk    string // header of the hunk that introduced the error; empty if it could not be attributed to a single hunk
+}
+
+func (e CompileError) String() string {
+	pos := e.File
+	if e.Line > 0 {
+		pos += ":" + strconv.Itoa(e.Line)
+		if e.Column > 0 {
+			pos += ":" + strconv.Itoa(e.Column)
+		}
+	}
+	if e.Hunk == "" {
+		return fmt.Sprintf("%s: %s", pos, e.Message)
+	}
+	return fmt.Sprintf("%s: %s (introduced by hunk %s)", pos, e.Message, e.Hunk)
+}
+
+// VerificationResult is the outcome of type checking the instrumented application.
+type VerificationResult struct {
+	Errors  []CompileError // errors that remain in the instrumented application
+	Dropped []CompileError // errors that were resolved by dropping the hunk that introduced them
+}
+
+// VerifyChanges type checks the instrumented application in memory, without writing anything to disk,
+// and maps every compile error back to the hunk of the diff that introduced it.
+//
+// If dropFailingHunks is true, every hunk that introduces a compile error is removed from the
+// changes, and the application is checked again until no more hunks can be dropped. Errors that
+// can not be attributed to a single hunk are never dropped, and are reported in the result.
+func (m *InstrumentationManager) VerifyChanges(dropFailingHunks bool) (*VerificationResult, error) {
+	if err := m.restoreChanges(); err != nil {
+		return nil, err
+	}
+
+	result := &VerificationResult{}
+	for {
+		compileErrors, err := m.typeCheckChanges()
+		if err != nil {
+			return nil, err
+		}
+
+		if !dropFailingHunks {
+			result.Errors = compileErrors
+			return result, nil
+		}
+
+		dropped, err := m.dropFailingHunks(compileErrors)
+		if err != nil {
+			return nil, err
+		}
+		if len(dropped) == 0 {
+			result.Errors = compileErrors
+			return result, nil
+		}
+		result.Dropped = append(result.Dropped, dropped...)
+	}
+}
+
+// typeCheckChanges loads every package in the application with the modified files overlaid on top of
+// the originals, and returns the compile errors reported for them.
+func (m *InstrumentationManager) typeCheckChanges() ([]CompileError, error) {
+	overlay := map[string][]byte{}
+	changes := map[string]*fileChange{}
+	for _, change := range m.changes {
+		// go.mod and go.sum changes can not be overlaid; missing modules are filtered out of the errors instead
+		if change.modified != change.original && filepath.Ext(change.path) == ".go" {
+			overlay[change.path] = []byte(change.modified)
+			changes[change.path] = change
+		}
+	}
+	if len(overlay) == 0 {
+		return nil, nil
+	}
+
+	patterns := []string{}
+	importsAdded := []string{}
+	for _, pkg := range m.getSortedPackages() {
+		state := m.packages[pkg]
+		for imp := range state.importsAdded {
+			importsAdded = append(importsAdded, imp)
+		}
+		if util.IsTestPackage(state.pkg) || slices.Contains(patterns, state.pkg.PkgPath) {
+			continue
+		}
+		patterns = append(patterns, state.pkg.PkgPath)
+	}
+	// cached packages are checked too, since they may be changed by the diff
+	for _, path := range m.cachedPaths() {
+		importsAdded = append(importsAdded, m.cached[path].ImportsAdded...)
+		patterns = append(patterns, path)
+	}
+
+	pkgs, err := packages.Load(&packages.Config{Dir: m.userAppPath, Mode: verifyLoadMode, Tests: true, Overlay: overlay}, patterns...)
+	if err != nil {
+		return nil, fmt.Errorf("loading instrumented packages: %w", err)
+	}
+
+	absAppPath, err := filepath.Abs(m.userAppPath)
+	if err != nil {
+		return nil, err
+	}
+
+	hunks := map[string][]*diff.Hunk{}
+	seen := map[string]bool{}
+	compileErrors := []CompileError{}
+	packages.Visit(pkgs, nil, func(pkg *packages.Package) {
+		for _, pkgErr := range pkg.Errors {
+			// compiler output from go list duplicates the errors reported by the type checker
+			if pkgErr.Kind == packages.ListError && strings.HasPrefix(pkgErr.Msg, "# ") {
+				continue
+			}
+
+			// a missing module for an import we added is resolved by go get, not by changing the code
+			if isMissingImport(pkgErr.Msg, importsAdded) {
+				continue
+			}
+
+			// test variants of a package report the same errors more than once
+			key := pkgErr.Pos + pkgErr.Msg
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			path, line, column := parseErrorPosition(pkgErr.Pos)
+			compileError := CompileError{
+				File:    path,
+				Line:    line,
+				Column:  column,
+				Message: pkgErr.Msg,
+			}
+			if rel, err := filepath.Rel(absAppPath, path); err == nil && path != "" {
+				compileError.File = rel
+			}
+
+			if change, ok := changes[path]; ok {
+				fileHunks, ok := hunks[path]
+				if !ok {
+					fileHunks, err = diff.Parse(change.patch())
+					if err != nil {
+						continue
+					}
+					hunks[path] = fileHunks
+				}
+				for _, hunk := range fileHunks {
+					if hunk.ContainsNewLine(line) {
+						compileError.Hunk = hunk.Header()
+						break
+					}
+				}
+			}
+
+			compileErrors = append(compileErrors, compileError)
+		}
+	})
+
+	slices.SortStableFunc(compileErrors, func(a, b CompileError) int {
+		if a.File != b.File {
+			return strings.Compare(a.File, b.File)
+		}
+		return a.Line - b.Line
+	})
+	return compileErrors, nil
+}
+
+// dropFailingHunks removes every hunk that introduced one of the compile errors from the changes, and
+// returns the errors that were attributed to a dropped hunk.
+func (m *InstrumentationManager) dropFailingHunks(compileErrors []CompileError) ([]CompileError, error) {
+	dropped := []CompileError{}
+	for _, change := range m.changes {
+		failing := map[string]bool{}
+		for _, compileError := range compileErrors {
+			if compileError.Hunk != "" && compileError.File == change.diffName {
+				failing[compileError.Hunk] = true
+				dropped = append(dropped, compileError)
+			}
+		}
+		if len(failing) == 0 {
+			continue
+		}
+
+		hunks, err := diff.Parse(change.patch())
+		if err != nil {
+			return nil, err
+		}
+		keep := slices.DeleteFunc(hunks, func(h *diff.Hunk) bool { return failing[h.Header()] })
+		modified, err := diff.Apply(change.original, keep)
+		if err != nil {
+			return nil, fmt.Errorf("dropping failing hunks from %s: %w", change.diffName, err)
+		}
+		change.modified = modified
+	}
+	return dropped, nil
+}
+
+// missingImportErrors are the parts of the package errors that report an import that can not be resolved, because
+// the module that provides it is not required yet.
+var missingImportErrors = []string{"could not import", "no required module provides package"}
+
+// isMissingImport returns true if a package error reports that one of the imports added by instrumentation can not
+// be resolved. Other errors that mention an added import, such as type errors, are real errors in the changes.
+func isMissingImport(msg string, importsAdded []string) bool {
+	if !slices.ContainsFunc(missingImportErrors, func(missing string) bool { return strings.Contains(msg, missing) }) {
+		return false
+	}
+	return slices.ContainsFunc(importsAdded, func(imp string) bool { return imp != "" && strings.Contains(msg, imp) })
+}
+
+// parseErrorPosition splits a go/packages error position in the form "file:line:column" into its parts.
+// Positions may omit the column or line, or be "-" if unknown.
+func parseErrorPosition(pos string) (string, int, int) {
+	if pos == "" || pos == "-" {
+		return "", 0, 0
+	}
+
+	numbers := []int{}
+	for range 2 {
+		i := strings.LastIndexByte(pos, ':')
+		if i < 0 {
+			break
+		}
+		n, err := strconv.Atoi(pos[i+1:])
+		if err != nil {
+			break
+		}
+		numbers = append([]int{n}, numbers...)
+		pos = pos[:i]
+	}
+
+	switch len(numbers) {
+	case 2:
+		return pos, numbers[0], numbers[1]
+	case 1:
+		return pos, numbers[0], 0
+	}
+	return pos, 0, 0
+}
@@ -0,0 +1,217 @@
+package parser
+
+import (
+	"fmt"
+	"go/token"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/dave/dst"
+)
+
+const verifyTestApp = `package main
+
+import "fmt"
+
+func main() {
+	fmt.Println("hello world")
+}
+`
+
+// verifyTestManager creates a manager for the verify test app, and appends the given statements to the body of main.
+func verifyTestManager(t *testing.T, stmts ...dst.Stmt) (*InstrumentationManager, string) {
+	id, err := Pseudo_uuid()
+	if err != nil {
+		t.Fatal(err)
+	}
+	testDir := fmt.Sprintf("tmp_%s", id)
+	t.Cleanup(func() { CleanTestApp(t, testDir) })
+
+	manager := TestInstrumentationManager(t, verifyTestApp, testDir)
+	pkg := manager.getDecoratorPackage()
+	if pkg == nil {
+		t.Fatalf("Package was nil: %+v", manager.packages)
+	}
+	mainDecl := pkg.Syntax[0].Decls[1].(*dst.FuncDecl)
+	mainDecl.Body.List = append(mainDecl.Body.List, stmts...)
+	return manager, testDir
+}
+
+func TestVerifyChanges(t *testing.T) {
+	tests := []struct {
+		name         string
+		stmts        []dst.Stmt
+		drop         bool
+		wantErrors   int
+		wantDropped  int
+		wantOriginal bool
+		wantMessage  string
+	}{
+		{
+			name:       "no changes",
+			wantErrors: 0,
+		},
+		{
+			name: "valid change",
+			stmts: []dst.Stmt{
+				&dst.ExprStmt{X: &dst.CallExpr{Fun: &dst.Ident{Name: "Println", Path: "fmt"}}},
+			},
+			wantErrors: 0,
+		},
+		{
+			name: "unused variable is attributed to hunk",
+			stmts: []dst.Stmt{
+				&dst.AssignStmt{Lhs: []dst.Expr{dst.NewIdent("unused")}, Tok: token.DEFINE, Rhs: []dst.Expr{&dst.BasicLit{Kind: token.INT, Value: "1"}}},
+			},
+			wantErrors:  1,
+			wantMessage: "unused",
+		},
+		{
+			name: "failing hunk is dropped",
+			stmts: []dst.Stmt{
+				&dst.AssignStmt{Lhs: []dst.Expr{dst.NewIdent("unused")}, Tok: token.DEFINE, Rhs: []dst.Expr{&dst.BasicLit{Kind: token.INT, Value: "1"}}},
+			},
+			drop:         true,
+			wantErrors:   0,
+			wantDropped:  1,
+			wantOriginal: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer PanicRecovery(t)
+			manager, _ := verifyTestManager(t, tt.stmts...)
+
+			result, err := manager.VerifyChanges(tt.drop)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(result.Errors) != tt.wantErrors {
+				t.Fatalf("expected %d compile errors, got %d: %v", tt.wantErrors, len(result.Errors), result.Errors)
+			}
+			if len(result.Dropped) != tt.wantDropped {
+				t.Fatalf("expected %d dropped hunks, got %d: %v", tt.wantDropped, len(result.Dropped), result.Dropped)
+			}
+
+			for _, compileError := range result.Errors {
+				if compileError.Hunk == "" {
+					t.Errorf("expected compile error to be attributed to a hunk: %s", compileError)
+				}
+				if compileError.File != "app.go" {
+					t.Errorf("expected compile error in app.go, got %q", compileError.File)
+				}
+				if !strings.Contains(compileError.Message, tt.wantMessage) {
+					t.Errorf("expected compile error to contain %q, got %q", tt.wantMessage, compileError.Message)
+				}
+			}
+
+			if tt.wantOriginal {
+				for _, change := range manager.changes {
+					if change.modified != change.original {
+						t.Errorf("expected all changes to %s to be dropped, got:\n%s", change.diffName, change.patch())
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestVerifyChangesWriteDiff(t *testing.T) {
+	defer PanicRecovery(t)
+	unused := &dst.AssignStmt{Lhs: []dst.Expr{dst.NewIdent("unused")}, Tok: token.DEFINE, Rhs: []dst.Expr{&dst.BasicLit{Kind: token.INT, Value: "1"}}}
+	manager, _ := verifyTestManager(t, unused)
+
+	if err := manager.CreateDiffFile(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := manager.VerifyChanges(true); err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.WriteDiff(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	patch, err := os.ReadFile(manager.diffFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(patch) != 0 {
+		t.Errorf("expected the dropped hunk to be left out of the diff, got:\n%s", patch)
+	}
+}
+
+func TestParseErrorPosition(t *testing.T) {
+	tests := []struct {
+		pos        string
+		wantFile   string
+		wantLine   int
+		wantColumn int
+	}{
+		{pos: "/app/main.go:10:2", wantFile: "/app/main.go", wantLine: 10, wantColumn: 2},
+		{pos: "/app/main.go:10", wantFile: "/app/main.go", wantLine: 10},
+		{pos: "/app/main.go", wantFile: "/app/main.go"},
+		{pos: `C:\app\main.go:3:4`, wantFile: `C:\app\main.go`, wantLine: 3, wantColumn: 4},
+		{pos: "-"},
+		{pos: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pos, func(t *testing.T) {
+			file, line, column := parseErrorPosition(tt.pos)
+			if file != tt.wantFile || line != tt.wantLine || column != tt.wantColumn {
+				t.Errorf("parseErrorPosition(%q) = %q, %d, %d; want %q, %d, %d", tt.pos, file, line, column, tt.wantFile, tt.wantLine, tt.wantColumn)
+			}
+		})
+	}
+}
+
+func TestIsMissingImport(t *testing.T) {
+	importsAdded := []string{"github.com/newrelic/go-agent/v3/newrelic", ""}
+	tests := []struct {
+		msg  string
+		want bool
+	}{
+		{msg: `could not import github.com/newrelic/go-agent/v3/newrelic (invalid package name: "")`, want: true},
+		{msg: "no required module provides package github.com/newrelic/go-agent/v3/newrelic; to add it:\n\tgo get github.com/newrelic/go-agent/v3/newrelic", want: true},
+		{msg: "could not import github.com/example/missing (invalid package name: \"\")", want: false},
+		{msg: "cannot use nrTxn (variable of type *github.com/newrelic/go-agent/v3/newrelic.Transaction) as string value in argument to greet", want: false},
+		{msg: "undefined: x", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.msg, func(t *testing.T) {
+			if got := isMissingImport(tt.msg, importsAdded); got != tt.want {
+				t.Errorf("isMissingImport(%q) = %v; want %v", tt.msg, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompileErrorString(t *testing.T) {
+	tests := []struct {
+		name string
+		err  CompileError
+		want string
+	}{
+		{
+			name: "attributed",
+			err:  CompileError{File: "main.go", Line: 7, Column: 2, Message: "declared and not used: x", Hunk: "@@ -4,3 +4,4 @@"},
+			want: "main.go:7:2: declared and not used: x (introduced by hunk @@ -4,3 +4,4 @@)",
+		},
+		{
+			name: "unattributed",
+			err:  CompileError{File: "main.go", Line: 7, Message: "undefined: x"},
+			want: "main.go:7: undefined: x",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.err.String(); got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
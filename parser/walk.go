@@ -0,0 +1,99 @@
+package parser
+
+import (
+	"maps"
+	"runtime"
+	"slices"
+	"sync"
+
+	"github.com/dave/dst"
+	"github.com/newrelic/go-easy-instrumentation/internal/util"
+	"github.com/newrelic/go-easy-instrumentation/parser/errorcache"
+	"github.com/newrelic/go-easy-instrumentation/parser/transactioncache"
+)
+
+// walkPackage returns the context of a walk through a single package: a copy of the manager whose current package
+// is the package walked. Walks of different packages can run at the same time, so each one has caches of its own,
+// which are merged back into the manager with mergeWalks once every walk is done. A walk shares the state of the
+// packages of the application with the others, but may only change the state of its own package.
+//
+// The functions declared in the packages of the application that the walked package imports are known to the
+// transaction cache of the walk from the start, so that calls to them are recognized no matter which package is
+// walked first.
+func (m *InstrumentationManager) walkPackage(pkgID string) *InstrumentationManager {
+	walk := *m
+	walk.currentPackage = pkgID
+	walk.errorCache = errorcache.ErrorCache{
+		ExistingErrors: slices.Clone(m.errorCache.ExistingErrors),
+	}
+	cache := transactioncache.NewTransactionCache()
+	maps.Copy(cache.Transactions, m.transactionCache.Transactions)
+	maps.Copy(cache.Functions, m.transactionCache.Functions)
+	cache.Objects = m.transactionCache.Objects // only changed before the packages are walked
+	walk.transactionCache = *cache
+
+	state := m.packages[pkgID]
+	imports := slices.Sorted(maps.Keys(state.pkg.Imports))
+	for _, path := range imports {
+		imported, ok := m.packages[state.pkg.Imports[path].ID]
+		if !ok {
+			continue
+		}
+		for _, file := range imported.pkg.Syntax {
+			for _, decl := range file.Decls {
+				if fn, ok := decl.(*dst.FuncDecl); ok {
+					walk.transactionCache.Functions[fn.Name.Name] = fn
+				}
+			}
+		}
+	}
+	return &walk
+}
+
+// walkPackages walks through the packages of the application other than tests, in sorted order, with as many walks
+// running at the same time as there are processors. Each walk gets a context of its own made with walkPackage, and is
+// passed the index of its package in the sorted order. The contexts are returned in the same order, so that what the
+// walks found can be merged back into the manager deterministically.
+func (m *InstrumentationManager) walkPackages(walk func(i int, walkManager *InstrumentationManager)) []*InstrumentationManager {
+	walks := []*InstrumentationManager{}
+	for _, pkgID := range m.getSortedPackages() {
+		if !util.IsTestPackage(m.packages[pkgID].pkg) {
+			walks = append(walks, m.walkPackage(pkgID))
+		}
+	}
+
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for range min(runtime.GOMAXPROCS(0), len(walks)) {
+		wg.Go(func() {
+			for i := range next {
+				walk(i, walks[i])
+			}
+		})
+	}
+	for i := range walks {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	return walks
+}
+
+// mergeWalks merges what the walks through the packages of the application found into the manager, in the order
+// they are given in. When more than one walk found the setup function of the application, or a name for the agent
+// variable, the last one wins.
+func (m *InstrumentationManager) mergeWalks(walks []*InstrumentationManager) {
+	setupFunc, agentVariableName := m.setupFunc, m.agentVariableName
+	existingErrors := len(m.errorCache.ExistingErrors)
+	for _, walk := range walks {
+		maps.Copy(m.transactionCache.Transactions, walk.transactionCache.Transactions)
+		maps.Copy(m.transactionCache.Functions, walk.transactionCache.Functions)
+		m.errorCache.ExistingErrors = append(m.errorCache.ExistingErrors, walk.errorCache.ExistingErrors[existingErrors:]...)
+		if walk.setupFunc != setupFunc {
+			m.setupFunc = walk.setupFunc
+		}
+		if walk.agentVariableName != agentVariableName {
+			m.agentVariableName = walk.agentVariableName
+		}
+	}
+}
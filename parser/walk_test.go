@@ -0,0 +1,133 @@
+package parser
+
+import (
+	"fmt"
+	"go/token"
+	"slices"
+	"testing"
+
+	"github.com/dave/dst"
+	"github.com/dave/dst/decorator"
+	"github.com/dave/dst/dstutil"
+	"github.com/newrelic/go-easy-instrumentation/parser/facts"
+	"github.com/newrelic/go-easy-instrumentation/parser/transactioncache"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/tools/go/packages"
+)
+
+// walkTestManager returns a manager for packages named pkg0 to pkgN, each declaring a function named after it,
+// and importing the package before it.
+func walkTestManager(n int) *InstrumentationManager {
+	manager := &InstrumentationManager{
+		packages:         map[string]*packageState{},
+		facts:            facts.NewKeeper(),
+		transactionCache: *transactioncache.NewTransactionCache(),
+	}
+	var previous *decorator.Package
+	for i := range n {
+		id := fmt.Sprintf("pkg%d", i)
+		pkg := &decorator.Package{
+			Package:   &packages.Package{ID: id, PkgPath: id},
+			Decorator: decorator.NewDecorator(token.NewFileSet()),
+			Imports:   map[string]*decorator.Package{},
+			Syntax: []*dst.File{
+				{
+					Decls: []dst.Decl{
+						&dst.FuncDecl{
+							Name: dst.NewIdent(fmt.Sprintf("func%d", i)),
+							Type: &dst.FuncType{},
+							Body: &dst.BlockStmt{},
+						},
+					},
+				},
+			},
+		}
+		if previous != nil {
+			pkg.Imports[previous.PkgPath] = previous
+		}
+		previous = pkg
+		manager.packages[id] = &packageState{
+			pkg:          pkg,
+			tracedFuncs:  map[string]*tracedFunctionDecl{},
+			importsAdded: map[string]bool{},
+		}
+	}
+	return manager
+}
+
+func TestWalkPackages(t *testing.T) {
+	manager := walkTestManager(20)
+	manager.currentPackage = "pkg3"
+
+	walks := manager.walkPackages(func(i int, walk *InstrumentationManager) {
+		walk.createFunctionDeclaration(walk.getDecoratorPackage().Syntax[0].Decls[0].(*dst.FuncDecl))
+	})
+
+	sorted := manager.getSortedPackages()
+	if assert.Len(t, walks, len(sorted)) {
+		for i, walk := range walks {
+			assert.Equal(t, sorted[i], walk.currentPackage)
+			assert.Len(t, manager.packages[sorted[i]].tracedFuncs, 1, "each walk records the functions of its own package")
+		}
+	}
+	assert.Equal(t, "pkg3", manager.currentPackage, "the current package of the manager is not changed")
+
+	// the functions of the packages imported by the walked package are known from the start
+	functions := walks[slices.Index(sorted, "pkg5")].transactionCache.Functions
+	assert.Contains(t, functions, "func4")
+	assert.NotContains(t, functions, "func6")
+}
+
+func TestScanPackages_Deterministic(t *testing.T) {
+	scan := func() *InstrumentationManager {
+		manager := walkTestManager(50)
+		err := scanPackages(manager, func(walk *InstrumentationManager, c *dstutil.Cursor) {
+			decl, ok := c.Node().(*dst.FuncDecl)
+			if !ok {
+				return
+			}
+			walk.transactionCache.Functions[decl.Name.Name] = decl
+			walk.transactionCache.AddFuncDecl(decl)
+			walk.errorCache.LoadExistingErrors(decl.Name)
+			walk.SetSetupFunc(decl)
+		})
+		assert.NoError(t, err)
+		return manager
+	}
+
+	manager := scan()
+	assert.Len(t, manager.transactionCache.Functions, 50)
+	assert.Len(t, manager.transactionCache.Transactions, 50)
+	// what the walks found is merged in the order of the packages
+	assert.Equal(t, []string{"func0", "func1", "func10"}, manager.errorCache.ExtractExistingErrors()[:3])
+	assert.Equal(t, "func9", manager.setupFunc.Name.Name, "the setup function found in the last package wins")
+
+	for range 5 {
+		assert.Equal(t, manager.errorCache.ExtractExistingErrors(), scan().errorCache.ExtractExistingErrors())
+	}
+}
+
+func TestTracePackageFunctionCalls_Facts(t *testing.T) {
+	manager := walkTestManager(10)
+	manager.packages["pkg0"].pkg.Syntax[0].Decls[0].(*dst.FuncDecl).Name.Name = "main"
+
+	discover := func(pkg *decorator.Package, node dst.Node) (facts.Entry, bool) {
+		decl, ok := node.(*dst.FuncDecl)
+		if !ok {
+			return facts.Entry{}, false
+		}
+		return facts.Entry{Name: decl.Name.Name, Fact: facts.GrpcServerType}, true
+	}
+	assert.NoError(t, tracePackageFunctionCalls(manager, discover))
+	assert.Len(t, manager.facts, 10)
+	assert.Equal(t, facts.GrpcServerType, manager.facts.GetFact("func5"))
+
+	// facts found in more than one package are an error, whichever package is walked first, and none is lost
+	manager = walkTestManager(10)
+	manager.packages["pkg0"].pkg.Syntax[0].Decls[0].(*dst.FuncDecl).Name.Name = "main"
+	manager.packages["pkg7"].pkg.Syntax[0].Decls[0].(*dst.FuncDecl).Name.Name = "func3"
+	manager.packages["pkg8"].pkg.Syntax[0].Decls[0].(*dst.FuncDecl).Name.Name = "func4"
+	err := tracePackageFunctionCalls(manager, discover)
+	assert.EqualError(t, err, "error adding fact entry {Name: func3, Fact: GrpcServer}: fact already exists: func3\n"+
+		"error adding fact entry {Name: func4, Fact: GrpcServer}: fact already exists: func4")
+}